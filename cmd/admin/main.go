@@ -0,0 +1,173 @@
+// Command admin runs maintenance tasks against the database:
+//
+//	admin check-integrity [-repair]
+//	admin create-tenant <name> <slug>
+//	admin move-user <user-id> <tenant-slug>
+//
+// check-integrity reports the addresses whose owning entity no longer
+// exists, and exits with status 1 if there are any. With -repair it deletes
+// them instead.
+//
+// create-tenant onboards a customer as a new tenant. Users registering
+// through the API join the default tenant; move-user then moves them, with
+// their addresses, to the tenant they belong to and logs them out. Members
+// of an organization cannot be moved. The database must have been migrated.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"go-test-api/internal/address"
+	"go-test-api/internal/config"
+	"go-test-api/internal/database"
+	"go-test-api/internal/user"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// orphanStore finds and deletes addresses whose entity no longer exists
+type orphanStore interface {
+	Orphans(ctx context.Context) ([]*address.AddressResponse, error)
+	DeleteOrphans(ctx context.Context) (int64, error)
+}
+
+// tenantStore creates tenants and moves users between them
+type tenantStore interface {
+	CreateTenant(ctx context.Context, name, slug string) (string, error)
+	MoveToTenant(ctx context.Context, id int32, slug string) error
+}
+
+// slugPattern matches tenant slugs: lowercase words joined by hyphens
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin check-integrity [-repair]")
+	fmt.Fprintln(os.Stderr, "       admin create-tenant <name> <slug>")
+	fmt.Fprintln(os.Stderr, "       admin move-user <user-id> <tenant-slug>")
+}
+
+// connect loads the configuration and connects to the database, exiting on
+// failure
+func connect(ctx context.Context) *pgxpool.Pool {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	pool, err := database.New(ctx, cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	return pool
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "check-integrity":
+		flags := flag.NewFlagSet("check-integrity", flag.ExitOnError)
+		repair := flags.Bool("repair", false, "delete orphaned addresses instead of only reporting them")
+		flags.Parse(os.Args[2:])
+
+		ctx := context.Background()
+		pool := connect(ctx)
+		repo := address.NewRepository(pool, address.NewRegistry())
+		code, err := checkIntegrity(ctx, repo, *repair, os.Stdout)
+		pool.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(code)
+	case "create-tenant", "move-user":
+		if len(os.Args) != 4 {
+			usage()
+			os.Exit(2)
+		}
+
+		ctx := context.Background()
+		pool := connect(ctx)
+		repo := user.NewRepository(pool)
+		var err error
+		if os.Args[1] == "create-tenant" {
+			err = createTenant(ctx, repo, os.Args[2], os.Args[3], os.Stdout)
+		} else {
+			err = moveUser(ctx, repo, os.Args[2], os.Args[3], os.Stdout)
+		}
+		pool.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// checkIntegrity reports orphaned addresses to out, deleting them if repair
+// is set, and returns the exit status: 1 if orphans were found and left
+func checkIntegrity(ctx context.Context, store orphanStore, repair bool, out io.Writer) (int, error) {
+	orphans, err := store.Orphans(ctx)
+	if err != nil {
+		return 1, err
+	}
+	for _, a := range orphans {
+		fmt.Fprintf(out, "Address %s belongs to %s %s, which does not exist\n", a.ID, a.EntityType, a.EntityID)
+	}
+	if len(orphans) == 0 {
+		fmt.Fprintln(out, "No orphaned addresses")
+		return 0, nil
+	}
+	if !repair {
+		fmt.Fprintf(out, "Found %d orphaned addresses; run with -repair to delete them\n", len(orphans))
+		return 1, nil
+	}
+
+	deleted, err := store.DeleteOrphans(ctx)
+	if err != nil {
+		return 1, err
+	}
+	fmt.Fprintf(out, "Deleted %d orphaned addresses\n", deleted)
+	return 0, nil
+}
+
+// createTenant creates a tenant with the name and slug
+func createTenant(ctx context.Context, store tenantStore, name, slug string, out io.Writer) error {
+	if name == "" {
+		return fmt.Errorf("tenant name is required")
+	}
+	if len(slug) > 63 || !slugPattern.MatchString(slug) {
+		return fmt.Errorf("invalid slug %q: use at most 63 lowercase letters, digits and hyphens", slug)
+	}
+	id, err := store.CreateTenant(ctx, name, slug)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Created tenant %s (%s) with ID %s\n", name, slug, id)
+	return nil
+}
+
+// moveUser moves the user with the ID to the tenant with the slug
+func moveUser(ctx context.Context, store tenantStore, userID, slug string, out io.Writer) error {
+	id, err := strconv.ParseInt(userID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid user ID %q", userID)
+	}
+	if err := store.MoveToTenant(ctx, int32(id), slug); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Moved user %d to tenant %s; they must log in again\n", id, slug)
+	return nil
+}
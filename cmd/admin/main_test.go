@@ -0,0 +1,160 @@
+//go:build unit
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-test-api/internal/address"
+	"go-test-api/internal/user"
+)
+
+// mockOrphanStore is a mock implementation of orphanStore for testing
+type mockOrphanStore struct {
+	orphans []*address.AddressResponse
+	err     error
+	deleted bool
+}
+
+func (m *mockOrphanStore) Orphans(ctx context.Context) ([]*address.AddressResponse, error) {
+	return m.orphans, m.err
+}
+
+func (m *mockOrphanStore) DeleteOrphans(ctx context.Context) (int64, error) {
+	m.deleted = true
+	return int64(len(m.orphans)), nil
+}
+
+// mockTenantStore is a mock implementation of tenantStore for testing
+type mockTenantStore struct {
+	created string
+	movedID int32
+	moveErr error
+}
+
+func (m *mockTenantStore) CreateTenant(ctx context.Context, name, slug string) (string, error) {
+	m.created = slug
+	return "2", nil
+}
+
+func (m *mockTenantStore) MoveToTenant(ctx context.Context, id int32, slug string) error {
+	if m.moveErr != nil {
+		return m.moveErr
+	}
+	m.movedID = id
+	return nil
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	orphan := &address.AddressResponse{ID: "3", EntityType: "user", EntityID: "42"}
+
+	tests := []struct {
+		name            string
+		store           *mockOrphanStore
+		repair          bool
+		expectedCode    int
+		expectedDeleted bool
+		expectedOutput  string
+	}{
+		{
+			name:           "no orphans",
+			store:          &mockOrphanStore{},
+			expectedOutput: "No orphaned addresses",
+		},
+		{
+			name:           "orphans reported",
+			store:          &mockOrphanStore{orphans: []*address.AddressResponse{orphan}},
+			expectedCode:   1,
+			expectedOutput: "Address 3 belongs to user 42, which does not exist",
+		},
+		{
+			name:            "orphans repaired",
+			store:           &mockOrphanStore{orphans: []*address.AddressResponse{orphan}},
+			repair:          true,
+			expectedDeleted: true,
+			expectedOutput:  "Deleted 1 orphaned addresses",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			code, err := checkIntegrity(context.Background(), tt.store, tt.repair, &out)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("expected exit status %d, got %d", tt.expectedCode, code)
+			}
+			if tt.store.deleted != tt.expectedDeleted {
+				t.Errorf("expected deleted %v, got %v", tt.expectedDeleted, tt.store.deleted)
+			}
+			if !strings.Contains(out.String(), tt.expectedOutput) {
+				t.Errorf("expected output to contain %q, got %q", tt.expectedOutput, out.String())
+			}
+		})
+	}
+
+	store := &mockOrphanStore{err: errors.New("database connection failed")}
+	if _, err := checkIntegrity(context.Background(), store, true, &bytes.Buffer{}); err == nil || store.deleted {
+		t.Error("expected an error and nothing deleted when orphans cannot be listed")
+	}
+}
+
+func TestCreateTenant(t *testing.T) {
+	tests := []struct {
+		name        string
+		tenantName  string
+		slug        string
+		expectError bool
+	}{
+		{name: "valid tenant", tenantName: "Acme Corp", slug: "acme-corp"},
+		{name: "missing name", slug: "acme", expectError: true},
+		{name: "uppercase slug", tenantName: "Acme", slug: "Acme", expectError: true},
+		{name: "trailing hyphen", tenantName: "Acme", slug: "acme-", expectError: true},
+		{name: "slug too long", tenantName: "Acme", slug: strings.Repeat("a", 64), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockTenantStore{}
+			var out bytes.Buffer
+			err := createTenant(context.Background(), store, tt.tenantName, tt.slug, &out)
+			if tt.expectError {
+				if err == nil || store.created != "" {
+					t.Errorf("expected an error and no tenant, got %v and %q", err, store.created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if store.created != tt.slug || !strings.Contains(out.String(), "with ID 2") {
+				t.Errorf("expected tenant %s to be created, got %q: %s", tt.slug, store.created, out.String())
+			}
+		})
+	}
+}
+
+func TestMoveUser(t *testing.T) {
+	store := &mockTenantStore{}
+	var out bytes.Buffer
+	if err := moveUser(context.Background(), store, "42", "acme", &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.movedID != 42 || !strings.Contains(out.String(), "Moved user 42 to tenant acme") {
+		t.Errorf("expected user 42 to be moved, got %d: %s", store.movedID, out.String())
+	}
+
+	if err := moveUser(context.Background(), &mockTenantStore{}, "abc", "acme", &out); err == nil {
+		t.Error("expected an error for an invalid user ID")
+	}
+	store = &mockTenantStore{moveErr: user.ErrOrganizationMember}
+	if err := moveUser(context.Background(), store, "42", "acme", &out); !errors.Is(err, user.ErrOrganizationMember) {
+		t.Errorf("expected ErrOrganizationMember, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 
@@ -27,7 +28,7 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer" followed by a space and JWT token.
+// @description Type "Bearer" followed by a space and JWT token, or "ApiKey" followed by a space and an API key.
 
 func setupLogger(cfg config.Config) {
 	var handler slog.Handler
@@ -49,7 +50,11 @@ func setupLogger(cfg config.Config) {
 }
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
 
 	setupLogger(cfg)
 
@@ -61,10 +66,34 @@ func main() {
 
 	// Create server
 	srv, err := server.New(server.Config{
-		Port:      cfg.Port,
-		Database:  cfg.Database,
-		JWTSecret: cfg.JWTSecret,
-		JWTExpiry: cfg.JWTExpiry,
+		Port:                    cfg.Port,
+		BaseURL:                 cfg.BaseURL,
+		FrontendURL:             cfg.FrontendURL,
+		Database:                cfg.Database,
+		Mail:                    cfg.Mail,
+		Password:                cfg.Password,
+		JWTSecret:               cfg.JWTSecret,
+		JWTSigningKeyFile:       cfg.JWTSigningKeyFile,
+		JWTVerificationKeyFiles: cfg.JWTVerificationKeyFiles,
+		JWTExpiry:               cfg.JWTExpiry,
+		RefreshTokenExpiry:      cfg.RefreshTokenExpiry,
+		RevocationSyncInterval:  cfg.RevocationSyncInterval,
+		SoftDeleteRetention:     cfg.SoftDeleteRetention,
+		PasswordResetExpiry:     cfg.PasswordResetExpiry,
+		EmailVerificationExpiry: cfg.EmailVerificationExpiry,
+		RequireVerifiedEmail:    cfg.RequireVerifiedEmail,
+		MagicLinkEnabled:        cfg.MagicLinkEnabled,
+		MagicLinkExpiry:         cfg.MagicLinkExpiry,
+		InvitationExpiry:        cfg.InvitationExpiry,
+		TOTPIssuer:              cfg.TOTPIssuer,
+		LoginMaxFailures:        cfg.LoginMaxFailures,
+		LoginIPMaxFailures:      cfg.LoginIPMaxFailures,
+		LoginFailureWindow:      cfg.LoginFailureWindow,
+		LoginLockoutDuration:    cfg.LoginLockoutDuration,
+		ClientIPHeader:          cfg.ClientIPHeader,
+		OIDCProviders:           cfg.OIDCProviders,
+		OIDCStateSecret:         cfg.OIDCStateSecret,
+		CursorSecret:            cfg.CursorSecret,
 	})
 	if err != nil {
 		slog.Error("Failed to create server", "error", err)
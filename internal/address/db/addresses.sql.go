@@ -18,8 +18,8 @@ INSERT INTO addresses (
     created_at, updated_at
 )
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 `
 
 type CreateAddressParams struct {
@@ -64,25 +64,47 @@ func (q *Queries) CreateAddress(ctx context.Context, arg CreateAddressParams) (A
 		&i.Country,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
 	)
 	return i, err
 }
 
 const deleteAddress = `-- name: DeleteAddress :exec
-DELETE FROM addresses
-WHERE id = $1
+UPDATE addresses
+SET deleted_at = $2
+WHERE id = $1 AND deleted_at IS NULL
 `
 
-func (q *Queries) DeleteAddress(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteAddress, id)
+type DeleteAddressParams struct {
+	ID        int32              `json:"id"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
+}
+
+// Addresses are soft-deleted: they can be restored until purged
+func (q *Queries) DeleteAddress(ctx context.Context, arg DeleteAddressParams) error {
+	_, err := q.db.Exec(ctx, deleteAddress, arg.ID, arg.DeletedAt)
 	return err
 }
 
+const deleteOrphanedAddresses = `-- name: DeleteOrphanedAddresses :execrows
+DELETE FROM addresses
+WHERE NOT address_entity_exists(entity_type, entity_id)
+`
+
+func (q *Queries) DeleteOrphanedAddresses(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrphanedAddresses)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getAddress = `-- name: GetAddress :one
-SELECT id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 FROM addresses
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetAddress(ctx context.Context, id int32) (Address, error) {
@@ -101,25 +123,138 @@ func (q *Queries) GetAddress(ctx context.Context, id int32) (Address, error) {
 		&i.Country,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
 	)
 	return i, err
 }
 
-const listAddressesByEntity = `-- name: ListAddressesByEntity :many
-SELECT id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+const getDeletedAddress = `-- name: GetDeletedAddress :one
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedAddress(ctx context.Context, id int32) (Address, error) {
+	row := q.db.QueryRow(ctx, getDeletedAddress, id)
+	var i Address
+	err := row.Scan(
+		&i.ID,
+		&i.EntityType,
+		&i.EntityID,
+		&i.AddressType,
+		&i.StreetLine1,
+		&i.StreetLine2,
+		&i.City,
+		&i.State,
+		&i.PostalCode,
+		&i.Country,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const listAddressesByCreatedAt = `-- name: ListAddressesByCreatedAt :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE entity_type = $1 AND entity_id = $2
+  AND ($3::address_type IS NULL OR address_type = $3)
+  AND ($4::boolean OR deleted_at IS NULL)
+  AND ($5::timestamptz IS NULL OR (created_at, id) > ($5::timestamptz, $6::int))
+ORDER BY created_at, id
+LIMIT $7
+`
+
+type ListAddressesByCreatedAtParams struct {
+	EntityType     EntityType         `json:"entity_type"`
+	EntityID       int32              `json:"entity_id"`
+	AddressType    NullAddressType    `json:"address_type"`
+	IncludeDeleted bool               `json:"include_deleted"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        int32              `json:"after_id"`
+	RowLimit       int32              `json:"row_limit"`
+}
+
+func (q *Queries) ListAddressesByCreatedAt(ctx context.Context, arg ListAddressesByCreatedAtParams) ([]Address, error) {
+	rows, err := q.db.Query(ctx, listAddressesByCreatedAt,
+		arg.EntityType,
+		arg.EntityID,
+		arg.AddressType,
+		arg.IncludeDeleted,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Address{}
+	for rows.Next() {
+		var i Address
+		if err := rows.Scan(
+			&i.ID,
+			&i.EntityType,
+			&i.EntityID,
+			&i.AddressType,
+			&i.StreetLine1,
+			&i.StreetLine2,
+			&i.City,
+			&i.State,
+			&i.PostalCode,
+			&i.Country,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAddressesByCreatedAtDesc = `-- name: ListAddressesByCreatedAtDesc :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 FROM addresses
 WHERE entity_type = $1 AND entity_id = $2
-ORDER BY address_type, id
+  AND ($3::address_type IS NULL OR address_type = $3)
+  AND ($4::boolean OR deleted_at IS NULL)
+  AND ($5::timestamptz IS NULL OR (created_at, id) < ($5::timestamptz, $6::int))
+ORDER BY created_at DESC, id DESC
+LIMIT $7
 `
 
-type ListAddressesByEntityParams struct {
-	EntityType EntityType `json:"entity_type"`
-	EntityID   int32      `json:"entity_id"`
+type ListAddressesByCreatedAtDescParams struct {
+	EntityType     EntityType         `json:"entity_type"`
+	EntityID       int32              `json:"entity_id"`
+	AddressType    NullAddressType    `json:"address_type"`
+	IncludeDeleted bool               `json:"include_deleted"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        int32              `json:"after_id"`
+	RowLimit       int32              `json:"row_limit"`
 }
 
-func (q *Queries) ListAddressesByEntity(ctx context.Context, arg ListAddressesByEntityParams) ([]Address, error) {
-	rows, err := q.db.Query(ctx, listAddressesByEntity, arg.EntityType, arg.EntityID)
+func (q *Queries) ListAddressesByCreatedAtDesc(ctx context.Context, arg ListAddressesByCreatedAtDescParams) ([]Address, error) {
+	rows, err := q.db.Query(ctx, listAddressesByCreatedAtDesc,
+		arg.EntityType,
+		arg.EntityID,
+		arg.AddressType,
+		arg.IncludeDeleted,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +275,8 @@ func (q *Queries) ListAddressesByEntity(ctx context.Context, arg ListAddressesBy
 			&i.Country,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.TenantID,
 		); err != nil {
 			return nil, err
 		}
@@ -151,22 +288,144 @@ func (q *Queries) ListAddressesByEntity(ctx context.Context, arg ListAddressesBy
 	return items, nil
 }
 
-const listAddressesByEntityAndType = `-- name: ListAddressesByEntityAndType :many
-SELECT id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+const listAddressesByID = `-- name: ListAddressesByID :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 FROM addresses
-WHERE entity_type = $1 AND entity_id = $2 AND address_type = $3
+WHERE entity_type = $1 AND entity_id = $2
+  AND ($3::address_type IS NULL OR address_type = $3)
+  AND ($4::boolean OR deleted_at IS NULL)
+  AND ($5::int IS NULL OR id > $5)
 ORDER BY id
+LIMIT $6
+`
+
+type ListAddressesByIDParams struct {
+	EntityType     EntityType      `json:"entity_type"`
+	EntityID       int32           `json:"entity_id"`
+	AddressType    NullAddressType `json:"address_type"`
+	IncludeDeleted bool            `json:"include_deleted"`
+	AfterID        pgtype.Int4     `json:"after_id"`
+	RowLimit       int32           `json:"row_limit"`
+}
+
+func (q *Queries) ListAddressesByID(ctx context.Context, arg ListAddressesByIDParams) ([]Address, error) {
+	rows, err := q.db.Query(ctx, listAddressesByID,
+		arg.EntityType,
+		arg.EntityID,
+		arg.AddressType,
+		arg.IncludeDeleted,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Address{}
+	for rows.Next() {
+		var i Address
+		if err := rows.Scan(
+			&i.ID,
+			&i.EntityType,
+			&i.EntityID,
+			&i.AddressType,
+			&i.StreetLine1,
+			&i.StreetLine2,
+			&i.City,
+			&i.State,
+			&i.PostalCode,
+			&i.Country,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAddressesByIDDesc = `-- name: ListAddressesByIDDesc :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE entity_type = $1 AND entity_id = $2
+  AND ($3::address_type IS NULL OR address_type = $3)
+  AND ($4::boolean OR deleted_at IS NULL)
+  AND ($5::int IS NULL OR id < $5)
+ORDER BY id DESC
+LIMIT $6
 `
 
-type ListAddressesByEntityAndTypeParams struct {
-	EntityType  EntityType  `json:"entity_type"`
-	EntityID    int32       `json:"entity_id"`
-	AddressType AddressType `json:"address_type"`
+type ListAddressesByIDDescParams struct {
+	EntityType     EntityType      `json:"entity_type"`
+	EntityID       int32           `json:"entity_id"`
+	AddressType    NullAddressType `json:"address_type"`
+	IncludeDeleted bool            `json:"include_deleted"`
+	AfterID        pgtype.Int4     `json:"after_id"`
+	RowLimit       int32           `json:"row_limit"`
+}
+
+func (q *Queries) ListAddressesByIDDesc(ctx context.Context, arg ListAddressesByIDDescParams) ([]Address, error) {
+	rows, err := q.db.Query(ctx, listAddressesByIDDesc,
+		arg.EntityType,
+		arg.EntityID,
+		arg.AddressType,
+		arg.IncludeDeleted,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Address{}
+	for rows.Next() {
+		var i Address
+		if err := rows.Scan(
+			&i.ID,
+			&i.EntityType,
+			&i.EntityID,
+			&i.AddressType,
+			&i.StreetLine1,
+			&i.StreetLine2,
+			&i.City,
+			&i.State,
+			&i.PostalCode,
+			&i.Country,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) ListAddressesByEntityAndType(ctx context.Context, arg ListAddressesByEntityAndTypeParams) ([]Address, error) {
-	rows, err := q.db.Query(ctx, listAddressesByEntityAndType, arg.EntityType, arg.EntityID, arg.AddressType)
+const listOrphanedAddresses = `-- name: ListOrphanedAddresses :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE NOT address_entity_exists(entity_type, entity_id)
+ORDER BY id
+`
+
+// Addresses whose entity no longer exists, left behind before the integrity
+// triggers were added
+func (q *Queries) ListOrphanedAddresses(ctx context.Context) ([]Address, error) {
+	rows, err := q.db.Query(ctx, listOrphanedAddresses)
 	if err != nil {
 		return nil, err
 	}
@@ -187,6 +446,8 @@ func (q *Queries) ListAddressesByEntityAndType(ctx context.Context, arg ListAddr
 			&i.Country,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.TenantID,
 		); err != nil {
 			return nil, err
 		}
@@ -198,6 +459,55 @@ func (q *Queries) ListAddressesByEntityAndType(ctx context.Context, arg ListAddr
 	return items, nil
 }
 
+const purgeDeletedAddresses = `-- name: PurgeDeletedAddresses :execrows
+DELETE FROM addresses
+WHERE deleted_at <= $1
+`
+
+func (q *Queries) PurgeDeletedAddresses(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedAddresses, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreAddress = `-- name: RestoreAddress :one
+UPDATE addresses
+SET deleted_at = NULL,
+    updated_at = $2
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+`
+
+type RestoreAddressParams struct {
+	ID        int32              `json:"id"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+func (q *Queries) RestoreAddress(ctx context.Context, arg RestoreAddressParams) (Address, error) {
+	row := q.db.QueryRow(ctx, restoreAddress, arg.ID, arg.UpdatedAt)
+	var i Address
+	err := row.Scan(
+		&i.ID,
+		&i.EntityType,
+		&i.EntityID,
+		&i.AddressType,
+		&i.StreetLine1,
+		&i.StreetLine2,
+		&i.City,
+		&i.State,
+		&i.PostalCode,
+		&i.Country,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
 const updateAddress = `-- name: UpdateAddress :one
 UPDATE addresses
 SET street_line1 = $2,
@@ -207,9 +517,9 @@ SET street_line1 = $2,
     postal_code = $6,
     country = $7,
     updated_at = $8
-WHERE id = $1
-RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 `
 
 type UpdateAddressParams struct {
@@ -248,6 +558,8 @@ func (q *Queries) UpdateAddress(ctx context.Context, arg UpdateAddressParams) (A
 		&i.Country,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
 	)
 	return i, err
 }
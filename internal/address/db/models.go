@@ -56,7 +56,8 @@ func (ns NullAddressType) Value() (driver.Value, error) {
 type EntityType string
 
 const (
-	EntityTypeUser EntityType = "user"
+	EntityTypeUser         EntityType = "user"
+	EntityTypeOrganization EntityType = "organization"
 )
 
 func (e *EntityType) Scan(src interface{}) error {
@@ -94,6 +95,49 @@ func (ns NullEntityType) Value() (driver.Value, error) {
 	return string(ns.EntityType), nil
 }
 
+type OrganizationRole string
+
+const (
+	OrganizationRoleOwner  OrganizationRole = "owner"
+	OrganizationRoleAdmin  OrganizationRole = "admin"
+	OrganizationRoleMember OrganizationRole = "member"
+)
+
+func (e *OrganizationRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = OrganizationRole(s)
+	case string:
+		*e = OrganizationRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for OrganizationRole: %T", src)
+	}
+	return nil
+}
+
+type NullOrganizationRole struct {
+	OrganizationRole OrganizationRole `json:"organization_role"`
+	Valid            bool             `json:"valid"` // Valid is true if OrganizationRole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullOrganizationRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.OrganizationRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.OrganizationRole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullOrganizationRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.OrganizationRole), nil
+}
+
 type Address struct {
 	ID          int32              `json:"id"`
 	EntityType  EntityType         `json:"entity_type"`
@@ -107,13 +151,216 @@ type Address struct {
 	Country     string             `json:"country"`
 	CreatedAt   pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt   pgtype.Timestamptz `json:"deleted_at"`
+	TenantID    int32              `json:"tenant_id"`
+}
+
+type ApiKey struct {
+	ID         int32              `json:"id"`
+	UserID     int32              `json:"user_id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	SecretHash string             `json:"secret_hash"`
+	Scopes     []string           `json:"scopes"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID        int64              `json:"id"`
+	EventType string             `json:"event_type"`
+	UserID    pgtype.Int4        `json:"user_id"`
+	ActorID   pgtype.Int4        `json:"actor_id"`
+	Email     pgtype.Text        `json:"email"`
+	IpAddress pgtype.Text        `json:"ip_address"`
+	Details   []byte             `json:"details"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type EmailChangeToken struct {
+	ID        int64              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	NewEmail  string             `json:"new_email"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type EmailVerificationToken struct {
+	ID        int64              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type FailedLoginAttempt struct {
+	ID        int64              `json:"id"`
+	Email     string             `json:"email"`
+	IpAddress string             `json:"ip_address"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type LoginLockout struct {
+	ID              int32              `json:"id"`
+	Email           string             `json:"email"`
+	LockedUntil     pgtype.Timestamptz `json:"locked_until"`
+	UnlockTokenHash string             `json:"unlock_token_hash"`
+	UnlockedAt      pgtype.Timestamptz `json:"unlocked_at"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
+
+type MagicLinkToken struct {
+	ID        int64              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type MfaRecoveryCode struct {
+	ID        int32              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Organization struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	OwnerID   int32              `json:"owner_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	TenantID  int32              `json:"tenant_id"`
+}
+
+type OrganizationInvitation struct {
+	ID             int64              `json:"id"`
+	OrganizationID int32              `json:"organization_id"`
+	Email          string             `json:"email"`
+	Role           OrganizationRole   `json:"role"`
+	InvitedBy      pgtype.Int4        `json:"invited_by"`
+	TokenHash      string             `json:"token_hash"`
+	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
+	AcceptedAt     pgtype.Timestamptz `json:"accepted_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int32              `json:"organization_id"`
+	UserID         int32              `json:"user_id"`
+	Role           OrganizationRole   `json:"role"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	ID        int64              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Permission struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RefreshToken struct {
+	ID        int64              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	FamilyID  pgtype.UUID        `json:"family_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type RevokedToken struct {
+	Jti       string             `json:"jti"`
+	UserID    int32              `json:"user_id"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type Role struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RolePermission struct {
+	RoleID       int32 `json:"role_id"`
+	PermissionID int32 `json:"permission_id"`
+}
+
+type Session struct {
+	ID         pgtype.UUID        `json:"id"`
+	UserID     int32              `json:"user_id"`
+	Method     string             `json:"method"`
+	Mfa        bool               `json:"mfa"`
+	UserAgent  string             `json:"user_agent"`
+	IpAddress  string             `json:"ip_address"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	LastSeenAt pgtype.Timestamptz `json:"last_seen_at"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type Tenant struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	Slug      string             `json:"slug"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
 type User struct {
-	ID           int32              `json:"id"`
-	Name         string             `json:"name"`
-	Email        string             `json:"email"`
+	ID              int32              `json:"id"`
+	Name            string             `json:"name"`
+	Email           string             `json:"email"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	PasswordHash    string             `json:"password_hash"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+	DeletedAt       pgtype.Timestamptz `json:"deleted_at"`
+	TenantID        int32              `json:"tenant_id"`
+}
+
+type UserIdentity struct {
+	ID            int32              `json:"id"`
+	UserID        int32              `json:"user_id"`
+	Provider      string             `json:"provider"`
+	Subject       string             `json:"subject"`
+	Email         string             `json:"email"`
+	LastLoginAt   pgtype.Timestamptz `json:"last_login_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	EmailVerified bool               `json:"email_verified"`
+}
+
+type UserRole struct {
+	UserID    int32              `json:"user_id"`
+	RoleID    int32              `json:"role_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type UserTokenRevocation struct {
+	UserID        int32              `json:"user_id"`
+	RevokedBefore pgtype.Timestamptz `json:"revoked_before"`
+	ExpiresAt     pgtype.Timestamptz `json:"expires_at"`
+}
+
+type UserTotp struct {
+	UserID       int32              `json:"user_id"`
+	Secret       string             `json:"secret"`
+	ConfirmedAt  pgtype.Timestamptz `json:"confirmed_at"`
+	LastUsedStep int64              `json:"last_used_step"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
-	PasswordHash string             `json:"password_hash"`
 }
@@ -5,28 +5,64 @@ INSERT INTO addresses (
     created_at, updated_at
 )
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at;
+RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id;
 
 -- name: GetAddress :one
-SELECT id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 FROM addresses
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
--- name: ListAddressesByEntity :many
-SELECT id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+-- name: GetDeletedAddress :one
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 FROM addresses
-WHERE entity_type = $1 AND entity_id = $2
-ORDER BY address_type, id;
+WHERE id = $1 AND deleted_at IS NOT NULL;
 
--- name: ListAddressesByEntityAndType :many
-SELECT id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at
+-- name: ListAddressesByCreatedAt :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
 FROM addresses
-WHERE entity_type = $1 AND entity_id = $2 AND address_type = $3
-ORDER BY id;
+WHERE entity_type = sqlc.arg('entity_type') AND entity_id = sqlc.arg('entity_id')
+  AND (sqlc.narg('address_type')::address_type IS NULL OR address_type = sqlc.narg('address_type'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL OR (created_at, id) > (sqlc.narg('after_created_at')::timestamptz, sqlc.arg('after_id')::int))
+ORDER BY created_at, id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListAddressesByCreatedAtDesc :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE entity_type = sqlc.arg('entity_type') AND entity_id = sqlc.arg('entity_id')
+  AND (sqlc.narg('address_type')::address_type IS NULL OR address_type = sqlc.narg('address_type'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL OR (created_at, id) < (sqlc.narg('after_created_at')::timestamptz, sqlc.arg('after_id')::int))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListAddressesByID :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE entity_type = sqlc.arg('entity_type') AND entity_id = sqlc.arg('entity_id')
+  AND (sqlc.narg('address_type')::address_type IS NULL OR address_type = sqlc.narg('address_type'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_id')::int IS NULL OR id > sqlc.narg('after_id'))
+ORDER BY id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListAddressesByIDDesc :many
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE entity_type = sqlc.arg('entity_type') AND entity_id = sqlc.arg('entity_id')
+  AND (sqlc.narg('address_type')::address_type IS NULL OR address_type = sqlc.narg('address_type'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_id')::int IS NULL OR id < sqlc.narg('after_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('row_limit');
 
 -- name: UpdateAddress :one
 UPDATE addresses
@@ -37,10 +73,37 @@ SET street_line1 = $2,
     postal_code = $6,
     country = $7,
     updated_at = $8
-WHERE id = $1
-RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2, 
-    city, state, postal_code, country, created_at, updated_at;
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id;
 
 -- name: DeleteAddress :exec
+-- Addresses are soft-deleted: they can be restored until purged
+UPDATE addresses
+SET deleted_at = $2
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreAddress :one
+UPDATE addresses
+SET deleted_at = NULL,
+    updated_at = $2
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id;
+
+-- name: PurgeDeletedAddresses :execrows
+DELETE FROM addresses
+WHERE deleted_at <= $1;
+
+-- name: ListOrphanedAddresses :many
+-- Addresses whose entity no longer exists, left behind before the integrity
+-- triggers were added
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE NOT address_entity_exists(entity_type, entity_id)
+ORDER BY id;
+
+-- name: DeleteOrphanedAddresses :execrows
 DELETE FROM addresses
-WHERE id = $1;
+WHERE NOT address_entity_exists(entity_type, entity_id);
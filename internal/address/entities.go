@@ -0,0 +1,54 @@
+package address
+
+import (
+	"context"
+
+	"go-test-api/internal/authz"
+)
+
+// Entity is a kind of entity addresses can belong to
+type Entity struct {
+	// Exists reports whether the entity with the ID exists and can be given
+	// addresses
+	Exists func(ctx context.Context, id int32) (bool, error)
+	// Rule decides who may access the entity's addresses
+	Rule authz.Rule
+}
+
+// Registry holds the entity types addresses can belong to. Every value of
+// the entity_type enum needs an entry, and the database's
+// address_entity_exists function a matching branch.
+type Registry struct {
+	entities map[string]Entity
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{entities: make(map[string]Entity)}
+}
+
+// Register adds an entity type
+func (r *Registry) Register(entityType string, e Entity) {
+	r.entities[entityType] = e
+}
+
+// Exists reports whether the entity of entityType with the ID exists.
+// Entities of unregistered types never do.
+func (r *Registry) Exists(ctx context.Context, entityType string, id int32) (bool, error) {
+	e, ok := r.entities[entityType]
+	if !ok {
+		return false, nil
+	}
+	return e.Exists(ctx, id)
+}
+
+// Policy creates a Policy authorizing access to addresses with the rule of
+// the entity type that owns them. Users holding adminPermission are allowed
+// everything. Entity types registered afterwards are not covered.
+func (r *Registry) Policy(adminPermission string) *authz.Policy {
+	policy := authz.NewPolicy(adminPermission)
+	for entityType, e := range r.entities {
+		policy.Register(entityType, e.Rule)
+	}
+	return policy
+}
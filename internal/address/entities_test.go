@@ -0,0 +1,52 @@
+//go:build unit
+
+package address
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/authz"
+)
+
+func TestRegistry(t *testing.T) {
+	entities := NewRegistry()
+	entities.Register("user", Entity{
+		Exists: func(ctx context.Context, id int32) (bool, error) { return id == 7, nil },
+		Rule:   authz.Self,
+	})
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		entityType string
+		id         int32
+		expected   bool
+	}{
+		{"user", 7, true},
+		{"user", 8, false},
+		{"organization", 7, false},
+	} {
+		exists, err := entities.Exists(ctx, tt.entityType, tt.id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if exists != tt.expected {
+			t.Errorf("expected %s %d to exist: %v, got %v", tt.entityType, tt.id, tt.expected, exists)
+		}
+	}
+
+	// The policy applies each entity type's rule, and denies unknown types
+	policy := entities.Policy("addresses:admin")
+	ctx = authctx.WithUser(ctx, "7", "alice@example.com")
+	if err := policy.Authorize(ctx, authz.ActionWrite, "user", "7"); err != nil {
+		t.Errorf("expected a user to access their own addresses, got %v", err)
+	}
+	if err := policy.Authorize(ctx, authz.ActionRead, "user", "8"); !errors.Is(err, authz.ErrDenied) {
+		t.Errorf("expected ErrDenied for another user's addresses, got %v", err)
+	}
+	if err := policy.Authorize(ctx, authz.ActionRead, "organization", "7"); !errors.Is(err, authz.ErrDenied) {
+		t.Errorf("expected ErrDenied for an unregistered entity type, got %v", err)
+	}
+}
@@ -2,10 +2,13 @@ package address
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"go-test-api/internal/authz"
+	"go-test-api/internal/pagination"
 	"go-test-api/internal/validator"
 	"go-test-api/pkg/response"
 )
@@ -14,16 +17,20 @@ import (
 type Handler struct {
 	validator *validator.Validator
 	repo      Repo
+	policy    *authz.Policy
+	pages     *pagination.Paginator
 }
 
-// NewHandler creates a new address Handler
-func NewHandler(v *validator.Validator, repo Repo) *Handler {
-	return &Handler{validator: v, repo: repo}
+// NewHandler creates a new address Handler. Every request is authorized
+// against policy using the entity that owns the addresses involved. Lists
+// are paginated with pages.
+func NewHandler(v *validator.Validator, repo Repo, policy *authz.Policy, pages *pagination.Paginator) *Handler {
+	return &Handler{validator: v, repo: repo, policy: policy, pages: pages}
 }
 
 // Create handles POST /addresses
 // @Summary Create a new address
-// @Description Create a new address for an entity (user, etc.)
+// @Description Create a new address for the caller, or for an organization the caller is an admin or the owner of
 // @Tags addresses
 // @Accept json
 // @Produce json
@@ -31,6 +38,8 @@ func NewHandler(v *validator.Validator, repo Repo) *Handler {
 // @Success 201 {object} AddressResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /addresses [post]
@@ -46,8 +55,16 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.authorize(w, r, authz.ActionWrite, req.EntityType, strconv.Itoa(int(req.EntityID)), "Entity not found") {
+		return
+	}
+
 	addr, err := h.repo.Create(r.Context(), &req)
 	if err != nil {
+		if errors.Is(err, ErrEntityNotFound) {
+			response.Error(w, http.StatusNotFound, "Entity not found")
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create address: %v", err))
 		return
 	}
@@ -57,14 +74,16 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 
 // Get handles GET /addresses/{id}
 // @Summary Get an address by ID
-// @Description Retrieve a specific address by its ID
+// @Description Retrieve a specific address by its ID. The addresses of an organization are visible to all its members. Addresses the caller may not access are reported as not found.
 // @Tags addresses
 // @Produce json
 // @Param id path int true "Address ID"
 // @Success 200 {object} AddressResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /addresses/{id} [get]
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
@@ -75,9 +94,8 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	addr, err := h.repo.Get(r.Context(), int32(id))
-	if err != nil {
-		response.Error(w, http.StatusNotFound, fmt.Sprintf("Address not found: %v", err))
+	addr, ok := h.getAuthorized(w, r, int32(id), authz.ActionRead)
+	if !ok {
 		return
 	}
 
@@ -86,15 +104,21 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 
 // List handles GET /addresses?entity_type=user&entity_id=1&address_type=shipping
 // @Summary List addresses for an entity
-// @Description Get all addresses for a specific entity, optionally filtered by address type
+// @Description Get a page of the addresses of the caller, or of an organization the caller is a member of, optionally filtered by address type. Deleted addresses are left out unless include_deleted is set, which requires the addresses:admin permission. Pass the next_cursor of a page as cursor, with the same sort, to get the next one; it is omitted on the last page.
 // @Tags addresses
 // @Produce json
-// @Param entity_type query string true "Entity type (e.g., user)"
+// @Param entity_type query string true "Entity type (user or organization)"
 // @Param entity_id query string true "Entity ID"
 // @Param address_type query string false "Address type (shipping, billing)"
-// @Success 200 {array} AddressResponse
+// @Param include_deleted query bool false "Include deleted addresses"
+// @Param limit query int false "Page size, 1 to 100" default(20)
+// @Param sort query string false "Sort field: id or created_at, prefixed with - for descending order" default(id)
+// @Param cursor query string false "Cursor of the page to get"
+// @Success 200 {object} pagination.Page{data=[]AddressResponse}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /addresses [get]
@@ -108,26 +132,45 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var addrs []*AddressResponse
-	var err error
+	page, err := h.pages.Parse(r.URL.Query(), addressSorts...)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	includeDeleted := false
+	if v := r.URL.Query().Get("include_deleted"); v != "" {
+		includeDeleted, err = strconv.ParseBool(v)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid include_deleted")
+			return
+		}
+	}
+	if includeDeleted && !h.policy.IsAdmin(r.Context()) {
+		response.Error(w, http.StatusForbidden, "Only administrators can list deleted addresses")
+		return
+	}
 
-	if addressType != "" {
-		addrs, err = h.repo.ListByEntityAndType(r.Context(), entityType, entityID, addressType)
-	} else {
-		addrs, err = h.repo.ListByEntity(r.Context(), entityType, entityID)
+	if !h.authorize(w, r, authz.ActionRead, entityType, entityID, "Entity not found") {
+		return
 	}
 
+	addrs, next, err := h.repo.List(r.Context(), entityType, entityID, addressType, includeDeleted, page)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list addresses: %v", err))
 		return
 	}
 
-	response.JSON(w, http.StatusOK, addrs)
+	response.JSON(w, http.StatusOK, pagination.Page{Data: addrs, NextCursor: h.pages.Encode(next)})
 }
 
 // Update handles PUT /addresses/{id}
 // @Summary Update an address
-// @Description Update an existing address by ID
+// @Description Update an existing address by ID. Only the admins and owner of an organization may change its addresses. Addresses the caller may not access are reported as not found.
 // @Tags addresses
 // @Accept json
 // @Produce json
@@ -136,6 +179,8 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} AddressResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /addresses/{id} [put]
@@ -158,6 +203,10 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := h.getAuthorized(w, r, int32(id), authz.ActionWrite); !ok {
+		return
+	}
+
 	addr, err := h.repo.Update(r.Context(), int32(id), &req)
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update address: %v", err))
@@ -169,12 +218,14 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 
 // Delete handles DELETE /addresses/{id}
 // @Summary Delete an address
-// @Description Delete an existing address by ID
+// @Description Delete an existing address by ID. It can be restored until it is purged once the retention window has passed. Only the admins and owner of an organization may change its addresses. Addresses the caller may not access are reported as not found.
 // @Tags addresses
 // @Param id path int true "Address ID"
 // @Success 204 "No Content"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /addresses/{id} [delete]
@@ -186,6 +237,10 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := h.getAuthorized(w, r, int32(id), authz.ActionWrite); !ok {
+		return
+	}
+
 	if err := h.repo.Delete(r.Context(), int32(id)); err != nil {
 		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete address: %v", err))
 		return
@@ -193,3 +248,86 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore handles POST /addresses/{id}/restore
+// @Summary Restore an address
+// @Description Restore a deleted address by ID, until it is purged once the retention window has passed. Only the admins and owner of an organization may change its addresses. Addresses the caller may not access are reported as not found.
+// @Tags addresses
+// @Produce json
+// @Param id path int true "Address ID"
+// @Success 200 {object} AddressResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /addresses/{id}/restore [post]
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid address ID")
+		return
+	}
+
+	deleted, err := h.repo.GetDeleted(r.Context(), int32(id))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "Deleted address not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get address: %v", err))
+		return
+	}
+	if !h.authorize(w, r, authz.ActionWrite, deleted.EntityType, deleted.EntityID, "Deleted address not found") {
+		return
+	}
+
+	addr, err := h.repo.Restore(r.Context(), int32(id))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "Deleted address not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to restore address: %v", err))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, addr)
+}
+
+// getAuthorized loads an address and checks that the caller may perform the
+// action on it. Addresses the caller may not access are reported as not
+// found, so that their existence is not revealed.
+func (h *Handler) getAuthorized(w http.ResponseWriter, r *http.Request, id int32, action authz.Action) (*AddressResponse, bool) {
+	addr, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "Address not found")
+			return nil, false
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get address: %v", err))
+		return nil, false
+	}
+
+	if !h.authorize(w, r, action, addr.EntityType, addr.EntityID, "Address not found") {
+		return nil, false
+	}
+	return addr, true
+}
+
+// authorize checks that the caller may perform the action on addresses owned
+// by the entity, writing a 404 response with notFoundMessage if not
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, action authz.Action, entityType, entityID, notFoundMessage string) bool {
+	err := h.policy.Authorize(r.Context(), action, entityType, entityID)
+	if err != nil {
+		if errors.Is(err, authz.ErrDenied) {
+			response.Error(w, http.StatusNotFound, notFoundMessage)
+			return false
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to authorize request: %v", err))
+		return false
+	}
+	return true
+}
@@ -1,8 +1,10 @@
 package address
 
+import "time"
+
 // CreateAddressRequest represents the request to create an address
 type CreateAddressRequest struct {
-	EntityType  string `json:"entity_type" validate:"required,oneof=user"`
+	EntityType  string `json:"entity_type" validate:"required,oneof=user organization"`
 	EntityID    int32  `json:"entity_id" validate:"required,min=1"`
 	AddressType string `json:"address_type" validate:"required,oneof=shipping billing"`
 	StreetLine1 string `json:"street_line1" validate:"required,max=255"`
@@ -23,16 +25,18 @@ type UpdateAddressRequest struct {
 	Country     string `json:"country" validate:"required,max=100"`
 }
 
-// AddressResponse represents an address in API responses
+// AddressResponse represents an address in API responses. DeletedAt is only
+// set on deleted addresses.
 type AddressResponse struct {
-	ID          string `json:"id"`
-	EntityType  string `json:"entity_type"`
-	EntityID    string `json:"entity_id"`
-	AddressType string `json:"address_type"`
-	StreetLine1 string `json:"street_line1"`
-	StreetLine2 string `json:"street_line2,omitempty"`
-	City        string `json:"city"`
-	State       string `json:"state"`
-	PostalCode  string `json:"postal_code"`
-	Country     string `json:"country"`
+	ID          string     `json:"id"`
+	EntityType  string     `json:"entity_type"`
+	EntityID    string     `json:"entity_id"`
+	AddressType string     `json:"address_type"`
+	StreetLine1 string     `json:"street_line1"`
+	StreetLine2 string     `json:"street_line2,omitempty"`
+	City        string     `json:"city"`
+	State       string     `json:"state"`
+	PostalCode  string     `json:"postal_code"`
+	Country     string     `json:"country"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
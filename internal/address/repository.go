@@ -2,135 +2,245 @@ package address
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
 	"go-test-api/internal/address/db"
-	userdb "go-test-api/internal/user/db"
+	"go-test-api/internal/database"
+	"go-test-api/internal/pagination"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// foreignKeyViolation is the Postgres error code raised when an address
+// would belong to an entity that does not exist
+const foreignKeyViolation = "23503"
+
+var (
+	ErrNotFound       = errors.New("address not found")
+	ErrEntityNotFound = errors.New("entity not found")
 )
 
 // Repo defines the interface for address data access
 type Repo interface {
 	Create(ctx context.Context, req *CreateAddressRequest) (*AddressResponse, error)
 	Get(ctx context.Context, id int32) (*AddressResponse, error)
-	ListByEntity(ctx context.Context, entityType, entityID string) ([]*AddressResponse, error)
-	ListByEntityAndType(ctx context.Context, entityType, entityID, addressType string) ([]*AddressResponse, error)
+	GetDeleted(ctx context.Context, id int32) (*AddressResponse, error)
+	List(ctx context.Context, entityType, entityID, addressType string, includeDeleted bool, page pagination.Request) ([]*AddressResponse, *pagination.Cursor, error)
 	Update(ctx context.Context, id int32, req *UpdateAddressRequest) (*AddressResponse, error)
 	Delete(ctx context.Context, id int32) error
+	Restore(ctx context.Context, id int32) (*AddressResponse, error)
 }
 
-// Repository handles address data access
+// Repository handles address data access. Addresses belong to the tenant of
+// their entity and are only visible to requests of that tenant: methods
+// acting on behalf of the caller run in a transaction scoped to the tenant of
+// the context, and fail with database.ErrNoTenant if it has none.
 type Repository struct {
-	queries     *db.Queries
-	userQueries *userdb.Queries
+	pool     *pgxpool.Pool
+	queries  *db.Queries
+	entities *Registry
 }
 
-// NewRepository creates a new Repository
-func NewRepository(queries *db.Queries, userQueries *userdb.Queries) *Repository {
+// NewRepository creates a new Repository. Addresses can be created for the
+// entities in entities.
+func NewRepository(pool *pgxpool.Pool, entities *Registry) *Repository {
 	return &Repository{
-		queries:     queries,
-		userQueries: userQueries,
+		pool:     pool,
+		queries:  db.New(pool),
+		entities: entities,
 	}
 }
 
-// Create creates a new address
+// inTenant runs fn with queries scoped to the tenant of ctx
+func (r *Repository) inTenant(ctx context.Context, fn func(q *db.Queries) error) error {
+	return database.InTenant(ctx, r.pool, func(tx pgx.Tx) error {
+		return fn(r.queries.WithTx(tx))
+	})
+}
+
+// Create creates a new address. It returns ErrEntityNotFound if the entity
+// it would belong to does not exist, is deleted or belongs to another tenant.
 func (r *Repository) Create(ctx context.Context, req *CreateAddressRequest) (*AddressResponse, error) {
-	// Validate that the entity exists
-	if req.EntityType == "user" {
-		_, err := r.userQueries.GetUser(ctx, req.EntityID)
-		if err != nil {
-			return nil, fmt.Errorf("user with id %d does not exist: %w", req.EntityID, err)
-		}
+	// The database only checks that the entity exists; the registry also
+	// turns away entities that cannot be given new addresses, such as
+	// deleted users
+	exists, err := r.entities.Exists(ctx, req.EntityType, req.EntityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %d: %w", req.EntityType, req.EntityID, err)
+	}
+	if !exists {
+		return nil, ErrEntityNotFound
 	}
 
+	// The address is given the tenant of its entity by the database
 	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
-	addr, err := r.queries.CreateAddress(ctx, db.CreateAddressParams{
-		EntityType:  db.EntityType(req.EntityType),
-		EntityID:    req.EntityID,
-		AddressType: db.AddressType(req.AddressType),
-		StreetLine1: req.StreetLine1,
-		StreetLine2: pgtype.Text{String: req.StreetLine2, Valid: req.StreetLine2 != ""},
-		City:        req.City,
-		State:       req.State,
-		PostalCode:  req.PostalCode,
-		Country:     req.Country,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+	var addr db.Address
+	err = r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		addr, err = q.CreateAddress(ctx, db.CreateAddressParams{
+			EntityType:  db.EntityType(req.EntityType),
+			EntityID:    req.EntityID,
+			AddressType: db.AddressType(req.AddressType),
+			StreetLine1: req.StreetLine1,
+			StreetLine2: pgtype.Text{String: req.StreetLine2, Valid: req.StreetLine2 != ""},
+			City:        req.City,
+			State:       req.State,
+			PostalCode:  req.PostalCode,
+			Country:     req.Country,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		return err
 	})
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return nil, ErrEntityNotFound
+		}
 		return nil, fmt.Errorf("failed to create address: %w", err)
 	}
 	return toAddressResponse(addr), nil
 }
 
-// Get retrieves an address by ID
+// Get retrieves an address by ID. It returns ErrNotFound if there is none.
 func (r *Repository) Get(ctx context.Context, id int32) (*AddressResponse, error) {
-	addr, err := r.queries.GetAddress(ctx, id)
+	var addr db.Address
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		addr, err = q.GetAddress(ctx, id)
+		return err
+	})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("failed to get address: %w", err)
 	}
 	return toAddressResponse(addr), nil
 }
 
-// ListByEntity retrieves all addresses for an entity
-func (r *Repository) ListByEntity(ctx context.Context, entityType, entityID string) ([]*AddressResponse, error) {
-	entityIdInt, err := stringToInt32(entityID)
-	if err != nil {
-		return nil, err
-	}
-
-	addrs, err := r.queries.ListAddressesByEntity(ctx, db.ListAddressesByEntityParams{
-		EntityType: db.EntityType(entityType),
-		EntityID:   int32(entityIdInt),
+// GetDeleted retrieves a deleted address by ID. It returns ErrNotFound if
+// there is none.
+func (r *Repository) GetDeleted(ctx context.Context, id int32) (*AddressResponse, error) {
+	var addr db.Address
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		addr, err = q.GetDeletedAddress(ctx, id)
+		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list addresses: %w", err)
-	}
-
-	res := make([]*AddressResponse, len(addrs))
-	for i, a := range addrs {
-		res[i] = toAddressResponse(a)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get address: %w", err)
 	}
-	return res, nil
+	return toAddressResponse(addr), nil
 }
 
-// ListByEntityAndType retrieves addresses for an entity filtered by type
-func (r *Repository) ListByEntityAndType(ctx context.Context, entityType, entityID, addressType string) ([]*AddressResponse, error) {
+// addressSorts are the fields addresses can be listed by; the first is the
+// default
+var addressSorts = []string{"id", "created_at"}
+
+// List retrieves a page of the addresses of an entity, optionally only those
+// of addressType, along with the cursor of the next page, if any. Deleted
+// addresses are left out unless includeDeleted is set. It returns an error
+// wrapping pagination.ErrInvalidCursor if the page's cursor cannot be read.
+func (r *Repository) List(ctx context.Context, entityType, entityID, addressType string, includeDeleted bool, page pagination.Request) ([]*AddressResponse, *pagination.Cursor, error) {
 	entityIdInt, err := stringToInt32(entityID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	kind := db.NullAddressType{AddressType: db.AddressType(addressType), Valid: addressType != ""}
+	var after pagination.Cursor
+	if page.After != nil {
+		after = *page.After
+	}
+
+	var afterCreatedAt pgtype.Timestamptz
+	if page.Sort.Field == "created_at" && page.After != nil {
+		t, err := page.After.Time()
+		if err != nil {
+			return nil, nil, err
+		}
+		afterCreatedAt = pgtype.Timestamptz{Time: t, Valid: true}
 	}
-	addrs, err := r.queries.ListAddressesByEntityAndType(ctx, db.ListAddressesByEntityAndTypeParams{
-		EntityType:  db.EntityType(entityType),
-		EntityID:    int32(entityIdInt),
-		AddressType: db.AddressType(addressType),
+
+	var addrs []db.Address
+	err = r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		switch page.Sort.Field {
+		case "created_at":
+			params := db.ListAddressesByCreatedAtParams{
+				EntityType:     db.EntityType(entityType),
+				EntityID:       entityIdInt,
+				AddressType:    kind,
+				IncludeDeleted: includeDeleted,
+				AfterCreatedAt: afterCreatedAt,
+				AfterID:        after.ID,
+				RowLimit:       page.FetchLimit(),
+			}
+			if page.Sort.Desc {
+				addrs, err = q.ListAddressesByCreatedAtDesc(ctx, db.ListAddressesByCreatedAtDescParams(params))
+			} else {
+				addrs, err = q.ListAddressesByCreatedAt(ctx, params)
+			}
+		default:
+			params := db.ListAddressesByIDParams{
+				EntityType:     db.EntityType(entityType),
+				EntityID:       entityIdInt,
+				AddressType:    kind,
+				IncludeDeleted: includeDeleted,
+				AfterID:        pgtype.Int4{Int32: after.ID, Valid: page.After != nil},
+				RowLimit:       page.FetchLimit(),
+			}
+			if page.Sort.Desc {
+				addrs, err = q.ListAddressesByIDDesc(ctx, db.ListAddressesByIDDescParams(params))
+			} else {
+				addrs, err = q.ListAddressesByID(ctx, params)
+			}
+		}
+		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list addresses: %w", err)
+		return nil, nil, fmt.Errorf("failed to list addresses: %w", err)
 	}
 
+	addrs, next := pagination.Next(page, addrs, func(a db.Address) (string, int32) {
+		if page.Sort.Field == "created_at" {
+			return pagination.TimeValue(a.CreatedAt.Time), a.ID
+		}
+		return "", a.ID
+	})
 	res := make([]*AddressResponse, len(addrs))
 	for i, a := range addrs {
 		res[i] = toAddressResponse(a)
 	}
-	return res, nil
+	return res, next, nil
 }
 
 // Update updates an existing address
 func (r *Repository) Update(ctx context.Context, id int32, req *UpdateAddressRequest) (*AddressResponse, error) {
 	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
-	addr, err := r.queries.UpdateAddress(ctx, db.UpdateAddressParams{
-		ID:          id,
-		StreetLine1: req.StreetLine1,
-		StreetLine2: pgtype.Text{String: req.StreetLine2, Valid: req.StreetLine2 != ""},
-		City:        req.City,
-		State:       req.State,
-		PostalCode:  req.PostalCode,
-		Country:     req.Country,
-		UpdatedAt:   now,
+	var addr db.Address
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		addr, err = q.UpdateAddress(ctx, db.UpdateAddressParams{
+			ID:          id,
+			StreetLine1: req.StreetLine1,
+			StreetLine2: pgtype.Text{String: req.StreetLine2, Valid: req.StreetLine2 != ""},
+			City:        req.City,
+			State:       req.State,
+			PostalCode:  req.PostalCode,
+			Country:     req.Country,
+			UpdatedAt:   now,
+		})
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to update address: %w", err)
@@ -138,16 +248,77 @@ func (r *Repository) Update(ctx context.Context, id int32, req *UpdateAddressReq
 	return toAddressResponse(addr), nil
 }
 
-// Delete deletes an address
+// Delete soft-deletes an address; it can be restored until purged
 func (r *Repository) Delete(ctx context.Context, id int32) error {
-	if err := r.queries.DeleteAddress(ctx, id); err != nil {
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		return q.DeleteAddress(ctx, db.DeleteAddressParams{
+			ID:        id,
+			DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+	})
+	if err != nil {
 		return fmt.Errorf("failed to delete address: %w", err)
 	}
 	return nil
 }
 
+// Restore undeletes an address. It returns ErrNotFound if there is no
+// deleted address with the ID.
+func (r *Repository) Restore(ctx context.Context, id int32) (*AddressResponse, error) {
+	var addr db.Address
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		addr, err = q.RestoreAddress(ctx, db.RestoreAddressParams{
+			ID:        id,
+			UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to restore address: %w", err)
+	}
+	return toAddressResponse(addr), nil
+}
+
+// PurgeDeleted hard-deletes addresses deleted before the cutoff, returning
+// how many there were. It covers every tenant.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	purged, err := r.queries.PurgeDeletedAddresses(ctx, pgtype.Timestamptz{Time: before, Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted addresses: %w", err)
+	}
+	return purged, nil
+}
+
+// Orphans returns the addresses whose entity no longer exists, in every
+// tenant
+func (r *Repository) Orphans(ctx context.Context) ([]*AddressResponse, error) {
+	addrs, err := r.queries.ListOrphanedAddresses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphaned addresses: %w", err)
+	}
+	res := make([]*AddressResponse, len(addrs))
+	for i, a := range addrs {
+		res[i] = toAddressResponse(a)
+	}
+	return res, nil
+}
+
+// DeleteOrphans hard-deletes the addresses whose entity no longer exists, in
+// every tenant, returning how many there were
+func (r *Repository) DeleteOrphans(ctx context.Context) (int64, error) {
+	deleted, err := r.queries.DeleteOrphanedAddresses(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphaned addresses: %w", err)
+	}
+	return deleted, nil
+}
+
 func toAddressResponse(addr db.Address) *AddressResponse {
-	return &AddressResponse{
+	res := &AddressResponse{
 		ID:          fmt.Sprintf("%d", addr.ID),
 		EntityType:  string(addr.EntityType),
 		EntityID:    fmt.Sprintf("%d", addr.EntityID),
@@ -159,6 +330,11 @@ func toAddressResponse(addr db.Address) *AddressResponse {
 		PostalCode:  addr.PostalCode,
 		Country:     addr.Country,
 	}
+	if addr.DeletedAt.Valid {
+		deletedAt := addr.DeletedAt.Time
+		res.DeletedAt = &deletedAt
+	}
+	return res
 }
 
 func stringToInt32(s string) (int32, error) {
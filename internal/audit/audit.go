@@ -0,0 +1,79 @@
+// Package audit records security relevant events, such as account lockouts,
+// in the audit_events table for later review.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-test-api/internal/audit/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Event types
+const (
+	EventAccountLocked   = "account_locked"
+	EventAccountUnlocked = "account_unlocked"
+
+	EventImpersonationStarted = "impersonation_started"
+
+	EventUserErased = "user_erased"
+)
+
+// Event is a single audit entry. Zero values mean the attribute does not
+// apply: UserID is unset when no known user is concerned, ActorID when the
+// event was not caused by one user acting on another.
+type Event struct {
+	Type    string
+	UserID  int32
+	ActorID int32
+	Email   string
+	IP      string
+	Details map[string]interface{}
+}
+
+// Log writes audit events
+type Log struct {
+	queries *db.Queries
+}
+
+// NewLog creates a new Log
+func NewLog(queries *db.Queries) *Log {
+	return &Log{queries: queries}
+}
+
+// WithTx returns a Log that records events in tx, so that they are only
+// kept if it commits
+func (l *Log) WithTx(tx pgx.Tx) *Log {
+	return &Log{queries: l.queries.WithTx(tx)}
+}
+
+// Record stores an event
+func (l *Log) Record(ctx context.Context, e Event) error {
+	details := e.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	b, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	err = l.queries.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		EventType: e.Type,
+		UserID:    pgtype.Int4{Int32: e.UserID, Valid: e.UserID != 0},
+		ActorID:   pgtype.Int4{Int32: e.ActorID, Valid: e.ActorID != 0},
+		Email:     pgtype.Text{String: e.Email, Valid: e.Email != ""},
+		IpAddress: pgtype.Text{String: e.IP, Valid: e.IP != ""},
+		Details:   b,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
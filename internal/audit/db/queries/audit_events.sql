@@ -0,0 +1,3 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (event_type, user_id, actor_id, email, ip_address, details, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
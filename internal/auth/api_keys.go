@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/auth/db"
+	"go-test-api/pkg/response"
+)
+
+// CreateAPIKey handles POST /auth/api-keys
+// @Summary Create an API key
+// @Description Create a personal API key for machine clients, which authenticate with the header "Authorization: ApiKey <key>". The key can only use the listed scopes, which must be permissions held by the caller. The full key is only returned by this call.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param request body CreateAPIKeyRequest true "API key details"
+// @Success 201 {object} CreateAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/api-keys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, claims, ok := h.interactiveUser(w, r, "API keys cannot manage API keys")
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !claims.HasPermission(scope) {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Scope %q is not a permission you hold", scope))
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		response.Error(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	key, prefix, secretHash, err := h.authService.GenerateAPIKey()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	scopes := slices.Compact(slices.Sorted(slices.Values(req.Scopes)))
+	stored, err := h.repo.CreateAPIKey(r.Context(), userID, req.Name, prefix, secretHash, scopes, req.ExpiresAt)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, CreateAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(stored),
+		Key:            key,
+	})
+}
+
+// ListAPIKeys handles GET /auth/api-keys
+// @Summary List API keys
+// @Description List the API keys of the current user
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} APIKeyResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/api-keys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.interactiveUser(w, r, "API keys cannot manage API keys")
+	if !ok {
+		return
+	}
+
+	keys, err := h.repo.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	res := make([]APIKeyResponse, len(keys))
+	for i := range keys {
+		res[i] = toAPIKeyResponse(&keys[i])
+	}
+	response.JSON(w, http.StatusOK, res)
+}
+
+// GetAPIKey handles GET /auth/api-keys/{id}
+// @Summary Get an API key
+// @Description Get one of the current user's API keys
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/api-keys/{id} [get]
+func (h *Handler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.interactiveUser(w, r, "API keys cannot manage API keys")
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	key, err := h.repo.GetAPIKey(r.Context(), int32(id), userID)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			response.Error(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get API key")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, toAPIKeyResponse(key))
+}
+
+// UpdateAPIKey handles PATCH /auth/api-keys/{id}
+// @Summary Rename an API key
+// @Description Change the name of one of the current user's API keys. Scopes and expiry cannot be changed; create a new key instead.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API key ID"
+// @Param request body UpdateAPIKeyRequest true "New name"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/api-keys/{id} [patch]
+func (h *Handler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.interactiveUser(w, r, "API keys cannot manage API keys")
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	var req UpdateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, err := h.repo.RenameAPIKey(r.Context(), int32(id), userID, req.Name)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			response.Error(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to update API key")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, toAPIKeyResponse(key))
+}
+
+// DeleteAPIKey handles DELETE /auth/api-keys/{id}
+// @Summary Delete an API key
+// @Description Delete one of the current user's API keys. Requests using it are rejected immediately.
+// @Tags api-keys
+// @Param id path int true "API key ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/api-keys/{id} [delete]
+func (h *Handler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.interactiveUser(w, r, "API keys cannot manage API keys")
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.repo.DeleteAPIKey(r.Context(), int32(id), userID); err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			response.Error(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to delete API key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toAPIKeyResponse(key *db.ApiKey) APIKeyResponse {
+	res := APIKeyResponse{
+		ID:        strconv.Itoa(int(key.ID)),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt.Time,
+	}
+	if key.ExpiresAt.Valid {
+		res.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		res.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return res
+}
@@ -0,0 +1,40 @@
+//go:build unit
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-test-api/internal/opaque"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	service := NewService(ServiceConfig{}, nil, nil)
+
+	key, prefix, secretHash, err := service.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("failed to generate api key: %v", err)
+	}
+
+	if !strings.HasPrefix(key, apiKeyTag+"_"+prefix+"_") {
+		t.Errorf("expected key to start with %q, got %q", apiKeyTag+"_"+prefix+"_", key)
+	}
+	secret := strings.TrimPrefix(key, apiKeyTag+"_"+prefix+"_")
+	if opaque.Hash(secret) != secretHash {
+		t.Error("expected secret hash to be the hash of the key's secret")
+	}
+}
+
+func TestValidateAPIKey_Malformed(t *testing.T) {
+	// Malformed keys are rejected before the repository is consulted
+	service := NewService(ServiceConfig{}, nil, nil)
+
+	for _, key := range []string{"", "gta", "gta_abc", "xyz_abc_def", "Bearer gta_abc_def"} {
+		if _, err := service.ValidateAPIKey(context.Background(), key); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("expected ErrInvalidAPIKey for %q, got %v", key, err)
+		}
+	}
+}
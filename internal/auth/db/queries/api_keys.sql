@@ -0,0 +1,36 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at;
+
+-- name: GetAPIKeyForAuth :one
+SELECT k.id, k.user_id, k.secret_hash, k.scopes, k.expires_at, k.last_used_at, u.email, u.email_verified_at
+FROM api_keys k
+JOIN users u ON u.id = k.user_id
+WHERE k.prefix = $1 AND u.deleted_at IS NULL;
+
+-- name: GetUserAPIKey :one
+SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListUserAPIKeys :many
+SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: RenameAPIKey :one
+UPDATE api_keys
+SET name = $3
+WHERE id = $1 AND user_id = $2
+RETURNING id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at;
+
+-- name: DeleteUserAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $2
+WHERE id = $1;
@@ -0,0 +1,16 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (user_id, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = $2
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+RETURNING user_id;
+
+-- name: GetEmailVerificationSendStats :one
+SELECT COUNT(*) AS sent,
+       MIN(created_at)::timestamptz AS first_sent_at,
+       MAX(created_at)::timestamptz AS last_sent_at
+FROM email_verification_tokens
+WHERE user_id = $1 AND created_at > $2;
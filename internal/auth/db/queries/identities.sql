@@ -0,0 +1,16 @@
+-- name: CreateUserIdentity :exec
+-- Identities are only linked by an email their provider verified
+INSERT INTO user_identities (user_id, provider, subject, email, email_verified, last_login_at, created_at)
+VALUES ($1, $2, $3, $4, TRUE, $5, $6);
+
+-- name: GetUserIdentity :one
+SELECT id, user_id, provider, subject, email, last_login_at, created_at, email_verified
+FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: UpdateUserIdentityLogin :exec
+UPDATE user_identities
+SET email = $3,
+    email_verified = $4,
+    last_login_at = $5
+WHERE provider = $1 AND subject = $2;
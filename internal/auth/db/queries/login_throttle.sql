@@ -0,0 +1,57 @@
+-- name: LockLoginThrottle :exec
+-- Serializes changes to the throttling state under key until the end of the
+-- transaction
+SELECT pg_advisory_xact_lock(hashtext(@key::text));
+
+-- name: RecordFailedLogin :one
+INSERT INTO failed_login_attempts (email, ip_address, created_at)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- name: DeleteFailedLogin :exec
+DELETE FROM failed_login_attempts
+WHERE id = $1;
+
+-- name: GetEmailLoginFailures :one
+SELECT count(*) AS failures, max(created_at)::timestamptz AS last_failed_at
+FROM failed_login_attempts
+WHERE email = $1 AND created_at > $2;
+
+-- name: GetIPLoginFailures :one
+SELECT count(*) AS failures, min(created_at)::timestamptz AS first_failed_at
+FROM failed_login_attempts
+WHERE ip_address = $1 AND created_at > $2;
+
+-- name: ClearFailedLogins :exec
+DELETE FROM failed_login_attempts
+WHERE email = $1;
+
+-- name: DeleteFailedLoginsBefore :execrows
+DELETE FROM failed_login_attempts
+WHERE created_at < $1;
+
+-- name: CreateLoginLockout :exec
+INSERT INTO login_lockouts (email, locked_until, unlock_token_hash, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetLatestLoginLockout :one
+SELECT id, email, locked_until, unlock_token_hash, unlocked_at, created_at
+FROM login_lockouts
+WHERE email = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: UnlockLoginLockoutByToken :one
+UPDATE login_lockouts
+SET unlocked_at = $2
+WHERE unlock_token_hash = $1 AND unlocked_at IS NULL AND locked_until > $2
+RETURNING email;
+
+-- name: UnlockLoginLockouts :execrows
+UPDATE login_lockouts
+SET unlocked_at = $2
+WHERE email = $1 AND unlocked_at IS NULL AND locked_until > $2;
+
+-- name: DeleteEndedLoginLockouts :execrows
+DELETE FROM login_lockouts
+WHERE locked_until < $1;
@@ -0,0 +1,23 @@
+-- name: CreateMagicLinkToken :exec
+INSERT INTO magic_link_tokens (user_id, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeMagicLinkToken :one
+UPDATE magic_link_tokens
+SET used_at = $2
+FROM users
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+  AND users.id = magic_link_tokens.user_id AND users.deleted_at IS NULL
+RETURNING magic_link_tokens.user_id;
+
+-- name: InvalidateMagicLinkTokens :exec
+UPDATE magic_link_tokens
+SET used_at = $2
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: GetMagicLinkSendStats :one
+SELECT COUNT(*) AS sent,
+       MIN(created_at)::timestamptz AS first_sent_at,
+       MAX(created_at)::timestamptz AS last_sent_at
+FROM magic_link_tokens
+WHERE user_id = $1 AND created_at > $2;
@@ -0,0 +1,40 @@
+-- name: StartTOTPEnrollment :execrows
+INSERT INTO user_totp (user_id, secret, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at
+WHERE user_totp.confirmed_at IS NULL;
+
+-- name: GetUserTOTP :one
+SELECT user_id, secret, confirmed_at, last_used_step, created_at
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: ConfirmTOTP :execrows
+UPDATE user_totp
+SET confirmed_at = $2, last_used_step = $3
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+WITH deleted_codes AS (
+    DELETE FROM mfa_recovery_codes WHERE user_id = $1
+)
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: ReplaceRecoveryCodes :exec
+WITH deleted_codes AS (
+    DELETE FROM mfa_recovery_codes WHERE user_id = @user_id::integer
+)
+INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at)
+SELECT @user_id::integer, unnest(@code_hashes::text[]), @created_at::timestamptz;
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = $3
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
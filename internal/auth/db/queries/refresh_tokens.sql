@@ -0,0 +1,24 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at;
+
+-- name: GetRefreshTokenByHash :one
+SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: MarkRefreshTokenUsed :execrows
+UPDATE refresh_tokens
+SET used_at = $2
+WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = $2
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL;
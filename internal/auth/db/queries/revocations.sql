@@ -0,0 +1,30 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeUserTokens :exec
+INSERT INTO user_token_revocations (user_id, revoked_before, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    revoked_before = EXCLUDED.revoked_before,
+    expires_at = EXCLUDED.expires_at;
+
+-- name: ListActiveRevokedTokens :many
+SELECT jti, expires_at
+FROM revoked_tokens
+WHERE expires_at > $1;
+
+-- name: ListActiveUserTokenRevocations :many
+SELECT user_id, revoked_before, expires_at
+FROM user_token_revocations
+WHERE expires_at > $1;
+
+-- name: DeleteExpiredRevokedTokens :execrows
+DELETE FROM revoked_tokens
+WHERE expires_at <= $1;
+
+-- name: DeleteExpiredUserTokenRevocations :execrows
+DELETE FROM user_token_revocations
+WHERE expires_at <= $1;
@@ -0,0 +1,19 @@
+-- name: ListUserRoles :many
+SELECT r.name
+FROM roles r
+JOIN user_roles ur ON ur.role_id = r.id
+WHERE ur.user_id = $1
+ORDER BY r.name;
+
+-- name: ListUserPermissions :many
+SELECT DISTINCT p.name
+FROM permissions p
+JOIN role_permissions rp ON rp.permission_id = p.id
+JOIN user_roles ur ON ur.role_id = rp.role_id
+WHERE ur.user_id = $1
+ORDER BY p.name;
+
+-- name: GetUserTenant :one
+SELECT tenant_id
+FROM users
+WHERE id = $1;
@@ -0,0 +1,44 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (id, user_id, method, mfa, user_agent, ip_address, created_at, last_seen_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8);
+
+-- name: ExtendSession :exec
+UPDATE sessions
+SET expires_at = $2, last_seen_at = $3
+WHERE id = $1;
+
+-- name: ListUserSessions :many
+SELECT id, user_id, method, mfa, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_seen_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = $3
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > $3;
+
+-- name: RevokeSessionByID :exec
+UPDATE sessions
+SET revoked_at = $2
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: ListRevokedSessions :many
+SELECT id, revoked_at
+FROM sessions
+WHERE revoked_at > $1;
+
+-- name: TouchSessions :exec
+UPDATE sessions AS s
+SET last_seen_at = t.last_seen_at
+FROM UNNEST(@ids::uuid[], @last_seen::timestamptz[]) AS t(id, last_seen_at)
+WHERE s.id = t.id AND s.last_seen_at < t.last_seen_at;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at <= $1;
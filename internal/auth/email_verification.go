@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/opaque"
+	userdb "go-test-api/internal/user/db"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Verification emails are throttled per user: at most one per
+// verificationResendInterval, and at most verificationResendLimit within
+// verificationResendWindow
+const (
+	verificationResendInterval = time.Minute
+	verificationResendWindow   = time.Hour
+	verificationResendLimit    = 5
+)
+
+// VerifyEmail handles GET /auth/verify
+// @Summary Verify email address
+// @Description Confirm the email address of an account using the token from a verification email. Tokens issued after this call carry email_verified=true.
+// @Tags auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify [get]
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Missing verification token")
+		return
+	}
+
+	userID, err := h.repo.ConsumeEmailVerificationToken(r.Context(), opaque.Hash(token))
+	if err != nil {
+		if errors.Is(err, ErrInvalidVerifyToken) {
+			response.Error(w, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to verify email address")
+		return
+	}
+
+	// Verifying twice is harmless, so the number of rows updated is ignored
+	_, err = h.userQueries.MarkUserEmailVerified(r.Context(), userdb.MarkUserEmailVerifiedParams{
+		ID:              userID,
+		EmailVerifiedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to verify email address")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, MessageResponse{Message: "Email address verified"})
+}
+
+// ResendVerification handles POST /auth/verify/resend
+// @Summary Resend verification email
+// @Description Email a new verification link to the current user. Resends are throttled; when throttled, Retry-After tells how many seconds to wait.
+// @Tags auth
+// @Produce json
+// @Success 202 {object} MessageResponse
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/verify/resend [post]
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(GetUserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	// The token may predate verification, so check the stored state
+	dbUser, err := h.userQueries.GetUser(r.Context(), int32(userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to resend verification email")
+		return
+	}
+	if dbUser.EmailVerifiedAt.Valid {
+		response.Error(w, http.StatusConflict, "Email address already verified")
+		return
+	}
+
+	retryAfter, err := h.verificationRetryAfter(r.Context(), dbUser.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to resend verification email")
+		return
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		response.Error(w, http.StatusTooManyRequests, "Too many verification emails, try again later")
+		return
+	}
+
+	if err := h.sendVerification(r.Context(), dbUser.ID, dbUser.Email); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to resend verification email")
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, MessageResponse{Message: "Verification email sent"})
+}
+
+// sendVerification issues a verification token to the user and emails it
+func (h *Handler) sendVerification(ctx context.Context, userID int32, email string) error {
+	token, err := opaque.New()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(h.authService.verifyExpiry)
+	if err := h.repo.CreateEmailVerificationToken(ctx, userID, opaque.Hash(token), expiresAt); err != nil {
+		return err
+	}
+	return h.notifier.SendVerification(ctx, email, token, h.authService.verifyExpiry)
+}
+
+// verificationRetryAfter returns how long the user must wait before another
+// verification email may be sent, or zero if one may be sent now
+func (h *Handler) verificationRetryAfter(ctx context.Context, userID int32) (time.Duration, error) {
+	now := time.Now()
+	stats, err := h.repo.EmailVerificationsSince(ctx, userID, now.Add(-verificationResendWindow))
+	if err != nil {
+		return 0, err
+	}
+	if stats.Sent == 0 {
+		return 0, nil
+	}
+
+	var retryAfter time.Duration
+	if stats.Sent >= verificationResendLimit {
+		retryAfter = stats.FirstSentAt.Time.Add(verificationResendWindow).Sub(now)
+	}
+	if wait := stats.LastSentAt.Time.Add(verificationResendInterval).Sub(now); wait > retryAfter {
+		retryAfter = wait
+	}
+	return retryAfter, nil
+}
@@ -1,18 +1,26 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/password"
 	"go-test-api/internal/user"
 	userdb "go-test-api/internal/user/db"
 	"go-test-api/internal/validator"
 	"go-test-api/pkg/response"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 // Handler handles authentication HTTP requests
@@ -21,27 +29,40 @@ type Handler struct {
 	authService *Service
 	userRepo    user.Repo
 	userQueries *userdb.Queries
+	repo        *Repository
+	notifier    *Notifier
+	throttle    *LoginThrottle
+	sso         *SSO
 }
 
-// NewHandler creates a new auth Handler
-func NewHandler(v *validator.Validator, authService *Service, userRepo user.Repo, userQueries *userdb.Queries) *Handler {
+// NewHandler creates a new auth Handler. sso may be nil if no identity
+// providers are configured.
+func NewHandler(v *validator.Validator, authService *Service, userRepo user.Repo, userQueries *userdb.Queries, repo *Repository, notifier *Notifier, throttle *LoginThrottle, sso *SSO) *Handler {
+	if sso == nil {
+		sso = NewSSO(nil, nil, false)
+	}
 	return &Handler{
 		validator:   v,
 		authService: authService,
 		userRepo:    userRepo,
 		userQueries: userQueries,
+		repo:        repo,
+		notifier:    notifier,
+		throttle:    throttle,
+		sso:         sso,
 	}
 }
 
 // Register handles POST /auth/register
 // @Summary Register a new user
-// @Description Create a new user account with email and password
+// @Description Create a new user account with email and password, in the default tenant. A verification link is emailed to the address.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param user body RegisterRequest true "Registration details"
 // @Success 201 {object} TokenResponse
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/register [post]
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
@@ -57,9 +78,19 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, err := h.defaultTenant(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := h.authService.HashPassword(req.Password)
 	if err != nil {
+		if errors.Is(err, password.ErrTooLong) {
+			response.Error(w, http.StatusBadRequest, "Password is too long")
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to process password")
 		return
 	}
@@ -69,27 +100,36 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		Name:  req.Name,
 		Email: req.Email,
 	}
-	dbUser, err := h.userRepo.Upsert(r.Context(), userReq, hashedPassword)
+	dbUser, err := h.userRepo.Create(ctx, userReq, hashedPassword)
 	if err != nil {
+		if errors.Is(err, user.ErrEmailExists) {
+			response.Error(w, http.StatusConflict, "Email already registered")
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create user: %v", err))
 		return
 	}
 
-	// Generate token
-	token, err := h.authService.GenerateToken(dbUser.ID, dbUser.Email)
+	// The account exists at this point, so a delivery failure is logged
+	// and the user can ask for the email again
+	userID, err := strconv.ParseInt(dbUser.ID, 10, 32)
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
+	if err := h.sendVerification(r.Context(), int32(userID), dbUser.Email); err != nil {
+		slog.Error("Failed to send verification email", "user_id", dbUser.ID, "error", err)
+	}
 
-	resp := TokenResponse{
-		Token:     token,
-		ExpiresAt: time.Now().Add(h.authService.jwtExpiry).Unix(),
-		User: User{
-			ID:    dbUser.ID,
-			Name:  dbUser.Name,
-			Email: dbUser.Email,
-		},
+	// Generate tokens
+	resp, err := h.startSession(r, User{
+		ID:    dbUser.ID,
+		Name:  dbUser.Name,
+		Email: dbUser.Email,
+	}, int32(userID), sessionMethodRegistration, false)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to generate token")
+		return
 	}
 
 	response.JSON(w, http.StatusCreated, resp)
@@ -97,14 +137,16 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 
 // Login handles POST /auth/login
 // @Summary Login user
-// @Description Authenticate user with email and password, returns JWT token
+// @Description Authenticate user with email and password, returns a JWT access token and a refresh token. Users with two-factor authentication enabled get an MFA challenge token instead, to be completed at /auth/login/mfa. Failed logins are throttled per email and per client IP, and repeated failures lock the account temporarily; when refused, Retry-After tells how many seconds to wait.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param credentials body LoginRequest true "Login credentials"
 // @Success 200 {object} TokenResponse
+// @Success 202 {object} MFAChallengeResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/login [post]
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -120,10 +162,19 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts while the email is locked or failing too fast
+	ip := h.throttle.ClientIP(r)
+	attempt, ok := h.beginLoginAttempt(w, r, req.Email, ip)
+	if !ok {
+		return
+	}
+	defer h.abandonLoginAttempt(r.Context(), attempt)
+
 	// Get user by email
 	dbUser, err := h.userQueries.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
+			h.recordLoginFailure(r.Context(), attempt, 0)
 			response.Error(w, http.StatusUnauthorized, "Invalid email or password")
 			return
 		}
@@ -133,26 +184,321 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Verify password
 	if err := h.authService.CheckPassword(dbUser.PasswordHash, req.Password); err != nil {
+		h.recordLoginFailure(r.Context(), attempt, dbUser.ID)
 		response.Error(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
+	if h.authService.PasswordNeedsRehash(dbUser.PasswordHash) {
+		h.rehashPassword(r.Context(), dbUser.ID, dbUser.PasswordHash, req.Password)
+	}
+
+	// Failures are only forgotten once a second factor is supplied too
+	if h.completeLogin(w, r, dbUser.ID, User{
+		ID:            fmt.Sprint(dbUser.ID),
+		Name:          dbUser.Name,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}, sessionMethodPassword) {
+		h.recordLoginSuccess(r.Context(), req.Email)
+	}
+}
 
-	// Generate token
-	token, err := h.authService.GenerateToken(fmt.Sprint(dbUser.ID), dbUser.Email)
+// completeLogin answers a login whose first factor, supplied with method,
+// has been verified: with tokens for a new session, or with an MFA challenge
+// if the user has two-factor authentication enabled. It returns true if
+// tokens were issued.
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, userID int32, u User, method string) bool {
+	// With two-factor authentication enabled the first factor only earns a
+	// challenge, which is exchanged for tokens at /auth/login/mfa
+	mfaEnabled, err := h.repo.TOTPEnabled(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return false
+	}
+	if mfaEnabled {
+		token, expiresAt, err := h.authService.GenerateMFAToken(fmt.Sprint(userID), method)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "Failed to generate token")
+			return false
+		}
+		response.JSON(w, http.StatusAccepted, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token,
+			ExpiresAt:   expiresAt.Unix(),
+		})
+		return false
+	}
+
+	resp, err := h.startSession(r, u, userID, method, false)
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to generate token")
+		return false
+	}
+	response.JSON(w, http.StatusOK, resp)
+	return true
+}
+
+// Refresh handles POST /auth/refresh
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can only be used once; reusing one revokes every token descended from the same login.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	resp := TokenResponse{
-		Token:     token,
-		ExpiresAt: time.Now().Add(h.authService.jwtExpiry).Unix(),
-		User: User{
-			ID:    fmt.Sprint(dbUser.ID),
-			Name:  dbUser.Name,
-			Email: dbUser.Email,
-		},
+	// Rotate the presented token
+	token, err := h.repo.ConsumeRefreshToken(r.Context(), opaque.Hash(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			slog.Warn("Refresh token reuse detected, token family revoked")
+		}
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			response.Error(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	// Reload the user so the new access token reflects current data
+	dbUser, err := h.userQueries.GetUser(r.Context(), token.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.Error(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	resp, err := h.issueTokens(r.Context(), User{
+		ID:            fmt.Sprint(dbUser.ID),
+		Name:          dbUser.Name,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}, token.FamilyID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	// The refresh token family is the session
+	if err := h.repo.ExtendSession(r.Context(), token.FamilyID, time.Unix(resp.RefreshTokenExpiresAt, 0)); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
 	}
 
 	response.JSON(w, http.StatusOK, resp)
 }
+
+// defaultTenant returns a copy of ctx scoped to the default tenant, which
+// every self-registered account joins. Callers never choose their tenant:
+// anyone could otherwise join a tenant by knowing its slug. Operators move
+// accounts to their tenant with admin move-user.
+func (h *Handler) defaultTenant(ctx context.Context) (context.Context, error) {
+	tenantID, err := h.userRepo.TenantID(ctx, user.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+	return authctx.WithTenant(ctx, tenantID), nil
+}
+
+// issueTokens mints an access token and a refresh token for a session of the
+// user. The refresh token joins the session's family.
+func (h *Handler) issueTokens(ctx context.Context, u User, sessionID pgtype.UUID) (*TokenResponse, error) {
+	userID, err := strconv.ParseInt(u.ID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id %q: %w", u.ID, err)
+	}
+
+	access, err := h.repo.GetUserAccess(ctx, int32(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := h.authService.GenerateToken(Identity{
+		UserID:        u.ID,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Roles:         access.Roles,
+		Permissions:   access.Permissions,
+		TenantID:      access.TenantID,
+		SessionID:     sessionID.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.Roles = access.Roles
+
+	refreshToken, refreshHash, err := h.authService.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(h.authService.refreshExpiry)
+	if err := h.repo.CreateRefreshToken(ctx, int32(userID), sessionID, refreshHash, refreshExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:                 token,
+		ExpiresAt:             now.Add(h.authService.jwtExpiry).Unix(),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt.Unix(),
+		User:                  u,
+	}, nil
+}
+
+// rehashPassword replaces a password hash made with an outdated algorithm or
+// parameters while the password is at hand. The old hash still works, so
+// errors are only logged; the update is skipped if the password changed in
+// the meantime.
+func (h *Handler) rehashPassword(ctx context.Context, userID int32, oldHash, plain string) {
+	newHash, err := h.authService.HashPassword(plain)
+	if err != nil {
+		slog.Error("Failed to rehash password", "user_id", userID, "error", err)
+		return
+	}
+	_, err = h.userQueries.RehashUserPassword(ctx, userdb.RehashUserPasswordParams{
+		NewHash: newHash,
+		ID:      userID,
+		OldHash: oldHash,
+	})
+	if err != nil {
+		slog.Error("Failed to store rehashed password", "user_id", userID, "error", err)
+	}
+}
+
+// Logout handles POST /auth/logout
+// @Summary Logout
+// @Description End the session of the access token used for this request, revoking its access and refresh tokens. For tokens issued without a session, only the access token is revoked, along with every refresh token from the same login as the refresh token, if one is supplied.
+// @Tags auth
+// @Accept json
+// @Param request body LogoutRequest false "Refresh token to revoke"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	claims := GetClaims(r.Context())
+	if claims.IsAPIKey() {
+		response.Error(w, http.StatusBadRequest, "API keys cannot log out; delete the key instead")
+		return
+	}
+	userID, err := strconv.ParseInt(claims.UserID, 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	if err := h.authService.RevokeToken(r.Context(), claims); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to logout")
+		return
+	}
+
+	if claims.SessionID != "" {
+		var sessionID pgtype.UUID
+		if err := sessionID.Scan(claims.SessionID); err == nil {
+			// A session that already ended or expired needs no revoking
+			err := h.authService.RevokeSession(r.Context(), int32(userID), sessionID)
+			if err != nil && !errors.Is(err, ErrSessionNotFound) {
+				response.Error(w, http.StatusInternalServerError, "Failed to logout")
+				return
+			}
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.repo.RevokeRefreshToken(r.Context(), opaque.Hash(req.RefreshToken), int32(userID)); err != nil {
+			response.Error(w, http.StatusInternalServerError, "Failed to logout")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll handles POST /auth/logout-all
+// @Summary Logout everywhere
+// @Description Revoke every access and refresh token issued to the current user, on all devices
+// @Tags auth
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/logout-all [post]
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims := GetClaims(r.Context())
+	if claims.IsAPIKey() {
+		response.Error(w, http.StatusBadRequest, "API keys cannot log out; delete the key instead")
+		return
+	}
+	userID, err := strconv.ParseInt(claims.UserID, 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	if err := h.authService.RevokeSessions(r.Context(), int32(userID)); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to logout")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// interactiveUser returns the current user for endpoints that manage the
+// account's credentials. Requests made with an API key are rejected with the
+// given message, so that a leaked key cannot be used to take over the account.
+func (h *Handler) interactiveUser(w http.ResponseWriter, r *http.Request, apiKeyMessage string) (int32, *Claims, bool) {
+	claims := GetClaims(r.Context())
+	userID, err := strconv.ParseInt(claims.UserID, 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+		return 0, nil, false
+	}
+	if claims.IsAPIKey() {
+		response.Error(w, http.StatusForbidden, apiKeyMessage)
+		return 0, nil, false
+	}
+	return int32(userID), claims, true
+}
+
+// JWKS handles GET /.well-known/jwks.json
+// @Summary JSON Web Key Set
+// @Description Public keys that access tokens can be verified with, selected by the kid header of a token. Empty when tokens are signed with a shared secret.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// Verifiers may cache keys briefly; a rotated key is added here well
+	// before it starts signing
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.JSON(w, http.StatusOK, h.authService.JWKS())
+}
@@ -0,0 +1,153 @@
+//go:build unit
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-test-api/internal/middleware"
+	"go-test-api/pkg/response"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestImpersonate(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, nil, nil)
+	actor := &Claims{UserID: "1", Email: "admin@example.com", Permissions: []string{PermUsersImpersonate}}
+
+	token, expiresAt, err := service.Impersonate(context.Background(), actor, Identity{
+		UserID:      "2",
+		Email:       "customer@example.com",
+		Permissions: []string{PermAddressesRead},
+		SessionID:   "admin-session",
+	}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to impersonate: %v", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Errorf("expected expiry in the future, got %v", expiresAt)
+	}
+
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("failed to validate impersonation token: %v", err)
+	}
+	if claims.UserID != "2" || !claims.HasPermission(PermAddressesRead) {
+		t.Errorf("expected the impersonated user's identity, got %+v", claims)
+	}
+	if !claims.IsImpersonated() || claims.Actor.UserID != "1" || claims.Actor.Email != "admin@example.com" {
+		t.Errorf("expected actor 1 in the act claim, got %+v", claims.Actor)
+	}
+	if claims.SessionID != "" {
+		t.Errorf("expected no session, got %q", claims.SessionID)
+	}
+}
+
+func TestMiddleware_Impersonation(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, nil, nil)
+
+	token, err := service.GenerateToken(Identity{
+		UserID:   "2",
+		Email:    "customer@example.com",
+		TenantID: "1",
+		Actor:    &Actor{UserID: "1", Email: "admin@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	var userID, actorID string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID = GetUserID(r.Context())
+		actorID = GetActorID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/addresses", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req = req.WithContext(middleware.WithLogAttrs(req.Context()))
+	w := httptest.NewRecorder()
+	Middleware(service)(ok).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if userID != "2" || actorID != "1" {
+		t.Errorf("expected user 2 acted on by 1, got user %q and actor %q", userID, actorID)
+	}
+
+	logged := map[string]string{}
+	for _, attr := range middleware.GetLogAttrs(req.Context()).Attrs() {
+		logged[attr.Key] = attr.Value.String()
+	}
+	if logged["user_id"] != "2" || logged["actor_id"] != "1" {
+		t.Errorf("expected both user IDs in the log line, got %v", logged)
+	}
+}
+
+func TestRejectImpersonation(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, nil, nil)
+
+	tests := []struct {
+		name           string
+		actor          *Actor
+		expectedStatus int
+	}{
+		{name: "own token", expectedStatus: http.StatusOK},
+		{name: "impersonation token", actor: &Actor{UserID: "1"}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := service.GenerateToken(Identity{UserID: "2", TenantID: "1", Actor: tt.actor})
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := Middleware(service)(RejectImpersonation(ok))
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/password/change", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusForbidden {
+				var body response.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if body.Code != CodeImpersonationForbidden {
+					t.Errorf("expected code %q, got %q", CodeImpersonationForbidden, body.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestIsRevoked_ImpersonatingActor(t *testing.T) {
+	revocations := NewRevocationList(nil, time.Minute)
+	issuedAt := time.Now().Add(-time.Second)
+	revocations.users["1"] = time.Now()
+
+	claims := &Claims{UserID: "2", Actor: &Actor{UserID: "1"}}
+	claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+	if !revocations.IsRevoked(claims) {
+		t.Error("expected impersonation token to be revoked with the administrator's tokens")
+	}
+
+	claims.Actor = nil
+	if revocations.IsRevoked(claims) {
+		t.Error("expected the user's own token to stay valid")
+	}
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet holds the key access tokens are signed with and every key they are
+// verified with. Keeping the previous signing key as a verification key for
+// one token lifetime lets keys be rotated without invalidating live tokens.
+type KeySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey // kid -> key
+}
+
+// jwtKey is a signing or verification key. Asymmetric keys are identified by
+// the RFC 7638 thumbprint of their public key; the HMAC key has no kid.
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+	jwk     *JWK
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKeySet creates a KeySet that signs and verifies with a shared HS256
+// secret. Such tokens can only be verified by holders of the secret.
+func NewHMACKeySet(secret string) *KeySet {
+	key := &jwtKey{
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+	return &KeySet{signing: key, keys: map[string]*jwtKey{"": key}}
+}
+
+// LoadKeySet creates a KeySet that signs with the private key in the PEM file
+// signingKeyFile and also accepts tokens signed by the keys in
+// verificationKeyFiles, which may hold public or private keys. RSA keys sign
+// with RS256, P-256 keys with ES256 and Ed25519 keys with EdDSA.
+func LoadKeySet(signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	private, err := readPEM(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain a private key", signingKeyFile)
+	}
+	signing, err := newJWTKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %w", signingKeyFile, err)
+	}
+	signing.private = signer
+
+	ks := &KeySet{signing: signing, keys: map[string]*jwtKey{signing.kid: signing}}
+	for _, file := range verificationKeyFiles {
+		parsed, err := readPEM(file)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := parsed.(crypto.Signer); ok {
+			parsed = signer.Public()
+		}
+		key, err := newJWTKey(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid verification key %s: %w", file, err)
+		}
+		ks.keys[key.kid] = key
+	}
+	return ks, nil
+}
+
+// sign signs the claims with the signing key, naming it in the kid header
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	if ks.signing.kid != "" {
+		token.Header["kid"] = ks.signing.kid
+	}
+	return token.SignedString(ks.signing.private)
+}
+
+// verificationKey is a jwt.Keyfunc selecting the key named by the kid header.
+// The token must use the algorithm of that key, so that a public key can
+// never be misused as an HMAC secret.
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok || token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public verification keys. HMAC secrets are never included.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	// List the signing key first, then the keys kept for rotation
+	if ks.signing.jwk != nil {
+		set.Keys = append(set.Keys, *ks.signing.jwk)
+	}
+	for _, kid := range slices.Sorted(maps.Keys(ks.keys)) {
+		if key := ks.keys[kid]; key.jwk != nil && kid != ks.signing.kid {
+			set.Keys = append(set.Keys, *key.jwk)
+		}
+	}
+	return set
+}
+
+// newJWTKey describes a public key as a verification key
+func newJWTKey(public interface{}) (*jwtKey, error) {
+	key := &jwtKey{public: public}
+	var thumbprint interface{}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+		key.jwk = &JWK{Kty: "RSA", N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())}
+		// RFC 7638 requires the members in lexicographic order
+		thumbprint = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{key.jwk.E, key.jwk.Kty, key.jwk.N}
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, only P-256 is supported", pub.Curve.Params().Name)
+		}
+		ecdh, err := pub.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		point := ecdh.Bytes() // uncompressed: 0x04 || x || y
+		key.method = jwt.SigningMethodES256
+		key.jwk = &JWK{Kty: "EC", Crv: "P-256", X: b64(point[1:33]), Y: b64(point[33:])}
+		thumbprint = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{key.jwk.Crv, key.jwk.Kty, key.jwk.X, key.jwk.Y}
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.jwk = &JWK{Kty: "OKP", Crv: "Ed25519", X: b64(pub)}
+		thumbprint = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{key.jwk.Crv, key.jwk.Kty, key.jwk.X}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	b, err := json.Marshal(thumbprint)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	key.kid = b64(sum[:])
+	key.jwk.Kid = key.kid
+	key.jwk.Use = "sig"
+	key.jwk.Alg = key.method.Alg()
+	return key, nil
+}
+
+// readPEM parses the first PEM block of a file as a private or public key
+func readPEM(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", file)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s holds an unsupported PEM block %q", file, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return key, nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
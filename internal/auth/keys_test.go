@@ -0,0 +1,146 @@
+//go:build unit
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKey writes a private key to a PEM file and returns its path
+func writeKey(t *testing.T, name string, key crypto.Signer) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+func newTestService(keys *KeySet) *Service {
+	return NewService(ServiceConfig{Keys: keys, JWTExpiry: time.Minute}, nil, nil)
+}
+
+func TestKeySet_SignAndValidate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ec key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		key         crypto.Signer
+		expectedAlg string
+	}{
+		{name: "rsa", key: rsaKey, expectedAlg: "RS256"},
+		{name: "ecdsa", key: ecKey, expectedAlg: "ES256"},
+		{name: "ed25519", key: edKey, expectedAlg: "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			keys, err := LoadKeySet(writeKey(t, tt.name+".pem", tt.key), nil)
+			if err != nil {
+				t.Fatalf("failed to load key set: %v", err)
+			}
+			service := newTestService(keys)
+
+			token, err := service.GenerateToken(Identity{UserID: "1", Email: "john@example.com"})
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+			claims, err := service.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("failed to validate token: %v", err)
+			}
+			if claims.UserID != "1" {
+				t.Errorf("expected user ID 1, got %q", claims.UserID)
+			}
+
+			jwks := service.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("expected 1 key in JWKS, got %d", len(jwks.Keys))
+			}
+			if jwks.Keys[0].Alg != tt.expectedAlg {
+				t.Errorf("expected alg %s, got %s", tt.expectedAlg, jwks.Keys[0].Alg)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	oldFile := writeKey(t, "old.pem", oldKey)
+	newFile := writeKey(t, "new.pem", newKey)
+
+	oldKeys, err := LoadKeySet(oldFile, nil)
+	if err != nil {
+		t.Fatalf("failed to load old key set: %v", err)
+	}
+	oldToken, err := newTestService(oldKeys).GenerateToken(Identity{UserID: "1"})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	// During the rotation window tokens signed by the old key stay valid
+	rotated, err := LoadKeySet(newFile, []string{oldFile})
+	if err != nil {
+		t.Fatalf("failed to load rotated key set: %v", err)
+	}
+	if _, err := newTestService(rotated).ValidateToken(oldToken); err != nil {
+		t.Errorf("expected token signed by the previous key to be valid, got %v", err)
+	}
+	if jwks := rotated.JWKS(); len(jwks.Keys) != 2 || jwks.Keys[0].Kid != rotated.signing.kid {
+		t.Errorf("expected JWKS to list the signing key first, then the previous key, got %+v", jwks.Keys)
+	}
+
+	// Afterwards they are rejected
+	newKeys, err := LoadKeySet(newFile, nil)
+	if err != nil {
+		t.Fatalf("failed to load new key set: %v", err)
+	}
+	if _, err := newTestService(newKeys).ValidateToken(oldToken); err == nil {
+		t.Error("expected token signed by a retired key to be rejected")
+	}
+
+	// Neither are tokens signed with a shared secret
+	hmacToken, err := newTestService(NewHMACKeySet("secret")).GenerateToken(Identity{UserID: "1"})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	if _, err := newTestService(newKeys).ValidateToken(hmacToken); err == nil {
+		t.Error("expected HS256 token to be rejected by an asymmetric key set")
+	}
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/user"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UnlockAccount handles GET /auth/unlock
+// @Summary Unlock account
+// @Description Lift a lockout imposed after repeated failed logins, using the token from the lockout email
+// @Tags auth
+// @Produce json
+// @Param token query string true "Unlock token"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/unlock [get]
+func (h *Handler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Missing unlock token")
+		return
+	}
+
+	if _, err := h.throttle.UnlockWithToken(r.Context(), token, h.throttle.ClientIP(r)); err != nil {
+		if errors.Is(err, ErrInvalidUnlockToken) {
+			response.Error(w, http.StatusBadRequest, "Invalid or expired unlock token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to unlock account")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, MessageResponse{Message: "Account unlocked"})
+}
+
+// UnlockUser handles POST /users/{id}/unlock
+// @Summary Unlock a user
+// @Description Lift any lockout of a user's account imposed after repeated failed logins. Requires the users:unlock permission.
+// @Tags users
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/unlock [post]
+func (h *Handler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	actorID, err := strconv.ParseInt(GetUserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	dbUser, err := h.userQueries.GetUser(r.Context(), int32(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.Error(w, http.StatusNotFound, "User not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to unlock user")
+		return
+	}
+	tenantID, err := h.repo.GetUserTenant(r.Context(), dbUser.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to unlock user")
+		return
+	}
+	// Users of other tenants are out of the administrator's sight
+	if tenantID != authctx.TenantID(r.Context()) {
+		response.Error(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := h.throttle.Unlock(r.Context(), dbUser.ID, dbUser.Email, int32(actorID), h.throttle.ClientIP(r)); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to unlock user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// beginLoginAttempt admits a login attempt for email from ip, refusing it
+// with 429 if the email is locked or attempts come too fast. The attempt must
+// be settled with recordLoginFailure or recordLoginSuccess, or else
+// withdrawn with abandonLoginAttempt. It returns false if the request was
+// answered.
+func (h *Handler) beginLoginAttempt(w http.ResponseWriter, r *http.Request, email, ip string) (*LoginAttempt, bool) {
+	attempt, retryAfter, err := h.throttle.Begin(r.Context(), email, ip)
+	if err == nil {
+		return attempt, true
+	}
+
+	switch {
+	case errors.Is(err, ErrAccountLocked):
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		response.Error(w, http.StatusTooManyRequests, "Account temporarily locked after too many failed login attempts")
+	case errors.Is(err, ErrLoginThrottled):
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		response.Error(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+	default:
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+	}
+	return nil, false
+}
+
+// recordLoginFailure counts a login attempt as failed. userID is the account
+// its email belongs to, or 0 if there is none; if the failure locks it, the
+// owner is emailed an unlock link. Errors are only logged, since the login
+// fails either way.
+func (h *Handler) recordLoginFailure(ctx context.Context, attempt *LoginAttempt, userID int32) {
+	recordFailure(ctx, attempt, h.notifier, userID)
+}
+
+// recordLoginSuccess resets the failed login count of an email
+func (h *Handler) recordLoginSuccess(ctx context.Context, email string) {
+	recordSuccess(ctx, h.throttle, email)
+}
+
+// abandonLoginAttempt withdraws a login attempt that did not fail. It is
+// deferred right after the attempt is admitted, and outlives the request so
+// that a client hanging up does not leave the attempt counted.
+func (h *Handler) abandonLoginAttempt(ctx context.Context, attempt *LoginAttempt) {
+	abandonAttempt(context.WithoutCancel(ctx), attempt)
+}
+
+// recordFailure counts attempt as failed, emailing the owner of userID an
+// unlock link if it locks the account
+func recordFailure(ctx context.Context, attempt *LoginAttempt, notifier *Notifier, userID int32) {
+	lockout, err := attempt.Fail(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to record failed login", "error", err)
+		return
+	}
+	if lockout == nil || userID == 0 {
+		return
+	}
+
+	slog.Warn("Account locked after failed logins", "user_id", userID, "ip", attempt.ip)
+	if err := notifier.SendUnlock(ctx, attempt.email, lockout.UnlockToken, lockout.Until); err != nil {
+		slog.Error("Failed to send unlock email", "user_id", userID, "error", err)
+	}
+}
+
+// recordSuccess resets the failed login count of an email
+func recordSuccess(ctx context.Context, throttle *LoginThrottle, email string) {
+	if err := throttle.RecordSuccess(ctx, email); err != nil {
+		slog.Error("Failed to reset failed logins", "error", err)
+	}
+}
+
+// abandonAttempt withdraws attempt unless it failed
+func abandonAttempt(ctx context.Context, attempt *LoginAttempt) {
+	if err := attempt.Abandon(ctx); err != nil {
+		slog.Error("Failed to withdraw login attempt", "error", err)
+	}
+}
+
+// AccountThrottle applies the login throttle to the current-password checks
+// of account changes, so that a stolen access token is no help in guessing
+// the password. It implements user.PasswordThrottle.
+type AccountThrottle struct {
+	throttle *LoginThrottle
+	notifier *Notifier
+}
+
+// NewAccountThrottle creates a new AccountThrottle. Lockouts are mailed
+// through notifier.
+func NewAccountThrottle(throttle *LoginThrottle, notifier *Notifier) *AccountThrottle {
+	return &AccountThrottle{throttle: throttle, notifier: notifier}
+}
+
+// ClientIP returns the address of the client that sent r
+func (a *AccountThrottle) ClientIP(r *http.Request) string {
+	return a.throttle.ClientIP(r)
+}
+
+// Begin admits a password check for the account with email, counting it as
+// a login attempt from ip. It returns user.ErrPasswordThrottled, along with
+// how long to wait, if the account is locked or attempts come too fast.
+func (a *AccountThrottle) Begin(ctx context.Context, email, ip string) (user.PasswordAttempt, time.Duration, error) {
+	attempt, retryAfter, err := a.throttle.Begin(ctx, email, ip)
+	if err != nil {
+		if errors.Is(err, ErrAccountLocked) || errors.Is(err, ErrLoginThrottled) {
+			return nil, retryAfter, fmt.Errorf("%w: %w", user.ErrPasswordThrottled, err)
+		}
+		return nil, 0, err
+	}
+	return &passwordAttempt{attempt: attempt, notifier: a.notifier}, 0, nil
+}
+
+// passwordAttempt is a password check admitted by an AccountThrottle
+type passwordAttempt struct {
+	attempt  *LoginAttempt
+	notifier *Notifier
+}
+
+// Fail counts an incorrect password as a failed login of the account
+func (p *passwordAttempt) Fail(ctx context.Context, userID int32) {
+	recordFailure(ctx, p.attempt, p.notifier, userID)
+}
+
+// Succeed resets the failed login count of the account
+func (p *passwordAttempt) Succeed(ctx context.Context) {
+	recordSuccess(ctx, p.attempt.throttle, p.attempt.email)
+}
+
+// Abandon withdraws a check that ended without a verdict
+func (p *passwordAttempt) Abandon(ctx context.Context) {
+	abandonAttempt(ctx, p.attempt)
+}
+
+// retryAfterSeconds formats a wait for the Retry-After header
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
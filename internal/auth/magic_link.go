@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go-test-api/internal/opaque"
+	userdb "go-test-api/internal/user/db"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// magicLinkMessage is returned whether or not the email is registered, so
+// that the endpoint cannot be used to discover accounts
+const magicLinkMessage = "If that email is registered, a sign-in link has been sent"
+
+// Magic links are throttled per account email: at most one per
+// magicLinkInterval, and at most magicLinkLimit within magicLinkWindow
+const (
+	magicLinkInterval = time.Minute
+	magicLinkWindow   = time.Hour
+	magicLinkLimit    = 5
+)
+
+// RequestMagicLink handles POST /auth/magic-link
+// @Summary Request a magic login link
+// @Description Email a single-use link that logs in without a password, if the address belongs to an account. The response is the same whether or not the account exists, and whether or not the request was throttled.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MagicLinkRequest true "Account email"
+// @Success 202 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/magic-link [post]
+func (h *Handler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbUser, err := h.userQueries.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.JSON(w, http.StatusAccepted, MessageResponse{Message: magicLinkMessage})
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to request sign-in link")
+		return
+	}
+
+	// Throttled requests are dropped silently: a 429 would only ever be
+	// returned for registered emails
+	retryAfter, err := h.magicLinkRetryAfter(r.Context(), dbUser.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to request sign-in link")
+		return
+	}
+	if retryAfter > 0 {
+		slog.Warn("Magic link request throttled", "user_id", dbUser.ID, "retry_after", retryAfter)
+		response.JSON(w, http.StatusAccepted, MessageResponse{Message: magicLinkMessage})
+		return
+	}
+
+	token, err := opaque.New()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to request sign-in link")
+		return
+	}
+	expiresAt := time.Now().Add(h.authService.magicExpiry)
+	if err := h.repo.CreateMagicLinkToken(r.Context(), dbUser.ID, opaque.Hash(token), expiresAt); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to request sign-in link")
+		return
+	}
+
+	// A delivery failure is logged rather than reported, since reporting
+	// it would reveal that the account exists
+	if err := h.notifier.SendMagicLink(r.Context(), dbUser.Email, token, h.authService.magicExpiry); err != nil {
+		slog.Error("Failed to send magic link email", "user_id", dbUser.ID, "error", err)
+	}
+
+	response.JSON(w, http.StatusAccepted, MessageResponse{Message: magicLinkMessage})
+}
+
+// ConsumeMagicLink handles GET /auth/magic-link/consume
+// @Summary Log in with a magic link
+// @Description Exchange the token from a magic link email for tokens. The link can only be used once, and using it verifies the email address. Users with two-factor authentication enabled get an MFA challenge token instead of tokens.
+// @Tags auth
+// @Produce json
+// @Param token query string true "Magic link token"
+// @Success 200 {object} TokenResponse
+// @Success 202 {object} MFAChallengeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/magic-link/consume [get]
+func (h *Handler) ConsumeMagicLink(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Missing sign-in token")
+		return
+	}
+
+	userID, err := h.repo.ConsumeMagicLinkToken(r.Context(), opaque.Hash(token))
+	if err != nil {
+		if errors.Is(err, ErrInvalidMagicLink) {
+			response.Error(w, http.StatusBadRequest, "Invalid or expired sign-in link")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+	// Older links in the mailbox are superseded by the one just used
+	if err := h.repo.InvalidateMagicLinkTokens(r.Context(), userID); err != nil {
+		slog.Error("Failed to invalidate magic links", "user_id", userID, "error", err)
+	}
+
+	// Opening the link proves the address belongs to the user
+	_, err = h.userQueries.MarkUserEmailVerified(r.Context(), userdb.MarkUserEmailVerifiedParams{
+		ID:              userID,
+		EmailVerifiedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+
+	dbUser, err := h.userQueries.GetUser(r.Context(), userID)
+	if err != nil {
+		// The user was deleted since the link was consumed
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.Error(w, http.StatusBadRequest, "Invalid or expired sign-in link")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+	if h.completeLogin(w, r, dbUser.ID, User{
+		ID:            fmt.Sprint(dbUser.ID),
+		Name:          dbUser.Name,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}, sessionMethodMagicLink) {
+		h.recordLoginSuccess(r.Context(), dbUser.Email)
+	}
+}
+
+// magicLinkRetryAfter returns how long the user must wait before another
+// magic link may be sent, or zero if one may be sent now
+func (h *Handler) magicLinkRetryAfter(ctx context.Context, userID int32) (time.Duration, error) {
+	now := time.Now()
+	stats, err := h.repo.MagicLinksSince(ctx, userID, now.Add(-magicLinkWindow))
+	if err != nil {
+		return 0, err
+	}
+	if stats.Sent == 0 {
+		return 0, nil
+	}
+
+	var retryAfter time.Duration
+	if stats.Sent >= magicLinkLimit {
+		retryAfter = stats.FirstSentAt.Time.Add(magicLinkWindow).Sub(now)
+	}
+	if wait := stats.LastSentAt.Time.Add(magicLinkInterval).Sub(now); wait > retryAfter {
+		retryAfter = wait
+	}
+	return retryAfter, nil
+}
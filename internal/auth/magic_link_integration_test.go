@@ -0,0 +1,40 @@
+//go:build integration
+// +build integration
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-test-api/internal/auth/db"
+)
+
+func TestRepository_ConsumeMagicLinkToken_DeletedUser_Integration(t *testing.T) {
+	ctx := context.Background()
+	_, err := testDB.Exec(ctx, "TRUNCATE users, magic_link_tokens RESTART IDENTITY CASCADE")
+	if err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	repo := NewRepository(db.New(testDB))
+
+	var userID int32
+	err = testDB.QueryRow(ctx, `INSERT INTO users (name, email, password_hash, created_at, updated_at)
+		VALUES ('Alice', 'alice@example.com', 'hashedpassword', NOW(), NOW()) RETURNING id`).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := repo.CreateMagicLinkToken(ctx, userID, "link-hash", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to create magic link: %v", err)
+	}
+	if _, err := testDB.Exec(ctx, "UPDATE users SET deleted_at = NOW() WHERE id = $1", userID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	// A link mailed before the deletion no longer logs the user in
+	if _, err := repo.ConsumeMagicLinkToken(ctx, "link-hash"); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("Expected ErrInvalidMagicLink, got %v", err)
+	}
+}
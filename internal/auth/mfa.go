@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/totp"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// totpSkew is how many time steps a TOTP code may be off, to allow for
+// clock drift between the server and the authenticator
+const totpSkew = 1
+
+// LoginMFA handles POST /auth/login/mfa
+// @Summary Complete a two-factor login
+// @Description Exchange the MFA challenge token returned by login, together with a current TOTP code or an unused recovery code, for a JWT access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginMFARequest true "Challenge token and code"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/login/mfa [post]
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req LoginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims, err := h.authService.ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	parsedID, err := strconv.ParseInt(claims.UserID, 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	userID := int32(parsedID)
+
+	dbUser, err := h.userQueries.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.Error(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+
+	// Wrong codes count as failed logins, like wrong passwords
+	attempt, ok := h.beginLoginAttempt(w, r, dbUser.Email, h.throttle.ClientIP(r))
+	if !ok {
+		return
+	}
+	defer h.abandonLoginAttempt(r.Context(), attempt)
+	if err := h.verifySecondFactor(r.Context(), userID, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			h.recordLoginFailure(r.Context(), attempt, userID)
+			response.Error(w, http.StatusUnauthorized, "Invalid authentication code")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+
+	// A challenge completes a single login
+	if err := h.authService.RevokeToken(r.Context(), claims); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+
+	// Challenges issued before sessions recorded a method came from passwords
+	method := claims.AuthMethod
+	if method == "" {
+		method = sessionMethodPassword
+	}
+	resp, err := h.startSession(r, User{
+		ID:            claims.UserID,
+		Name:          dbUser.Name,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}, userID, method, true)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	h.recordLoginSuccess(r.Context(), dbUser.Email)
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// EnrollTOTP handles POST /auth/mfa/totp
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret for the current user. Two-factor authentication is only enabled once the secret is confirmed with a code from the authenticator app. Calling this again before confirming replaces the secret.
+// @Tags mfa
+// @Produce json
+// @Success 200 {object} TOTPEnrollmentResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/mfa/totp [post]
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, claims, ok := h.interactiveUser(w, r, "API keys cannot manage two-factor authentication")
+	if !ok {
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+	if err := h.repo.StartTOTPEnrollment(r.Context(), userID, secret); err != nil {
+		if errors.Is(err, ErrMFAAlreadyEnabled) {
+			response.Error(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(h.authService.totpIssuer, claims.Email, secret),
+	})
+}
+
+// ConfirmTOTP handles POST /auth/mfa/totp/confirm
+// @Summary Confirm TOTP enrollment
+// @Description Enable two-factor authentication by proving the authenticator app holds the pending secret. Returns one-time recovery codes, which are only shown once.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "Current TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/mfa/totp/confirm [post]
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.interactiveUser(w, r, "API keys cannot manage two-factor authentication")
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pending, err := h.repo.GetTOTP(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			response.Error(w, http.StatusBadRequest, "No TOTP enrollment in progress")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to confirm enrollment")
+		return
+	}
+	if pending.ConfirmedAt.Valid {
+		response.Error(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	step, ok := totp.Validate(pending.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		response.Error(w, http.StatusBadRequest, "Invalid authentication code")
+		return
+	}
+
+	if err := h.repo.ConfirmTOTP(r.Context(), userID, step); err != nil {
+		if errors.Is(err, ErrMFAAlreadyEnabled) {
+			response.Error(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to confirm enrollment")
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP handles DELETE /auth/mfa/totp
+// @Summary Disable two-factor authentication
+// @Description Remove the TOTP secret and all recovery codes of the current user. Requires a current TOTP code or an unused recovery code; wrong codes count as failed logins.
+// @Tags mfa
+// @Accept json
+// @Param request body MFACodeRequest true "TOTP or recovery code"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/mfa/totp [delete]
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, claims, ok := h.interactiveUser(w, r, "API keys cannot manage two-factor authentication")
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !h.checkSecondFactor(w, r, userID, claims.Email, req.Code, "Failed to disable two-factor authentication") {
+		return
+	}
+
+	if err := h.repo.DeleteTOTP(r.Context(), userID); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes handles POST /auth/mfa/recovery-codes
+// @Summary Regenerate recovery codes
+// @Description Replace the recovery codes of the current user with new ones, which are only shown once. Requires a current TOTP code or an unused recovery code; wrong codes count as failed logins.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/mfa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, claims, ok := h.interactiveUser(w, r, "API keys cannot manage two-factor authentication")
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !h.checkSecondFactor(w, r, userID, claims.Email, req.Code, "Failed to generate recovery codes") {
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// checkSecondFactor verifies a code supplied to manage the two-factor
+// authentication of a user. Wrong codes count as failed logins of the user's
+// email, as at /auth/login/mfa, so that an access token alone is no help in
+// guessing them. Other errors are answered with failMessage. It returns false
+// if the request was answered.
+func (h *Handler) checkSecondFactor(w http.ResponseWriter, r *http.Request, userID int32, email, code, failMessage string) bool {
+	attempt, ok := h.beginLoginAttempt(w, r, email, h.throttle.ClientIP(r))
+	if !ok {
+		return false
+	}
+	defer h.abandonLoginAttempt(r.Context(), attempt)
+
+	if err := h.verifySecondFactor(r.Context(), userID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			h.recordLoginFailure(r.Context(), attempt, userID)
+			response.Error(w, http.StatusBadRequest, "Invalid authentication code")
+			return false
+		}
+		response.Error(w, http.StatusInternalServerError, failMessage)
+		return false
+	}
+	h.recordLoginSuccess(r.Context(), email)
+	return true
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code of a user with
+// two-factor authentication enabled, and consumes it so it cannot be used
+// again. It returns ErrInvalidMFACode if the code is not accepted.
+func (h *Handler) verifySecondFactor(ctx context.Context, userID int32, code string) error {
+	// Recovery codes are much longer than TOTP codes
+	if len(code) != totp.Digits {
+		return h.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	}
+
+	secret, err := h.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	if !secret.ConfirmedAt.Valid {
+		return ErrInvalidMFACode
+	}
+	step, ok := totp.Validate(secret.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	return h.repo.UseTOTPStep(ctx, userID, step)
+}
+
+// replaceRecoveryCodes generates and stores a new set of recovery codes for
+// a user, invalidating the previous ones
+func (h *Handler) replaceRecoveryCodes(ctx context.Context, userID int32) ([]string, error) {
+	codes, hashes, err := h.authService.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
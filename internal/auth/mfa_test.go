@@ -0,0 +1,67 @@
+//go:build unit
+
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMFAToken_NotAnAccessToken(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, nil, nil)
+
+	challenge, expiresAt, err := service.GenerateMFAToken("1", sessionMethodMagicLink)
+	if err != nil {
+		t.Fatalf("failed to generate mfa token: %v", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Errorf("expected expiry in the future, got %v", expiresAt)
+	}
+	claims, err := service.ValidateMFAToken(challenge)
+	if err != nil {
+		t.Fatalf("failed to validate mfa token: %v", err)
+	}
+	if claims.UserID != "1" {
+		t.Errorf("expected user ID 1, got %q", claims.UserID)
+	}
+	if claims.AuthMethod != sessionMethodMagicLink {
+		t.Errorf("expected auth method %q, got %q", sessionMethodMagicLink, claims.AuthMethod)
+	}
+	if _, err := service.ValidateToken(challenge); err == nil {
+		t.Error("expected mfa token to be rejected as an access token")
+	}
+
+	access, err := service.GenerateToken(Identity{UserID: "1"})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	if _, err := service.ValidateMFAToken(access); err == nil {
+		t.Error("expected access token to be rejected as an mfa token")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret"}, nil, nil)
+
+	codes, hashes, err := service.GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("failed to generate recovery codes: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes and hashes, got %d and %d", recoveryCodeCount, len(codes), len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("duplicate recovery code %s", code)
+		}
+		seen[code] = true
+
+		// Codes match however they are typed
+		if hashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))) != hashes[i] {
+			t.Errorf("expected hash of %s to ignore case and separators", code)
+		}
+	}
+}
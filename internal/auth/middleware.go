@@ -2,21 +2,35 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/middleware"
 	"go-test-api/pkg/response"
 )
 
 type contextKey string
 
-const (
-	userIDKey contextKey = "user_id"
-	emailKey  contextKey = "email"
-)
+const claimsKey contextKey = "claims"
 
-// Middleware creates an authentication middleware
+// Middleware creates an authentication middleware. Requests authenticate with
+// either "Bearer <token>" or "ApiKey <key>". When the service requires
+// verified email addresses, users who have not verified theirs are rejected.
 func Middleware(authService *Service) func(http.Handler) http.Handler {
+	return authenticate(authService, authService.requireVerifiedEmail)
+}
+
+// UnverifiedMiddleware creates an authentication middleware that admits users
+// whose email address is not verified yet, for the few routes they need
+// before verifying, such as resending the verification email
+func UnverifiedMiddleware(authService *Service) func(http.Handler) http.Handler {
+	return authenticate(authService, false)
+}
+
+func authenticate(authService *Service, requireVerified bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
@@ -26,25 +40,57 @@ func Middleware(authService *Service) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Check Bearer scheme
+			// Check Bearer or ApiKey scheme
 			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || parts[0] != "Bearer" {
+			if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
 				response.Error(w, http.StatusUnauthorized, "Invalid authorization header format")
 				return
 			}
 
-			token := parts[1]
+			// Validate token or API key
+			var claims *Claims
+			var err error
+			if parts[0] == "ApiKey" {
+				claims, err = authService.ValidateAPIKey(r.Context(), parts[1])
+				if err != nil {
+					if errors.Is(err, ErrInvalidAPIKey) {
+						response.Error(w, http.StatusUnauthorized, "Invalid or expired API key")
+						return
+					}
+					response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+					return
+				}
+			} else {
+				claims, err = authService.ValidateToken(parts[1])
+				// Tokens issued before tenants were introduced name none,
+				// and must be refreshed
+				if err != nil || claims.TenantID == "" {
+					response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+					return
+				}
+			}
 
-			// Validate token
-			claims, err := authService.ValidateToken(token)
-			if err != nil {
-				response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
+			if requireVerified && !claims.EmailVerified {
+				response.Error(w, http.StatusForbidden, "Email address not verified")
 				return
 			}
+			authService.sessions.Touch(claims.SessionID)
 
 			// Add user info to context
-			ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
-			ctx = context.WithValue(ctx, emailKey, claims.Email)
+			ctx := authctx.WithUser(r.Context(), claims.UserID, claims.Email)
+			ctx = authctx.WithPermissions(ctx, claims.Permissions)
+			ctx = authctx.WithTenant(ctx, claims.TenantID)
+			if claims.IsAPIKey() {
+				ctx = authctx.WithAPIKey(ctx)
+			}
+			ctx = context.WithValue(ctx, claimsKey, claims)
+
+			// Impersonated requests are logged with both users
+			middleware.AddLogAttrs(ctx, slog.String("user_id", claims.UserID))
+			if claims.IsImpersonated() {
+				ctx = authctx.WithActor(ctx, claims.Actor.UserID)
+				middleware.AddLogAttrs(ctx, slog.String("actor_id", claims.Actor.UserID))
+			}
 
 			// Continue with authenticated request
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -52,18 +98,28 @@ func Middleware(authService *Service) func(http.Handler) http.Handler {
 	}
 }
 
-// GetUserID extracts the user ID from the request context
+// GetUserID extracts the user ID from the request context. Under
+// impersonation, this is the impersonated user.
 func GetUserID(ctx context.Context) string {
-	if userID, ok := ctx.Value(userIDKey).(string); ok {
-		return userID
-	}
-	return ""
+	return authctx.UserID(ctx)
+}
+
+// GetActorID extracts the ID of the user really making the request from the
+// context: the administrator under impersonation, otherwise the same as
+// GetUserID
+func GetActorID(ctx context.Context) string {
+	return authctx.ActorID(ctx)
 }
 
 // GetEmail extracts the email from the request context
 func GetEmail(ctx context.Context) string {
-	if email, ok := ctx.Value(emailKey).(string); ok {
-		return email
+	return authctx.Email(ctx)
+}
+
+// GetClaims extracts the validated token claims from the request context
+func GetClaims(ctx context.Context) *Claims {
+	if claims, ok := ctx.Value(claimsKey).(*Claims); ok {
+		return claims
 	}
-	return ""
+	return nil
 }
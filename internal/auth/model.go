@@ -1,10 +1,12 @@
 package auth
 
+import "time"
+
 // RegisterRequest represents a user registration request
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required,min=1,max=100"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=72"`
+	Password string `json:"password" validate:"required,min=8,max=256"`
 }
 
 // LoginRequest represents a user login request
@@ -13,16 +15,142 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// RefreshRequest represents a request to exchange a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest represents a logout request. The refresh token is optional;
+// when present its whole rotation family is revoked as well.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPasswordRequest represents a request for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkRequest represents a request for a login link by email
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password using a
+// reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=256"`
+}
+
+// LoginMFARequest represents the second step of a login with two-factor
+// authentication. Code is either a current TOTP code or a recovery code.
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFACodeRequest represents a request confirmed by a two-factor
+// authentication code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// CreateAPIKeyRequest represents a request to create an API key. Scopes
+// are permissions the key may use, out of those held by its owner.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// UpdateAPIKeyRequest represents a request to rename an API key
+type UpdateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// APIKeyResponse represents an API key in API responses. The secret part of
+// the key is never returned after creation.
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyResponse represents a newly created API key, including the
+// full key, which is only ever shown once
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// SessionResponse represents a login of the current user. Method is how the
+// user logged in: password, registration, magic_link or oidc:<provider>.
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Method     string    `json:"method"`
+	MFA        bool      `json:"mfa"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// TOTPEnrollmentResponse represents a pending TOTP secret. OTPAuthURI holds
+// the same secret in the form authenticator apps scan as a QR code.
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse represents newly generated recovery codes, which are
+// only ever shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse is returned by login instead of tokens when the user
+// has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// MessageResponse represents a response that only carries a message
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
 // TokenResponse represents the authentication token response
 type TokenResponse struct {
+	Token                 string `json:"token"`
+	ExpiresAt             int64  `json:"expires_at"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresAt int64  `json:"refresh_token_expires_at"`
+	User                  User   `json:"user"`
+}
+
+// ImpersonationResponse represents an access token for acting as another
+// user. It cannot be refreshed.
+type ImpersonationResponse struct {
 	Token     string `json:"token"`
 	ExpiresAt int64  `json:"expires_at"`
 	User      User   `json:"user"`
+	ActorID   string `json:"actor_id"`
 }
 
 // User represents user data in token response
 type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
 }
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go-test-api/internal/mail"
+)
+
+// Notifier composes and sends the emails of the auth flows
+type Notifier struct {
+	mailer      mail.Mailer
+	baseURL     string
+	frontendURL string
+}
+
+// NewNotifier creates a new Notifier. Links that complete a flow on their
+// own point at the API at baseURL. Links to flows that need a form point at
+// pages of the web app at frontendURL, which submit to the API: the
+// /reset-password page asks for a new password and posts it to
+// /auth/password/reset, and the /accept-invitation page signs the invitee in
+// and posts to /invitations/accept.
+func NewNotifier(mailer mail.Mailer, baseURL, frontendURL string) *Notifier {
+	return &Notifier{mailer: mailer, baseURL: baseURL, frontendURL: frontendURL}
+}
+
+// SendPasswordReset emails a password reset link
+func (n *Notifier) SendPasswordReset(ctx context.Context, to, token string, ttl time.Duration) error {
+	link := n.link(n.frontendURL, "/reset-password", token)
+	return n.mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your account.\n\n"+
+			"To choose a new password, open the link below within %s:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.\n", ttl, link),
+	})
+}
+
+// SendVerification emails a link confirming the address belongs to the user
+func (n *Notifier) SendVerification(ctx context.Context, to, token string, ttl time.Duration) error {
+	link := n.link(n.baseURL, "/auth/verify", token)
+	return n.mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Please confirm that this is your email address.\n\n"+
+			"To verify it, open the link below within %s:\n\n%s\n\n"+
+			"If you did not create an account, you can ignore this email.\n", ttl, link),
+	})
+}
+
+// SendEmailChange emails a link confirming that a new email address for an
+// account belongs to its owner
+func (n *Notifier) SendEmailChange(ctx context.Context, to, token string, ttl time.Duration) error {
+	link := n.link(n.baseURL, "/auth/email/confirm", token)
+	return n.mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Someone asked to change the email address of an account to this address.\n\n"+
+			"To confirm the change, open the link below within %s:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.\n", ttl, link),
+	})
+}
+
+// SendMagicLink emails a link that logs the user in without a password
+func (n *Notifier) SendMagicLink(ctx context.Context, to, token string, ttl time.Duration) error {
+	link := n.link(n.baseURL, "/auth/magic-link/consume", token)
+	return n.mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Someone asked for a link to sign in to your account.\n\n"+
+			"To sign in, open the link below within %s. It can only be used once:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.\n", ttl, link),
+	})
+}
+
+// SendUnlock tells the owner of an account that it was locked after repeated
+// failed logins, with a link to unlock it early
+func (n *Notifier) SendUnlock(ctx context.Context, to, token string, until time.Time) error {
+	link := n.link(n.baseURL, "/auth/unlock", token)
+	return n.mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "Your account has been locked",
+		Body: fmt.Sprintf("Sign-in to your account was locked after several failed login attempts.\n\n"+
+			"It unlocks automatically at %s. If it was you, you can unlock it now by opening the link below:\n\n%s\n\n"+
+			"If it was not you, someone may be guessing your password; consider changing it.\n", until.UTC().Format(time.RFC1123), link),
+	})
+}
+
+// SendInvitation emails an invitation to join an organization. The link
+// leads to a page that has the invitee log in, or register with this
+// address, before accepting.
+func (n *Notifier) SendInvitation(ctx context.Context, to, organization, token string, ttl time.Duration) error {
+	link := n.link(n.frontendURL, "/accept-invitation", token)
+	return n.mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: "You have been invited to join " + organization,
+		Body: fmt.Sprintf("You have been invited to join %s.\n\n"+
+			"To accept, open the link below within %s and sign in, or create an account with this email address:\n\n%s\n\n"+
+			"If you do not want to join, you can ignore this email.\n", organization, ttl, link),
+	})
+}
+
+// link builds an absolute link to path under base carrying a token as query
+// parameter
+func (n *Notifier) link(base, path, token string) string {
+	return base + path + "?token=" + url.QueryEscape(token)
+}
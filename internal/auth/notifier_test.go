@@ -0,0 +1,69 @@
+//go:build unit
+
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test-api/internal/mail"
+)
+
+func TestNotifier_SendMagicLink(t *testing.T) {
+	outbox, err := mail.NewFileMailer(t.TempDir(), "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+	notifier := NewNotifier(outbox, "https://api.example.com", "https://app.example.com")
+
+	if err := notifier.SendMagicLink(context.Background(), "jane@example.com", "token/with+chars", 15*time.Minute); err != nil {
+		t.Fatalf("failed to send magic link: %v", err)
+	}
+
+	messages, err := outbox.Messages()
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if messages[0].To != "jane@example.com" {
+		t.Errorf("expected message to jane@example.com, got %q", messages[0].To)
+	}
+	link := "https://api.example.com/auth/magic-link/consume?token=token%2Fwith%2Bchars"
+	if !strings.Contains(messages[0].Body, link) {
+		t.Errorf("expected body to contain %q, got %q", link, messages[0].Body)
+	}
+	if !strings.Contains(messages[0].Body, "15m0s") {
+		t.Errorf("expected body to mention the link lifetime, got %q", messages[0].Body)
+	}
+}
+
+func TestNotifier_SendInvitation(t *testing.T) {
+	outbox, err := mail.NewFileMailer(t.TempDir(), "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+	notifier := NewNotifier(outbox, "https://api.example.com", "https://app.example.com")
+
+	if err := notifier.SendInvitation(context.Background(), "jane@example.com", "Acme", "invite-token", 7*24*time.Hour); err != nil {
+		t.Fatalf("failed to send invitation: %v", err)
+	}
+
+	messages, err := outbox.Messages()
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0].Subject, "Acme") {
+		t.Errorf("expected subject to name the organization, got %q", messages[0].Subject)
+	}
+	link := "https://app.example.com/accept-invitation?token=invite-token"
+	if !strings.Contains(messages[0].Body, link) {
+		t.Errorf("expected body to contain %q, got %q", link, messages[0].Body)
+	}
+}
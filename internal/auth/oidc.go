@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-test-api/internal/oidc"
+	"go-test-api/internal/user"
+	userdb "go-test-api/internal/user/db"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	errIdentityEmailUnverified = errors.New("identity provider did not verify the email")
+	errIdentityEmailTaken      = errors.New("email belongs to an unverified account")
+	errIdentityUserDeleted     = errors.New("identity belongs to a deleted account")
+)
+
+const (
+	// oidcStateCookie carries the state of a login with an identity provider
+	// from the start endpoint to the callback
+	oidcStateCookie = "oidc_state"
+	// oidcLoginTimeout is how long a user has to log in at the provider
+	oidcLoginTimeout = 10 * time.Minute
+	// maxNameLength is the longest name a user can have
+	maxNameLength = 100
+)
+
+// SSO holds the OpenID Connect providers users can log in with
+type SSO struct {
+	providers    map[string]*oidc.Provider
+	stateKey     []byte
+	secureCookie bool
+}
+
+// NewSSO creates an SSO for the given providers. Login state cookies are
+// signed with stateKey, and only sent over HTTPS if secureCookie is set.
+func NewSSO(providers []*oidc.Provider, stateKey []byte, secureCookie bool) *SSO {
+	sso := &SSO{
+		providers:    make(map[string]*oidc.Provider, len(providers)),
+		stateKey:     stateKey,
+		secureCookie: secureCookie,
+	}
+	for _, p := range providers {
+		sso.providers[p.Name()] = p
+	}
+	return sso
+}
+
+// OIDCStart handles GET /auth/oidc/{provider}/start
+// @Summary Start login with an identity provider
+// @Description Redirect to the login page of a configured OpenID Connect provider, which redirects back to /auth/oidc/{provider}/callback. The login must be completed within 10 minutes in the same browser.
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/oidc/{provider}/start [get]
+func (h *Handler) OIDCStart(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.sso.providers[r.PathValue("provider")]
+	if !ok {
+		response.Error(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	state, err := oidc.NewState(provider.Name(), oidcLoginTimeout)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	authURL, err := provider.AuthCodeURL(r.Context(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		slog.Error("Failed to reach identity provider", "provider", provider.Name(), "error", err)
+		response.Error(w, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+	cookie, err := state.Encode(h.sso.stateKey)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	h.setStateCookie(w, cookie, int(oidcLoginTimeout.Seconds()))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback handles GET /auth/oidc/{provider}/callback
+// @Summary Complete login with an identity provider
+// @Description Verify the identity the provider redirected back with and log its user in. Identities are linked to the account with the same email if the provider verified it; otherwise a new account is created. Users with two-factor authentication enabled get an MFA challenge token instead of tokens.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "Login state"
+// @Success 200 {object} TokenResponse
+// @Success 202 {object} MFAChallengeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.sso.providers[r.PathValue("provider")]
+	if !ok {
+		response.Error(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	// The state is single use whatever the outcome
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Missing login state")
+		return
+	}
+	h.setStateCookie(w, "", -1)
+
+	query := r.URL.Query()
+	state, err := oidc.DecodeState(cookie.Value, h.sso.stateKey)
+	if err != nil || state.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		response.Error(w, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+	if query.Get("error") != "" {
+		response.Error(w, http.StatusUnauthorized, "Login was not completed at the identity provider")
+		return
+	}
+	if query.Get("code") == "" {
+		response.Error(w, http.StatusBadRequest, "Missing authorization code")
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		slog.Warn("Failed to verify external identity", "provider", provider.Name(), "error", err)
+		response.Error(w, http.StatusUnauthorized, "Failed to verify identity")
+		return
+	}
+
+	dbUser, err := h.identityAccount(r.Context(), provider.Name(), identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errIdentityEmailUnverified):
+			response.Error(w, http.StatusForbidden, "The identity provider did not verify your email address")
+		case errors.Is(err, errIdentityEmailTaken):
+			response.Error(w, http.StatusConflict, "An unverified account with this email already exists")
+		case errors.Is(err, errIdentityUserDeleted):
+			response.Error(w, http.StatusUnauthorized, "The account linked to this identity no longer exists")
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to authenticate")
+		}
+		return
+	}
+	h.completeLogin(w, r, dbUser.ID, User{
+		ID:            fmt.Sprint(dbUser.ID),
+		Name:          dbUser.Name,
+		Email:         dbUser.Email,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}, sessionMethodOIDC+provider.Name())
+}
+
+// identityAccount returns the account an external identity belongs to, as
+// identityUser does. It returns errIdentityUserDeleted if the identity is
+// linked to an account that has been deleted since.
+func (h *Handler) identityAccount(ctx context.Context, provider string, identity *oidc.Identity) (userdb.GetUserRow, error) {
+	userID, err := h.identityUser(ctx, provider, identity)
+	if err != nil {
+		return userdb.GetUserRow{}, err
+	}
+	dbUser, err := h.userQueries.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return userdb.GetUserRow{}, errIdentityUserDeleted
+		}
+		return userdb.GetUserRow{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return dbUser, nil
+}
+
+// identityUser returns the user an external identity belongs to. An identity
+// seen for the first time is linked to the account with its email, or to a
+// new account if there is none. Either way the provider must have verified
+// the email, and an existing account must have verified it too: otherwise
+// whoever registered the address first would be handed the identity.
+func (h *Handler) identityUser(ctx context.Context, provider string, identity *oidc.Identity) (int32, error) {
+	userID, err := h.repo.GetIdentityUser(ctx, provider, identity.Subject)
+	if err == nil {
+		// The login succeeds either way, so bookkeeping errors are only logged
+		if err := h.repo.RecordIdentityLogin(ctx, provider, identity.Subject, identity.Email, identity.EmailVerified); err != nil {
+			slog.Error("Failed to record identity login", "user_id", userID, "error", err)
+		}
+		return userID, nil
+	}
+	if !errors.Is(err, ErrIdentityNotLinked) {
+		return 0, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return 0, errIdentityEmailUnverified
+	}
+	dbUser, err := h.userQueries.GetUserByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if !dbUser.EmailVerifiedAt.Valid {
+			return 0, errIdentityEmailTaken
+		}
+		userID = dbUser.ID
+	case errors.Is(err, pgx.ErrNoRows):
+		if userID, err = h.createIdentityUser(ctx, identity); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := h.repo.LinkIdentity(ctx, userID, provider, identity.Subject, identity.Email); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// createIdentityUser creates an account for an external identity, in the
+// default tenant. It has no password until one is set through a password
+// reset.
+func (h *Handler) createIdentityUser(ctx context.Context, identity *oidc.Identity) (int32, error) {
+	ctx, err := h.defaultTenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	created, err := h.userRepo.Create(ctx, &user.CreateUserRequest{
+		Name:  identityName(identity),
+		Email: identity.Email,
+	}, "")
+	if err != nil {
+		if errors.Is(err, user.ErrEmailExists) {
+			return 0, errIdentityEmailTaken
+		}
+		return 0, err
+	}
+	userID, err := strconv.ParseInt(created.ID, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user id %q: %w", created.ID, err)
+	}
+
+	// The provider vouched for the address
+	_, err = h.userQueries.MarkUserEmailVerified(ctx, userdb.MarkUserEmailVerifiedParams{
+		ID:              int32(userID),
+		EmailVerifiedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return int32(userID), nil
+}
+
+// setStateCookie sets the login state cookie, or clears it if maxAge is
+// negative. It must be sent along with the top-level redirect back from the
+// provider, hence SameSite=Lax.
+func (h *Handler) setStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/auth/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.sso.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// identityName returns the name for an account created for an identity,
+// falling back to the local part of its email
+func identityName(identity *oidc.Identity) string {
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	for utf8.RuneCountInString(name) > maxNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -0,0 +1,47 @@
+//go:build integration
+// +build integration
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-test-api/internal/auth/db"
+	"go-test-api/internal/oidc"
+	userdb "go-test-api/internal/user/db"
+	"go-test-api/internal/validator"
+)
+
+func TestHandler_IdentityAccount_DeletedUser_Integration(t *testing.T) {
+	ctx := context.Background()
+	_, err := testDB.Exec(ctx, "TRUNCATE users, user_identities RESTART IDENTITY CASCADE")
+	if err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	repo := NewRepository(db.New(testDB))
+	handler := NewHandler(validator.New(), nil, nil, userdb.New(testDB), repo, nil, nil, nil)
+
+	var userID int32
+	err = testDB.QueryRow(ctx, `INSERT INTO users (name, email, password_hash, email_verified_at, created_at, updated_at)
+		VALUES ('Alice', 'alice@example.com', '', NOW(), NOW(), NOW()) RETURNING id`).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := repo.LinkIdentity(ctx, userID, "corp", "alice-subject", "alice@example.com"); err != nil {
+		t.Fatalf("Failed to link identity: %v", err)
+	}
+	identity := &oidc.Identity{Subject: "alice-subject", Email: "alice@example.com", EmailVerified: true}
+
+	if account, err := handler.identityAccount(ctx, "corp", identity); err != nil || account.ID != userID {
+		t.Fatalf("Expected the linked account %d, got %d (%v)", userID, account.ID, err)
+	}
+
+	if _, err := testDB.Exec(ctx, "UPDATE users SET deleted_at = NOW() WHERE id = $1", userID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if _, err := handler.identityAccount(ctx, "corp", identity); !errors.Is(err, errIdentityUserDeleted) {
+		t.Errorf("Expected errIdentityUserDeleted, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+//go:build unit
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test-api/internal/oidc"
+	"go-test-api/internal/validator"
+)
+
+// newTestSSO returns an SSO with a provider named fake whose discovery
+// document is served by an in-process issuer
+func newTestSSO(t *testing.T) *SSO {
+	t.Helper()
+
+	var issuer *httptest.Server
+	issuer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	}))
+	t.Cleanup(issuer.Close)
+
+	provider := oidc.NewProvider(oidc.ProviderConfig{
+		Name:     "fake",
+		Issuer:   issuer.URL,
+		ClientID: "test-client",
+	}, "https://app.example.com/auth/oidc/fake/callback", issuer.Client())
+	return NewSSO([]*oidc.Provider{provider}, []byte("state-key"), true)
+}
+
+func TestHandler_OIDCStart(t *testing.T) {
+	sso := newTestSSO(t)
+	handler := NewHandler(validator.New(), nil, nil, nil, nil, nil, nil, sso)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/fake/start", nil)
+	req.SetPathValue("provider", "fake")
+	w := httptest.NewRecorder()
+	handler.OIDCStart(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusFound, w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasSuffix(location.Path, "/authorize") {
+		t.Fatalf("expected redirect to the authorization endpoint, got %q", w.Header().Get("Location"))
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("expected a secure, HTTP-only state cookie, got %+v", cookies)
+	}
+	state, err := oidc.DecodeState(cookies[0].Value, []byte("state-key"))
+	if err != nil {
+		t.Fatalf("failed to decode state cookie: %v", err)
+	}
+	q := location.Query()
+	if state.Provider != "fake" || q.Get("state") != state.State || q.Get("nonce") != state.Nonce ||
+		q.Get("code_challenge") != oidc.Challenge(state.Verifier) {
+		t.Errorf("expected the redirect to carry the state in the cookie, got %q", location.RawQuery)
+	}
+}
+
+func TestHandler_OIDCCallback_RejectsState(t *testing.T) {
+	sso := newTestSSO(t)
+	handler := NewHandler(validator.New(), nil, nil, nil, nil, nil, nil, sso)
+
+	valid, err := oidc.NewState("fake", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	other, err := oidc.NewState("other", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	encode := func(s *oidc.State, key string) string {
+		encoded, err := s.Encode([]byte(key))
+		if err != nil {
+			t.Fatalf("failed to encode state: %v", err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name           string
+		provider       string
+		cookie         string
+		state          string
+		expectedStatus int
+	}{
+		{name: "unknown provider", provider: "other", cookie: encode(valid, "state-key"), state: valid.State, expectedStatus: http.StatusNotFound},
+		{name: "missing cookie", provider: "fake", state: valid.State, expectedStatus: http.StatusBadRequest},
+		{name: "state mismatch", provider: "fake", cookie: encode(valid, "state-key"), state: "forged", expectedStatus: http.StatusBadRequest},
+		{name: "forged cookie", provider: "fake", cookie: encode(valid, "other-key"), state: valid.State, expectedStatus: http.StatusBadRequest},
+		{name: "state of another provider", provider: "fake", cookie: encode(other, "state-key"), state: other.State, expectedStatus: http.StatusBadRequest},
+		{name: "missing code", provider: "fake", cookie: encode(valid, "state-key"), state: valid.State, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/"+tt.provider+"/callback?state="+url.QueryEscape(tt.state), nil)
+			req.SetPathValue("provider", tt.provider)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.OIDCCallback(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdentityName(t *testing.T) {
+	tests := []struct {
+		identity oidc.Identity
+		expected string
+	}{
+		{identity: oidc.Identity{Name: " Jane Doe ", Email: "jane@example.com"}, expected: "Jane Doe"},
+		{identity: oidc.Identity{Email: "jane@example.com"}, expected: "jane"},
+		{identity: oidc.Identity{Name: strings.Repeat("é", 120)}, expected: strings.Repeat("é", maxNameLength)},
+	}
+
+	for _, tt := range tests {
+		if got := identityName(&tt.identity); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/password"
+	userdb "go-test-api/internal/user/db"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// forgotPasswordMessage is returned whether or not the email is registered,
+// so that the endpoint cannot be used to discover accounts
+const forgotPasswordMessage = "If that email is registered, a password reset link has been sent"
+
+// resetMailTimeout bounds creating and mailing a password reset token, which
+// happens after the request has been answered
+const resetMailTimeout = 30 * time.Second
+
+// ForgotPassword handles POST /auth/password/forgot
+// @Summary Request a password reset
+// @Description Email a single-use password reset link to the address, if it belongs to an account. The link leads to the /reset-password page of the web app, which submits the new password to /auth/password/reset. The response is the same, and as fast, whether or not the account exists: the email is sent after responding.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 202 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/password/forgot [post]
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbUser, err := h.userQueries.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			response.JSON(w, http.StatusAccepted, MessageResponse{Message: forgotPasswordMessage})
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to request password reset")
+		return
+	}
+
+	// Only known addresses get a token and an email, which take time: doing
+	// it after answering keeps the response time from revealing the account
+	go h.sendPasswordReset(context.WithoutCancel(r.Context()), dbUser.ID, dbUser.Email)
+
+	response.JSON(w, http.StatusAccepted, MessageResponse{Message: forgotPasswordMessage})
+}
+
+// sendPasswordReset creates a password reset token for the user and emails
+// it. Failures are logged, since the request has been answered already.
+func (h *Handler) sendPasswordReset(ctx context.Context, userID int32, email string) {
+	ctx, cancel := context.WithTimeout(ctx, resetMailTimeout)
+	defer cancel()
+
+	token, err := opaque.New()
+	if err != nil {
+		slog.Error("Failed to generate password reset token", "user_id", userID, "error", err)
+		return
+	}
+	expiresAt := time.Now().Add(h.authService.resetExpiry)
+	if err := h.repo.CreatePasswordResetToken(ctx, userID, opaque.Hash(token), expiresAt); err != nil {
+		slog.Error("Failed to create password reset token", "user_id", userID, "error", err)
+		return
+	}
+	if err := h.notifier.SendPasswordReset(ctx, email, token, h.authService.resetExpiry); err != nil {
+		slog.Error("Failed to send password reset email", "user_id", userID, "error", err)
+	}
+}
+
+// ResetPassword handles POST /auth/password/reset
+// @Summary Reset password
+// @Description Set a new password using a token from a password reset email. The token can only be used once, and every existing session of the user is revoked.
+// @Tags auth
+// @Accept json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/password/reset [post]
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Hash first so that a password the hasher refuses does not use up the token
+	hashedPassword, err := h.authService.HashPassword(req.Password)
+	if err != nil {
+		if errors.Is(err, password.ErrTooLong) {
+			response.Error(w, http.StatusBadRequest, "Password is too long")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	userID, err := h.repo.ConsumePasswordResetToken(r.Context(), opaque.Hash(req.Token))
+	if err != nil {
+		if errors.Is(err, ErrInvalidResetToken) {
+			response.Error(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	err = h.userQueries.UpdateUserPassword(r.Context(), userdb.UpdateUserPasswordParams{
+		ID:           userID,
+		PasswordHash: hashedPassword,
+		UpdatedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	// Whoever knew the old password may still hold a session
+	if err := h.repo.InvalidatePasswordResetTokens(r.Context(), userID); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	if err := h.authService.RevokeSessions(r.Context(), userID); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+
+	"go-test-api/pkg/response"
+)
+
+// Permissions seeded by the migrations. Roles grant permissions; routes
+// require them.
+const (
+	PermUsersRead        = "users:read"
+	PermUsersUnlock      = "users:unlock"
+	PermUsersDelete      = "users:delete"
+	PermUsersErase       = "users:erase"
+	PermUsersImpersonate = "users:impersonate"
+	PermAddressesRead    = "addresses:read"
+	PermAddressesWrite   = "addresses:write"
+	PermAddressesAdmin   = "addresses:admin"
+
+	PermOrganizationsRead  = "organizations:read"
+	PermOrganizationsWrite = "organizations:write"
+	PermOrganizationsAdmin = "organizations:admin"
+)
+
+// CodePermissionDenied is the error code of responses rejected by
+// RequirePermission
+const CodePermissionDenied = "permission_denied"
+
+// RequirePermission creates a middleware that only lets through requests whose
+// token grants the permission. It must run after Middleware.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := GetClaims(r.Context())
+			if claims == nil {
+				response.Error(w, http.StatusUnauthorized, "Missing authorization header")
+				return
+			}
+
+			if !claims.HasPermission(permission) {
+				response.CodedError(w, http.StatusForbidden, CodePermissionDenied, "Missing required permission", map[string]interface{}{
+					"permission": permission,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+//go:build unit
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/pkg/response"
+)
+
+func TestRequirePermission(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, nil, nil)
+
+	tests := []struct {
+		name           string
+		permissions    []string
+		expectedStatus int
+	}{
+		{
+			name:           "permission granted",
+			permissions:    []string{PermAddressesRead, PermUsersRead},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "permission missing",
+			permissions:    []string{PermAddressesRead},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "no permissions",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			token, err := service.GenerateToken(Identity{
+				UserID:      "1",
+				Email:       "john@example.com",
+				Permissions: tt.permissions,
+				TenantID:    "1",
+			})
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := Middleware(service)(RequirePermission(PermUsersRead)(ok))
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusForbidden {
+				return
+			}
+
+			var resp response.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if resp.Code != CodePermissionDenied {
+				t.Errorf("expected code %q, got %q", CodePermissionDenied, resp.Code)
+			}
+			if resp.Details["permission"] != PermUsersRead {
+				t.Errorf("expected permission %q in details, got %v", PermUsersRead, resp.Details["permission"])
+			}
+		})
+	}
+}
+
+func TestRequirePermission_WithoutClaims(t *testing.T) {
+	handler := RequirePermission(PermUsersRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestMiddleware_Tenant(t *testing.T) {
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, nil, nil)
+
+	var tenantID string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID = authctx.TenantID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		expectedStatus int
+	}{
+		{name: "scoped to the tenant of the token", tenantID: "2", expectedStatus: http.StatusOK},
+		{name: "token without a tenant", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantID = ""
+			token, err := service.GenerateToken(Identity{UserID: "1", TenantID: tt.tenantID})
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			Middleware(service)(ok).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tenantID != tt.tenantID {
+				t.Errorf("expected tenant %q in the context, got %q", tt.tenantID, tenantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,654 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/auth/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrInvalidResetToken   = errors.New("invalid password reset token")
+	ErrInvalidVerifyToken  = errors.New("invalid email verification token")
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication not enrolled")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication already enabled")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrIdentityNotLinked   = errors.New("external identity not linked")
+	ErrInvalidMagicLink    = errors.New("invalid magic link token")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// Repository handles persistence of auth state such as refresh tokens
+type Repository struct {
+	queries *db.Queries
+}
+
+// NewRepository creates a new Repository
+func NewRepository(queries *db.Queries) *Repository {
+	return &Repository{queries: queries}
+}
+
+// CreateRefreshToken stores the hash of a refresh token issued to a user.
+// Every token issued by rotation shares the family of the token it replaced.
+func (r *Repository) CreateRefreshToken(ctx context.Context, userID int32, familyID pgtype.UUID, tokenHash string, expiresAt time.Time) error {
+	_, err := r.queries.CreateRefreshToken(ctx, db.CreateRefreshTokenParams{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// ConsumeRefreshToken marks a refresh token as used so that it can be rotated.
+// Presenting a token that was already used revokes its whole family, since it
+// means the token has leaked and either the client or an attacker holds a
+// stale copy.
+func (r *Repository) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*db.RefreshToken, error) {
+	token, err := r.queries.GetRefreshTokenByHash(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if token.RevokedAt.Valid || token.ExpiresAt.Time.Before(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if token.UsedAt.Valid {
+		return nil, r.revokeReusedFamily(ctx, token.FamilyID)
+	}
+
+	rows, err := r.queries.MarkRefreshTokenUsed(ctx, db.MarkRefreshTokenUsedParams{
+		ID:     token.ID,
+		UsedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+	if rows == 0 {
+		// Lost a race with a concurrent use of the same token
+		return nil, r.revokeReusedFamily(ctx, token.FamilyID)
+	}
+
+	return &token, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token in a family
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, familyID pgtype.UUID) error {
+	err := r.queries.RevokeRefreshTokenFamily(ctx, db.RevokeRefreshTokenFamilyParams{
+		FamilyID:  familyID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeRefreshToken revokes the family of a refresh token, provided the token
+// belongs to the given user. Unknown tokens are ignored.
+func (r *Repository) RevokeRefreshToken(ctx context.Context, tokenHash string, userID int32) error {
+	token, err := r.queries.GetRefreshTokenByHash(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if token.UserID != userID {
+		return nil
+	}
+	return r.RevokeRefreshTokenFamily(ctx, token.FamilyID)
+}
+
+// RevokeUserRefreshTokens revokes every refresh token issued to a user
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userID int32) error {
+	err := r.queries.RevokeUserRefreshTokens(ctx, db.RevokeUserRefreshTokensParams{
+		UserID:    userID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// CreateSession records a login whose refresh tokens form the family
+// sessionID and expire at expiresAt
+func (r *Repository) CreateSession(ctx context.Context, sessionID pgtype.UUID, userID int32, method string, mfa bool, userAgent, ipAddress string, expiresAt time.Time) error {
+	err := r.queries.CreateSession(ctx, db.CreateSessionParams{
+		ID:        sessionID,
+		UserID:    userID,
+		Method:    method,
+		Mfa:       mfa,
+		UserAgent: userAgent,
+		IpAddress: ipAddress,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// ExtendSession records a refresh of a session, whose latest refresh token
+// now expires at expiresAt
+func (r *Repository) ExtendSession(ctx context.Context, sessionID pgtype.UUID, expiresAt time.Time) error {
+	err := r.queries.ExtendSession(ctx, db.ExtendSessionParams{
+		ID:         sessionID,
+		ExpiresAt:  pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		LastSeenAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of a user, most recently seen first
+func (r *Repository) ListSessions(ctx context.Context, userID int32) ([]db.Session, error) {
+	sessions, err := r.queries.ListUserSessions(ctx, db.ListUserSessionsParams{
+		UserID:    userID,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeUserSessions marks every session of a user as revoked
+func (r *Repository) RevokeUserSessions(ctx context.Context, userID int32) error {
+	err := r.queries.RevokeUserSessions(ctx, db.RevokeUserSessionsParams{
+		UserID:    userID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+// CreatePasswordResetToken stores the hash of a password reset token
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, userID int32, tokenHash string, expiresAt time.Time) error {
+	err := r.queries.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+// ConsumePasswordResetToken atomically marks an unused, unexpired reset token
+// as used and returns the ID of the user it was issued to
+func (r *Repository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (int32, error) {
+	userID, err := r.queries.ConsumePasswordResetToken(ctx, db.ConsumePasswordResetTokenParams{
+		TokenHash: tokenHash,
+		UsedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+	return userID, nil
+}
+
+// InvalidatePasswordResetTokens marks every outstanding reset token of a user as used
+func (r *Repository) InvalidatePasswordResetTokens(ctx context.Context, userID int32) error {
+	err := r.queries.InvalidatePasswordResetTokens(ctx, db.InvalidatePasswordResetTokensParams{
+		UserID: userID,
+		UsedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+	return nil
+}
+
+// CreateEmailVerificationToken stores the hash of an email verification token
+func (r *Repository) CreateEmailVerificationToken(ctx context.Context, userID int32, tokenHash string, expiresAt time.Time) error {
+	err := r.queries.CreateEmailVerificationToken(ctx, db.CreateEmailVerificationTokenParams{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create email verification token: %w", err)
+	}
+	return nil
+}
+
+// ConsumeEmailVerificationToken atomically marks an unused, unexpired
+// verification token as used and returns the ID of the user it was issued to
+func (r *Repository) ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (int32, error) {
+	userID, err := r.queries.ConsumeEmailVerificationToken(ctx, db.ConsumeEmailVerificationTokenParams{
+		TokenHash: tokenHash,
+		UsedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidVerifyToken
+		}
+		return 0, fmt.Errorf("failed to consume email verification token: %w", err)
+	}
+	return userID, nil
+}
+
+// EmailVerificationsSince returns how many verification tokens were issued to
+// a user since the given time, along with when the first and the latest of
+// them were issued
+func (r *Repository) EmailVerificationsSince(ctx context.Context, userID int32, since time.Time) (*db.GetEmailVerificationSendStatsRow, error) {
+	stats, err := r.queries.GetEmailVerificationSendStats(ctx, db.GetEmailVerificationSendStatsParams{
+		UserID:    userID,
+		CreatedAt: pgtype.Timestamptz{Time: since, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email verification stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// CreateMagicLinkToken stores the hash of a magic link login token
+func (r *Repository) CreateMagicLinkToken(ctx context.Context, userID int32, tokenHash string, expiresAt time.Time) error {
+	err := r.queries.CreateMagicLinkToken(ctx, db.CreateMagicLinkTokenParams{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create magic link token: %w", err)
+	}
+	return nil
+}
+
+// ConsumeMagicLinkToken atomically marks an unused, unexpired magic link
+// token as used and returns the ID of the user it was issued to. Links of
+// deleted users are not accepted.
+func (r *Repository) ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (int32, error) {
+	userID, err := r.queries.ConsumeMagicLinkToken(ctx, db.ConsumeMagicLinkTokenParams{
+		TokenHash: tokenHash,
+		UsedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidMagicLink
+		}
+		return 0, fmt.Errorf("failed to consume magic link token: %w", err)
+	}
+	return userID, nil
+}
+
+// InvalidateMagicLinkTokens marks every outstanding magic link of a user as used
+func (r *Repository) InvalidateMagicLinkTokens(ctx context.Context, userID int32) error {
+	err := r.queries.InvalidateMagicLinkTokens(ctx, db.InvalidateMagicLinkTokensParams{
+		UserID: userID,
+		UsedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to invalidate magic link tokens: %w", err)
+	}
+	return nil
+}
+
+// MagicLinksSince returns how many magic links were issued to a user since
+// the given time, along with when the first and the latest of them were
+// issued
+func (r *Repository) MagicLinksSince(ctx context.Context, userID int32, since time.Time) (*db.GetMagicLinkSendStatsRow, error) {
+	stats, err := r.queries.GetMagicLinkSendStats(ctx, db.GetMagicLinkSendStatsParams{
+		UserID:    userID,
+		CreatedAt: pgtype.Timestamptz{Time: since, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get magic link stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// Access describes what tokens issued to a user grant: the tenant they
+// belong to, the names of their roles and of the permissions those carry
+type Access struct {
+	TenantID    string
+	Roles       []string
+	Permissions []string
+}
+
+// GetUserTenant returns the ID of the tenant a user belongs to. The error
+// wraps pgx.ErrNoRows if there is no such user.
+func (r *Repository) GetUserTenant(ctx context.Context, userID int32) (string, error) {
+	tenantID, err := r.queries.GetUserTenant(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user tenant: %w", err)
+	}
+	return strconv.Itoa(int(tenantID)), nil
+}
+
+// GetUserAccess returns the tenant, roles and permissions of a user
+func (r *Repository) GetUserAccess(ctx context.Context, userID int32) (*Access, error) {
+	tenantID, err := r.GetUserTenant(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := r.queries.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	permissions, err := r.queries.ListUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user permissions: %w", err)
+	}
+	return &Access{
+		TenantID:    tenantID,
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+// CreateAPIKey stores a new API key. Only the hash of its secret is kept.
+func (r *Repository) CreateAPIKey(ctx context.Context, userID int32, name, prefix, secretHash string, scopes []string, expiresAt *time.Time) (*db.ApiKey, error) {
+	var expires pgtype.Timestamptz
+	if expiresAt != nil {
+		expires = pgtype.Timestamptz{Time: *expiresAt, Valid: true}
+	}
+	key, err := r.queries.CreateAPIKey(ctx, db.CreateAPIKeyParams{
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: secretHash,
+		Scopes:     scopes,
+		ExpiresAt:  expires,
+		CreatedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKeyForAuth looks up the key with the given prefix along with the
+// owner details needed to authenticate a request with it
+func (r *Repository) GetAPIKeyForAuth(ctx context.Context, prefix string) (*db.GetAPIKeyForAuthRow, error) {
+	key, err := r.queries.GetAPIKeyForAuth(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKey returns one of a user's API keys
+func (r *Repository) GetAPIKey(ctx context.Context, id, userID int32) (*db.ApiKey, error) {
+	key, err := r.queries.GetUserAPIKey(ctx, db.GetUserAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every API key of a user
+func (r *Repository) ListAPIKeys(ctx context.Context, userID int32) ([]db.ApiKey, error) {
+	keys, err := r.queries.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RenameAPIKey changes the name of one of a user's API keys
+func (r *Repository) RenameAPIKey(ctx context.Context, id, userID int32, name string) (*db.ApiKey, error) {
+	key, err := r.queries.RenameAPIKey(ctx, db.RenameAPIKeyParams{ID: id, UserID: userID, Name: name})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to rename api key: %w", err)
+	}
+	return &key, nil
+}
+
+// DeleteAPIKey deletes one of a user's API keys
+func (r *Repository) DeleteAPIKey(ctx context.Context, id, userID int32) error {
+	rows, err := r.queries.DeleteUserAPIKey(ctx, db.DeleteUserAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %w", err)
+	}
+	if rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records that an API key was just used
+func (r *Repository) TouchAPIKey(ctx context.Context, id int32) error {
+	err := r.queries.TouchAPIKey(ctx, db.TouchAPIKeyParams{
+		ID:         id,
+		LastUsedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update api key last use: %w", err)
+	}
+	return nil
+}
+
+// StartTOTPEnrollment stores a new, unconfirmed TOTP secret for a user,
+// replacing any earlier unconfirmed one. It fails with ErrMFAAlreadyEnabled
+// once a secret has been confirmed.
+func (r *Repository) StartTOTPEnrollment(ctx context.Context, userID int32, secret string) error {
+	rows, err := r.queries.StartTOTPEnrollment(ctx, db.StartTOTPEnrollmentParams{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start totp enrollment: %w", err)
+	}
+	if rows == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// GetTOTP returns the TOTP secret of a user, confirmed or not
+func (r *Repository) GetTOTP(ctx context.Context, userID int32) (*db.UserTotp, error) {
+	t, err := r.queries.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	return &t, nil
+}
+
+// TOTPEnabled reports whether a user has a confirmed TOTP secret
+func (r *Repository) TOTPEnabled(ctx context.Context, userID int32) (bool, error) {
+	t, err := r.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return t.ConfirmedAt.Valid, nil
+}
+
+// ConfirmTOTP enables a user's pending TOTP secret. step is the time step of
+// the code that confirmed it, which cannot be used again.
+func (r *Repository) ConfirmTOTP(ctx context.Context, userID int32, step int64) error {
+	rows, err := r.queries.ConfirmTOTP(ctx, db.ConfirmTOTPParams{
+		UserID:       userID,
+		ConfirmedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to confirm totp secret: %w", err)
+	}
+	if rows == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// UseTOTPStep records that a code of the given time step was accepted. It
+// fails with ErrInvalidMFACode if a code of that or a later step was already
+// accepted, so that every code works only once.
+func (r *Repository) UseTOTPStep(ctx context.Context, userID int32, step int64) error {
+	rows, err := r.queries.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record totp use: %w", err)
+	}
+	if rows == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// DeleteTOTP disables two-factor authentication for a user, removing the
+// TOTP secret and all recovery codes
+func (r *Repository) DeleteTOTP(ctx context.Context, userID int32) error {
+	if err := r.queries.DeleteUserTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete totp secret: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes stores new recovery code hashes for a user,
+// invalidating the previous codes
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userID int32, codeHashes []string) error {
+	err := r.queries.ReplaceRecoveryCodes(ctx, db.ReplaceRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: codeHashes,
+		CreatedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode atomically marks an unused recovery code as used. It fails
+// with ErrInvalidMFACode if the user has no such unused code.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int32, codeHash string) error {
+	rows, err := r.queries.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: codeHash,
+		UsedAt:   pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if rows == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// GetIdentityUser returns the ID of the user an external identity is linked
+// to, or ErrIdentityNotLinked
+func (r *Repository) GetIdentityUser(ctx context.Context, provider, subject string) (int32, error) {
+	identity, err := r.queries.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  subject,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrIdentityNotLinked
+		}
+		return 0, fmt.Errorf("failed to get identity: %w", err)
+	}
+	return identity.UserID, nil
+}
+
+// LinkIdentity links an external identity to a user by the email its
+// provider verified, recording a login with it
+func (r *Repository) LinkIdentity(ctx context.Context, userID int32, provider, subject, email string) error {
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	err := r.queries.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+		UserID:      userID,
+		Provider:    provider,
+		Subject:     subject,
+		Email:       email,
+		LastLoginAt: now,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+// RecordIdentityLogin notes a login with an external identity and the email
+// the provider reported for it, along with whether the provider verified it
+func (r *Repository) RecordIdentityLogin(ctx context.Context, provider, subject, email string, emailVerified bool) error {
+	err := r.queries.UpdateUserIdentityLogin(ctx, db.UpdateUserIdentityLoginParams{
+		Provider:      provider,
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: emailVerified,
+		LastLoginAt:   pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record identity login: %w", err)
+	}
+	return nil
+}
+
+// revokeReusedFamily ends the session of a leaked refresh token family
+func (r *Repository) revokeReusedFamily(ctx context.Context, familyID pgtype.UUID) error {
+	if err := r.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		return err
+	}
+	err := r.queries.RevokeSessionByID(ctx, db.RevokeSessionByIDParams{
+		ID:        familyID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return ErrRefreshTokenReused
+}
+
+// newFamilyID returns a random (version 4) UUID for a new refresh token family
+func newFamilyID() (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if _, err := rand.Read(id.Bytes[:]); err != nil {
+		return id, fmt.Errorf("failed to generate family id: %w", err)
+	}
+	id.Bytes[6] = (id.Bytes[6] & 0x0f) | 0x40
+	id.Bytes[8] = (id.Bytes[8] & 0x3f) | 0x80
+	id.Valid = true
+	return id, nil
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-test-api/internal/auth/db"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RevocationList tracks revoked access tokens. Revocations are persisted in
+// Postgres so that every replica sees them, and mirrored in an in-process
+// cache so that validating a token never costs a database round trip.
+// Revocations made on another replica become visible after the next Sync.
+// Tokens are revoked individually, per user, or per session.
+type RevocationList struct {
+	queries       *db.Queries
+	tokenLifetime time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time // jti -> expiry of the revoked token
+	users    map[string]time.Time // user ID -> tokens issued before this are revoked
+	sessions map[string]time.Time // session ID -> when its revocation can be forgotten
+}
+
+// NewRevocationList creates a RevocationList. tokenLifetime is the longest an
+// access token can live, which bounds how long a revocation must be kept.
+func NewRevocationList(queries *db.Queries, tokenLifetime time.Duration) *RevocationList {
+	return &RevocationList{
+		queries:       queries,
+		tokenLifetime: tokenLifetime,
+		tokens:        make(map[string]time.Time),
+		users:         make(map[string]time.Time),
+		sessions:      make(map[string]time.Time),
+	}
+}
+
+// IsRevoked reports whether the token described by claims has been revoked,
+// either individually, with its session, or by a revocation of all of the
+// user's tokens (or, under impersonation, the administrator's)
+func (l *RevocationList) IsRevoked(claims *Claims) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.tokens[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+	if _, ok := l.sessions[claims.SessionID]; ok && claims.SessionID != "" {
+		return true
+	}
+	if l.revokedForUser(claims.UserID, claims) {
+		return true
+	}
+	// Impersonation tokens also end when the administrator logs out everywhere
+	if claims.IsImpersonated() && l.revokedForUser(claims.Actor.UserID, claims) {
+		return true
+	}
+	return false
+}
+
+// revokedForUser reports whether the token was issued before all of the
+// user's tokens were revoked. The caller must hold l.mu.
+func (l *RevocationList) revokedForUser(userID string, claims *Claims) bool {
+	before, ok := l.users[userID]
+	return ok && (claims.IssuedAt == nil || claims.IssuedAt.Before(before))
+}
+
+// RevokeToken revokes a single access token until it expires
+func (l *RevocationList) RevokeToken(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" {
+		return fmt.Errorf("token has no jti claim")
+	}
+	userID, err := strconv.ParseInt(claims.UserID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid user id %q: %w", claims.UserID, err)
+	}
+
+	expiresAt := time.Now().Add(l.tokenLifetime)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	err = l.queries.RevokeToken(ctx, db.RevokeTokenParams{
+		Jti:       claims.ID,
+		UserID:    int32(userID),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	l.mu.Lock()
+	l.tokens[claims.ID] = expiresAt
+	l.mu.Unlock()
+	return nil
+}
+
+// RevokeUser revokes every access token issued to the user before now
+func (l *RevocationList) RevokeUser(ctx context.Context, userID int32) error {
+	// JWT timestamps have second precision, so compare at that precision
+	// to keep tokens issued right after this call valid
+	return l.revokeUserBefore(ctx, userID, time.Now().Truncate(time.Second))
+}
+
+// RevokeDeletedUser revokes every access token issued to a user who is being
+// deleted, including those issued within the current second, which
+// RevokeUser leaves valid. No more can be issued to them.
+func (l *RevocationList) RevokeDeletedUser(ctx context.Context, userID int32) error {
+	return l.revokeUserBefore(ctx, userID, time.Now().Truncate(time.Second).Add(time.Second))
+}
+
+// revokeUserBefore revokes the user's access tokens issued before
+// revokedBefore
+func (l *RevocationList) revokeUserBefore(ctx context.Context, userID int32, revokedBefore time.Time) error {
+	expiresAt := revokedBefore.Add(l.tokenLifetime)
+
+	err := l.queries.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		UserID:        userID,
+		RevokedBefore: pgtype.Timestamptz{Time: revokedBefore, Valid: true},
+		ExpiresAt:     pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	l.mu.Lock()
+	l.users[strconv.Itoa(int(userID))] = revokedBefore
+	l.mu.Unlock()
+	return nil
+}
+
+// RevokeSession revokes a session of the user and with it every access token
+// issued for it. It returns ErrSessionNotFound if the user has no such active
+// session. The session's refresh tokens are left to the caller.
+func (l *RevocationList) RevokeSession(ctx context.Context, userID int32, sessionID pgtype.UUID) error {
+	now := time.Now()
+	rows, err := l.queries.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:        sessionID,
+		UserID:    userID,
+		RevokedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if rows == 0 {
+		return ErrSessionNotFound
+	}
+
+	l.mu.Lock()
+	l.sessions[sessionID.String()] = now.Add(l.tokenLifetime)
+	l.mu.Unlock()
+	return nil
+}
+
+// Sync replaces the cache with the active revocations stored in the database
+func (l *RevocationList) Sync(ctx context.Context) error {
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	revokedTokens, err := l.queries.ListActiveRevokedTokens(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list revoked tokens: %w", err)
+	}
+	userRevocations, err := l.queries.ListActiveUserTokenRevocations(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list user token revocations: %w", err)
+	}
+	// Tokens of sessions revoked longer ago than a token lives have expired
+	revokedSessions, err := l.queries.ListRevokedSessions(ctx, pgtype.Timestamptz{Time: now.Time.Add(-l.tokenLifetime), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to list revoked sessions: %w", err)
+	}
+
+	tokens := make(map[string]time.Time, len(revokedTokens))
+	for _, t := range revokedTokens {
+		tokens[t.Jti] = t.ExpiresAt.Time
+	}
+	users := make(map[string]time.Time, len(userRevocations))
+	for _, u := range userRevocations {
+		users[strconv.Itoa(int(u.UserID))] = u.RevokedBefore.Time
+	}
+
+	sessions := make(map[string]time.Time, len(revokedSessions))
+	for _, rs := range revokedSessions {
+		sessions[rs.ID.String()] = rs.RevokedAt.Time.Add(l.tokenLifetime)
+	}
+
+	l.mu.Lock()
+	l.tokens = tokens
+	l.users = users
+	l.sessions = sessions
+	l.mu.Unlock()
+	return nil
+}
+
+// Purge deletes revocations whose tokens have expired anyway
+func (l *RevocationList) Purge(ctx context.Context) error {
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	tokens, err := l.queries.DeleteExpiredRevokedTokens(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to purge revoked tokens: %w", err)
+	}
+	users, err := l.queries.DeleteExpiredUserTokenRevocations(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to purge user token revocations: %w", err)
+	}
+
+	if tokens > 0 || users > 0 {
+		slog.Info("Purged expired token revocations", "tokens", tokens, "users", users)
+	}
+	return nil
+}
+
+// Run purges expired revocations and refreshes the cache every interval
+// until ctx is cancelled
+func (l *RevocationList) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Purge(ctx); err != nil {
+				slog.Error("Failed to purge token revocations", "error", err)
+			}
+			if err := l.Sync(ctx); err != nil {
+				slog.Error("Failed to sync token revocations", "error", err)
+			}
+		}
+	}
+}
@@ -1,64 +1,231 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"go-test-api/internal/audit"
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/password"
+
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token has expired")
+	ErrRevokedToken = errors.New("token has been revoked")
 )
 
-// Claims represents the JWT claims
+// Claims represents the JWT claims. Every token carries a unique ID in the
+// registered jti claim so that it can be revoked individually. Roles and
+// permissions are captured when the token is issued, so changes to them
+// take effect on the next refresh.
 type Claims struct {
+	UserID        string   `json:"user_id"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles,omitempty"`
+	Permissions   []string `json:"permissions,omitempty"`
+	// TenantID is the tenant the user belongs to. Requests made with the
+	// token only see that tenant's users and addresses.
+	TenantID string `json:"tid,omitempty"`
+	// TokenUse marks tokens that are not access tokens, such as MFA
+	// challenges. Access tokens leave it empty.
+	TokenUse string `json:"token_use,omitempty"`
+	// SessionID names the session an access token was issued for, so that
+	// revoking the session revokes the token
+	SessionID string `json:"sid,omitempty"`
+	// AuthMethod is how the first factor of an MFA challenge was supplied,
+	// which the session started by the challenge records
+	AuthMethod string `json:"auth_method,omitempty"`
+	// Actor is set on tokens an administrator obtained to impersonate the
+	// user, after the act claim of RFC 8693. UserID is then the impersonated
+	// user.
+	Actor *Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+
+	// APIKeyID is set instead of the registered claims when the request was
+	// authenticated with an API key rather than a token
+	APIKeyID int32 `json:"-"`
+}
+
+// Actor identifies the administrator behind an impersonation token
+type Actor struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
-	jwt.RegisteredClaims
+}
+
+// IsImpersonated reports whether the token was issued to impersonate the user
+func (c *Claims) IsImpersonated() bool {
+	return c.Actor != nil
+}
+
+// IsAPIKey reports whether the claims come from an API key
+func (c *Claims) IsAPIKey() bool {
+	return c.APIKeyID != 0
+}
+
+// HasPermission reports whether the token grants the permission
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+// Identity describes the user a token is issued to
+type Identity struct {
+	UserID        string
+	Email         string
+	EmailVerified bool
+	Roles         []string
+	Permissions   []string
+	TenantID      string
+	SessionID     string
+	// Actor is set when an administrator impersonates the user
+	Actor *Actor
+}
+
+// ServiceConfig holds auth service configuration
+type ServiceConfig struct {
+	// Keys signs and verifies access tokens. If nil, tokens are signed
+	// with JWTSecret using HS256.
+	Keys      *KeySet
+	JWTSecret string
+	// JWTExpiry is the lifetime of access tokens
+	JWTExpiry time.Duration
+	// RefreshTokenExpiry is the lifetime of refresh tokens
+	RefreshTokenExpiry time.Duration
+	// PasswordResetExpiry is the lifetime of password reset links
+	PasswordResetExpiry time.Duration
+	// EmailVerificationExpiry is the lifetime of email verification links
+	EmailVerificationExpiry time.Duration
+	// MagicLinkExpiry is the lifetime of magic login links
+	MagicLinkExpiry time.Duration
+	// RequireVerifiedEmail makes Middleware reject users who have not
+	// verified their email address yet
+	RequireVerifiedEmail bool
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string
+	// Passwords hashes and verifies passwords. If nil, password.Default()
+	// is used.
+	Passwords *password.Hasher
+	// Sessions records when sessions were last seen. If nil, it is not
+	// recorded.
+	Sessions *SessionTracker
+	// Audit records impersonations. If nil, they are not recorded.
+	Audit *audit.Log
 }
 
 // Service handles authentication logic
 type Service struct {
-	jwtSecret []byte
-	jwtExpiry time.Duration
+	keys          *KeySet
+	jwtExpiry     time.Duration
+	refreshExpiry time.Duration
+	resetExpiry   time.Duration
+	verifyExpiry  time.Duration
+	magicExpiry   time.Duration
+	revocations   *RevocationList
+	repo          *Repository
+	passwords     *password.Hasher
+	sessions      *SessionTracker
+	audit         *audit.Log
+
+	requireVerifiedEmail bool
+	totpIssuer           string
 }
 
-// NewService creates a new auth service
-func NewService(secret string, expiry time.Duration) *Service {
+// NewService creates a new auth service. Tokens are checked against
+// revocations when validated; API keys are looked up in repo.
+func NewService(cfg ServiceConfig, revocations *RevocationList, repo *Repository) *Service {
+	keys := cfg.Keys
+	if keys == nil {
+		keys = NewHMACKeySet(cfg.JWTSecret)
+	}
+	passwords := cfg.Passwords
+	if passwords == nil {
+		passwords = password.Default()
+	}
 	return &Service{
-		jwtSecret: []byte(secret),
-		jwtExpiry: expiry,
+		keys:          keys,
+		jwtExpiry:     cfg.JWTExpiry,
+		refreshExpiry: cfg.RefreshTokenExpiry,
+		resetExpiry:   cfg.PasswordResetExpiry,
+		verifyExpiry:  cfg.EmailVerificationExpiry,
+		magicExpiry:   cfg.MagicLinkExpiry,
+		revocations:   revocations,
+		repo:          repo,
+		passwords:     passwords,
+		sessions:      cfg.Sessions,
+		audit:         cfg.Audit,
+
+		requireVerifiedEmail: cfg.RequireVerifiedEmail,
+		totpIssuer:           cfg.TOTPIssuer,
 	}
 }
 
 // GenerateToken creates a new JWT token for a user
-func (s *Service) GenerateToken(userID, email string) (string, error) {
+func (s *Service) GenerateToken(identity Identity) (string, error) {
+	jti, err := opaque.New()
+	if err != nil {
+		return "", err
+	}
+
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:        identity.UserID,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Roles:         identity.Roles,
+		Permissions:   identity.Permissions,
+		TenantID:      identity.TenantID,
+		SessionID:     identity.SessionID,
+		Actor:         identity.Actor,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.jwtSecret)
+	return s.keys.sign(claims)
+}
+
+// GenerateRefreshToken creates a new opaque refresh token. The returned hash is
+// what gets persisted; the token itself is only ever handed to the client.
+func (s *Service) GenerateRefreshToken() (token, hash string, err error) {
+	token, err = opaque.New()
+	if err != nil {
+		return "", "", err
+	}
+	return token, opaque.Hash(token), nil
 }
 
-// ValidateToken verifies and parses a JWT token
+// ValidateToken verifies and parses an access token
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return s.jwtSecret, nil
-	})
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// parseToken verifies a JWT of any use and returns its claims
+func (s *Service) parseToken(tokenString string) (*Claims, error) {
+	// The key, and with it the signing method, is selected by the kid header
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keys.verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -74,19 +241,220 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, ErrExpiredToken
 	}
 
+	// Check revocation
+	if s.revocations != nil && s.revocations.IsRevoked(claims) {
+		return nil, ErrRevokedToken
+	}
+
 	return claims, nil
 }
 
-// HashPassword creates a bcrypt hash of the password
-func (s *Service) HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+const (
+	// mfaTokenUse is the token_use of MFA challenge tokens
+	mfaTokenUse = "mfa_challenge"
+	// mfaTokenExpiry is how long after the password check the second
+	// factor can be supplied
+	mfaTokenExpiry = 5 * time.Minute
+)
+
+// GenerateMFAToken creates a short-lived challenge token stating that the
+// user passed the first factor check with the given method. It is only
+// accepted by ValidateMFAToken, never as an access token.
+func (s *Service) GenerateMFAToken(userID, method string) (string, time.Time, error) {
+	jti, err := opaque.New()
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(mfaTokenExpiry)
+	claims := &Claims{
+		UserID:     userID,
+		TokenUse:   mfaTokenUse,
+		AuthMethod: method,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := s.keys.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ValidateMFAToken verifies and parses an MFA challenge token
+func (s *Service) ValidateMFAToken(tokenString string) (*Claims, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
 	}
-	return string(hash), nil
+	if claims.TokenUse != mfaTokenUse {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+// recoveryEncoding formats recovery codes, which users may have to type
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes creates a set of one-time recovery codes of the form
+// xxxx-xxxx-xxxx-xxxx, along with the hashes to persist
+func (s *Service) GenerateRecoveryCodes() (codes, hashes []string, err error) {
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode returns the stored form of a recovery code. Case, dashes
+// and spaces are ignored, so codes can be typed as printed or not.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return opaque.Hash(code)
+}
+
+// JWKS returns the public keys that access tokens can be verified with
+func (s *Service) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+// RevokeToken revokes a single access token
+func (s *Service) RevokeToken(ctx context.Context, claims *Claims) error {
+	return s.revocations.RevokeToken(ctx, claims)
+}
+
+// RevokeSessions revokes every access and refresh token issued to the user so
+// far. If ctx belongs to a request the user authenticated with a token, that
+// token is revoked explicitly, since the per-user cutoff has second precision
+// and may miss it.
+func (s *Service) RevokeSessions(ctx context.Context, userID int32) error {
+	if err := s.repo.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.revocations.RevokeUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	if claims := GetClaims(ctx); claims != nil && !claims.IsAPIKey() && claims.UserID == strconv.Itoa(int(userID)) {
+		return s.revocations.RevokeToken(ctx, claims)
+	}
+	return nil
+}
+
+// RevokeSession ends one session of the user: its access tokens are rejected
+// from now on and its refresh tokens revoked. It returns ErrSessionNotFound if
+// the user has no such active session.
+func (s *Service) RevokeSession(ctx context.Context, userID int32, sessionID pgtype.UUID) error {
+	if err := s.revocations.RevokeSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	return s.repo.RevokeRefreshTokenFamily(ctx, sessionID)
+}
+
+// HashPassword hashes a password with the configured algorithm
+func (s *Service) HashPassword(plain string) (string, error) {
+	return s.passwords.Hash(plain)
+}
+
+// CheckPassword verifies a password against its hash, whichever supported
+// algorithm made it
+func (s *Service) CheckPassword(hashedPassword, plain string) error {
+	return s.passwords.Verify(hashedPassword, plain)
 }
 
-// CheckPassword verifies a password against its hash
-func (s *Service) CheckPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+// PasswordNeedsRehash reports whether a password hash was made with an
+// outdated algorithm or parameters
+func (s *Service) PasswordNeedsRehash(hashedPassword string) bool {
+	return s.passwords.NeedsRehash(hashedPassword)
+}
+
+const (
+	// apiKeyTag starts every API key so that leaked keys are easy to spot
+	apiKeyTag = "gta"
+	// apiKeyTouchInterval limits how often the last use of a key is written
+	apiKeyTouchInterval = time.Minute
+)
+
+// GenerateAPIKey creates a new API key of the form gta_<prefix>_<secret>. The
+// prefix identifies the key and is stored as is; only the hash of the secret
+// is persisted.
+func (s *Service) GenerateAPIKey() (key, prefix, secretHash string, err error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key prefix: %w", err)
+	}
+	prefix = hex.EncodeToString(b)
+
+	secret, err := opaque.New()
+	if err != nil {
+		return "", "", "", err
+	}
+	return apiKeyTag + "_" + prefix + "_" + secret, prefix, opaque.Hash(secret), nil
+}
+
+// ValidateAPIKey authenticates an API key and returns claims describing its
+// owner. The claims grant the key's scopes, limited to the permissions the
+// owner currently holds.
+func (s *Service) ValidateAPIKey(ctx context.Context, key string) (*Claims, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, secret := parts[1], parts[2]
+
+	stored, err := s.repo.GetAPIKeyForAuth(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(opaque.Hash(secret)), []byte(stored.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if stored.ExpiresAt.Valid && stored.ExpiresAt.Time.Before(time.Now()) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	access, err := s.repo.GetUserAccess(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var granted []string
+	for _, scope := range stored.Scopes {
+		if slices.Contains(access.Permissions, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	// Last use is informational, so it is only recorded once in a while
+	// and a failure to record it does not fail the request
+	if !stored.LastUsedAt.Valid || time.Since(stored.LastUsedAt.Time) > apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, stored.ID); err != nil {
+			slog.Warn("Failed to record api key use", "api_key_id", stored.ID, "error", err)
+		}
+	}
+
+	return &Claims{
+		UserID:        strconv.Itoa(int(stored.UserID)),
+		Email:         stored.Email,
+		EmailVerified: stored.EmailVerifiedAt.Valid,
+		Roles:         access.Roles,
+		Permissions:   granted,
+		TenantID:      access.TenantID,
+		APIKeyID:      stored.ID,
+	}, nil
 }
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-test-api/internal/auth/db"
+	"go-test-api/pkg/response"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// How a session was logged in to
+const (
+	sessionMethodPassword     = "password"
+	sessionMethodRegistration = "registration"
+	sessionMethodMagicLink    = "magic_link"
+	sessionMethodOIDC         = "oidc:" // followed by the provider name
+)
+
+// maxUserAgentLength bounds the user agent stored for a session
+const maxUserAgentLength = 512
+
+// SessionTracker records when sessions were last seen. Requests only note
+// the time in memory; the notes are written to the database in one batch per
+// Run interval, so that authenticating a request never waits on a write.
+type SessionTracker struct {
+	queries *db.Queries
+
+	mu   sync.Mutex
+	seen map[string]time.Time // session ID -> last request
+}
+
+// NewSessionTracker creates a SessionTracker
+func NewSessionTracker(queries *db.Queries) *SessionTracker {
+	return &SessionTracker{queries: queries, seen: make(map[string]time.Time)}
+}
+
+// Touch notes a request made with a token of the session. It is a no-op on
+// a nil tracker.
+func (t *SessionTracker) Touch(sessionID string) {
+	if t == nil || sessionID == "" {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	t.seen[sessionID] = now
+	t.mu.Unlock()
+}
+
+// LastSeen returns the last request noted for the session that has not been
+// written yet, if any
+func (t *SessionTracker) LastSeen(sessionID string) (time.Time, bool) {
+	if t == nil {
+		return time.Time{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen, ok := t.seen[sessionID]
+	return seen, ok
+}
+
+// Flush writes the noted requests to the database
+func (t *SessionTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	seen := t.seen
+	t.seen = make(map[string]time.Time, len(seen))
+	t.mu.Unlock()
+	if len(seen) == 0 {
+		return nil
+	}
+
+	ids := make([]pgtype.UUID, 0, len(seen))
+	lastSeen := make([]pgtype.Timestamptz, 0, len(seen))
+	for sessionID, at := range seen {
+		var id pgtype.UUID
+		if err := id.Scan(sessionID); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		lastSeen = append(lastSeen, pgtype.Timestamptz{Time: at, Valid: true})
+	}
+
+	err := t.queries.TouchSessions(ctx, db.TouchSessionsParams{Ids: ids, LastSeen: lastSeen})
+	if err != nil {
+		return fmt.Errorf("failed to record session activity: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes sessions whose last refresh token has expired. Every access
+// token issued for them has expired by then too.
+func (t *SessionTracker) Purge(ctx context.Context) error {
+	deleted, err := t.queries.DeleteExpiredSessions(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to purge sessions: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("Purged expired sessions", "sessions", deleted)
+	}
+	return nil
+}
+
+// Run writes noted requests and purges expired sessions every interval until
+// ctx is cancelled
+func (t *SessionTracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				slog.Error("Failed to flush session activity", "error", err)
+			}
+			if err := t.Purge(ctx); err != nil {
+				slog.Error("Failed to purge sessions", "error", err)
+			}
+		}
+	}
+}
+
+// ListSessions handles GET /auth/sessions
+// @Summary List sessions
+// @Description List the devices the current user is logged in on. Each login is a session, which lasts as long as its tokens keep being refreshed; the session of the token used for this request is marked current.
+// @Tags auth
+// @Produce json
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/sessions [get]
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, claims, ok := h.interactiveUser(w, r, "API keys cannot manage sessions")
+	if !ok {
+		return
+	}
+
+	sessions, err := h.repo.ListSessions(r.Context(), userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list sessions")
+		return
+	}
+
+	res := make([]SessionResponse, len(sessions))
+	for i := range sessions {
+		res[i] = h.toSessionResponse(&sessions[i], claims.SessionID)
+	}
+	response.JSON(w, http.StatusOK, res)
+}
+
+// DeleteSession handles DELETE /auth/sessions/{id}
+// @Summary Revoke a session
+// @Description Log the current user out of one session: its refresh tokens are revoked and its access tokens are rejected from now on. Revoking the current session logs out this client.
+// @Tags auth
+// @Param id path string true "Session ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/sessions/{id} [delete]
+func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.interactiveUser(w, r, "API keys cannot manage sessions")
+	if !ok {
+		return
+	}
+	var sessionID pgtype.UUID
+	if err := sessionID.Scan(r.PathValue("id")); err != nil {
+		response.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			response.Error(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession records a new login of the user and issues its first tokens
+func (h *Handler) startSession(r *http.Request, u User, userID int32, method string, mfa bool) (*TokenResponse, error) {
+	sessionID, err := newFamilyID()
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := storedUserAgent(r.UserAgent())
+	expiresAt := time.Now().Add(h.authService.refreshExpiry)
+	if err := h.repo.CreateSession(r.Context(), sessionID, userID, method, mfa, userAgent, h.throttle.ClientIP(r), expiresAt); err != nil {
+		return nil, err
+	}
+
+	return h.issueTokens(r.Context(), u, sessionID)
+}
+
+// storedUserAgent shortens a user agent to at most maxUserAgentLength bytes.
+// Postgres refuses text that is not valid UTF-8, so a character split by the
+// cut is dropped, as are invalid bytes sent by the client.
+func storedUserAgent(userAgent string) string {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return strings.ToValidUTF8(userAgent, "")
+}
+
+func (h *Handler) toSessionResponse(s *db.Session, currentID string) SessionResponse {
+	id := s.ID.String()
+	lastSeen := s.LastSeenAt.Time
+	if pending, ok := h.authService.sessions.LastSeen(id); ok && pending.After(lastSeen) {
+		lastSeen = pending
+	}
+	return SessionResponse{
+		ID:         id,
+		Method:     s.Method,
+		MFA:        s.Mfa,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IpAddress,
+		CreatedAt:  s.CreatedAt.Time,
+		LastSeenAt: lastSeen,
+		ExpiresAt:  s.ExpiresAt.Time,
+		Current:    id == currentID,
+	}
+}
@@ -0,0 +1,97 @@
+//go:build unit
+
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestValidateToken_RevokedSession(t *testing.T) {
+	revocations := NewRevocationList(nil, time.Minute)
+	service := NewService(ServiceConfig{JWTSecret: "test-secret", JWTExpiry: time.Minute}, revocations, nil)
+
+	revoked, err := service.GenerateToken(Identity{UserID: "1", SessionID: "revoked-session"})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	active, err := service.GenerateToken(Identity{UserID: "1", SessionID: "active-session"})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	revocations.sessions["revoked-session"] = time.Now().Add(time.Minute)
+
+	if _, err := service.ValidateToken(revoked); err == nil {
+		t.Error("expected token of a revoked session to be rejected")
+	}
+	claims, err := service.ValidateToken(active)
+	if err != nil {
+		t.Fatalf("expected token of an active session to be accepted, got %v", err)
+	}
+	if claims.SessionID != "active-session" {
+		t.Errorf("expected session ID active-session, got %q", claims.SessionID)
+	}
+}
+
+func TestSessionTracker_Touch(t *testing.T) {
+	tracker := NewSessionTracker(nil)
+
+	if _, ok := tracker.LastSeen("session"); ok {
+		t.Error("expected no activity before the session is touched")
+	}
+
+	before := time.Now()
+	tracker.Touch("session")
+	tracker.Touch("")
+	seen, ok := tracker.LastSeen("session")
+	if !ok {
+		t.Fatal("expected activity after the session is touched")
+	}
+	if seen.Before(before) {
+		t.Errorf("expected last seen at or after %v, got %v", before, seen)
+	}
+	if _, ok := tracker.LastSeen(""); ok {
+		t.Error("expected tokens without a session to be ignored")
+	}
+
+	// Services built without a tracker, such as in tests, touch a nil one
+	var none *SessionTracker
+	none.Touch("session")
+	if _, ok := none.LastSeen("session"); ok {
+		t.Error("expected a nil tracker to report no activity")
+	}
+}
+
+func TestStoredUserAgent(t *testing.T) {
+	// 512 is no multiple of 3, so the cut splits a character
+	long := strings.Repeat("日本語", 200)
+
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{name: "short", userAgent: "Mozilla/5.0", expected: "Mozilla/5.0"},
+		{name: "invalid bytes", userAgent: "Mozilla\xff/5.0", expected: "Mozilla/5.0"},
+		{name: "long non-ASCII", userAgent: long},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := storedUserAgent(tt.userAgent)
+			if !utf8.ValidString(got) || len(got) > maxUserAgentLength {
+				t.Fatalf("expected at most %d bytes of valid UTF-8, got %d bytes: %q", maxUserAgentLength, len(got), got)
+			}
+			if tt.expected != "" && got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+			// Only the character split by the cut is lost
+			if tt.expected == "" && (!strings.HasPrefix(tt.userAgent, got) || len(got) < maxUserAgentLength-utf8.UTFMax) {
+				t.Errorf("expected a prefix of nearly %d bytes, got %d bytes", maxUserAgentLength, len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-test-api/internal/audit"
+	"go-test-api/internal/auth/db"
+	"go-test-api/internal/opaque"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrLoginThrottled     = errors.New("too many failed login attempts")
+	ErrAccountLocked      = errors.New("account temporarily locked")
+	ErrInvalidUnlockToken = errors.New("invalid unlock token")
+)
+
+// maxLoginDelay caps the delay enforced between login attempts for an email
+const maxLoginDelay = time.Minute
+
+// ThrottleConfig holds the limits on failed logins
+type ThrottleConfig struct {
+	// MaxFailures is how many failed logins for an email within Window
+	// lock it for LockoutDuration
+	MaxFailures int
+	// IPMaxFailures is how many failed logins from one client IP within
+	// Window are allowed before further attempts from it are refused
+	IPMaxFailures   int
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// ClientIPHeader names a header set by a trusted reverse proxy with the
+	// client IP, such as X-Forwarded-For. If empty, the remote address of
+	// the connection is used.
+	ClientIPHeader string
+}
+
+// LoginThrottle limits password guessing. Failed logins are counted per
+// email and per client IP: each failure for an email doubles the wait before
+// the next attempt, MaxFailures lock the email temporarily, and an IP that
+// fails too often is refused altogether. State is kept in Postgres so the
+// limits hold across replicas.
+type LoginThrottle struct {
+	cfg     ThrottleConfig
+	pool    *pgxpool.Pool
+	queries *db.Queries
+	audit   *audit.Log
+}
+
+// LoginAttempt is a login attempt admitted by LoginThrottle.Begin. It counts
+// as failed from the moment it is admitted, so that concurrent attempts are
+// held to the limits too, until it is abandoned or the login succeeds.
+type LoginAttempt struct {
+	throttle *LoginThrottle
+	id       int64
+	email    string
+	ip       string
+	failed   bool
+}
+
+// Lockout describes a lockout that was just imposed
+type Lockout struct {
+	// UnlockToken lifts the lockout early; only its hash is stored
+	UnlockToken string
+	Until       time.Time
+}
+
+// NewLoginThrottle creates a new LoginThrottle. Lockouts are recorded in
+// auditLog.
+func NewLoginThrottle(cfg ThrottleConfig, pool *pgxpool.Pool, auditLog *audit.Log) *LoginThrottle {
+	return &LoginThrottle{cfg: cfg, pool: pool, queries: db.New(pool), audit: auditLog}
+}
+
+// ClientIP returns the address login attempts in r are counted under
+func (t *LoginThrottle) ClientIP(r *http.Request) string {
+	if t.cfg.ClientIPHeader != "" {
+		// Proxies append to the header, so the last entry is the one added
+		// by the trusted proxy; earlier ones are supplied by the client
+		if values := r.Header.Values(t.cfg.ClientIPHeader); len(values) > 0 {
+			entries := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Begin admits a login attempt for email from ip, or returns ErrAccountLocked
+// or ErrLoginThrottled, along with how long to wait, if it must be refused.
+// The limits are checked and the attempt recorded under a lock on the email
+// and the IP, so that attempts made in parallel count against each other.
+func (t *LoginThrottle) Begin(ctx context.Context, email, ip string) (*LoginAttempt, time.Duration, error) {
+	now := time.Now()
+	email = normalizeEmail(email)
+
+	tx, err := t.pool.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := t.queries.WithTx(tx)
+
+	// Every transaction takes the email's lock before the IP's, so they
+	// cannot deadlock
+	if err := q.LockLoginThrottle(ctx, "email:"+email); err != nil {
+		return nil, 0, fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+	if err := q.LockLoginThrottle(ctx, "ip:"+ip); err != nil {
+		return nil, 0, fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+
+	if wait, err := t.check(ctx, q, email, ip, now); err != nil {
+		return nil, wait, err
+	}
+
+	id, err := q.RecordFailedLogin(ctx, db.RecordFailedLoginParams{
+		Email:     email,
+		IpAddress: ip,
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &LoginAttempt{throttle: t, id: id, email: email, ip: ip}, 0, nil
+}
+
+// check returns ErrAccountLocked or ErrLoginThrottled, along with how long to
+// wait, if a login attempt for email from ip must be refused
+func (t *LoginThrottle) check(ctx context.Context, q *db.Queries, email, ip string, now time.Time) (time.Duration, error) {
+	since, locked, err := t.lockout(ctx, q, email, now)
+	if err != nil {
+		return 0, err
+	}
+	if locked > 0 {
+		return locked, ErrAccountLocked
+	}
+
+	ipFailures, err := q.GetIPLoginFailures(ctx, db.GetIPLoginFailuresParams{
+		IpAddress: ip,
+		CreatedAt: pgtype.Timestamptz{Time: now.Add(-t.cfg.Window), Valid: true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+	if ipFailures.Failures >= int64(t.cfg.IPMaxFailures) {
+		return ipFailures.FirstFailedAt.Time.Add(t.cfg.Window).Sub(now), ErrLoginThrottled
+	}
+
+	emailFailures, err := t.emailFailures(ctx, q, email, since)
+	if err != nil {
+		return 0, err
+	}
+	if emailFailures.Failures > 0 {
+		wait := emailFailures.LastFailedAt.Time.Add(loginDelay(emailFailures.Failures)).Sub(now)
+		if wait > 0 {
+			return wait, ErrLoginThrottled
+		}
+	}
+	return 0, nil
+}
+
+// Fail counts the attempt as failed for good. userID is the account the
+// email belongs to, or 0 if there is none. If the failure locks the email,
+// the lockout is audited and returned so that the owner can be told.
+func (a *LoginAttempt) Fail(ctx context.Context, userID int32) (*Lockout, error) {
+	a.failed = true
+	return a.throttle.lockIfExceeded(ctx, a.email, a.ip, userID)
+}
+
+// Abandon withdraws an attempt that neither failed nor completed a login, such
+// as one whose password was right but that still awaits a second factor. It
+// does nothing once the attempt failed.
+func (a *LoginAttempt) Abandon(ctx context.Context) error {
+	if a.failed {
+		return nil
+	}
+	if err := a.throttle.queries.DeleteFailedLogin(ctx, a.id); err != nil {
+		return fmt.Errorf("failed to withdraw login attempt: %w", err)
+	}
+	return nil
+}
+
+// lockIfExceeded locks email if it has failed MaxFailures times since its
+// last lockout. The email's lock is held meanwhile, so that failures
+// recorded in parallel impose a single lockout.
+func (t *LoginThrottle) lockIfExceeded(ctx context.Context, email, ip string, userID int32) (*Lockout, error) {
+	now := time.Now()
+
+	tx, err := t.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := t.queries.WithTx(tx)
+	if err := q.LockLoginThrottle(ctx, "email:"+email); err != nil {
+		return nil, fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+
+	since, locked, err := t.lockout(ctx, q, email, now)
+	if err != nil {
+		return nil, err
+	}
+	// A failure made in parallel may have locked the email already
+	if locked > 0 {
+		return nil, nil
+	}
+	failures, err := t.emailFailures(ctx, q, email, since)
+	if err != nil {
+		return nil, err
+	}
+	if failures.Failures < int64(t.cfg.MaxFailures) {
+		return nil, nil
+	}
+
+	token, err := opaque.New()
+	if err != nil {
+		return nil, err
+	}
+	until := now.Add(t.cfg.LockoutDuration)
+	err = q.CreateLoginLockout(ctx, db.CreateLoginLockoutParams{
+		Email:           email,
+		LockedUntil:     pgtype.Timestamptz{Time: until, Valid: true},
+		UnlockTokenHash: opaque.Hash(token),
+		CreatedAt:       pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create login lockout: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// The lockout is in force either way, so a failure to audit it is only logged
+	err = t.audit.Record(ctx, audit.Event{
+		Type:   audit.EventAccountLocked,
+		UserID: userID,
+		Email:  email,
+		IP:     ip,
+		Details: map[string]interface{}{
+			"failures":     failures.Failures,
+			"locked_until": until,
+		},
+	})
+	if err != nil {
+		slog.Error("Failed to audit account lockout", "email", email, "error", err)
+	}
+
+	return &Lockout{UnlockToken: token, Until: until}, nil
+}
+
+// RecordSuccess forgets the failed logins for an email after a successful
+// login
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	if err := t.queries.ClearFailedLogins(ctx, normalizeEmail(email)); err != nil {
+		return fmt.Errorf("failed to clear failed logins: %w", err)
+	}
+	return nil
+}
+
+// UnlockWithToken lifts the lockout an unlock link was issued for and returns
+// the email it applied to
+func (t *LoginThrottle) UnlockWithToken(ctx context.Context, token, ip string) (string, error) {
+	email, err := t.queries.UnlockLoginLockoutByToken(ctx, db.UnlockLoginLockoutByTokenParams{
+		UnlockTokenHash: opaque.Hash(token),
+		UnlockedAt:      pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrInvalidUnlockToken
+		}
+		return "", fmt.Errorf("failed to unlock login: %w", err)
+	}
+
+	err = t.audit.Record(ctx, audit.Event{
+		Type:    audit.EventAccountUnlocked,
+		Email:   email,
+		IP:      ip,
+		Details: map[string]interface{}{"method": "email"},
+	})
+	if err != nil {
+		slog.Error("Failed to audit account unlock", "email", email, "error", err)
+	}
+	return email, nil
+}
+
+// Unlock lifts any lockout of a user's email on behalf of actorID
+func (t *LoginThrottle) Unlock(ctx context.Context, userID int32, email string, actorID int32, ip string) error {
+	email = normalizeEmail(email)
+	rows, err := t.queries.UnlockLoginLockouts(ctx, db.UnlockLoginLockoutsParams{
+		Email:      email,
+		UnlockedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unlock login: %w", err)
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	return t.audit.Record(ctx, audit.Event{
+		Type:    audit.EventAccountUnlocked,
+		UserID:  userID,
+		ActorID: actorID,
+		Email:   email,
+		IP:      ip,
+		Details: map[string]interface{}{"method": "admin"},
+	})
+}
+
+// Purge deletes failed logins and lockouts that no longer affect any limit
+func (t *LoginThrottle) Purge(ctx context.Context) error {
+	before := pgtype.Timestamptz{Time: time.Now().Add(-t.cfg.Window), Valid: true}
+
+	attempts, err := t.queries.DeleteFailedLoginsBefore(ctx, before)
+	if err != nil {
+		return fmt.Errorf("failed to purge failed logins: %w", err)
+	}
+	lockouts, err := t.queries.DeleteEndedLoginLockouts(ctx, before)
+	if err != nil {
+		return fmt.Errorf("failed to purge login lockouts: %w", err)
+	}
+
+	if attempts > 0 || lockouts > 0 {
+		slog.Info("Purged login throttling state", "failed_logins", attempts, "lockouts", lockouts)
+	}
+	return nil
+}
+
+// Run purges stale state every interval until ctx is cancelled
+func (t *LoginThrottle) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Purge(ctx); err != nil {
+				slog.Error("Failed to purge login throttling state", "error", err)
+			}
+		}
+	}
+}
+
+// lockout returns how long an email stays locked, and since when its failed
+// logins count: the start of the window, or the end of its last lockout if
+// that is later, so that every lockout starts the count afresh
+func (t *LoginThrottle) lockout(ctx context.Context, q *db.Queries, email string, now time.Time) (time.Time, time.Duration, error) {
+	since := now.Add(-t.cfg.Window)
+
+	last, err := q.GetLatestLoginLockout(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return since, 0, nil
+		}
+		return time.Time{}, 0, fmt.Errorf("failed to get login lockout: %w", err)
+	}
+
+	end := last.LockedUntil.Time
+	if last.UnlockedAt.Valid && last.UnlockedAt.Time.Before(end) {
+		end = last.UnlockedAt.Time
+	}
+	if end.After(now) {
+		return since, end.Sub(now), nil
+	}
+	if end.After(since) {
+		since = end
+	}
+	return since, 0, nil
+}
+
+func (t *LoginThrottle) emailFailures(ctx context.Context, q *db.Queries, email string, since time.Time) (*db.GetEmailLoginFailuresRow, error) {
+	failures, err := q.GetEmailLoginFailures(ctx, db.GetEmailLoginFailuresParams{
+		Email:     email,
+		CreatedAt: pgtype.Timestamptz{Time: since, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+	return &failures, nil
+}
+
+// loginDelay is how long to wait after the given number of consecutive failed
+// logins for an email: one second after the first, doubling with each further
+// failure up to maxLoginDelay
+func loginDelay(failures int64) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures > 7 {
+		return maxLoginDelay
+	}
+	return min(time.Second<<(failures-1), maxLoginDelay)
+}
+
+// normalizeEmail returns the form of an email address failures are counted
+// under, so that changing its case does not reset the count
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
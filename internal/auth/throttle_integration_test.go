@@ -0,0 +1,181 @@
+//go:build integration
+// +build integration
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go-test-api/internal/audit"
+	auditdb "go-test-api/internal/audit/db"
+	"go-test-api/internal/config"
+	"go-test-api/internal/database"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var testDB *pgxpool.Pool
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	testDB, err = database.New(ctx, cfg.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	testDB.Close()
+	os.Exit(code)
+}
+
+// setupThrottle empties the throttling tables and returns a LoginThrottle
+// locking an email after 3 failures and refusing an IP after 5
+func setupThrottle(t *testing.T) *LoginThrottle {
+	t.Helper()
+	_, err := testDB.Exec(context.Background(), "TRUNCATE failed_login_attempts, login_lockouts, audit_events RESTART IDENTITY")
+	if err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	return NewLoginThrottle(ThrottleConfig{
+		MaxFailures:     3,
+		IPMaxFailures:   5,
+		Window:          15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+	}, testDB, audit.NewLog(auditdb.New(testDB)))
+}
+
+// beginInParallel starts n login attempts at once, for the email and IP
+// returned by target, and returns those admitted
+func beginInParallel(t *testing.T, throttle *LoginThrottle, n int, target func(i int) (string, string)) []*LoginAttempt {
+	t.Helper()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		admitted []*LoginAttempt
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			email, ip := target(i)
+			attempt, _, err := throttle.Begin(context.Background(), email, ip)
+			if err != nil {
+				if !errors.Is(err, ErrLoginThrottled) {
+					t.Errorf("Expected ErrLoginThrottled, got %v", err)
+				}
+				return
+			}
+			mu.Lock()
+			admitted = append(admitted, attempt)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return admitted
+}
+
+func TestLoginThrottle_ParallelAttempts_Integration(t *testing.T) {
+	t.Run("one email", func(t *testing.T) {
+		throttle := setupThrottle(t)
+		admitted := beginInParallel(t, throttle, 10, func(i int) (string, string) {
+			return "alice@example.com", fmt.Sprintf("192.0.2.%d", i)
+		})
+
+		// The first attempt imposes a delay on every other
+		if len(admitted) != 1 {
+			t.Errorf("Expected 1 attempt to be admitted, got %d", len(admitted))
+		}
+	})
+
+	t.Run("one IP", func(t *testing.T) {
+		throttle := setupThrottle(t)
+		admitted := beginInParallel(t, throttle, 20, func(i int) (string, string) {
+			return fmt.Sprintf("user%d@example.com", i), "192.0.2.1"
+		})
+
+		if len(admitted) != 5 {
+			t.Errorf("Expected the IP's 5 attempts to be admitted, got %d", len(admitted))
+		}
+	})
+
+	t.Run("abandoned attempts", func(t *testing.T) {
+		throttle := setupThrottle(t)
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			attempt, _, err := throttle.Begin(ctx, "alice@example.com", "192.0.2.1")
+			if err != nil {
+				t.Fatalf("Expected attempt %d to be admitted, got %v", i+1, err)
+			}
+			if err := attempt.Abandon(ctx); err != nil {
+				t.Fatalf("Failed to abandon attempt: %v", err)
+			}
+		}
+	})
+}
+
+func TestLoginThrottle_ParallelFailures_Integration(t *testing.T) {
+	throttle := setupThrottle(t)
+	ctx := context.Background()
+	email := "alice@example.com"
+
+	// Failures admitted before the limits applied to each other, all ending
+	// at once
+	attempts := make([]*LoginAttempt, 5)
+	for i := range attempts {
+		var id int64
+		err := testDB.QueryRow(ctx, `INSERT INTO failed_login_attempts (email, ip_address, created_at)
+			VALUES ($1, $2, NOW()) RETURNING id`, email, fmt.Sprintf("192.0.2.%d", i)).Scan(&id)
+		if err != nil {
+			t.Fatalf("Failed to record attempt: %v", err)
+		}
+		attempts[i] = &LoginAttempt{throttle: throttle, id: id, email: email, ip: fmt.Sprintf("192.0.2.%d", i)}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		lockouts int
+	)
+	for _, attempt := range attempts {
+		wg.Add(1)
+		go func(attempt *LoginAttempt) {
+			defer wg.Done()
+			lockout, err := attempt.Fail(ctx, 1)
+			if err != nil {
+				t.Errorf("Failed to record failure: %v", err)
+				return
+			}
+			if lockout != nil {
+				mu.Lock()
+				lockouts++
+				mu.Unlock()
+			}
+		}(attempt)
+	}
+	wg.Wait()
+
+	// so only one unlock email goes out
+	var rows int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM login_lockouts WHERE email = $1", email).Scan(&rows); err != nil {
+		t.Fatalf("Failed to count lockouts: %v", err)
+	}
+	if lockouts != 1 || rows != 1 {
+		t.Errorf("Expected a single lockout, got %d returned and %d stored", lockouts, rows)
+	}
+	if _, _, err := throttle.Begin(ctx, email, "198.51.100.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Expected ErrAccountLocked, got %v", err)
+	}
+}
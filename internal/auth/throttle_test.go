@@ -0,0 +1,74 @@
+//go:build unit
+
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int64
+		expected time.Duration
+	}{
+		{failures: 0, expected: 0},
+		{failures: 1, expected: time.Second},
+		{failures: 2, expected: 2 * time.Second},
+		{failures: 4, expected: 8 * time.Second},
+		{failures: 7, expected: maxLoginDelay},
+		{failures: 100, expected: maxLoginDelay},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.expected {
+			t.Errorf("after %d failures: expected %v, got %v", tt.failures, tt.expected, got)
+		}
+	}
+}
+
+func TestLoginThrottle_ClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{
+			name:       "remote address",
+			remoteAddr: "192.0.2.1:54321",
+			forwarded:  []string{"198.51.100.7"},
+			expected:   "192.0.2.1",
+		},
+		{
+			name:       "trusted proxy header",
+			header:     "X-Forwarded-For",
+			remoteAddr: "10.0.0.1:54321",
+			forwarded:  []string{"203.0.113.9, 198.51.100.7"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "trusted proxy header missing",
+			header:     "X-Forwarded-For",
+			remoteAddr: "10.0.0.1:54321",
+			expected:   "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := NewLoginThrottle(ThrottleConfig{ClientIPHeader: tt.header}, nil, nil)
+			req := httptest.NewRequest("POST", "/auth/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := throttle.ClientIP(req); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package authctx carries the authenticated user through a request context.
+// It has no dependencies so that handler packages can read the current user
+// without importing auth (which itself depends on them).
+package authctx
+
+import (
+	"context"
+	"slices"
+)
+
+type contextKey string
+
+const (
+	userIDKey      contextKey = "user_id"
+	emailKey       contextKey = "email"
+	permissionsKey contextKey = "permissions"
+	apiKeyKey      contextKey = "api_key"
+	actorIDKey     contextKey = "actor_id"
+	tenantIDKey    contextKey = "tenant_id"
+)
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, userID, email string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	return context.WithValue(ctx, emailKey, email)
+}
+
+// UserID extracts the authenticated user ID from the context
+func UserID(ctx context.Context) string {
+	if userID, ok := ctx.Value(userIDKey).(string); ok {
+		return userID
+	}
+	return ""
+}
+
+// Email extracts the authenticated user's email from the context
+func Email(ctx context.Context) string {
+	if email, ok := ctx.Value(emailKey).(string); ok {
+		return email
+	}
+	return ""
+}
+
+// WithPermissions returns a copy of ctx carrying the permissions granted to
+// the authenticated user
+func WithPermissions(ctx context.Context, permissions []string) context.Context {
+	return context.WithValue(ctx, permissionsKey, permissions)
+}
+
+// HasPermission reports whether the authenticated user holds the permission
+func HasPermission(ctx context.Context, permission string) bool {
+	permissions, _ := ctx.Value(permissionsKey).([]string)
+	return slices.Contains(permissions, permission)
+}
+
+// WithAPIKey returns a copy of ctx marking the request as authenticated with
+// an API key rather than a login
+func WithAPIKey(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiKeyKey, true)
+}
+
+// IsAPIKey reports whether the request was authenticated with an API key
+func IsAPIKey(ctx context.Context) bool {
+	apiKey, _ := ctx.Value(apiKeyKey).(bool)
+	return apiKey
+}
+
+// WithActor returns a copy of ctx marking the request as made by actorID
+// while impersonating the authenticated user
+func WithActor(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorIDKey, actorID)
+}
+
+// ActorID extracts the user really making the request: the impersonating
+// administrator under impersonation, otherwise the authenticated user
+func ActorID(ctx context.Context) string {
+	if actorID, ok := ctx.Value(actorIDKey).(string); ok {
+		return actorID
+	}
+	return UserID(ctx)
+}
+
+// IsImpersonated reports whether the request is made by another user
+// impersonating the authenticated user
+func IsImpersonated(ctx context.Context) bool {
+	_, ok := ctx.Value(actorIDKey).(string)
+	return ok
+}
+
+// WithTenant returns a copy of ctx scoped to the tenant with the ID, whose
+// rows alone tenant-scoped queries can see
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// TenantID extracts the tenant the request is scoped to from the context
+func TenantID(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantIDKey).(string); ok {
+		return tenantID
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+//go:build unit
+
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-test-api/internal/authctx"
+)
+
+func TestPolicy_Authorize(t *testing.T) {
+	policy := NewPolicy("addresses:admin")
+	policy.Register("user", Self)
+
+	errRule := errors.New("rule failed")
+	policy.Register("broken", func(ctx context.Context, userID, entityID string, action Action) (bool, error) {
+		return false, errRule
+	})
+
+	tests := []struct {
+		name        string
+		userID      string
+		permissions []string
+		entityType  string
+		entityID    string
+		expectedErr error
+	}{
+		{
+			name:       "owner allowed",
+			userID:     "5",
+			entityType: "user",
+			entityID:   "5",
+		},
+		{
+			name:        "other user denied",
+			userID:      "5",
+			entityType:  "user",
+			entityID:    "7",
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "admin allowed",
+			userID:      "5",
+			permissions: []string{"addresses:admin"},
+			entityType:  "user",
+			entityID:    "7",
+		},
+		{
+			name:        "unauthenticated denied",
+			entityType:  "user",
+			entityID:    "7",
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "unknown entity type denied",
+			userID:      "5",
+			entityType:  "organization",
+			entityID:    "5",
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "rule error returned",
+			userID:      "5",
+			entityType:  "broken",
+			entityID:    "5",
+			expectedErr: errRule,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			if tt.userID != "" {
+				ctx = authctx.WithUser(ctx, tt.userID, "john@example.com")
+				ctx = authctx.WithPermissions(ctx, tt.permissions)
+			}
+
+			err := policy.Authorize(ctx, ActionRead, tt.entityType, tt.entityID)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_IsAdmin(t *testing.T) {
+	ctx := authctx.WithUser(context.Background(), "5", "john@example.com")
+
+	if NewPolicy("addresses:admin").IsAdmin(authctx.WithPermissions(ctx, []string{"addresses:read"})) {
+		t.Error("expected a user without the admin permission not to be an admin")
+	}
+	if !NewPolicy("addresses:admin").IsAdmin(authctx.WithPermissions(ctx, []string{"addresses:admin"})) {
+		t.Error("expected a user with the admin permission to be an admin")
+	}
+	if NewPolicy("").IsAdmin(authctx.WithPermissions(ctx, []string{""})) {
+		t.Error("expected a policy without an admin permission to have no admins")
+	}
+}
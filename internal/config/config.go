@@ -1,9 +1,14 @@
 package config
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"go-test-api/internal/database"
+	"go-test-api/internal/mail"
+	"go-test-api/internal/oidc"
+	"go-test-api/internal/password"
 )
 
 const (
@@ -15,33 +20,115 @@ const (
 type Config struct {
 	Environment string
 	Port        string
+	// BaseURL is the public URL of the application, used to build links
+	// in emails
+	BaseURL string
+	// FrontendURL is the public URL of the web app, which serves the pages
+	// emails link to when the flow needs a form: /reset-password and
+	// /accept-invitation
+	FrontendURL string
 	Database    database.Config
+	Mail        mail.Config
 	JWTSecret   string
 	JWTExpiry   time.Duration
+	// Password selects how new password hashes are made; existing hashes
+	// are upgraded on login
+	Password password.Config
+	// JWTSigningKeyFile is a PEM private key to sign access tokens with
+	// instead of JWTSecret
+	JWTSigningKeyFile string
+	// JWTVerificationKeyFiles are PEM keys that access tokens are still
+	// accepted from, such as the previous signing key during a rotation
+	JWTVerificationKeyFiles []string
+	// RefreshTokenExpiry is how long a refresh token can be exchanged for
+	// a new access token
+	RefreshTokenExpiry time.Duration
+	// RevocationSyncInterval is how often each replica reloads revoked
+	// tokens from the database
+	RevocationSyncInterval time.Duration
+	// SoftDeleteRetention is how long deleted users and addresses are kept,
+	// and can be restored, before they are purged
+	SoftDeleteRetention time.Duration
+	// PasswordResetExpiry is how long a password reset link stays valid
+	PasswordResetExpiry time.Duration
+	// EmailVerificationExpiry is how long an email verification or email
+	// change link stays valid
+	EmailVerificationExpiry time.Duration
+	// RequireVerifiedEmail restricts protected routes to users who have
+	// verified their email address
+	RequireVerifiedEmail bool
+	// MagicLinkEnabled turns on passwordless login by emailed link;
+	// MagicLinkExpiry is how long such a link stays valid
+	MagicLinkEnabled bool
+	MagicLinkExpiry  time.Duration
+	// InvitationExpiry is how long an invitation to join an organization
+	// stays valid
+	InvitationExpiry time.Duration
+	// TOTPIssuer is the name authenticator apps show for this service
+	TOTPIssuer string
+	// LoginMaxFailures failed logins for an email within LoginFailureWindow
+	// lock it for LoginLockoutDuration
+	LoginMaxFailures int
+	// LoginIPMaxFailures failed logins from one client IP within
+	// LoginFailureWindow refuse further attempts from it
+	LoginIPMaxFailures   int
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+	// ClientIPHeader names a header set by a trusted reverse proxy with the
+	// client IP, such as X-Forwarded-For
+	ClientIPHeader string
+	// OIDCProviders are the OpenID Connect providers users can log in with
+	OIDCProviders []oidc.ProviderConfig
+	// OIDCStateSecret signs the cookie carrying the state of a login with a
+	// provider; it defaults to JWTSecret, and must be set along with
+	// JWTSigningKeyFile if there are providers
+	OIDCStateSecret string
+	// CursorSecret signs the pagination cursors of list endpoints; it
+	// defaults to JWTSecret, and must be set along with JWTSigningKeyFile
+	CursorSecret string
 }
 
 // Load reads configuration from environment variables.
-// In production, critical values (JWT_SECRET, DB_PASSWORD) must be set.
+// In production, critical values (JWT_SECRET unless JWT_SIGNING_KEY_FILE is
+// set, DB_PASSWORD, and APP_BASE_URL, FRONTEND_URL and MAIL_DRIVER, without
+// which emailed links would point to localhost and mail would go to a local
+// file) must be set.
 // In development, sensible defaults are provided.
-func Load() Config {
+// It returns an error if the configuration is incomplete.
+func Load() (Config, error) {
 	environment := getEnv("ENV", EnvDevelopment)
 	isProduction := environment == EnvProduction
 
 	// Critical secrets - required in production, optional in development
 	var jwtSecret string
 	var dbPassword string
+	var baseURL string
+	var frontendURL string
+	var mailDriver string
+
+	jwtSigningKeyFile := getEnv("JWT_SIGNING_KEY_FILE", "")
 
 	if isProduction {
-		jwtSecret = mustGetEnv("JWT_SECRET")
+		if jwtSigningKeyFile == "" {
+			jwtSecret = mustGetEnv("JWT_SECRET")
+		}
 		dbPassword = mustGetEnv("DB_PASSWORD")
+		baseURL = mustGetEnv("APP_BASE_URL")
+		frontendURL = mustGetEnv("FRONTEND_URL")
+		mailDriver = mustGetEnv("MAIL_DRIVER")
 	} else {
 		jwtSecret = getEnv("JWT_SECRET", "dev-secret-key-not-for-production")
 		dbPassword = getEnv("DB_PASSWORD", "gopassword")
+		baseURL = getEnv("APP_BASE_URL", "http://localhost:8080")
+		frontendURL = getEnv("FRONTEND_URL", "http://localhost:3000")
+		mailDriver = getEnv("MAIL_DRIVER", mail.DriverFile)
 	}
 
-	return Config{
+	cfg := Config{
 		Environment: environment,
 		Port:        getEnv("PORT", "8080"),
+		BaseURL:     baseURL,
+		FrontendURL: frontendURL,
 		Database: database.Config{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnvAsInt("DB_PORT", 5432),
@@ -50,9 +137,62 @@ func Load() Config {
 			DBName:   getEnv("DB_NAME", "gotestdb"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
-		JWTSecret: jwtSecret,
-		JWTExpiry: 24 * time.Hour,
+		Mail: mail.Config{
+			Driver:       mailDriver,
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			OutboxDir:    getEnv("MAIL_OUTBOX_DIR", "tmp/outbox"),
+		},
+		Password: password.Config{
+			Algorithm: getEnv("PASSWORD_HASH_ALGORITHM", password.Argon2id),
+			Argon2id: password.Argon2idParams{
+				Memory:      uint32(getEnvAsInt("ARGON2_MEMORY_KIB", 19*1024)),
+				Iterations:  uint32(getEnvAsInt("ARGON2_ITERATIONS", 2)),
+				Parallelism: uint8(getEnvAsInt("ARGON2_PARALLELISM", 1)),
+			},
+			BcryptCost: getEnvAsInt("BCRYPT_COST", 10),
+		},
+		JWTSecret:               jwtSecret,
+		JWTSigningKeyFile:       jwtSigningKeyFile,
+		JWTVerificationKeyFiles: getEnvAsList("JWT_VERIFICATION_KEY_FILES"),
+		JWTExpiry:               getEnvAsDuration("JWT_EXPIRY", 15*time.Minute),
+		RefreshTokenExpiry:      getEnvAsDuration("REFRESH_TOKEN_EXPIRY", 30*24*time.Hour),
+		RevocationSyncInterval:  getEnvAsDuration("REVOCATION_SYNC_INTERVAL", 30*time.Second),
+		SoftDeleteRetention:     getEnvAsDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+		PasswordResetExpiry:     getEnvAsDuration("PASSWORD_RESET_EXPIRY", time.Hour),
+		EmailVerificationExpiry: getEnvAsDuration("EMAIL_VERIFICATION_EXPIRY", 48*time.Hour),
+		RequireVerifiedEmail:    getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+		MagicLinkEnabled:        getEnvAsBool("MAGIC_LINK_ENABLED", true),
+		MagicLinkExpiry:         getEnvAsDuration("MAGIC_LINK_EXPIRY", 15*time.Minute),
+		InvitationExpiry:        getEnvAsDuration("INVITATION_EXPIRY", 7*24*time.Hour),
+		TOTPIssuer:              getEnv("TOTP_ISSUER", "go-test-api"),
+		LoginMaxFailures:        getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:      getEnvAsInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginFailureWindow:      getEnvAsDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutDuration:    getEnvAsDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		ClientIPHeader:          getEnv("CLIENT_IP_HEADER", ""),
+		OIDCProviders:           loadOIDCProviders(),
+		OIDCStateSecret:         getEnv("OIDC_STATE_SECRET", jwtSecret),
+		CursorSecret:            getEnv("CURSOR_SECRET", jwtSecret),
 	}
+
+	// A ticker cannot run at a zero or negative interval
+	if cfg.RevocationSyncInterval <= 0 {
+		return Config{}, fmt.Errorf("REVOCATION_SYNC_INTERVAL must be positive, got %s", cfg.RevocationSyncInterval)
+	}
+
+	// Secrets default to JWT_SECRET, which is not set when tokens are
+	// signed with a private key
+	if cfg.CursorSecret == "" {
+		return Config{}, fmt.Errorf("CURSOR_SECRET is required when JWT_SIGNING_KEY_FILE is set")
+	}
+	if len(cfg.OIDCProviders) > 0 && cfg.OIDCStateSecret == "" {
+		return Config{}, fmt.Errorf("OIDC_STATE_SECRET is required when JWT_SIGNING_KEY_FILE is set and OIDC providers are configured")
+	}
+	return cfg, nil
 }
 
 // IsProduction returns true if running in production environment
@@ -64,3 +204,22 @@ func (c Config) IsProduction() bool {
 func (c Config) IsDevelopment() bool {
 	return c.Environment == EnvDevelopment
 }
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS. Each
+// provider is configured by variables prefixed with its upper-cased name,
+// e.g. OIDC_CORP_ISSUER, OIDC_CORP_CLIENT_ID, OIDC_CORP_CLIENT_SECRET and
+// OIDC_CORP_SCOPES for a provider named corp.
+func loadOIDCProviders() []oidc.ProviderConfig {
+	var providers []oidc.ProviderConfig
+	for _, name := range getEnvAsList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, oidc.ProviderConfig{
+			Name:         name,
+			Issuer:       mustGetEnv(prefix + "ISSUER"),
+			ClientID:     mustGetEnv(prefix + "CLIENT_ID"),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getEnvAsList(prefix + "SCOPES"),
+		})
+	}
+	return providers
+}
@@ -0,0 +1,142 @@
+//go:build unit
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setProductionEnv sets the variables production requires besides the JWT
+// secrets
+func setProductionEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("ENV", EnvProduction)
+	t.Setenv("DB_PASSWORD", "dbpassword")
+	t.Setenv("APP_BASE_URL", "https://api.example.com")
+	t.Setenv("FRONTEND_URL", "https://app.example.com")
+	t.Setenv("MAIL_DRIVER", "smtp")
+}
+
+func TestLoad_SigningKeySecrets(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expectedError string
+	}{
+		{
+			name:          "signing key without a cursor secret",
+			env:           map[string]string{},
+			expectedError: "CURSOR_SECRET",
+		},
+		{
+			name:          "signing key and OIDC providers without a state secret",
+			env:           map[string]string{"CURSOR_SECRET": "cursor-secret", "OIDC_PROVIDERS": "corp"},
+			expectedError: "OIDC_STATE_SECRET",
+		},
+		{
+			name: "signing key with its secrets",
+			env: map[string]string{
+				"CURSOR_SECRET":     "cursor-secret",
+				"OIDC_PROVIDERS":    "corp",
+				"OIDC_STATE_SECRET": "state-secret",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setProductionEnv(t)
+			t.Setenv("JWT_SECRET", "")
+			t.Setenv("JWT_SIGNING_KEY_FILE", "/etc/keys/signing.pem")
+			t.Setenv("CURSOR_SECRET", "")
+			t.Setenv("OIDC_PROVIDERS", "")
+			t.Setenv("OIDC_STATE_SECRET", "")
+			t.Setenv("OIDC_CORP_ISSUER", "https://sso.example.com")
+			t.Setenv("OIDC_CORP_CLIENT_ID", "client")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("Expected an error naming %s, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if cfg.CursorSecret != "cursor-secret" || cfg.OIDCStateSecret != "state-secret" {
+				t.Errorf("Expected the configured secrets, got %q and %q", cfg.CursorSecret, cfg.OIDCStateSecret)
+			}
+		})
+	}
+}
+
+func TestLoad_SecretsDefaultToJWTSecret(t *testing.T) {
+	setProductionEnv(t)
+	t.Setenv("JWT_SECRET", "jwt-secret")
+	t.Setenv("JWT_SIGNING_KEY_FILE", "")
+	t.Setenv("CURSOR_SECRET", "")
+	t.Setenv("OIDC_STATE_SECRET", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.CursorSecret != "jwt-secret" || cfg.OIDCStateSecret != "jwt-secret" {
+		t.Errorf("Expected the secrets to default to JWT_SECRET, got %q and %q", cfg.CursorSecret, cfg.OIDCStateSecret)
+	}
+}
+
+func TestLoad_RevocationSyncInterval(t *testing.T) {
+	t.Setenv("ENV", EnvDevelopment)
+	for _, interval := range []string{"0s", "-1m"} {
+		t.Run(interval, func(t *testing.T) {
+			t.Setenv("REVOCATION_SYNC_INTERVAL", interval)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), "REVOCATION_SYNC_INTERVAL") {
+				t.Errorf("Expected an error naming REVOCATION_SYNC_INTERVAL, got %v", err)
+			}
+		})
+	}
+
+	t.Setenv("REVOCATION_SYNC_INTERVAL", "1m")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.RevocationSyncInterval != time.Minute {
+		t.Errorf("Expected an interval of 1m, got %s", cfg.RevocationSyncInterval)
+	}
+}
+
+func TestLoad_ProductionRequiresMailSettings(t *testing.T) {
+	for _, key := range []string{"APP_BASE_URL", "FRONTEND_URL", "MAIL_DRIVER"} {
+		t.Run(key, func(t *testing.T) {
+			setProductionEnv(t)
+			t.Setenv("JWT_SECRET", "jwt-secret")
+			t.Setenv(key, "")
+
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), key) {
+					t.Errorf("Expected a panic naming %s, got %v", key, r)
+				}
+			}()
+			Load()
+		})
+	}
+
+	setProductionEnv(t)
+	t.Setenv("JWT_SECRET", "jwt-secret")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.BaseURL != "https://api.example.com" || cfg.FrontendURL != "https://app.example.com" || cfg.Mail.Driver != "smtp" {
+		t.Errorf("Expected the configured URLs and mail driver, got %q, %q and %q", cfg.BaseURL, cfg.FrontendURL, cfg.Mail.Driver)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // getEnv retrieves the value of the environment variable named by the key.
@@ -39,3 +41,44 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsDuration retrieves the value of the environment variable named by the
+// key and parses it as a time.Duration (e.g. "15m", "720h"). If the variable is
+// not present or cannot be parsed, it returns the defaultValue.
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	if duration, err := time.ParseDuration(valueStr); err == nil {
+		return duration
+	}
+	return defaultValue
+}
+
+// getEnvAsBool retrieves the value of the environment variable named by the key
+// and parses it as a boolean (e.g. "true", "1", "false"). If the variable is not
+// present or cannot be parsed, it returns the defaultValue.
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	if boolValue, err := strconv.ParseBool(valueStr); err == nil {
+		return boolValue
+	}
+	return defaultValue
+}
+
+// getEnvAsList retrieves the value of the environment variable named by the key
+// and splits it on commas, dropping empty entries. If the variable is not
+// present, it returns nil.
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-test-api/internal/authctx"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoTenant is returned when a tenant-scoped query is run for a context
+// that is not scoped to a tenant
+var ErrNoTenant = errors.New("no tenant in context")
+
+// InTenant runs fn in a transaction that only sees the rows of the tenant
+// ctx is scoped to, and commits it unless fn fails. Row-level security
+// policies, rather than the queries run by fn, keep other tenants' rows out.
+// It returns ErrNoTenant without running fn if ctx has no tenant.
+func InTenant(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	return InTenantTx(ctx, pool, pgx.TxOptions{}, fn)
+}
+
+// InTenantTx is InTenant with a transaction started with opts
+func InTenantTx(ctx context.Context, pool *pgxpool.Pool, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	tenantID := authctx.TenantID(ctx)
+	if tenantID == "" {
+		return ErrNoTenant
+	}
+
+	tx, err := pool.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The connecting role owns the tables and would bypass the policies.
+	// Both settings end with the transaction, before the connection goes
+	// back to the pool.
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE app_tenant"); err != nil {
+		return fmt.Errorf("failed to set tenant role: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		return fmt.Errorf("failed to set tenant: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package mail delivers transactional email (password resets, verification
+// links, ...) through a pluggable Mailer.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config holds mail delivery configuration
+type Config struct {
+	// Driver selects the Mailer implementation: "smtp" or "file"
+	Driver string
+	From   string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// OutboxDir is where the file driver writes messages
+	OutboxDir string
+}
+
+// New creates the Mailer selected by cfg.Driver
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg), nil
+	case DriverFile:
+		return NewFileMailer(cfg.OutboxDir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// validateHeaders rejects header values that could inject extra headers
+func validateHeaders(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("invalid header value %q", v)
+		}
+	}
+	return nil
+}
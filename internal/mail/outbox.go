@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// outboxEntry is the on-disk format of a message in the outbox
+type outboxEntry struct {
+	From   string    `json:"from"`
+	SentAt time.Time `json:"sent_at"`
+	Message
+}
+
+// FileMailer writes every message as a JSON file into an outbox directory
+// instead of sending it. It is meant for local development and tests, where
+// there is no mail server and messages need to be inspected.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a FileMailer, creating the outbox directory if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message to the outbox
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := validateHeaders(msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	entry := outboxEntry{From: m.from, SentAt: time.Now().UTC(), Message: msg}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to name email file: %w", err)
+	}
+	// Timestamp prefix keeps the outbox sorted by send time
+	name := fmt.Sprintf("%s-%s.json", entry.SentAt.Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+// Messages returns every message in the outbox, oldest first
+func (m *FileMailer) Messages() ([]Message, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	messages := make([]Message, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(m.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read email: %w", err)
+		}
+		var entry outboxEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode email %s: %w", name, err)
+		}
+		messages = append(messages, entry.Message)
+	}
+	return messages, nil
+}
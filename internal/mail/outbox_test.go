@@ -0,0 +1,50 @@
+//go:build unit
+
+package mail
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileMailer_SendAndRead(t *testing.T) {
+	mailer, err := NewFileMailer(t.TempDir(), "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	sent := []Message{
+		{To: "alice@example.com", Subject: "First", Body: "one"},
+		{To: "bob@example.com", Subject: "Second", Body: "two"},
+	}
+	for _, msg := range sent {
+		if err := mailer.Send(context.Background(), msg); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+
+	got, err := mailer.Messages()
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	if len(got) != len(sent) {
+		t.Fatalf("expected %d messages, got %d", len(sent), len(got))
+	}
+	for i := range sent {
+		if got[i] != sent[i] {
+			t.Errorf("message %d: expected %+v, got %+v", i, sent[i], got[i])
+		}
+	}
+}
+
+func TestFileMailer_RejectsHeaderInjection(t *testing.T) {
+	mailer, err := NewFileMailer(t.TempDir(), "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	msg := Message{To: "alice@example.com\r\nBcc: mallory@example.com", Subject: "Hi", Body: "x"}
+	if err := mailer.Send(context.Background(), msg); err == nil {
+		t.Fatal("expected an error for a header containing CRLF")
+	}
+}
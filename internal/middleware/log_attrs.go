@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+const logAttrsKey contextKey = "log_attrs"
+
+// LogAttrs collects attributes that handlers add to the canonical log line of
+// a request
+type LogAttrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// Add appends attributes to the log line
+func (a *LogAttrs) Add(attrs ...slog.Attr) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.attrs = append(a.attrs, attrs...)
+}
+
+// Attrs returns the collected attributes
+func (a *LogAttrs) Attrs() []slog.Attr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]slog.Attr(nil), a.attrs...)
+}
+
+// GetLogAttrs retrieves LogAttrs from context
+func GetLogAttrs(ctx context.Context) *LogAttrs {
+	if attrs, ok := ctx.Value(logAttrsKey).(*LogAttrs); ok {
+		return attrs
+	}
+	return nil
+}
+
+// WithLogAttrs adds LogAttrs to context
+func WithLogAttrs(ctx context.Context) context.Context {
+	return context.WithValue(ctx, logAttrsKey, &LogAttrs{})
+}
+
+// AddLogAttrs adds attributes to the canonical log line of the request ctx
+// belongs to. It is a no-op outside of Logging.
+func AddLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if a := GetLogAttrs(ctx); a != nil {
+		a.Add(attrs...)
+	}
+}
@@ -37,8 +37,9 @@ func Logging(next http.Handler) http.Handler {
 		start := time.Now()
 		wrapped := newResponseWriter(w)
 
-		// Add DB stats tracking to context
+		// Add DB stats tracking and handler attributes to context
 		ctx := WithDBStats(r.Context())
+		ctx = WithLogAttrs(ctx)
 		r = r.WithContext(ctx)
 
 		// Process request
@@ -50,7 +51,7 @@ func Logging(next http.Handler) http.Handler {
 
 		// Log canonical line
 		duration := time.Since(start)
-		slog.Info("http_request",
+		args := []interface{}{
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", wrapped.statusCode,
@@ -65,6 +66,10 @@ func Logging(next http.Handler) http.Handler {
 				slog.Int("updates", updates),
 				slog.Int("deletes", deletes),
 			),
-		)
+		}
+		for _, attr := range GetLogAttrs(ctx).Attrs() {
+			args = append(args, attr)
+		}
+		slog.Info("http_request", args...)
 	})
 }
@@ -0,0 +1,90 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"log/slog"
+	"math/big"
+)
+
+// jwks is a JSON Web Key Set as published by a provider
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk is a public key in JSON Web Key format
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKeys returns the signing keys of the set by kid. Keys that cannot be
+// used are skipped, so that one unsupported key does not lock out the rest.
+func (s jwks) publicKeys() map[string]interface{} {
+	keys := make(map[string]interface{}, len(s.Keys))
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, ok := k.publicKey()
+		if !ok {
+			slog.Warn("Skipping unsupported signing key", "kid", k.Kid, "kty", k.Kty, "crv", k.Crv)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys
+}
+
+func (k jwk) publicKey() (interface{}, bool) {
+	switch k.Kty {
+	case "RSA":
+		n, okN := decodeInt(k.N)
+		e, okE := decodeInt(k.E)
+		if !okN || !okE || !e.IsInt64() {
+			return nil, false
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, true
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, false
+		}
+		x, okX := decodeInt(k.X)
+		y, okY := decodeInt(k.Y)
+		if !okX || !okY {
+			return nil, false
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, false
+		}
+		return ed25519.PublicKey(x), true
+	default:
+		return nil, false
+	}
+}
+
+func decodeInt(s string) (*big.Int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(b), true
+}
@@ -0,0 +1,311 @@
+// Package oidc implements the relying party side of OpenID Connect: the
+// authorization code flow with PKCE against providers described by a
+// discovery document, and verification of the ID tokens they issue.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrExchangeFailed = errors.New("authorization code exchange failed")
+)
+
+// keyRefreshInterval limits how often the signing keys of a provider are
+// fetched again because a token names a key that is not known yet
+const keyRefreshInterval = time.Minute
+
+// clockSkew is how far the clocks of a provider and this service may differ
+const clockSkew = time.Minute
+
+// signingMethods are the ID token algorithms accepted
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}
+
+// ProviderConfig describes an identity provider registered with this
+// service as an OAuth client
+type ProviderConfig struct {
+	// Name identifies the provider in URLs and linked identities
+	Name string
+	// Issuer is the issuer URL; the discovery document is read from
+	// Issuer + "/.well-known/openid-configuration"
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested in addition to openid. If nil, email and
+	// profile are requested.
+	Scopes []string
+}
+
+// Identity is the user an ID token was issued for
+type Identity struct {
+	// Subject identifies the user at the provider and never changes
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an identity provider users can log in with. Its discovery
+// document and signing keys are fetched on first use and cached.
+type Provider struct {
+	cfg         ProviderConfig
+	redirectURL string
+	client      *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// metadata holds the parts of a discovery document that are used
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims are the ID token claims that are used
+type idTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// NewProvider creates a Provider. The provider redirects back to redirectURL,
+// which must be registered with it. If client is nil, http.DefaultClient is
+// used.
+func NewProvider(cfg ProviderConfig, redirectURL string, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Provider{cfg: cfg, redirectURL: redirectURL, client: client}
+}
+
+// Name returns the name the provider is configured under
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the URL to send the user to for logging in. state and
+// nonce are echoed back in the redirect and the ID token respectively, and
+// verifier is the PKCE code verifier later passed to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if scopes == nil {
+		scopes = []string{"email", "profile"}
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity from the
+// verified ID token, which must carry nonce
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s %s", ErrExchangeFailed, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id token in response", ErrExchangeFailed)
+	}
+
+	return p.verify(ctx, meta, tokens.IDToken, nonce)
+}
+
+// verify checks the signature and claims of an ID token
+func (p *Provider) verify(ctx context.Context, meta *metadata, rawIDToken, nonce string) (*Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, meta, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// metadata returns the discovery document, fetching it on first use. Failed
+// fetches are retried on the next call.
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build discovery request: %w", err)
+	}
+	var meta metadata
+	status, err := p.doJSON(req, &meta)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch discovery document of %s: status %d", p.cfg.Name, status)
+	}
+	// The issuer must be the one configured, so that a compromised
+	// discovery document cannot vouch for tokens from another issuer
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery document of %s names issuer %q", p.cfg.Name, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document of %s is incomplete", p.cfg.Name)
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key returns the signing key named kid, fetching the provider's keys again
+// if it is not known, at most once per keyRefreshInterval
+func (p *Provider) key(ctx context.Context, meta *metadata, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwks request: %w", err)
+	}
+	var set jwks
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing keys of %s: status %d", p.cfg.Name, status)
+	}
+	p.keys = set.publicKeys()
+	p.keysFetched = time.Now()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey returns the cached key named kid, or nil. A provider with a
+// single key may leave out the kid.
+func (p *Provider) lookupKey(kid string) interface{} {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// doJSON sends a request and decodes the JSON response body into v, whatever
+// the status, which is returned
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call %s: %w", req.URL.Host, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response of %s: %w", req.URL.Host, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("failed to parse response of %s: %w", req.URL.Host, err)
+	}
+	return resp.StatusCode, nil
+}
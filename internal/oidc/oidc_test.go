@@ -0,0 +1,303 @@
+//go:build unit
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "test-client"
+	testClientSecret = "test-secret"
+	testRedirectURL  = "https://app.example.com/auth/oidc/fake/callback"
+)
+
+// fakeIssuer is an in-process OpenID provider that logs every authorization
+// request in as the same user
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant // code -> grant
+	// audience overrides the aud claim of issued ID tokens
+	audience string
+}
+
+type fakeGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	f := &fakeIssuer{key: key, grants: map[string]fakeGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("GET /jwks", f.jwks)
+	mux.HandleFunc("GET /authorize", f.authorize)
+	mux.HandleFunc("POST /token", f.token)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 f.URL,
+		"authorization_endpoint": f.URL + "/authorize",
+		"token_endpoint":         f.URL + "/token",
+		"jwks_uri":               f.URL + "/jwks",
+	})
+}
+
+func (f *fakeIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := f.key.PublicKey
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "fake-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize skips the login page and redirects straight back with a code
+func (f *fakeIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL ||
+		q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := "code-" + q.Get("state")
+	f.mu.Lock()
+	f.grants[code] = fakeGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	f.mu.Unlock()
+
+	http.Redirect(w, r, testRedirectURL+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+}
+
+func (f *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// Codes are single use
+	f.mu.Lock()
+	grant, ok := f.grants[r.PostFormValue("code")]
+	delete(f.grants, r.PostFormValue("code"))
+	audience := f.audience
+	f.mu.Unlock()
+	if !ok || Challenge(r.PostFormValue("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	if audience == "" {
+		audience = testClientID
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            f.URL,
+		"sub":            "fake-user-1",
+		"aud":            audience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"email":          "sso.user@example.com",
+		"email_verified": true,
+		"name":           "SSO User",
+	})
+	token.Header["kid"] = "fake-key"
+	idToken, err := token.SignedString(f.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": idToken})
+}
+
+// login runs the browser side of a login against the fake issuer and
+// returns the code it redirects back with
+func login(t *testing.T, f *fakeIssuer, p *Provider, state *State) string {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		t.Fatalf("failed to build authorization URL: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("failed to call authorization endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect from authorization endpoint, got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	if location.Query().Get("state") != state.State {
+		t.Fatalf("expected state %q to be echoed, got %q", state.State, location.Query().Get("state"))
+	}
+	return location.Query().Get("code")
+}
+
+func newTestProvider(f *fakeIssuer) *Provider {
+	return NewProvider(ProviderConfig{
+		Name:         "fake",
+		Issuer:       f.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+	}, testRedirectURL, f.Client())
+}
+
+func TestProvider_Login(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+	state, err := NewState("fake", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+
+	code := login(t, f, p, state)
+	identity, err := p.Exchange(context.Background(), code, state.Verifier, state.Nonce)
+	if err != nil {
+		t.Fatalf("failed to exchange code: %v", err)
+	}
+	if identity.Subject != "fake-user-1" || identity.Email != "sso.user@example.com" || !identity.EmailVerified || identity.Name != "SSO User" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	// The code was used up
+	if _, err := p.Exchange(context.Background(), code, state.Verifier, state.Nonce); !errors.Is(err, ErrExchangeFailed) {
+		t.Errorf("expected ErrExchangeFailed reusing a code, got %v", err)
+	}
+}
+
+func TestProvider_Rejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		audience    string
+		verifier    func(*State) string
+		nonce       func(*State) string
+		expectedErr error
+	}{
+		{
+			name:        "wrong code verifier",
+			verifier:    func(*State) string { return "not-the-verifier" },
+			expectedErr: ErrExchangeFailed,
+		},
+		{
+			name:        "nonce of another login",
+			nonce:       func(*State) string { return "another-nonce" },
+			expectedErr: ErrInvalidIDToken,
+		},
+		{
+			name:        "token for another client",
+			audience:    "another-client",
+			expectedErr: ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeIssuer(t)
+			f.audience = tt.audience
+			p := newTestProvider(f)
+			state, err := NewState("fake", time.Minute)
+			if err != nil {
+				t.Fatalf("failed to create state: %v", err)
+			}
+
+			code := login(t, f, p, state)
+			verifier, nonce := state.Verifier, state.Nonce
+			if tt.verifier != nil {
+				verifier = tt.verifier(state)
+			}
+			if tt.nonce != nil {
+				nonce = tt.nonce(state)
+			}
+			if _, err := p.Exchange(context.Background(), code, verifier, nonce); !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := NewProvider(ProviderConfig{Name: "fake", Issuer: f.URL + "/other", ClientID: testClientID}, testRedirectURL, f.Client())
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Error("expected a discovery document for another issuer to be rejected")
+	}
+}
+
+func TestState_Encode(t *testing.T) {
+	key := []byte("state-key")
+	state, err := NewState("fake", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	encoded, err := state.Encode(key)
+	if err != nil {
+		t.Fatalf("failed to encode state: %v", err)
+	}
+
+	decoded, err := DecodeState(encoded, key)
+	if err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	if decoded.Provider != "fake" || decoded.State != state.State || decoded.Nonce != state.Nonce || decoded.Verifier != state.Verifier {
+		t.Errorf("expected %+v, got %+v", state, decoded)
+	}
+
+	if _, err := DecodeState(encoded, []byte("other-key")); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState with another key, got %v", err)
+	}
+	if _, err := DecodeState("x"+encoded, key); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState for a tampered state, got %v", err)
+	}
+
+	state.ExpiresAt = time.Now().Add(-time.Second)
+	expired, err := state.Encode(key)
+	if err != nil {
+		t.Fatalf("failed to encode state: %v", err)
+	}
+	if _, err := DecodeState(expired, key); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState for an expired state, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-test-api/internal/opaque"
+)
+
+var ErrInvalidState = errors.New("invalid login state")
+
+// State is what a login remembers between sending the user to the provider
+// and the redirect back. It travels in a signed cookie, so that any replica
+// can complete a login another one started.
+type State struct {
+	Provider string `json:"p"`
+	// State is echoed back by the provider and ties the redirect to the
+	// browser that started the login
+	State string `json:"s"`
+	// Nonce must be carried by the ID token, so that a token cannot be
+	// replayed into another login
+	Nonce string `json:"n"`
+	// Verifier is the PKCE code verifier
+	Verifier  string    `json:"v"`
+	ExpiresAt time.Time `json:"e"`
+}
+
+// NewState starts a login with provider that must complete within ttl
+func NewState(provider string, ttl time.Duration) (*State, error) {
+	state, err := opaque.New()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := opaque.New()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := opaque.New()
+	if err != nil {
+		return nil, err
+	}
+	return &State{
+		Provider:  provider,
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// Encode serializes the state and signs it with key
+func (s *State) Encode(key []byte) (string, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode login state: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(key, encoded)), nil
+}
+
+// DecodeState verifies and parses a state serialized by Encode. It returns
+// ErrInvalidState if the signature does not match or the state has expired.
+func DecodeState(value string, key []byte) (*State, error) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, sign(key, encoded)) {
+		return nil, ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	var s State
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, ErrInvalidState
+	}
+	if time.Now().After(s.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+	return &s, nil
+}
+
+// Challenge returns the S256 PKCE code challenge for a code verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func sign(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,29 @@
+// Package opaque generates and hashes random bearer tokens (refresh tokens,
+// reset links, etc.) that are handed to clients but only stored hashed.
+package opaque
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the amount of randomness in a generated token
+const tokenBytes = 32
+
+// New returns a new URL-safe random token
+func New() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the hex-encoded SHA-256 hash of a token for storage and lookup
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,29 @@
+-- name: AcceptOrganizationInvitation :one
+-- Marks an outstanding invitation accepted, so that it cannot be used again
+UPDATE organization_invitations
+SET accepted_at = $2
+WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > $2
+RETURNING organization_id, email, role;
+
+-- name: CreateOrganizationInvitation :one
+INSERT INTO organization_invitations (organization_id, email, role, invited_by, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, organization_id, email, role, expires_at, created_at;
+
+-- name: GetUserEmail :one
+SELECT email
+FROM users
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: IsOrganizationMemberEmail :one
+SELECT EXISTS (
+    SELECT 1
+    FROM organization_members m
+    JOIN users u ON u.id = m.user_id
+    WHERE m.organization_id = sqlc.arg('organization_id') AND lower(u.email) = lower(sqlc.arg('email'))
+);
+
+-- name: SupersedeOrganizationInvitations :exec
+-- Outstanding invitations to an address stop working once another is sent
+DELETE FROM organization_invitations
+WHERE organization_id = $1 AND email = $2 AND accepted_at IS NULL;
@@ -0,0 +1,39 @@
+-- name: AddOrganizationMember :one
+INSERT INTO organization_members (organization_id, user_id, role, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING organization_id, user_id, role, created_at;
+
+-- name: GetOrganizationMemberRole :one
+SELECT role
+FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: ListOrganizationMembers :many
+SELECT m.user_id, u.name, u.email, m.role, m.created_at
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.organization_id = sqlc.arg('organization_id')
+  AND u.deleted_at IS NULL
+  AND (sqlc.narg('after_id')::int IS NULL OR m.user_id > sqlc.narg('after_id'))
+ORDER BY m.user_id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListOrganizationMembersDesc :many
+SELECT m.user_id, u.name, u.email, m.role, m.created_at
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.organization_id = sqlc.arg('organization_id')
+  AND u.deleted_at IS NULL
+  AND (sqlc.narg('after_id')::int IS NULL OR m.user_id < sqlc.narg('after_id'))
+ORDER BY m.user_id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: RemoveOrganizationMember :execrows
+-- The owner cannot be removed, only replaced by transferring ownership
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2 AND role <> 'owner';
+
+-- name: SetOrganizationMemberRole :execrows
+UPDATE organization_members
+SET role = $3
+WHERE organization_id = $1 AND user_id = $2;
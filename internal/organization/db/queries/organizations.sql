@@ -0,0 +1,60 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name, owner_id, created_at, updated_at, tenant_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, name, owner_id, created_at, updated_at, tenant_id;
+
+-- name: GetOrganization :one
+SELECT id, name, owner_id, created_at, updated_at, tenant_id
+FROM organizations
+WHERE id = $1;
+
+-- name: ListOrganizationsByID :many
+-- Organizations of one member, or of everyone when member_id is null
+SELECT id, name, owner_id, created_at, updated_at, tenant_id
+FROM organizations
+WHERE (sqlc.narg('member_id')::int IS NULL OR EXISTS (
+        SELECT 1
+        FROM organization_members m
+        WHERE m.organization_id = organizations.id AND m.user_id = sqlc.narg('member_id')
+    ))
+  AND (sqlc.narg('after_id')::int IS NULL OR id > sqlc.narg('after_id'))
+ORDER BY id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListOrganizationsByIDDesc :many
+SELECT id, name, owner_id, created_at, updated_at, tenant_id
+FROM organizations
+WHERE (sqlc.narg('member_id')::int IS NULL OR EXISTS (
+        SELECT 1
+        FROM organization_members m
+        WHERE m.organization_id = organizations.id AND m.user_id = sqlc.narg('member_id')
+    ))
+  AND (sqlc.narg('after_id')::int IS NULL OR id < sqlc.narg('after_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: LockOrganization :one
+-- Serializes changes of ownership
+SELECT id, name, owner_id, created_at, updated_at, tenant_id
+FROM organizations
+WHERE id = $1
+FOR UPDATE;
+
+-- name: SetOrganizationOwner :execrows
+UPDATE organizations
+SET owner_id = $2,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: UpdateOrganization :one
+UPDATE organizations
+SET name = $2,
+    updated_at = $3
+WHERE id = $1
+RETURNING id, name, owner_id, created_at, updated_at, tenant_id;
+
+-- name: DeleteOrganization :execrows
+-- Its addresses are deleted by a trigger, its members and invitations by
+-- the foreign keys
+DELETE FROM organizations
+WHERE id = $1;
@@ -0,0 +1,560 @@
+package organization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/authz"
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/pagination"
+	"go-test-api/internal/validator"
+	"go-test-api/pkg/response"
+)
+
+// EntityType is the entity type of organizations in authorization policies
+// and addresses
+const EntityType = "organization"
+
+// InvitationNotifier sends invitations to join an organization
+type InvitationNotifier interface {
+	SendInvitation(ctx context.Context, to, organization, token string, ttl time.Duration) error
+}
+
+// Handler handles HTTP requests for organizations
+type Handler struct {
+	validator        *validator.Validator
+	repo             Repo
+	policy           *authz.Policy
+	pages            *pagination.Paginator
+	notifier         InvitationNotifier
+	invitationExpiry time.Duration
+}
+
+// NewHandler creates a new organization Handler. Requests on an
+// organization are authorized against policy with EntityType. Lists are
+// paginated with pages. Invitations are sent with notifier, and expire
+// after invitationExpiry.
+func NewHandler(v *validator.Validator, repo Repo, policy *authz.Policy, pages *pagination.Paginator, notifier InvitationNotifier, invitationExpiry time.Duration) *Handler {
+	return &Handler{
+		validator:        v,
+		repo:             repo,
+		policy:           policy,
+		pages:            pages,
+		notifier:         notifier,
+		invitationExpiry: invitationExpiry,
+	}
+}
+
+// Create handles POST /organizations
+// @Summary Create an organization
+// @Description Create a new organization, owned by the caller
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param organization body CreateOrganizationRequest true "Organization to create"
+// @Success 201 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations [post]
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid user")
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	org, err := h.repo.Create(r.Context(), int32(ownerID), &req)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create organization: %v", err))
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, org)
+}
+
+// Get handles GET /organizations/{id}
+// @Summary Get an organization by ID
+// @Description Retrieve a specific organization by its ID. Organizations the caller is not a member of are reported as not found.
+// @Tags organizations
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id} [get]
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.authorizedID(w, r, authz.ActionRead)
+	if !ok {
+		return
+	}
+
+	org, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		h.repoError(w, err, "Failed to get organization")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, org)
+}
+
+// List handles GET /organizations
+// @Summary List organizations
+// @Description Get a page of the organizations the caller is a member of, or of every organization for holders of the organizations:admin permission. Pass the next_cursor of a page as cursor, with the same sort, to get the next one; it is omitted on the last page.
+// @Tags organizations
+// @Produce json
+// @Param limit query int false "Page size, 1 to 100" default(20)
+// @Param sort query string false "Sort field: id, prefixed with - for descending order" default(id)
+// @Param cursor query string false "Cursor of the page to get"
+// @Success 200 {object} pagination.Page{data=[]OrganizationResponse}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	page, err := h.pages.Parse(r.URL.Query(), organizationSorts...)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var memberID int64
+	if !h.policy.IsAdmin(r.Context()) {
+		memberID, err = strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+		if err != nil {
+			response.Error(w, http.StatusUnauthorized, "Invalid user")
+			return
+		}
+	}
+
+	orgs, next, err := h.repo.List(r.Context(), int32(memberID), page)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list organizations: %v", err))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, pagination.Page{Data: orgs, NextCursor: h.pages.Encode(next)})
+}
+
+// Update handles PUT /organizations/{id}
+// @Summary Update an organization
+// @Description Rename an organization by ID. Only its admins and owner may. Organizations the caller is not a member of are reported as not found.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param organization body UpdateOrganizationRequest true "Updated organization data"
+// @Success 200 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id} [put]
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	var req UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, ok := h.authorizedID(w, r, authz.ActionWrite)
+	if !ok {
+		return
+	}
+
+	org, err := h.repo.Update(r.Context(), id, &req)
+	if err != nil {
+		h.repoError(w, err, "Failed to update organization")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, org)
+}
+
+// Delete handles DELETE /organizations/{id}
+// @Summary Delete an organization
+// @Description Delete an organization by ID, along with its addresses, members and invitations. Only its owner may. Organizations the caller is not a member of are reported as not found.
+// @Tags organizations
+// @Param id path int true "Organization ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id} [delete]
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.authorizedID(w, r, authz.ActionWrite)
+	if !ok || !h.requireRole(w, r, id, RoleOwner) {
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id); err != nil {
+		h.repoError(w, err, "Failed to delete organization")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Members handles GET /organizations/{id}/members
+// @Summary List the members of an organization
+// @Description Get a page of the members of an organization, with their roles. Organizations the caller is not a member of are reported as not found. Pass the next_cursor of a page as cursor, with the same sort, to get the next one; it is omitted on the last page.
+// @Tags organizations
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param limit query int false "Page size, 1 to 100" default(20)
+// @Param sort query string false "Sort field: user_id, prefixed with - for descending order" default(user_id)
+// @Param cursor query string false "Cursor of the page to get"
+// @Success 200 {object} pagination.Page{data=[]MemberResponse}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/members [get]
+func (h *Handler) Members(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.authorizedID(w, r, authz.ActionRead)
+	if !ok {
+		return
+	}
+
+	page, err := h.pages.Parse(r.URL.Query(), memberSorts...)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	members, next, err := h.repo.Members(r.Context(), id, page)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list members: %v", err))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, pagination.Page{Data: members, NextCursor: h.pages.Encode(next)})
+}
+
+// RemoveMember handles DELETE /organizations/{id}/members/{user_id}
+// @Summary Remove a member from an organization
+// @Description Remove a member from an organization. Members may remove themselves, admins may remove members, and the owner may also remove admins. The owner cannot be removed; they must transfer ownership first. Organizations the caller is not a member of are reported as not found.
+// @Tags organizations
+// @Param id path int true "Organization ID"
+// @Param user_id path int true "User ID of the member"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/members/{user_id} [delete]
+func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.authorizedID(w, r, authz.ActionRead)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	target, err := h.repo.Role(r.Context(), id, int32(userID))
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to remove member: %v", err))
+		return
+	}
+	switch target {
+	case "":
+		response.Error(w, http.StatusNotFound, "Member not found")
+		return
+	case RoleOwner:
+		response.Error(w, http.StatusConflict, "The owner cannot be removed; transfer ownership first")
+		return
+	}
+
+	// Anyone may leave; removing someone else takes a higher role than theirs
+	if authctx.UserID(r.Context()) != strconv.FormatInt(userID, 10) {
+		required := RoleAdmin
+		if target == RoleAdmin {
+			required = RoleOwner
+		}
+		if !h.requireRole(w, r, id, required) {
+			return
+		}
+	}
+
+	if err := h.repo.RemoveMember(r.Context(), id, int32(userID)); err != nil {
+		if errors.Is(err, ErrMemberNotFound) {
+			response.Error(w, http.StatusNotFound, "Member not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to remove member: %v", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TransferOwnership handles POST /organizations/{id}/transfer
+// @Summary Transfer ownership of an organization
+// @Description Make another member the owner of an organization. The previous owner stays on as an admin. Only the owner may. Organizations the caller is not a member of are reported as not found.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param request body TransferOwnershipRequest true "New owner"
+// @Success 200 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/transfer [post]
+func (h *Handler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	var req TransferOwnershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, ok := h.authorizedID(w, r, authz.ActionWrite)
+	if !ok || !h.requireRole(w, r, id, RoleOwner) {
+		return
+	}
+
+	org, err := h.repo.TransferOwnership(r.Context(), id, req.UserID)
+	if err != nil {
+		if errors.Is(err, ErrMemberNotFound) {
+			response.Error(w, http.StatusBadRequest, "The new owner must be a member of the organization")
+			return
+		}
+		h.repoError(w, err, "Failed to transfer ownership")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, org)
+}
+
+// Invite handles POST /organizations/{id}/invitations
+// @Summary Invite someone to an organization
+// @Description Email an invitation to join an organization as an admin or a member. It can be accepted once, before it expires, by whoever has an account with the address, including one registered after the invitation was sent. A new invitation to the same address replaces the previous one. Only admins and the owner may invite. Organizations the caller is not a member of are reported as not found.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param invitation body CreateInvitationRequest true "Invitee and their role"
+// @Success 201 {object} InvitationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/invitations [post]
+func (h *Handler) Invite(w http.ResponseWriter, r *http.Request) {
+	inviterID, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid user")
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, ok := h.authorizedID(w, r, authz.ActionWrite)
+	if !ok {
+		return
+	}
+
+	org, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		h.repoError(w, err, "Failed to invite")
+		return
+	}
+
+	token, err := opaque.New()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to invite")
+		return
+	}
+	expiresAt := time.Now().Add(h.invitationExpiry)
+	invitation, err := h.repo.Invite(r.Context(), id, int32(inviterID), &req, opaque.Hash(token), expiresAt)
+	if err != nil {
+		if errors.Is(err, ErrAlreadyMember) {
+			response.Error(w, http.StatusConflict, "Already a member of the organization")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to invite: %v", err))
+		return
+	}
+
+	if err := h.notifier.SendInvitation(r.Context(), req.Email, org.Name, token, h.invitationExpiry); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to send invitation email")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, invitation)
+}
+
+// AcceptInvitation handles POST /invitations/accept
+// @Summary Accept an invitation to an organization
+// @Description Join an organization with the token from an invitation email, with the role it offers. The caller's email address must be the one the invitation was sent to. An invitation can only be accepted once.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body AcceptInvitationRequest true "Invitation token"
+// @Success 200 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /invitations/accept [post]
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid user")
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	org, err := h.repo.AcceptInvitation(r.Context(), int32(userID), opaque.Hash(req.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidInvitation):
+			response.Error(w, http.StatusBadRequest, "Invalid or expired invitation")
+		case errors.Is(err, ErrInvitationEmail):
+			response.Error(w, http.StatusForbidden, "This invitation was sent to another email address")
+		case errors.Is(err, ErrAlreadyMember):
+			response.Error(w, http.StatusConflict, "Already a member of the organization")
+		default:
+			response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to accept invitation: %v", err))
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, org)
+}
+
+// authorizedID parses the organization ID of the request and checks that
+// the caller may perform the action on it. Organizations the caller may not
+// access are reported as not found, so that their existence is not
+// revealed. It returns false if the request was answered.
+func (h *Handler) authorizedID(w http.ResponseWriter, r *http.Request, action authz.Action) (int32, bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid organization ID")
+		return 0, false
+	}
+
+	if err := h.policy.Authorize(r.Context(), action, EntityType, idStr); err != nil {
+		if errors.Is(err, authz.ErrDenied) {
+			// Members already know the organization exists
+			if action != authz.ActionRead && h.policy.Authorize(r.Context(), authz.ActionRead, EntityType, idStr) == nil {
+				response.Error(w, http.StatusForbidden, "Your role in the organization does not allow this")
+				return 0, false
+			}
+			response.Error(w, http.StatusNotFound, "Organization not found")
+			return 0, false
+		}
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to authorize request: %v", err))
+		return 0, false
+	}
+	return int32(id), true
+}
+
+// requireRole checks that the caller has at least the role in the
+// organization; holders of the policy's admin permission have every role.
+// It returns false if the request was answered.
+func (h *Handler) requireRole(w http.ResponseWriter, r *http.Request, id int32, min Role) bool {
+	if h.policy.IsAdmin(r.Context()) {
+		return true
+	}
+	userID, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid user")
+		return false
+	}
+	role, err := h.repo.Role(r.Context(), id, int32(userID))
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("Failed to authorize request: %v", err))
+		return false
+	}
+	if !role.atLeast(min) {
+		response.Error(w, http.StatusForbidden, "Your role in the organization does not allow this")
+		return false
+	}
+	return true
+}
+
+// repoError answers a request whose repository call failed
+func (h *Handler) repoError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, ErrNotFound) {
+		response.Error(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+	response.Error(w, http.StatusInternalServerError, fmt.Sprintf("%s: %v", message, err))
+}
@@ -0,0 +1,537 @@
+//go:build unit
+
+package organization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/authz"
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/pagination"
+	"go-test-api/internal/validator"
+)
+
+// mockOrganizationRepository is a mock implementation of Repo for testing
+type mockOrganizationRepository struct {
+	createFunc   func(ctx context.Context, ownerID int32, req *CreateOrganizationRequest) (*OrganizationResponse, error)
+	getFunc      func(ctx context.Context, id int32) (*OrganizationResponse, error)
+	listFunc     func(ctx context.Context, ownerID int32, page pagination.Request) ([]*OrganizationResponse, *pagination.Cursor, error)
+	updateFunc   func(ctx context.Context, id int32, req *UpdateOrganizationRequest) (*OrganizationResponse, error)
+	deleteFunc   func(ctx context.Context, id int32) error
+	membersFunc  func(ctx context.Context, id int32, page pagination.Request) ([]*MemberResponse, *pagination.Cursor, error)
+	removeFunc   func(ctx context.Context, id, userID int32) error
+	transferFunc func(ctx context.Context, id, userID int32) (*OrganizationResponse, error)
+	inviteFunc   func(ctx context.Context, id, invitedBy int32, req *CreateInvitationRequest, tokenHash string, expiresAt time.Time) (*InvitationResponse, error)
+	acceptFunc   func(ctx context.Context, userID int32, tokenHash string) (*OrganizationResponse, error)
+}
+
+func (m *mockOrganizationRepository) Create(ctx context.Context, ownerID int32, req *CreateOrganizationRequest) (*OrganizationResponse, error) {
+	if m.createFunc != nil {
+		return m.createFunc(ctx, ownerID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) Get(ctx context.Context, id int32) (*OrganizationResponse, error) {
+	if m.getFunc != nil {
+		return m.getFunc(ctx, id)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) List(ctx context.Context, ownerID int32, page pagination.Request) ([]*OrganizationResponse, *pagination.Cursor, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx, ownerID, page)
+	}
+	return nil, nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) Update(ctx context.Context, id int32, req *UpdateOrganizationRequest) (*OrganizationResponse, error) {
+	if m.updateFunc != nil {
+		return m.updateFunc(ctx, id, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) Delete(ctx context.Context, id int32) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, id)
+	}
+	return errors.New("not implemented")
+}
+
+// Role returns the role of the user in organization 1: user 7 owns it,
+// user 8 is an admin and user 9 a member
+func (m *mockOrganizationRepository) Role(ctx context.Context, id, userID int32) (Role, error) {
+	if id != 1 {
+		return "", nil
+	}
+	return map[int32]Role{7: RoleOwner, 8: RoleAdmin, 9: RoleMember}[userID], nil
+}
+
+func (m *mockOrganizationRepository) Members(ctx context.Context, id int32, page pagination.Request) ([]*MemberResponse, *pagination.Cursor, error) {
+	if m.membersFunc != nil {
+		return m.membersFunc(ctx, id, page)
+	}
+	return nil, nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) RemoveMember(ctx context.Context, id, userID int32) error {
+	if m.removeFunc != nil {
+		return m.removeFunc(ctx, id, userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) TransferOwnership(ctx context.Context, id, userID int32) (*OrganizationResponse, error) {
+	if m.transferFunc != nil {
+		return m.transferFunc(ctx, id, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) Invite(ctx context.Context, id, invitedBy int32, req *CreateInvitationRequest, tokenHash string, expiresAt time.Time) (*InvitationResponse, error) {
+	if m.inviteFunc != nil {
+		return m.inviteFunc(ctx, id, invitedBy, req, tokenHash, expiresAt)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOrganizationRepository) AcceptInvitation(ctx context.Context, userID int32, tokenHash string) (*OrganizationResponse, error) {
+	if m.acceptFunc != nil {
+		return m.acceptFunc(ctx, userID, tokenHash)
+	}
+	return nil, errors.New("not implemented")
+}
+
+// mockInvitationNotifier records the invitations sent
+type mockInvitationNotifier struct {
+	sent  []string
+	token string
+}
+
+func (n *mockInvitationNotifier) SendInvitation(ctx context.Context, to, organization, token string, ttl time.Duration) error {
+	n.sent = append(n.sent, to)
+	n.token = token
+	return nil
+}
+
+// newTestHandler creates a Handler whose policy applies the roles of the
+// mock repository, like Repository.MemberRule
+func newTestHandler(repo Repo) *Handler {
+	return newTestHandlerWithNotifier(repo, &mockInvitationNotifier{})
+}
+
+func newTestHandlerWithNotifier(repo Repo, notifier InvitationNotifier) *Handler {
+	policy := authz.NewPolicy("organizations:admin")
+	policy.Register(EntityType, func(ctx context.Context, userID, entityID string, action authz.Action) (bool, error) {
+		id, _ := strconv.ParseInt(entityID, 10, 32)
+		uid, _ := strconv.ParseInt(userID, 10, 32)
+		role, err := repo.Role(ctx, int32(id), int32(uid))
+		if action == authz.ActionRead {
+			return role.atLeast(RoleMember), err
+		}
+		return role.atLeast(RoleAdmin), err
+	})
+	return NewHandler(validator.New(), repo, policy, pagination.New([]byte("test-secret")), notifier, 7*24*time.Hour)
+}
+
+// userContext returns a context authenticated as the user, holding the
+// permissions
+func userContext(userID string, permissions ...string) context.Context {
+	ctx := authctx.WithUser(context.Background(), userID, "user@example.com")
+	return authctx.WithPermissions(ctx, permissions)
+}
+
+func TestHandler_Create(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "valid organization", body: `{"name":"Acme"}`, expectedStatus: http.StatusCreated},
+		{name: "missing name", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{"name":`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(&mockOrganizationRepository{
+				createFunc: func(ctx context.Context, ownerID int32, req *CreateOrganizationRequest) (*OrganizationResponse, error) {
+					if ownerID != 7 {
+						return nil, errors.New("unexpected owner")
+					}
+					return &OrganizationResponse{ID: "1", Name: req.Name, OwnerID: "7"}, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/organizations", strings.NewReader(tt.body)).WithContext(userContext("7"))
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_Get(t *testing.T) {
+	repo := &mockOrganizationRepository{
+		getFunc: func(ctx context.Context, id int32) (*OrganizationResponse, error) {
+			if id != 1 {
+				return nil, ErrNotFound
+			}
+			return &OrganizationResponse{ID: "1", Name: "Acme", OwnerID: "7"}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		ctx            context.Context
+		expectedStatus int
+	}{
+		{name: "owner", id: "1", ctx: userContext("7"), expectedStatus: http.StatusOK},
+		{name: "member", id: "1", ctx: userContext("9"), expectedStatus: http.StatusOK},
+		{name: "someone else", id: "1", ctx: userContext("10"), expectedStatus: http.StatusNotFound},
+		{name: "admin", id: "1", ctx: userContext("10", "organizations:admin"), expectedStatus: http.StatusOK},
+		{name: "admin, missing organization", id: "2", ctx: userContext("10", "organizations:admin"), expectedStatus: http.StatusNotFound},
+		{name: "invalid ID", id: "abc", ctx: userContext("7"), expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(repo)
+
+			req := httptest.NewRequest(http.MethodGet, "/organizations/"+tt.id, nil).WithContext(tt.ctx)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			handler.Get(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	tests := []struct {
+		name             string
+		ctx              context.Context
+		expectedMemberID int32
+	}{
+		{name: "member lists their own", ctx: userContext("9"), expectedMemberID: 9},
+		{name: "admin lists everyone's", ctx: userContext("10", "organizations:admin"), expectedMemberID: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var memberID int32 = -1
+			handler := newTestHandler(&mockOrganizationRepository{
+				listFunc: func(ctx context.Context, member int32, page pagination.Request) ([]*OrganizationResponse, *pagination.Cursor, error) {
+					memberID = member
+					return []*OrganizationResponse{{ID: "1", Name: "Acme", OwnerID: "7"}}, nil, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/organizations", nil).WithContext(tt.ctx)
+			w := httptest.NewRecorder()
+
+			handler.List(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if memberID != tt.expectedMemberID {
+				t.Errorf("expected organizations of member %d, got %d", tt.expectedMemberID, memberID)
+			}
+			var orgs []OrganizationResponse
+			if err := json.NewDecoder(w.Body).Decode(&pagination.Page{Data: &orgs}); err != nil || len(orgs) != 1 {
+				t.Errorf("expected 1 organization, got %v (%v)", orgs, err)
+			}
+		})
+	}
+}
+
+func TestHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		ctx            context.Context
+		mockDelete     func(ctx context.Context, id int32) error
+		expectedStatus int
+	}{
+		{
+			name:           "owner",
+			id:             "1",
+			ctx:            userContext("7"),
+			mockDelete:     func(ctx context.Context, id int32) error { return nil },
+			expectedStatus: http.StatusNoContent,
+		},
+		{name: "organization admin", id: "1", ctx: userContext("8"), expectedStatus: http.StatusForbidden},
+		{name: "member", id: "1", ctx: userContext("9"), expectedStatus: http.StatusForbidden},
+		{name: "someone else", id: "1", ctx: userContext("10"), expectedStatus: http.StatusNotFound},
+		{
+			name:           "admin, missing organization",
+			id:             "2",
+			ctx:            userContext("10", "organizations:admin"),
+			mockDelete:     func(ctx context.Context, id int32) error { return ErrNotFound },
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "database error",
+			id:             "1",
+			ctx:            userContext("7"),
+			mockDelete:     func(ctx context.Context, id int32) error { return errors.New("database connection failed") },
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(&mockOrganizationRepository{deleteFunc: tt.mockDelete})
+
+			req := httptest.NewRequest(http.MethodDelete, "/organizations/"+tt.id, nil).WithContext(tt.ctx)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			handler.Delete(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_Update(t *testing.T) {
+	repo := &mockOrganizationRepository{
+		updateFunc: func(ctx context.Context, id int32, req *UpdateOrganizationRequest) (*OrganizationResponse, error) {
+			return &OrganizationResponse{ID: "1", Name: req.Name, OwnerID: "7"}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		expectedStatus int
+	}{
+		{name: "organization admin", ctx: userContext("8"), expectedStatus: http.StatusOK},
+		{name: "member", ctx: userContext("9"), expectedStatus: http.StatusForbidden},
+		{name: "someone else", ctx: userContext("10"), expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(repo)
+
+			req := httptest.NewRequest(http.MethodPut, "/organizations/1", strings.NewReader(`{"name":"Acme Corp"}`)).WithContext(tt.ctx)
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+
+			handler.Update(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_RemoveMember(t *testing.T) {
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		userID         string
+		expectedStatus int
+	}{
+		{name: "member leaves", ctx: userContext("9"), userID: "9", expectedStatus: http.StatusNoContent},
+		{name: "admin removes member", ctx: userContext("8"), userID: "9", expectedStatus: http.StatusNoContent},
+		{name: "owner removes admin", ctx: userContext("7"), userID: "8", expectedStatus: http.StatusNoContent},
+		{name: "member removes admin", ctx: userContext("9"), userID: "8", expectedStatus: http.StatusForbidden},
+		{name: "admin leaves", ctx: userContext("8"), userID: "8", expectedStatus: http.StatusNoContent},
+		{name: "owner leaves", ctx: userContext("7"), userID: "7", expectedStatus: http.StatusConflict},
+		{name: "not a member", ctx: userContext("7"), userID: "10", expectedStatus: http.StatusNotFound},
+		{name: "someone else", ctx: userContext("10"), userID: "9", expectedStatus: http.StatusNotFound},
+		{name: "invalid user ID", ctx: userContext("7"), userID: "abc", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(&mockOrganizationRepository{
+				removeFunc: func(ctx context.Context, id, userID int32) error { return nil },
+			})
+
+			req := httptest.NewRequest(http.MethodDelete, "/organizations/1/members/"+tt.userID, nil).WithContext(tt.ctx)
+			req.SetPathValue("id", "1")
+			req.SetPathValue("user_id", tt.userID)
+			w := httptest.NewRecorder()
+
+			handler.RemoveMember(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_TransferOwnership(t *testing.T) {
+	repo := &mockOrganizationRepository{
+		transferFunc: func(ctx context.Context, id, userID int32) (*OrganizationResponse, error) {
+			if userID == 10 {
+				return nil, ErrMemberNotFound
+			}
+			return &OrganizationResponse{ID: "1", Name: "Acme", OwnerID: strconv.Itoa(int(userID))}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		body           string
+		expectedStatus int
+	}{
+		{name: "owner", ctx: userContext("7"), body: `{"user_id":8}`, expectedStatus: http.StatusOK},
+		{name: "organization admin", ctx: userContext("8"), body: `{"user_id":8}`, expectedStatus: http.StatusForbidden},
+		{name: "to someone outside", ctx: userContext("7"), body: `{"user_id":10}`, expectedStatus: http.StatusBadRequest},
+		{name: "missing user", ctx: userContext("7"), body: `{}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(repo)
+
+			req := httptest.NewRequest(http.MethodPost, "/organizations/1/transfer", strings.NewReader(tt.body)).WithContext(tt.ctx)
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+
+			handler.TransferOwnership(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_Invite(t *testing.T) {
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		body           string
+		expectedStatus int
+		expectedSent   int
+	}{
+		{name: "organization admin", ctx: userContext("8"), body: `{"email":"jane@example.com","role":"member"}`, expectedStatus: http.StatusCreated, expectedSent: 1},
+		{name: "member", ctx: userContext("9"), body: `{"email":"jane@example.com","role":"member"}`, expectedStatus: http.StatusForbidden},
+		{name: "already a member", ctx: userContext("8"), body: `{"email":"member@example.com","role":"member"}`, expectedStatus: http.StatusConflict},
+		{name: "owner role", ctx: userContext("7"), body: `{"email":"jane@example.com","role":"owner"}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid email", ctx: userContext("7"), body: `{"email":"jane","role":"member"}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var storedHash string
+			notifier := &mockInvitationNotifier{}
+			handler := newTestHandlerWithNotifier(&mockOrganizationRepository{
+				getFunc: func(ctx context.Context, id int32) (*OrganizationResponse, error) {
+					return &OrganizationResponse{ID: "1", Name: "Acme", OwnerID: "7"}, nil
+				},
+				inviteFunc: func(ctx context.Context, id, invitedBy int32, req *CreateInvitationRequest, tokenHash string, expiresAt time.Time) (*InvitationResponse, error) {
+					if req.Email == "member@example.com" {
+						return nil, ErrAlreadyMember
+					}
+					storedHash = tokenHash
+					return &InvitationResponse{ID: "1", OrganizationID: "1", Email: req.Email, Role: req.Role, ExpiresAt: expiresAt}, nil
+				},
+			}, notifier)
+
+			req := httptest.NewRequest(http.MethodPost, "/organizations/1/invitations", strings.NewReader(tt.body)).WithContext(tt.ctx)
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+
+			handler.Invite(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if len(notifier.sent) != tt.expectedSent {
+				t.Fatalf("expected %d invitations sent, got %d", tt.expectedSent, len(notifier.sent))
+			}
+			// Only the hash of the emailed token is stored
+			if tt.expectedSent > 0 && (storedHash == notifier.token || storedHash != opaque.Hash(notifier.token)) {
+				t.Errorf("expected the hash of the emailed token to be stored, got %q", storedHash)
+			}
+		})
+	}
+}
+
+func TestHandler_AcceptInvitation(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "valid invitation", expectedStatus: http.StatusOK},
+		{name: "invalid invitation", err: ErrInvalidInvitation, expectedStatus: http.StatusBadRequest},
+		{name: "another address", err: ErrInvitationEmail, expectedStatus: http.StatusForbidden},
+		{name: "already a member", err: ErrAlreadyMember, expectedStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := newTestHandler(&mockOrganizationRepository{
+				acceptFunc: func(ctx context.Context, userID int32, tokenHash string) (*OrganizationResponse, error) {
+					if userID != 10 || tokenHash != opaque.Hash("invite-token") {
+						return nil, errors.New("unexpected invitation")
+					}
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &OrganizationResponse{ID: "1", Name: "Acme", OwnerID: "7"}, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/invitations/accept", strings.NewReader(`{"token":"invite-token"}`)).WithContext(userContext("10"))
+			w := httptest.NewRecorder()
+
+			handler.AcceptInvitation(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package organization
+
+import "time"
+
+// CreateOrganizationRequest represents the request to create an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// UpdateOrganizationRequest represents the request to update an organization
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// OrganizationResponse represents an organization in API responses
+type OrganizationResponse struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OwnerID string `json:"owner_id"`
+}
+
+// Role is a member's role in an organization
+type Role string
+
+const (
+	// RoleOwner can do anything to the organization, including deleting it
+	// and handing it over to another member. Each organization has one.
+	RoleOwner Role = "owner"
+	// RoleAdmin manages the organization, its addresses and its members
+	RoleAdmin Role = "admin"
+	// RoleMember can only read the organization and its addresses
+	RoleMember Role = "member"
+)
+
+// roleRanks orders roles by what they allow
+var roleRanks = map[Role]int{RoleMember: 1, RoleAdmin: 2, RoleOwner: 3}
+
+// atLeast reports whether the role allows everything min does. The empty
+// role, of users outside the organization, allows nothing.
+func (r Role) atLeast(min Role) bool {
+	return r != "" && roleRanks[r] >= roleRanks[min]
+}
+
+// MemberResponse represents a member of an organization in API responses
+type MemberResponse struct {
+	UserID   string    `json:"user_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// CreateInvitationRequest represents the request to invite someone to an
+// organization
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  Role   `json:"role" validate:"required,oneof=admin member"`
+}
+
+// InvitationResponse represents an invitation in API responses. The token
+// is only sent to the invited address.
+type InvitationResponse struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"organization_id"`
+	Email          string    `json:"email"`
+	Role           Role      `json:"role"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// AcceptInvitationRequest represents the request to accept an invitation
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// TransferOwnershipRequest represents the request to hand an organization
+// over to another of its members
+type TransferOwnershipRequest struct {
+	UserID int32 `json:"user_id" validate:"required"`
+}
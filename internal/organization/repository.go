@@ -0,0 +1,492 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/authz"
+	"go-test-api/internal/database"
+	"go-test-api/internal/organization/db"
+	"go-test-api/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+var (
+	ErrNotFound          = errors.New("organization not found")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrAlreadyMember     = errors.New("already a member")
+	ErrInvalidInvitation = errors.New("invalid invitation")
+	ErrInvitationEmail   = errors.New("invitation sent to another email address")
+)
+
+// Repo defines the interface for organization data access
+type Repo interface {
+	Create(ctx context.Context, ownerID int32, req *CreateOrganizationRequest) (*OrganizationResponse, error)
+	Get(ctx context.Context, id int32) (*OrganizationResponse, error)
+	List(ctx context.Context, memberID int32, page pagination.Request) ([]*OrganizationResponse, *pagination.Cursor, error)
+	Update(ctx context.Context, id int32, req *UpdateOrganizationRequest) (*OrganizationResponse, error)
+	Delete(ctx context.Context, id int32) error
+	Role(ctx context.Context, id, userID int32) (Role, error)
+	Members(ctx context.Context, id int32, page pagination.Request) ([]*MemberResponse, *pagination.Cursor, error)
+	RemoveMember(ctx context.Context, id, userID int32) error
+	TransferOwnership(ctx context.Context, id, userID int32) (*OrganizationResponse, error)
+	Invite(ctx context.Context, id, invitedBy int32, req *CreateInvitationRequest, tokenHash string, expiresAt time.Time) (*InvitationResponse, error)
+	AcceptInvitation(ctx context.Context, userID int32, tokenHash string) (*OrganizationResponse, error)
+}
+
+// Repository handles organization data access. Organizations belong to the
+// tenant they were created in, and only users of that tenant can see them,
+// join them or own them: every method runs in a transaction scoped to the
+// tenant of the context, and fails with database.ErrNoTenant if it has none.
+type Repository struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+// NewRepository creates a new Repository
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	return &Repository{pool: pool, queries: db.New(pool)}
+}
+
+// inTenant runs fn with queries scoped to the tenant of ctx
+func (r *Repository) inTenant(ctx context.Context, fn func(q *db.Queries) error) error {
+	return database.InTenant(ctx, r.pool, func(tx pgx.Tx) error {
+		return fn(r.queries.WithTx(tx))
+	})
+}
+
+// Create creates a new organization in the tenant of ctx, with ownerID as
+// its owner and only member
+func (r *Repository) Create(ctx context.Context, ownerID int32, req *CreateOrganizationRequest) (*OrganizationResponse, error) {
+	var org db.Organization
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		tenantID, err := strconv.ParseInt(authctx.TenantID(ctx), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid tenant id: %w", err)
+		}
+		now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		org, err = q.CreateOrganization(ctx, db.CreateOrganizationParams{
+			Name:      req.Name,
+			OwnerID:   ownerID,
+			CreatedAt: now,
+			UpdatedAt: now,
+			TenantID:  int32(tenantID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+		_, err = q.AddOrganizationMember(ctx, db.AddOrganizationMemberParams{
+			OrganizationID: org.ID,
+			UserID:         ownerID,
+			Role:           db.OrganizationRoleOwner,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOrganizationResponse(org), nil
+}
+
+// Get retrieves an organization by ID. It returns ErrNotFound if there is
+// none.
+func (r *Repository) Get(ctx context.Context, id int32) (*OrganizationResponse, error) {
+	var org db.Organization
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		org, err = q.GetOrganization(ctx, id)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return toOrganizationResponse(org), nil
+}
+
+// Exists reports whether there is an organization with the ID
+func (r *Repository) Exists(ctx context.Context, id int32) (bool, error) {
+	if _, err := r.Get(ctx, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// organizationSorts are the fields organizations can be listed by; the first
+// is the default
+var organizationSorts = []string{"id"}
+
+// List retrieves a page of the organizations memberID belongs to, or of
+// every organization if memberID is 0, along with the cursor of the next
+// page, if any
+func (r *Repository) List(ctx context.Context, memberID int32, page pagination.Request) ([]*OrganizationResponse, *pagination.Cursor, error) {
+	params := db.ListOrganizationsByIDParams{
+		MemberID: pgtype.Int4{Int32: memberID, Valid: memberID != 0},
+		RowLimit: page.FetchLimit(),
+	}
+	if page.After != nil {
+		params.AfterID = pgtype.Int4{Int32: page.After.ID, Valid: true}
+	}
+
+	var orgs []db.Organization
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		if page.Sort.Desc {
+			orgs, err = q.ListOrganizationsByIDDesc(ctx, db.ListOrganizationsByIDDescParams(params))
+		} else {
+			orgs, err = q.ListOrganizationsByID(ctx, params)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	orgs, next := pagination.Next(page, orgs, func(o db.Organization) (string, int32) {
+		return "", o.ID
+	})
+	res := make([]*OrganizationResponse, len(orgs))
+	for i, o := range orgs {
+		res[i] = toOrganizationResponse(o)
+	}
+	return res, next, nil
+}
+
+// Update renames an organization. It returns ErrNotFound if there is none
+// with the ID.
+func (r *Repository) Update(ctx context.Context, id int32, req *UpdateOrganizationRequest) (*OrganizationResponse, error) {
+	var org db.Organization
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		org, err = q.UpdateOrganization(ctx, db.UpdateOrganizationParams{
+			ID:        id,
+			Name:      req.Name,
+			UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+	return toOrganizationResponse(org), nil
+}
+
+// Delete deletes an organization along with its addresses, members and
+// invitations. It returns ErrNotFound if there is none with the ID.
+func (r *Repository) Delete(ctx context.Context, id int32) error {
+	var deleted int64
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		deleted, err = q.DeleteOrganization(ctx, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Role returns the role of the user in the organization, or the empty role
+// if they are not a member
+func (r *Repository) Role(ctx context.Context, id, userID int32) (Role, error) {
+	var role db.OrganizationRole
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		role, err = q.GetOrganizationMemberRole(ctx, db.GetOrganizationMemberRoleParams{
+			OrganizationID: id,
+			UserID:         userID,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get member role: %w", err)
+	}
+	return Role(role), nil
+}
+
+// MemberRule is an authz.Rule for organizations and the resources they own:
+// members may read them, and admins and the owner may also change them
+func (r *Repository) MemberRule(ctx context.Context, userID, entityID string, action authz.Action) (bool, error) {
+	id, err := strconv.ParseInt(entityID, 10, 32)
+	if err != nil {
+		return false, nil
+	}
+	uid, err := strconv.ParseInt(userID, 10, 32)
+	if err != nil {
+		return false, nil
+	}
+	role, err := r.Role(ctx, int32(id), int32(uid))
+	if err != nil {
+		return false, err
+	}
+	if action == authz.ActionRead {
+		return role.atLeast(RoleMember), nil
+	}
+	return role.atLeast(RoleAdmin), nil
+}
+
+// memberSorts are the fields members can be listed by; the first is the
+// default
+var memberSorts = []string{"user_id"}
+
+// Members retrieves a page of the members of an organization, along with the
+// cursor of the next page, if any
+func (r *Repository) Members(ctx context.Context, id int32, page pagination.Request) ([]*MemberResponse, *pagination.Cursor, error) {
+	params := db.ListOrganizationMembersParams{
+		OrganizationID: id,
+		RowLimit:       page.FetchLimit(),
+	}
+	if page.After != nil {
+		params.AfterID = pgtype.Int4{Int32: page.After.ID, Valid: true}
+	}
+
+	var rows []db.ListOrganizationMembersRow
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		if !page.Sort.Desc {
+			var err error
+			rows, err = q.ListOrganizationMembers(ctx, params)
+			return err
+		}
+		desc, err := q.ListOrganizationMembersDesc(ctx, db.ListOrganizationMembersDescParams(params))
+		for _, m := range desc {
+			rows = append(rows, db.ListOrganizationMembersRow(m))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	rows, next := pagination.Next(page, rows, func(m db.ListOrganizationMembersRow) (string, int32) {
+		return "", m.UserID
+	})
+	res := make([]*MemberResponse, len(rows))
+	for i, m := range rows {
+		res[i] = &MemberResponse{
+			UserID:   strconv.Itoa(int(m.UserID)),
+			Name:     m.Name,
+			Email:    m.Email,
+			Role:     Role(m.Role),
+			JoinedAt: m.CreatedAt.Time,
+		}
+	}
+	return res, next, nil
+}
+
+// RemoveMember removes a user from an organization. It returns
+// ErrMemberNotFound if they are not a member, or are its owner.
+func (r *Repository) RemoveMember(ctx context.Context, id, userID int32) error {
+	var removed int64
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		removed, err = q.RemoveOrganizationMember(ctx, db.RemoveOrganizationMemberParams{
+			OrganizationID: id,
+			UserID:         userID,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if removed == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// TransferOwnership makes a member the owner of an organization, and its
+// previous owner an admin. It returns ErrNotFound if there is no such
+// organization, and ErrMemberNotFound if the user is not a member of it;
+// members only ever belong to the tenant of the organization, so ownership
+// cannot leave it.
+func (r *Repository) TransferOwnership(ctx context.Context, id, userID int32) (*OrganizationResponse, error) {
+	var org db.Organization
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		org, err = q.LockOrganization(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to lock organization: %w", err)
+		}
+		if org.OwnerID == userID {
+			return nil
+		}
+
+		// The previous owner steps down first, since there can only be one
+		_, err = q.SetOrganizationMemberRole(ctx, db.SetOrganizationMemberRoleParams{
+			OrganizationID: id,
+			UserID:         org.OwnerID,
+			Role:           db.OrganizationRoleAdmin,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to demote owner: %w", err)
+		}
+		promoted, err := q.SetOrganizationMemberRole(ctx, db.SetOrganizationMemberRoleParams{
+			OrganizationID: id,
+			UserID:         userID,
+			Role:           db.OrganizationRoleOwner,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to promote member: %w", err)
+		}
+		if promoted == 0 {
+			return ErrMemberNotFound
+		}
+		now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		if _, err := q.SetOrganizationOwner(ctx, db.SetOrganizationOwnerParams{ID: id, OwnerID: userID, UpdatedAt: now}); err != nil {
+			return fmt.Errorf("failed to set owner: %w", err)
+		}
+		org.OwnerID = userID
+		org.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOrganizationResponse(org), nil
+}
+
+// Invite records an invitation to join an organization, which stops earlier
+// ones to the same address from working. Only the hash of its token is
+// stored. It returns ErrAlreadyMember if a member has the email address.
+func (r *Repository) Invite(ctx context.Context, id, invitedBy int32, req *CreateInvitationRequest, tokenHash string, expiresAt time.Time) (*InvitationResponse, error) {
+	var inv db.CreateOrganizationInvitationRow
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		member, err := q.IsOrganizationMemberEmail(ctx, db.IsOrganizationMemberEmailParams{
+			OrganizationID: id,
+			Email:          req.Email,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check members: %w", err)
+		}
+		if member {
+			return ErrAlreadyMember
+		}
+
+		err = q.SupersedeOrganizationInvitations(ctx, db.SupersedeOrganizationInvitationsParams{
+			OrganizationID: id,
+			Email:          req.Email,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to supersede invitations: %w", err)
+		}
+		inv, err = q.CreateOrganizationInvitation(ctx, db.CreateOrganizationInvitationParams{
+			OrganizationID: id,
+			Email:          req.Email,
+			Role:           db.OrganizationRole(req.Role),
+			InvitedBy:      pgtype.Int4{Int32: invitedBy, Valid: true},
+			TokenHash:      tokenHash,
+			ExpiresAt:      pgtype.Timestamptz{Time: expiresAt, Valid: true},
+			CreatedAt:      pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &InvitationResponse{
+		ID:             strconv.FormatInt(inv.ID, 10),
+		OrganizationID: strconv.Itoa(int(inv.OrganizationID)),
+		Email:          inv.Email,
+		Role:           Role(inv.Role),
+		ExpiresAt:      inv.ExpiresAt.Time,
+	}, nil
+}
+
+// AcceptInvitation adds the user to the organization of the invitation with
+// the token hash, with the role it offers, and returns the organization. The
+// invitation can only be used once, and only by the holder of the invited
+// email address. It returns ErrInvalidInvitation if the invitation does not
+// exist, was used or expired, ErrInvitationEmail if it was sent to another
+// address, and ErrAlreadyMember if the user is already a member; in those
+// last two cases the invitation can still be used. Invitations to the
+// organizations of other tenants are invalid.
+func (r *Repository) AcceptInvitation(ctx context.Context, userID int32, tokenHash string) (*OrganizationResponse, error) {
+	var org db.Organization
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		email, err := q.GetUserEmail(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user email: %w", err)
+		}
+		now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		inv, err := q.AcceptOrganizationInvitation(ctx, db.AcceptOrganizationInvitationParams{
+			TokenHash:  tokenHash,
+			AcceptedAt: now,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidInvitation
+			}
+			return fmt.Errorf("failed to accept invitation: %w", err)
+		}
+		if !strings.EqualFold(inv.Email, email) {
+			return ErrInvitationEmail
+		}
+
+		_, err = q.AddOrganizationMember(ctx, db.AddOrganizationMemberParams{
+			OrganizationID: inv.OrganizationID,
+			UserID:         userID,
+			Role:           inv.Role,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return ErrAlreadyMember
+			}
+			return fmt.Errorf("failed to add member: %w", err)
+		}
+		org, err = q.GetOrganization(ctx, inv.OrganizationID)
+		if err != nil {
+			return fmt.Errorf("failed to get organization: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOrganizationResponse(org), nil
+}
+
+func toOrganizationResponse(org db.Organization) *OrganizationResponse {
+	return &OrganizationResponse{
+		ID:      strconv.Itoa(int(org.ID)),
+		Name:    org.Name,
+		OwnerID: strconv.Itoa(int(org.OwnerID)),
+	}
+}
@@ -0,0 +1,210 @@
+//go:build integration
+// +build integration
+
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/authz"
+	"go-test-api/internal/config"
+	"go-test-api/internal/database"
+	"go-test-api/internal/pagination"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var testDB *pgxpool.Pool
+
+// defaultTenant is the context of requests made in the tenant users are
+// created in
+var defaultTenant = authctx.WithTenant(context.Background(), "1")
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	testDB, err = database.New(ctx, cfg.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	testDB.Close()
+	os.Exit(code)
+}
+
+// setupRepository empties the tables involved and returns a Repository
+func setupRepository(t *testing.T) *Repository {
+	t.Helper()
+	_, err := testDB.Exec(context.Background(), "TRUNCATE users, addresses RESTART IDENTITY CASCADE")
+	if err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	return NewRepository(testDB)
+}
+
+// createUser inserts a user in the default tenant and returns their ID
+func createUser(t *testing.T, name, email string) int32 {
+	t.Helper()
+	return createTenantUser(t, "1", name, email)
+}
+
+// createTenantUser inserts a user in the tenant and returns their ID
+func createTenantUser(t *testing.T, tenantID, name, email string) int32 {
+	t.Helper()
+	var id int32
+	err := testDB.QueryRow(context.Background(), `INSERT INTO users (name, email, password_hash, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, 'hashedpassword', NOW(), NOW(), $3) RETURNING id`, name, email, tenantID).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return id
+}
+
+func TestRepository_Membership_Integration(t *testing.T) {
+	repo := setupRepository(t)
+	ctx := defaultTenant
+	alice := createUser(t, "Alice", "alice@example.com")
+	bob := createUser(t, "Bob", "bob@example.com")
+
+	org, err := repo.Create(ctx, alice, &CreateOrganizationRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	var id int32
+	fmt.Sscan(org.ID, &id)
+
+	if role, err := repo.Role(ctx, id, alice); err != nil || role != RoleOwner {
+		t.Fatalf("Expected the creator to be the owner, got %q (%v)", role, err)
+	}
+
+	// Bob joins as a member through an invitation to his address
+	invitation := &CreateInvitationRequest{Email: "BOB@example.com", Role: RoleMember}
+	if _, err := repo.Invite(ctx, id, alice, invitation, "hash-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to invite: %v", err)
+	}
+	if _, err := repo.AcceptInvitation(ctx, alice, "hash-1"); !errors.Is(err, ErrInvitationEmail) {
+		t.Fatalf("Expected ErrInvitationEmail accepting someone else's invitation, got %v", err)
+	}
+	if _, err := repo.AcceptInvitation(ctx, bob, "hash-1"); err != nil {
+		t.Fatalf("Failed to accept invitation: %v", err)
+	}
+	if _, err := repo.AcceptInvitation(ctx, bob, "hash-1"); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("Expected ErrInvalidInvitation accepting twice, got %v", err)
+	}
+	if _, err := repo.Invite(ctx, id, alice, invitation, "hash-2", time.Now().Add(time.Hour)); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("Expected ErrAlreadyMember inviting a member, got %v", err)
+	}
+
+	// Members can read the organization; only admins and the owner change it
+	for _, tt := range []struct {
+		userID   int32
+		action   authz.Action
+		expected bool
+	}{
+		{alice, authz.ActionWrite, true},
+		{bob, authz.ActionRead, true},
+		{bob, authz.ActionWrite, false},
+	} {
+		allowed, err := repo.MemberRule(ctx, fmt.Sprint(tt.userID), org.ID, tt.action)
+		if err != nil || allowed != tt.expected {
+			t.Errorf("Expected user %d to be allowed to %s: %v, got %v (%v)", tt.userID, tt.action, tt.expected, allowed, err)
+		}
+	}
+
+	orgs, _, err := repo.List(ctx, bob, pagination.Request{Limit: pagination.DefaultLimit, Sort: pagination.Sort{Field: "id"}})
+	if err != nil || len(orgs) != 1 {
+		t.Errorf("Expected Bob to list 1 organization, got %v (%v)", orgs, err)
+	}
+
+	// The owner cannot be removed, but can hand the organization over
+	if err := repo.RemoveMember(ctx, id, alice); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Expected ErrMemberNotFound removing the owner, got %v", err)
+	}
+	transferred, err := repo.TransferOwnership(ctx, id, bob)
+	if err != nil {
+		t.Fatalf("Failed to transfer ownership: %v", err)
+	}
+	if transferred.OwnerID != fmt.Sprint(bob) {
+		t.Errorf("Expected owner %d, got %s", bob, transferred.OwnerID)
+	}
+	if role, _ := repo.Role(ctx, id, alice); role != RoleAdmin {
+		t.Errorf("Expected the previous owner to become an admin, got %q", role)
+	}
+	if err := repo.RemoveMember(ctx, id, alice); err != nil {
+		t.Errorf("Failed to remove the previous owner: %v", err)
+	}
+	if _, err := repo.TransferOwnership(ctx, id, alice); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Expected ErrMemberNotFound transferring to a non-member, got %v", err)
+	}
+}
+
+func TestRepository_TenantIsolation_Integration(t *testing.T) {
+	repo := setupRepository(t)
+	var globexID string
+	err := testDB.QueryRow(context.Background(), `INSERT INTO tenants (name, slug) VALUES ('globex', 'globex')
+		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name RETURNING id::text`).Scan(&globexID)
+	if err != nil {
+		t.Fatalf("Failed to create tenant: %v", err)
+	}
+	globex := authctx.WithTenant(context.Background(), globexID)
+	alice := createUser(t, "Alice", "alice@example.com")
+	mallory := createTenantUser(t, globexID, "Mallory", "mallory@example.com")
+
+	org, err := repo.Create(defaultTenant, alice, &CreateOrganizationRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	var id int32
+	fmt.Sscan(org.ID, &id)
+
+	// The organization is out of sight of other tenants
+	if _, err := repo.Get(globex, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound reading an organization of another tenant, got %v", err)
+	}
+	page := pagination.Request{Limit: pagination.DefaultLimit, Sort: pagination.Sort{Field: "id"}}
+	if members, _, err := repo.Members(globex, id, page); err != nil || len(members) != 0 {
+		t.Errorf("Expected no members listed from another tenant, got %v (%v)", members, err)
+	}
+	if orgs, _, err := repo.List(globex, 0, page); err != nil || len(orgs) != 0 {
+		t.Errorf("Expected no organizations listed from another tenant, got %v (%v)", orgs, err)
+	}
+
+	// Invitations cannot bring in users of another tenant
+	invitation := &CreateInvitationRequest{Email: "mallory@example.com", Role: RoleAdmin}
+	if _, err := repo.Invite(defaultTenant, id, alice, invitation, "hash-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to invite: %v", err)
+	}
+	if _, err := repo.AcceptInvitation(globex, mallory, "hash-1"); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("Expected ErrInvalidInvitation accepting an invitation of another tenant, got %v", err)
+	}
+	_, err = testDB.Exec(context.Background(), `INSERT INTO organization_members (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, 'member', NOW())`, id, mallory)
+	if err == nil {
+		t.Errorf("Expected adding a member of another tenant to fail")
+	}
+
+	// Nor can ownership be handed to them
+	if _, err := repo.TransferOwnership(defaultTenant, id, mallory); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Expected ErrMemberNotFound transferring to a user of another tenant, got %v", err)
+	}
+	if role, err := repo.Role(defaultTenant, id, alice); err != nil || role != RoleOwner {
+		t.Errorf("Expected Alice to remain the owner, got %q (%v)", role, err)
+	}
+
+	if _, err := repo.Get(context.Background(), id); !errors.Is(err, database.ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant without a tenant, got %v", err)
+	}
+}
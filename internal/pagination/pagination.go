@@ -0,0 +1,185 @@
+// Package pagination implements keyset pagination for list endpoints. Each
+// page ends with an opaque cursor naming the last row returned by its sort
+// value and ID, and the next page is read from just after that row, so deep
+// pages cost as little as the first one. Cursors are signed so that clients
+// cannot forge positions or reuse them with another sort order.
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes, as set with the limit query parameter
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidLimit  = errors.New("invalid limit")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Sort is the order of a list: by a whitelisted field, ascending unless Desc.
+// Rows with the same value are ordered by ID in the same direction.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// String returns the sort as given in the sort query parameter: the field,
+// prefixed with "-" when descending
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Cursor is the position of the last row of a page: its value of the sort
+// field, formatted by the list that issued it, and its ID
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int32  `json:"id"`
+}
+
+// TimeValue formats a timestamp as the sort value of a cursor
+func TimeValue(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// Time parses a sort value made with TimeValue
+func (c *Cursor) Time() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return t, nil
+}
+
+// Request holds the pagination parameters of a list request
+type Request struct {
+	Limit int
+	Sort  Sort
+	// After is the position the page starts after, or nil for the first page
+	After *Cursor
+}
+
+// FetchLimit is how many rows to read for the page: one more than the limit,
+// to learn whether another page follows
+func (r Request) FetchLimit() int32 {
+	return int32(r.Limit + 1)
+}
+
+// Page is the response envelope of a paginated list. Data holds the rows of
+// the page and NextCursor is omitted on the last one.
+type Page struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// Paginator parses pagination query parameters and signs cursors
+type Paginator struct {
+	key []byte
+}
+
+// New creates a Paginator that signs cursors with key
+func New(key []byte) *Paginator {
+	return &Paginator{key: key}
+}
+
+// Parse reads the limit, sort and cursor query parameters. sorts lists the
+// fields the list can be sorted by; the first one is the default, ascending.
+// Errors wrap ErrInvalidLimit, ErrInvalidSort or ErrInvalidCursor and are
+// meant to be shown to the client.
+func (p *Paginator) Parse(query url.Values, sorts ...string) (Request, error) {
+	req := Request{Limit: DefaultLimit, Sort: Sort{Field: sorts[0]}}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return Request{}, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidLimit, MaxLimit)
+		}
+		req.Limit = n
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		field, desc := strings.CutPrefix(sort, "-")
+		if !slices.Contains(sorts, field) {
+			return Request{}, fmt.Errorf("%w: must be one of %s, optionally prefixed with -", ErrInvalidSort, strings.Join(sorts, ", "))
+		}
+		req.Sort = Sort{Field: field, Desc: desc}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := p.decode(cursor)
+		if err != nil || after.Sort != req.Sort.String() {
+			return Request{}, fmt.Errorf("%w: it may belong to another sort order", ErrInvalidCursor)
+		}
+		req.After = after
+	}
+
+	return req, nil
+}
+
+// Next returns the rows of the page out of rows read with FetchLimit, and the
+// cursor of the next page, or nil if this is the last one. position returns
+// the sort value and ID of a row.
+func Next[T any](req Request, rows []T, position func(T) (string, int32)) ([]T, *Cursor) {
+	if len(rows) <= req.Limit {
+		return rows, nil
+	}
+	rows = rows[:req.Limit]
+	value, id := position(rows[len(rows)-1])
+	return rows, &Cursor{Sort: req.Sort.String(), Value: value, ID: id}
+}
+
+// Encode returns the signed, opaque form of a cursor, or "" for nil
+func (p *Paginator) Encode(c *Cursor) string {
+	if c == nil {
+		return ""
+	}
+	// Marshalling a struct of strings and ints cannot fail
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(p.sign(encoded))
+}
+
+// decode verifies and parses a cursor made by Encode
+func (p *Paginator) decode(s string) (*Cursor, error) {
+	encoded, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, p.sign(encoded)) {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func (p *Paginator) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
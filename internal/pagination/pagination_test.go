@@ -0,0 +1,153 @@
+//go:build unit
+
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaginator_Parse(t *testing.T) {
+	p := New([]byte("test-secret"))
+	cursor := p.Encode(&Cursor{Sort: "-name", Value: "Bob", ID: 7})
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedLimit int
+		expectedSort  string
+		expectedAfter *Cursor
+		expectedErr   error
+	}{
+		{
+			name:          "defaults",
+			expectedLimit: DefaultLimit,
+			expectedSort:  "id",
+		},
+		{
+			name:          "limit and descending sort",
+			query:         "limit=5&sort=-name",
+			expectedLimit: 5,
+			expectedSort:  "-name",
+		},
+		{
+			name:          "maximum limit",
+			query:         "limit=100",
+			expectedLimit: MaxLimit,
+			expectedSort:  "id",
+		},
+		{
+			name:          "cursor",
+			query:         "sort=-name&cursor=" + cursor,
+			expectedLimit: DefaultLimit,
+			expectedSort:  "-name",
+			expectedAfter: &Cursor{Sort: "-name", Value: "Bob", ID: 7},
+		},
+		{name: "zero limit", query: "limit=0", expectedErr: ErrInvalidLimit},
+		{name: "limit above maximum", query: "limit=101", expectedErr: ErrInvalidLimit},
+		{name: "non-numeric limit", query: "limit=ten", expectedErr: ErrInvalidLimit},
+		{name: "unknown sort field", query: "sort=password_hash", expectedErr: ErrInvalidSort},
+		{name: "cursor of another sort", query: "sort=name&cursor=" + cursor, expectedErr: ErrInvalidCursor},
+		{name: "cursor of the default sort", query: "cursor=" + cursor, expectedErr: ErrInvalidCursor},
+		{name: "garbage cursor", query: "sort=-name&cursor=not-a-cursor", expectedErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid query: %v", err)
+			}
+
+			req, err := p.Parse(query, "id", "name", "created_at")
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if req.Limit != tt.expectedLimit {
+				t.Errorf("expected limit %d, got %d", tt.expectedLimit, req.Limit)
+			}
+			if req.Sort.String() != tt.expectedSort {
+				t.Errorf("expected sort %q, got %q", tt.expectedSort, req.Sort.String())
+			}
+			if (req.After == nil) != (tt.expectedAfter == nil) || (req.After != nil && *req.After != *tt.expectedAfter) {
+				t.Errorf("expected cursor %+v, got %+v", tt.expectedAfter, req.After)
+			}
+		})
+	}
+}
+
+func TestPaginator_Encode_Tampered(t *testing.T) {
+	p := New([]byte("test-secret"))
+	cursor := p.Encode(&Cursor{Sort: "id", ID: 7})
+	payload, sig, _ := strings.Cut(cursor, ".")
+
+	forged := New([]byte("other-secret")).Encode(&Cursor{Sort: "id", ID: 1000})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	for name, c := range map[string]string{
+		"other key":         forged,
+		"swapped payload":   forgedPayload + "." + sig,
+		"missing signature": payload,
+		"empty signature":   payload + ".",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Parse(url.Values{"cursor": {c}}, "id"); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+
+	if p.Encode(nil) != "" {
+		t.Error("expected no cursor for nil")
+	}
+}
+
+func TestNext(t *testing.T) {
+	type row struct {
+		id   int32
+		name string
+	}
+	position := func(r row) (string, int32) { return r.name, r.id }
+	rows := []row{{1, "Alice"}, {2, "Bob"}, {3, "Carol"}}
+
+	req := Request{Limit: 2, Sort: Sort{Field: "name", Desc: true}}
+	page, next := Next(req, rows, position)
+	if len(page) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(page))
+	}
+	if next == nil || *next != (Cursor{Sort: "-name", Value: "Bob", ID: 2}) {
+		t.Errorf("expected a cursor after Bob, got %+v", next)
+	}
+
+	req.Limit = 3
+	page, next = Next(req, rows, position)
+	if len(page) != 3 || next != nil {
+		t.Errorf("expected the last page of 3 rows, got %d rows and cursor %+v", len(page), next)
+	}
+}
+
+func TestCursor_Time(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	c := &Cursor{Sort: "created_at", Value: TimeValue(created), ID: 1}
+	got, err := c.Time()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Equal(created) {
+		t.Errorf("expected %v, got %v", created, got)
+	}
+
+	c.Value = "yesterday"
+	if _, err := c.Time(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
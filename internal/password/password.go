@@ -0,0 +1,195 @@
+// Package password hashes and verifies passwords. Hashes are stored as PHC
+// strings that name their algorithm and parameters, so hashes made with
+// older settings keep verifying and can be recognised and upgraded.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+var (
+	ErrMismatch    = errors.New("password does not match")
+	ErrUnsupported = errors.New("unsupported password hash")
+	ErrTooLong     = errors.New("password too long")
+)
+
+// Argon2idParams are the cost parameters of argon2id
+type Argon2idParams struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Config selects the algorithm and parameters new hashes are made with
+type Config struct {
+	Algorithm  string
+	Argon2id   Argon2idParams
+	BcryptCost int
+}
+
+// DefaultConfig returns argon2id with the minimum parameters recommended by
+// OWASP, and the default bcrypt cost for when bcrypt is selected
+func DefaultConfig() Config {
+	return Config{
+		Algorithm: Argon2id,
+		Argon2id: Argon2idParams{
+			Memory:      19 * 1024,
+			Iterations:  2,
+			Parallelism: 1,
+		},
+		BcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes
+// made with any supported algorithm
+type Hasher struct {
+	cfg Config
+}
+
+// New creates a Hasher, checking that the configuration is usable
+func New(cfg Config) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case Argon2id:
+		p := cfg.Argon2id
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+		}
+	case Bcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", cfg.BcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.Algorithm)
+	}
+	return &Hasher{cfg: cfg}, nil
+}
+
+// Default returns a Hasher using DefaultConfig
+func Default() *Hasher {
+	return &Hasher{cfg: DefaultConfig()}
+}
+
+// Hash hashes a password with the configured algorithm
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return "", ErrTooLong
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	p := h.cfg.Argon2id
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, keyLength)
+	return encodeArgon2id(p, salt, key), nil
+}
+
+// Verify checks a password against a hash made with any supported algorithm.
+// It returns ErrMismatch if the password is wrong.
+func (h *Hasher) Verify(encoded, password string) error {
+	if isBcrypt(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm or
+// other parameters than the configured ones
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	if isBcrypt(encoded) {
+		if h.cfg.Algorithm != Bcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.cfg.BcryptCost
+	}
+
+	p, _, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return h.cfg.Algorithm != Argon2id || p != h.cfg.Argon2id || len(key) != keyLength
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+// encodeArgon2id formats an argon2id hash as a PHC string:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func encodeArgon2id(p Argon2idParams, salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != Argon2id {
+		return p, nil, nil, ErrUnsupported
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnsupported
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnsupported
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnsupported
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnsupported
+	}
+	return p, salt, key, nil
+}
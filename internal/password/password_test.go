@@ -0,0 +1,113 @@
+//go:build unit
+
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast
+var (
+	testArgon2id = Config{Algorithm: Argon2id, Argon2id: Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1}}
+	testBcrypt   = Config{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+)
+
+func mustNew(t *testing.T, cfg Config) *Hasher {
+	t.Helper()
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create hasher: %v", err)
+	}
+	return h
+}
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            Config
+		expectedPrefix string
+	}{
+		{name: "argon2id", cfg: testArgon2id, expectedPrefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{name: "bcrypt", cfg: testBcrypt, expectedPrefix: "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mustNew(t, tt.cfg)
+
+			hash, err := h.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatalf("failed to hash password: %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.expectedPrefix) {
+				t.Errorf("expected hash to start with %s, got %s", tt.expectedPrefix, hash)
+			}
+			if err := h.Verify(hash, "correct horse battery staple"); err != nil {
+				t.Errorf("expected password to verify, got %v", err)
+			}
+			if err := h.Verify(hash, "wrong password"); !errors.Is(err, ErrMismatch) {
+				t.Errorf("expected ErrMismatch, got %v", err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Error("expected a fresh hash not to need rehashing")
+			}
+		})
+	}
+}
+
+func TestHasher_VerifiesOtherAlgorithms(t *testing.T) {
+	bcryptHash, err := mustNew(t, testBcrypt).Hash("secret password")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	// Hashes made before switching algorithms keep working, but get upgraded
+	h := mustNew(t, testArgon2id)
+	if err := h.Verify(bcryptHash, "secret password"); err != nil {
+		t.Errorf("expected bcrypt hash to verify, got %v", err)
+	}
+	if !h.NeedsRehash(bcryptHash) {
+		t.Error("expected bcrypt hash to need rehashing under argon2id")
+	}
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	oldHash, err := mustNew(t, testArgon2id).Hash("secret password")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	stronger := testArgon2id
+	stronger.Argon2id.Iterations = 2
+	if !mustNew(t, stronger).NeedsRehash(oldHash) {
+		t.Error("expected hash with outdated parameters to need rehashing")
+	}
+
+	bcryptHash, err := mustNew(t, testBcrypt).Hash("secret password")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	costlier := testBcrypt
+	costlier.BcryptCost = bcrypt.MinCost + 1
+	if !mustNew(t, costlier).NeedsRehash(bcryptHash) {
+		t.Error("expected bcrypt hash with outdated cost to need rehashing")
+	}
+}
+
+func TestHasher_Invalid(t *testing.T) {
+	h := mustNew(t, testArgon2id)
+
+	if err := h.Verify("plaintext", "plaintext"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := mustNew(t, testBcrypt).Hash(strings.Repeat("a", 73)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("expected ErrTooLong from bcrypt, got %v", err)
+	}
+	if _, err := New(Config{Algorithm: "md5"}); err == nil {
+		t.Error("expected an unknown algorithm to be rejected")
+	}
+}
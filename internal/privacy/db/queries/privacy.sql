@@ -0,0 +1,157 @@
+-- name: ExportUser :one
+SELECT id, name, email, email_verified_at, created_at, updated_at
+FROM users
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ExportUserRoles :many
+SELECT r.name, ur.created_at
+FROM user_roles ur
+JOIN roles r ON r.id = ur.role_id
+WHERE ur.user_id = $1
+ORDER BY r.name;
+
+-- name: ExportUserAddresses :many
+-- Deleted addresses are still held until purged, so they are exported too
+SELECT id, entity_type, entity_id, address_type, street_line1, street_line2,
+    city, state, postal_code, country, created_at, updated_at, deleted_at, tenant_id
+FROM addresses
+WHERE entity_type = 'user' AND entity_id = $1
+ORDER BY id;
+
+-- name: ExportUserIdentities :many
+SELECT provider, subject, email, last_login_at, created_at
+FROM user_identities
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ExportUserSessions :many
+SELECT method, mfa, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+FROM sessions
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserAPIKeys :many
+SELECT name, prefix, scopes, expires_at, last_used_at, created_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ExportUserTOTP :many
+SELECT confirmed_at, created_at
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: ExportUserEmailChanges :many
+SELECT new_email, expires_at, used_at, created_at
+FROM email_change_tokens
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ExportUserAuditEvents :many
+SELECT event_type, ip_address, details, created_at
+FROM audit_events
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: ExportUserOrganizations :many
+SELECT o.id, o.name, m.role, m.created_at
+FROM organization_members m
+JOIN organizations o ON o.id = m.organization_id
+WHERE m.user_id = $1
+ORDER BY o.id;
+
+-- name: ExportOrganizationInvitations :many
+-- Invitations sent to the user's addresses
+SELECT organization_id, role, expires_at, accepted_at, created_at
+FROM organization_invitations
+WHERE email = ANY(@emails::text[])
+ORDER BY id;
+
+-- name: ExportFailedLogins :many
+SELECT email, ip_address, created_at
+FROM failed_login_attempts
+WHERE email = ANY(@emails::text[])
+ORDER BY id;
+
+-- name: ExportLoginLockouts :many
+SELECT email, locked_until, unlocked_at, created_at
+FROM login_lockouts
+WHERE email = ANY(@emails::text[])
+ORDER BY id;
+
+-- name: ListUserEmails :many
+-- Every email address the user is known by: failed logins and lockouts are
+-- recorded by email rather than by user. Only addresses the user proved to
+-- hold count; anyone can ask to change their email to someone else's.
+SELECT email FROM users WHERE id = @user_id
+UNION
+SELECT email FROM user_identities WHERE user_id = @user_id AND email_verified
+UNION
+SELECT new_email FROM email_change_tokens WHERE user_id = @user_id AND used_at IS NOT NULL;
+
+-- name: LockUserForErasure :one
+SELECT id, email
+FROM users
+WHERE id = $1 AND tenant_id = $2
+FOR UPDATE;
+
+-- name: DeleteUserAddresses :execrows
+-- The trigger deleting addresses with their user would do, but does not count
+-- them for the audit record
+DELETE FROM addresses
+WHERE entity_type = 'user' AND entity_id = $1;
+
+-- name: DeleteLoginHistory :exec
+WITH deleted_attempts AS (
+    DELETE FROM failed_login_attempts
+    WHERE email = ANY(@emails::text[])
+)
+DELETE FROM login_lockouts
+WHERE email = ANY(@emails::text[]);
+
+-- name: AnonymizeAuditEvents :execrows
+-- Audit events outlive the user, but not their email or IP addresses. The
+-- email is that of the event's subject, and the IP that of whoever made the
+-- request: the actor if there is one, the subject otherwise. Other people's
+-- details stay, such as those of users an erased administrator acted on.
+UPDATE audit_events
+SET email = CASE
+        WHEN user_id = @user_id::int OR email = ANY(@emails::text[]) THEN NULL
+        ELSE email
+    END,
+    ip_address = CASE
+        WHEN actor_id = @user_id::int THEN NULL
+        WHEN actor_id IS NULL AND (user_id = @user_id::int OR email = ANY(@emails::text[])) THEN NULL
+        ELSE ip_address
+    END
+WHERE user_id = @user_id::int
+   OR actor_id = @user_id::int
+   OR email = ANY(@emails::text[]);
+
+-- name: DeleteOrganizationInvitations :exec
+DELETE FROM organization_invitations
+WHERE email = ANY(@emails::text[]);
+
+-- name: DeleteUser :execrows
+-- Everything else the user owns cascades, and audit events lose the reference
+DELETE FROM users
+WHERE id = $1;
+
+-- name: OwnsSharedOrganization :one
+-- Whether the user owns an organization someone else is a member of, which
+-- must be handed over before they can be erased
+SELECT EXISTS (
+    SELECT 1
+    FROM organizations o
+    JOIN organization_members m ON m.organization_id = o.id
+    WHERE o.owner_id = $1 AND m.user_id <> o.owner_id
+);
+
+-- name: DeleteOwnedOrganizations :execrows
+-- Organizations the user is the only member of go with them
+DELETE FROM organizations o
+WHERE o.owner_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM organization_members m
+    WHERE m.organization_id = o.id AND m.user_id <> o.owner_id
+);
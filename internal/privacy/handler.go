@@ -0,0 +1,164 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/pkg/response"
+)
+
+// DataService exports and erases users' personal data
+type DataService interface {
+	Export(ctx context.Context, userID int32, w io.Writer) error
+	Erase(ctx context.Context, userID, actorID int32, ip string) error
+}
+
+// Handler handles HTTP requests for users' data protection rights
+type Handler struct {
+	service  DataService
+	clientIP func(*http.Request) string
+}
+
+// NewHandler creates a new privacy Handler. clientIP returns the address
+// recorded when an administrator erases a user.
+func NewHandler(service DataService, clientIP func(*http.Request) string) *Handler {
+	return &Handler{service: service, clientIP: clientIP}
+}
+
+// Export handles GET /users/me/export
+// @Summary Export your data
+// @Description Download an archive of everything held about the authenticated user: their profile, roles, addresses (including deleted ones not purged yet), linked identities, sessions, API keys, two-factor enrollment, email changes, organization memberships and invitations, audit events, and failed logins and lockouts of their email addresses. Secrets such as password and API key hashes are left out. The archive is streamed, so an error midway leaves it truncated. Not available to API keys.
+// @Tags users
+// @Produce json
+// @Success 200 {object} Export
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me/export [get]
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentUser(w, r, "API keys cannot export account data")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"user-%d-export.json\"", id))
+	cw := &countingWriter{w: w}
+	err := h.service.Export(r.Context(), id, cw)
+	if err == nil {
+		return
+	}
+	if cw.n > 0 {
+		// The status has been sent already, all that is left is to stop
+		slog.Error("Failed to export account data", "user_id", id, "error", err)
+		return
+	}
+
+	w.Header().Del("Content-Disposition")
+	if errors.Is(err, ErrNotFound) {
+		response.Error(w, http.StatusNotFound, "User not found")
+		return
+	}
+	response.Error(w, http.StatusInternalServerError, "Failed to export account data")
+}
+
+// EraseMe handles DELETE /users/me
+// @Summary Erase your account
+// @Description Irreversibly erase the authenticated user's account and everything they own, including their addresses and the organizations only they are a member of. Organizations with other members must be handed over first. Unlike an administrator's delete, it cannot be restored. Audit events about the user are kept without their email or IP addresses, and the erasure is recorded in the audit log. Sessions, refresh tokens and API keys are erased with it, and access tokens already issued stop working at once. Not available to API keys.
+// @Tags users
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me [delete]
+func (h *Handler) EraseMe(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentUser(w, r, "API keys cannot erase accounts")
+	if !ok {
+		return
+	}
+	h.erase(w, r, id, id)
+}
+
+// EraseUser handles POST /admin/users/{id}/erase
+// @Summary Erase a user
+// @Description Irreversibly erase a user and everything they own, including their addresses and the organizations only they are a member of, to honor a request for erasure. Organizations with other members must be handed over first. Deleted users that have not been purged yet can be erased too. Audit events about the user are kept without their email or IP addresses, and the erasure is recorded in the audit log under the administrator. The user's access tokens stop working at once. Requires the users:erase permission; not available to API keys.
+// @Tags users
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users/{id}/erase [post]
+func (h *Handler) EraseUser(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := currentUser(w, r, "API keys cannot erase accounts")
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	h.erase(w, r, int32(id), actorID)
+}
+
+// erase erases the user on behalf of actorID and answers the request
+func (h *Handler) erase(w http.ResponseWriter, r *http.Request, id, actorID int32) {
+	if err := h.service.Erase(r.Context(), id, actorID, h.clientIP(r)); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if errors.Is(err, ErrOwnsOrganization) {
+			response.Error(w, http.StatusConflict, "Transfer ownership of organizations with other members first")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to erase user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentUser returns the ID of the authenticated user, rejecting requests
+// made with an API key with apiKeyMessage. It returns false if the request
+// was answered.
+func currentUser(w http.ResponseWriter, r *http.Request, apiKeyMessage string) (int32, bool) {
+	id, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid user")
+		return 0, false
+	}
+	if authctx.IsAPIKey(r.Context()) {
+		response.Error(w, http.StatusForbidden, apiKeyMessage)
+		return 0, false
+	}
+	return int32(id), true
+}
+
+// countingWriter counts the bytes written through it, to tell whether a
+// response has started
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,235 @@
+//go:build unit
+
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-test-api/internal/authctx"
+)
+
+// mockDataService is a mock implementation of DataService for testing
+type mockDataService struct {
+	exportFunc func(ctx context.Context, userID int32, w io.Writer) error
+	eraseFunc  func(ctx context.Context, userID, actorID int32, ip string) error
+}
+
+func (m *mockDataService) Export(ctx context.Context, userID int32, w io.Writer) error {
+	if m.exportFunc != nil {
+		return m.exportFunc(ctx, userID, w)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockDataService) Erase(ctx context.Context, userID, actorID int32, ip string) error {
+	if m.eraseFunc != nil {
+		return m.eraseFunc(ctx, userID, actorID, ip)
+	}
+	return errors.New("not implemented")
+}
+
+func clientIP(*http.Request) string { return "203.0.113.7" }
+
+func TestHandler_Export(t *testing.T) {
+	tests := []struct {
+		name           string
+		apiKey         bool
+		mockExport     func(ctx context.Context, userID int32, w io.Writer) error
+		expectedStatus int
+		attachment     bool
+	}{
+		{
+			name: "archive",
+			mockExport: func(ctx context.Context, userID int32, w io.Writer) error {
+				if userID != 7 {
+					return errors.New("unexpected id")
+				}
+				_, err := io.WriteString(w, `{"profile":{"id":"7"}}`)
+				return err
+			},
+			expectedStatus: http.StatusOK,
+			attachment:     true,
+		},
+		{
+			name: "user not found",
+			mockExport: func(ctx context.Context, userID int32, w io.Writer) error {
+				return ErrNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "error before streaming",
+			mockExport: func(ctx context.Context, userID int32, w io.Writer) error {
+				return errors.New("database connection failed")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "error while streaming",
+			mockExport: func(ctx context.Context, userID int32, w io.Writer) error {
+				io.WriteString(w, `{"profile":{"id":"7"}`)
+				return errors.New("database connection failed")
+			},
+			expectedStatus: http.StatusOK,
+			attachment:     true,
+		},
+		{name: "API key", apiKey: true, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := NewHandler(&mockDataService{exportFunc: tt.mockExport}, clientIP)
+
+			ctx := authctx.WithUser(context.Background(), "7", "alice@example.com")
+			if tt.apiKey {
+				ctx = authctx.WithAPIKey(ctx)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/users/me/export", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			handler.Export(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			disposition := w.Header().Get("Content-Disposition")
+			if tt.attachment && disposition != `attachment; filename="user-7-export.json"` {
+				t.Errorf("expected the archive as an attachment, got %q", disposition)
+			}
+			if !tt.attachment && disposition != "" {
+				t.Errorf("expected no attachment, got %q", disposition)
+			}
+		})
+	}
+}
+
+func TestHandler_EraseMe(t *testing.T) {
+	var erased, actor int32
+	var ip string
+	handler := NewHandler(&mockDataService{
+		eraseFunc: func(ctx context.Context, userID, actorID int32, clientIP string) error {
+			erased, actor, ip = userID, actorID, clientIP
+			return nil
+		},
+	}, clientIP)
+
+	ctx := authctx.WithUser(context.Background(), "7", "alice@example.com")
+	req := httptest.NewRequest(http.MethodDelete, "/users/me", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	handler.EraseMe(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if erased != 7 || actor != 7 || ip != "203.0.113.7" {
+		t.Errorf("expected user 7 to erase themselves from 203.0.113.7, got user %d by %d from %q", erased, actor, ip)
+	}
+
+	// API keys cannot erase the account they belong to
+	req = httptest.NewRequest(http.MethodDelete, "/users/me", nil).WithContext(authctx.WithAPIKey(ctx))
+	w = httptest.NewRecorder()
+	handler.EraseMe(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for an API key, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestHandler_EraseUser(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		mockErase      func(ctx context.Context, userID, actorID int32, ip string) error
+		expectedStatus int
+	}{
+		{
+			name: "existing user",
+			id:   "8",
+			mockErase: func(ctx context.Context, userID, actorID int32, ip string) error {
+				if userID != 8 || actorID != 1 {
+					return errors.New("unexpected ids")
+				}
+				return nil
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "user not found",
+			id:   "9",
+			mockErase: func(ctx context.Context, userID, actorID int32, ip string) error {
+				return ErrNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "owner of a shared organization",
+			id:   "8",
+			mockErase: func(ctx context.Context, userID, actorID int32, ip string) error {
+				return ErrOwnsOrganization
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{name: "invalid ID", id: "abc", expectedStatus: http.StatusBadRequest},
+		{
+			name: "database error",
+			id:   "8",
+			mockErase: func(ctx context.Context, userID, actorID int32, ip string) error {
+				return errors.New("database connection failed")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := NewHandler(&mockDataService{eraseFunc: tt.mockErase}, clientIP)
+
+			ctx := authctx.WithUser(context.Background(), "1", "admin@example.com")
+			req := httptest.NewRequest(http.MethodPost, "/admin/users/"+tt.id+"/erase", nil).WithContext(ctx)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			handler.EraseUser(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestObjectWriter(t *testing.T) {
+	var buf bytes.Buffer
+	out := &objectWriter{w: &buf}
+	out.member("exported_at", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	out.member("profile", Profile{ID: "7", Email: "alice@example.com"})
+	out.member("roles", []Role{{Name: "user"}})
+	if err := out.close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var export Export
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("expected a JSON archive, got %v: %s", err, buf.String())
+	}
+	if export.Profile.Email != "alice@example.com" || len(export.Roles) != 1 {
+		t.Errorf("unexpected archive: %+v", export)
+	}
+
+	buf.Reset()
+	if err := (&objectWriter{w: &buf}).close(); err != nil || buf.String() != "{}\n" {
+		t.Errorf("expected an empty object, got %q (%v)", buf.String(), err)
+	}
+}
@@ -0,0 +1,144 @@
+package privacy
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Export is the archive of everything held about a user. It is streamed one
+// section at a time, in the order of the fields. Secrets, such as password
+// and API key hashes, are left out.
+type Export struct {
+	ExportedAt    time.Time        `json:"exported_at"`
+	Profile       Profile          `json:"profile"`
+	Roles         []Role           `json:"roles"`
+	Addresses     []Address        `json:"addresses"`
+	Identities    []Identity       `json:"identities"`
+	Sessions      []Session        `json:"sessions"`
+	APIKeys       []APIKey         `json:"api_keys"`
+	MFA           []TOTPEnrollment `json:"mfa"`
+	EmailChanges  []EmailChange    `json:"email_changes"`
+	Organizations []Membership     `json:"organizations"`
+	Invitations   []Invitation     `json:"invitations"`
+	AuditEvents   []AuditEvent     `json:"audit_events"`
+	FailedLogins  []FailedLogin    `json:"failed_logins"`
+	LoginLockouts []LoginLockout   `json:"login_lockouts"`
+}
+
+// Profile is the user's account
+type Profile struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Role is a role granted to the user
+type Role struct {
+	Name      string    `json:"name"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// Address is one of the user's addresses, including deleted ones that have
+// not been purged yet
+type Address struct {
+	ID          string     `json:"id"`
+	AddressType string     `json:"address_type"`
+	StreetLine1 string     `json:"street_line1"`
+	StreetLine2 string     `json:"street_line2,omitempty"`
+	City        string     `json:"city"`
+	State       string     `json:"state"`
+	PostalCode  string     `json:"postal_code"`
+	Country     string     `json:"country"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Identity is an identity provider account linked to the user
+type Identity struct {
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Session is a login session
+type Session struct {
+	Method     string     `json:"method"`
+	MFA        bool       `json:"mfa"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// APIKey is an API key, without its secret
+type APIKey struct {
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TOTPEnrollment is an authenticator app enrollment, without its secret
+type TOTPEnrollment struct {
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// EmailChange is a requested change of email address
+type EmailChange struct {
+	NewEmail  string     `json:"new_email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Membership is the user's membership of an organization
+type Membership struct {
+	OrganizationID string    `json:"organization_id"`
+	Name           string    `json:"name"`
+	Role           string    `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+// Invitation is an invitation to join an organization sent to one of the
+// user's email addresses
+type Invitation struct {
+	OrganizationID string     `json:"organization_id"`
+	Role           string     `json:"role"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// AuditEvent is an audit log entry about the user
+type AuditEvent struct {
+	EventType string          `json:"event_type"`
+	IPAddress string          `json:"ip_address,omitempty"`
+	Details   json.RawMessage `json:"details" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// FailedLogin is a failed login to one of the user's email addresses
+type FailedLogin struct {
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginLockout is a lockout of one of the user's email addresses
+type LoginLockout struct {
+	Email       string     `json:"email"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
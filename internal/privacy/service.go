@@ -0,0 +1,407 @@
+// Package privacy serves users' data protection rights: exporting everything
+// held about them, and erasing it for good.
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"go-test-api/internal/audit"
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/database"
+	"go-test-api/internal/privacy/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound is returned when there is no user with the ID
+var (
+	ErrNotFound         = errors.New("user not found")
+	ErrOwnsOrganization = errors.New("user owns an organization with other members")
+)
+
+// TokenRevoker invalidates every access token issued to a user so far,
+// without relying on the user still existing
+type TokenRevoker interface {
+	RevokeDeletedUser(ctx context.Context, userID int32) error
+}
+
+// Service exports and erases users' personal data
+type Service struct {
+	pool   *pgxpool.Pool
+	tokens TokenRevoker
+	audit  *audit.Log
+}
+
+// NewService creates a new Service. The access tokens of erased users are
+// revoked through tokens, and erasures are recorded in auditLog.
+func NewService(pool *pgxpool.Pool, tokens TokenRevoker, auditLog *audit.Log) *Service {
+	return &Service{pool: pool, tokens: tokens, audit: auditLog}
+}
+
+// Export writes the archive of everything held about the user to w, as a
+// JSON Export, from one consistent snapshot of the database. It only reads
+// what the tenant of ctx can see, which leaves out the organizations of
+// other tenants and their invitations. Nothing is written if the user does
+// not exist in the tenant, in which case it returns ErrNotFound; any later
+// error leaves the archive truncated.
+func (s *Service) Export(ctx context.Context, userID int32, w io.Writer) error {
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	return database.InTenantTx(ctx, s.pool, opts, func(tx pgx.Tx) error {
+		return export(ctx, db.New(tx), userID, w)
+	})
+}
+
+// export writes the archive of the user to w, reading it with q
+func export(ctx context.Context, q *db.Queries, userID int32, w io.Writer) error {
+	user, err := q.ExportUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to export user: %w", err)
+	}
+	emails, err := q.ListUserEmails(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list user emails: %w", err)
+	}
+
+	// Sections are loaded one at a time, so that only one of them is held in
+	// memory at once
+	sections := []struct {
+		name string
+		load func() (interface{}, error)
+	}{
+		{"roles", func() (interface{}, error) {
+			rows, err := q.ExportUserRoles(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserRolesRow) Role {
+				return Role{Name: r.Name, GrantedAt: r.CreatedAt.Time}
+			})
+		}},
+		{"addresses", func() (interface{}, error) {
+			rows, err := q.ExportUserAddresses(ctx, userID)
+			return convert(rows, err, func(r db.Address) Address {
+				return Address{
+					ID:          strconv.Itoa(int(r.ID)),
+					AddressType: string(r.AddressType),
+					StreetLine1: r.StreetLine1,
+					StreetLine2: r.StreetLine2.String,
+					City:        r.City,
+					State:       r.State,
+					PostalCode:  r.PostalCode,
+					Country:     r.Country,
+					CreatedAt:   r.CreatedAt.Time,
+					UpdatedAt:   r.UpdatedAt.Time,
+					DeletedAt:   timePtr(r.DeletedAt),
+				}
+			})
+		}},
+		{"identities", func() (interface{}, error) {
+			rows, err := q.ExportUserIdentities(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserIdentitiesRow) Identity {
+				return Identity{
+					Provider:    r.Provider,
+					Subject:     r.Subject,
+					Email:       r.Email,
+					LastLoginAt: r.LastLoginAt.Time,
+					CreatedAt:   r.CreatedAt.Time,
+				}
+			})
+		}},
+		{"sessions", func() (interface{}, error) {
+			rows, err := q.ExportUserSessions(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserSessionsRow) Session {
+				return Session{
+					Method:     r.Method,
+					MFA:        r.Mfa,
+					UserAgent:  r.UserAgent,
+					IPAddress:  r.IpAddress,
+					CreatedAt:  r.CreatedAt.Time,
+					LastSeenAt: r.LastSeenAt.Time,
+					ExpiresAt:  r.ExpiresAt.Time,
+					RevokedAt:  timePtr(r.RevokedAt),
+				}
+			})
+		}},
+		{"api_keys", func() (interface{}, error) {
+			rows, err := q.ExportUserAPIKeys(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserAPIKeysRow) APIKey {
+				return APIKey{
+					Name:       r.Name,
+					Prefix:     r.Prefix,
+					Scopes:     r.Scopes,
+					ExpiresAt:  timePtr(r.ExpiresAt),
+					LastUsedAt: timePtr(r.LastUsedAt),
+					CreatedAt:  r.CreatedAt.Time,
+				}
+			})
+		}},
+		{"mfa", func() (interface{}, error) {
+			rows, err := q.ExportUserTOTP(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserTOTPRow) TOTPEnrollment {
+				return TOTPEnrollment{ConfirmedAt: timePtr(r.ConfirmedAt), CreatedAt: r.CreatedAt.Time}
+			})
+		}},
+		{"email_changes", func() (interface{}, error) {
+			rows, err := q.ExportUserEmailChanges(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserEmailChangesRow) EmailChange {
+				return EmailChange{
+					NewEmail:  r.NewEmail,
+					ExpiresAt: r.ExpiresAt.Time,
+					UsedAt:    timePtr(r.UsedAt),
+					CreatedAt: r.CreatedAt.Time,
+				}
+			})
+		}},
+		{"organizations", func() (interface{}, error) {
+			rows, err := q.ExportUserOrganizations(ctx, userID)
+			return convert(rows, err, func(r db.ExportUserOrganizationsRow) Membership {
+				return Membership{
+					OrganizationID: strconv.Itoa(int(r.ID)),
+					Name:           r.Name,
+					Role:           string(r.Role),
+					JoinedAt:       r.CreatedAt.Time,
+				}
+			})
+		}},
+		{"invitations", func() (interface{}, error) {
+			rows, err := q.ExportOrganizationInvitations(ctx, emails)
+			return convert(rows, err, func(r db.ExportOrganizationInvitationsRow) Invitation {
+				return Invitation{
+					OrganizationID: strconv.Itoa(int(r.OrganizationID)),
+					Role:           string(r.Role),
+					ExpiresAt:      r.ExpiresAt.Time,
+					AcceptedAt:     timePtr(r.AcceptedAt),
+					CreatedAt:      r.CreatedAt.Time,
+				}
+			})
+		}},
+		{"audit_events", func() (interface{}, error) {
+			rows, err := q.ExportUserAuditEvents(ctx, pgtype.Int4{Int32: userID, Valid: true})
+			return convert(rows, err, func(r db.ExportUserAuditEventsRow) AuditEvent {
+				return AuditEvent{
+					EventType: r.EventType,
+					IPAddress: r.IpAddress.String,
+					Details:   json.RawMessage(r.Details),
+					CreatedAt: r.CreatedAt.Time,
+				}
+			})
+		}},
+		{"failed_logins", func() (interface{}, error) {
+			rows, err := q.ExportFailedLogins(ctx, emails)
+			return convert(rows, err, func(r db.ExportFailedLoginsRow) FailedLogin {
+				return FailedLogin{Email: r.Email, IPAddress: r.IpAddress, CreatedAt: r.CreatedAt.Time}
+			})
+		}},
+		{"login_lockouts", func() (interface{}, error) {
+			rows, err := q.ExportLoginLockouts(ctx, emails)
+			return convert(rows, err, func(r db.ExportLoginLockoutsRow) LoginLockout {
+				return LoginLockout{
+					Email:       r.Email,
+					LockedUntil: r.LockedUntil.Time,
+					UnlockedAt:  timePtr(r.UnlockedAt),
+					CreatedAt:   r.CreatedAt.Time,
+				}
+			})
+		}},
+	}
+
+	out := &objectWriter{w: w}
+	out.member("exported_at", time.Now().UTC())
+	out.member("profile", Profile{
+		ID:              strconv.Itoa(int(user.ID)),
+		Name:            user.Name,
+		Email:           user.Email,
+		EmailVerifiedAt: timePtr(user.EmailVerifiedAt),
+		CreatedAt:       user.CreatedAt.Time,
+		UpdatedAt:       user.UpdatedAt.Time,
+	})
+	for _, section := range sections {
+		v, err := section.load()
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", section.name, err)
+		}
+		out.member(section.name, v)
+	}
+	return out.close()
+}
+
+// Erase irreversibly deletes the user and everything they own, in one
+// transaction: their addresses, their login history, the invitations sent
+// to them, the organizations only they are a member of, and through the
+// foreign keys every credential, session, token and membership. Audit
+// events about them or by them are kept but lose the user's email and IP
+// addresses, not those of anyone else they concern, and a user_erased event
+// records the erasure without identifying them. actorID is the administrator
+// erasing the user, or the user themselves, in which case ip is not recorded
+// either. The user's access tokens are revoked before anything is deleted,
+// so they are logged out even if the erasure then fails. It returns ErrNotFound if there is no such user in
+// the tenant of ctx; deleted users that have not been purged yet can be
+// erased. It returns ErrOwnsOrganization if they own an organization with
+// other members, which must be handed over first.
+func (s *Service) Erase(ctx context.Context, userID, actorID int32, ip string) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin erasure: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := db.New(tx)
+
+	if _, err := q.LockUserForErasure(ctx, db.LockUserForErasureParams{ID: userID, TenantID: tenantID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	shared, err := q.OwnsSharedOrganization(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check organization ownership: %w", err)
+	}
+	if shared {
+		return ErrOwnsOrganization
+	}
+	if err := s.tokens.RevokeDeletedUser(ctx, userID); err != nil {
+		return err
+	}
+	emails, err := q.ListUserEmails(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list user emails: %w", err)
+	}
+
+	addresses, err := q.DeleteUserAddresses(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete addresses: %w", err)
+	}
+	if err := q.DeleteLoginHistory(ctx, emails); err != nil {
+		return fmt.Errorf("failed to delete login history: %w", err)
+	}
+	if err := q.DeleteOrganizationInvitations(ctx, emails); err != nil {
+		return fmt.Errorf("failed to delete invitations: %w", err)
+	}
+	organizations, err := q.DeleteOwnedOrganizations(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete organizations: %w", err)
+	}
+	events, err := q.AnonymizeAuditEvents(ctx, db.AnonymizeAuditEventsParams{UserID: userID, Emails: emails})
+	if err != nil {
+		return fmt.Errorf("failed to anonymize audit events: %w", err)
+	}
+	if _, err := q.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	// The tombstone only carries the erased user's former ID, which nothing
+	// refers to anymore
+	tombstone := audit.Event{
+		Type: audit.EventUserErased,
+		Details: map[string]interface{}{
+			"erased_user_id":          userID,
+			"method":                  "self",
+			"addresses_deleted":       addresses,
+			"organizations_deleted":   organizations,
+			"audit_events_anonymized": events,
+		},
+	}
+	if actorID != userID {
+		tombstone.ActorID = actorID
+		tombstone.IP = ip
+		tombstone.Details["method"] = "admin"
+	}
+	if err := s.audit.WithTx(tx).Record(ctx, tombstone); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit erasure: %w", err)
+	}
+	return nil
+}
+
+// tenantOf returns the tenant ctx is scoped to, or database.ErrNoTenant.
+// Users of other tenants are treated as if they did not exist.
+func tenantOf(ctx context.Context) (int32, error) {
+	tenantID := authctx.TenantID(ctx)
+	if tenantID == "" {
+		return 0, database.ErrNoTenant
+	}
+	id, err := strconv.ParseInt(tenantID, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tenant id: %w", err)
+	}
+	return int32(id), nil
+}
+
+// convert maps the rows of a query with f
+func convert[R, T any](rows []R, err error, f func(R) T) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, f(r))
+	}
+	return items, nil
+}
+
+// timePtr returns the time of a nullable timestamp, or nil if it is null
+func timePtr(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// objectWriter writes a JSON object one member at a time. The first error
+// stops all further writes and is returned by close.
+type objectWriter struct {
+	w       io.Writer
+	members int
+	err     error
+}
+
+// member writes the member name with the JSON encoding of v
+func (o *objectWriter) member(name string, v interface{}) {
+	if o.err != nil {
+		return
+	}
+	key, err := json.Marshal(name)
+	if err != nil {
+		o.err = err
+		return
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		o.err = fmt.Errorf("failed to encode %s: %w", name, err)
+		return
+	}
+	sep := ","
+	if o.members == 0 {
+		sep = "{"
+	}
+	_, o.err = fmt.Fprintf(o.w, "%s%s:%s\n", sep, key, value)
+	o.members++
+}
+
+// close ends the object
+func (o *objectWriter) close() error {
+	if o.err != nil {
+		return o.err
+	}
+	end := "}\n"
+	if o.members == 0 {
+		end = "{}\n"
+	}
+	_, o.err = io.WriteString(o.w, end)
+	return o.err
+}
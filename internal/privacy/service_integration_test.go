@@ -0,0 +1,385 @@
+//go:build integration
+// +build integration
+
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-test-api/internal/audit"
+	auditdb "go-test-api/internal/audit/db"
+	"go-test-api/internal/auth"
+	authdb "go-test-api/internal/auth/db"
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/config"
+	"go-test-api/internal/database"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var testDB *pgxpool.Pool
+
+// defaultTenant is the context of requests made in the tenant users are
+// created in
+var defaultTenant = authctx.WithTenant(context.Background(), "1")
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	testDB, err = database.New(ctx, cfg.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	testDB.Close()
+	os.Exit(code)
+}
+
+// setupService empties the tables involved and returns a Service
+func setupService(t *testing.T) *Service {
+	t.Helper()
+	_, err := testDB.Exec(context.Background(), "TRUNCATE users, addresses, audit_events, failed_login_attempts, login_lockouts, user_token_revocations RESTART IDENTITY CASCADE")
+	if err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	revocations := auth.NewRevocationList(authdb.New(testDB), time.Hour)
+	return NewService(testDB, revocations, audit.NewLog(auditdb.New(testDB)))
+}
+
+// createUser inserts a user with an address, a failed login and an audit
+// event, and returns their ID
+func createUser(t *testing.T, name, email string) int32 {
+	t.Helper()
+	ctx := context.Background()
+	var id int32
+	err := testDB.QueryRow(ctx, `INSERT INTO users (name, email, password_hash, created_at, updated_at)
+		VALUES ($1, $2, 'hashedpassword', NOW(), NOW()) RETURNING id`, name, email).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO addresses (entity_type, entity_id, address_type, street_line1, city, state, postal_code, country, created_at, updated_at)
+			VALUES ('user', $1, 'shipping', '1 Main St', 'Springfield', 'IL', '62701', 'US', NOW(), NOW())`,
+		`INSERT INTO failed_login_attempts (email, ip_address, created_at)
+			SELECT email, '198.51.100.1', NOW() FROM users WHERE id = $1`,
+		`INSERT INTO audit_events (event_type, user_id, email, ip_address, details, created_at)
+			SELECT 'account_locked', id, email, '198.51.100.1', '{}', NOW() FROM users WHERE id = $1`,
+	} {
+		if _, err := testDB.Exec(ctx, stmt, id); err != nil {
+			t.Fatalf("Failed to create user data: %v", err)
+		}
+	}
+	return id
+}
+
+func TestService_Export_Integration(t *testing.T) {
+	service := setupService(t)
+	ctx := defaultTenant
+	alice := createUser(t, "Alice", "alice@example.com")
+	createUser(t, "Bob", "bob@example.com")
+
+	var buf bytes.Buffer
+	if err := service.Export(ctx, alice, &buf); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	var export Export
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("Expected a JSON archive, got %v: %s", err, buf.String())
+	}
+	if export.Profile.Email != "alice@example.com" {
+		t.Errorf("Expected Alice's profile, got %+v", export.Profile)
+	}
+	if len(export.Addresses) != 1 || len(export.FailedLogins) != 1 || len(export.AuditEvents) != 1 {
+		t.Errorf("Expected one address, failed login and audit event, got %+v", export)
+	}
+	if export.FailedLogins[0].Email != "alice@example.com" {
+		t.Errorf("Expected only Alice's failed logins, got %+v", export.FailedLogins)
+	}
+
+	buf.Reset()
+	if err := service.Export(ctx, 999, &buf); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing written for a missing user, got %q", buf.String())
+	}
+}
+
+func TestService_Erase_Integration(t *testing.T) {
+	service := setupService(t)
+	ctx := defaultTenant
+	admin := createUser(t, "Admin", "admin@example.com")
+	alice := createUser(t, "Alice", "alice@example.com")
+	bob := createUser(t, "Bob", "bob@example.com")
+
+	if err := service.Erase(ctx, alice, admin, "203.0.113.7"); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+
+	counts := map[string]string{
+		"users":          "SELECT COUNT(*) FROM users WHERE id = $1",
+		"addresses":      "SELECT COUNT(*) FROM addresses WHERE entity_type = 'user' AND entity_id = $1",
+		"failed logins":  "SELECT COUNT(*) FROM failed_login_attempts WHERE email = 'alice@example.com' AND $1 > 0",
+		"audit emails":   "SELECT COUNT(*) FROM audit_events WHERE email = 'alice@example.com' AND $1 > 0",
+		"audit IPs":      "SELECT COUNT(*) FROM audit_events WHERE event_type = 'account_locked' AND user_id IS NULL AND ip_address IS NOT NULL AND $1 > 0",
+		"audit user IDs": "SELECT COUNT(*) FROM audit_events WHERE user_id = $1",
+	}
+	for name, query := range counts {
+		var n int
+		if err := testDB.QueryRow(ctx, query, alice).Scan(&n); err != nil {
+			t.Fatalf("Failed to count %s: %v", name, err)
+		}
+		if n != 0 {
+			t.Errorf("Expected no %s left for the erased user, got %d", name, n)
+		}
+	}
+
+	// Everyone else is untouched
+	var n int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM addresses WHERE entity_id = $1", bob).Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected Bob's address to be kept, got %d (%v)", n, err)
+	}
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM audit_events WHERE email = 'bob@example.com'").Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected Bob's audit event to be kept, got %d (%v)", n, err)
+	}
+
+	// The tombstone names the administrator and the former ID only
+	var actorID int32
+	var details map[string]interface{}
+	err := testDB.QueryRow(ctx, "SELECT actor_id, details FROM audit_events WHERE event_type = $1", audit.EventUserErased).Scan(&actorID, &details)
+	if err != nil {
+		t.Fatalf("Expected a tombstone audit event: %v", err)
+	}
+	if actorID != admin || details["erased_user_id"] != float64(alice) || details["method"] != "admin" {
+		t.Errorf("Unexpected tombstone: actor %d, details %v", actorID, details)
+	}
+
+	if err := service.Erase(ctx, alice, admin, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound erasing twice, got %v", err)
+	}
+}
+
+func TestService_EraseRevokesTokens_Integration(t *testing.T) {
+	service := setupService(t)
+	ctx := defaultTenant
+	alice := createUser(t, "Alice", "alice@example.com")
+	claims := &auth.Claims{
+		UserID:           strconv.Itoa(int(alice)),
+		RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(time.Now())},
+	}
+
+	if err := service.Erase(ctx, alice, alice, ""); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+
+	// The revocation outlives the user, so every replica loading it rejects
+	// the token, even one issued in the second of the erasure
+	revocations := auth.NewRevocationList(authdb.New(testDB), time.Hour)
+	if err := revocations.Sync(context.Background()); err != nil {
+		t.Fatalf("Failed to sync revocations: %v", err)
+	}
+	if !revocations.IsRevoked(claims) {
+		t.Error("Expected an access token issued before the erasure to be revoked")
+	}
+}
+
+// createOrganization inserts an organization owned by the user, with the
+// members given besides them, and returns its ID
+func createOrganization(t *testing.T, name string, owner int32, members ...int32) int32 {
+	t.Helper()
+	ctx := context.Background()
+	var id int32
+	err := testDB.QueryRow(ctx, `INSERT INTO organizations (name, owner_id, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, NOW(), NOW(), 1) RETURNING id`, name, owner).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	roles := map[int32]string{owner: "owner"}
+	for _, member := range members {
+		roles[member] = "member"
+	}
+	for userID, role := range roles {
+		_, err := testDB.Exec(ctx, `INSERT INTO organization_members (organization_id, user_id, role, created_at)
+			VALUES ($1, $2, $3, NOW())`, id, userID, role)
+		if err != nil {
+			t.Fatalf("Failed to add organization member: %v", err)
+		}
+	}
+	return id
+}
+
+func TestService_EraseOrganizationOwner_Integration(t *testing.T) {
+	service := setupService(t)
+	ctx := defaultTenant
+	alice := createUser(t, "Alice", "alice@example.com")
+	bob := createUser(t, "Bob", "bob@example.com")
+	shared := createOrganization(t, "Acme", alice, bob)
+	solo := createOrganization(t, "Alice's", alice)
+
+	// Erasing Alice would take Bob's organization with her
+	if err := service.Erase(ctx, alice, alice, ""); !errors.Is(err, ErrOwnsOrganization) {
+		t.Fatalf("Expected ErrOwnsOrganization, got %v", err)
+	}
+	var n int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE id = $1", alice).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("Expected Alice to be kept, got %d (%v)", n, err)
+	}
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM organization_members WHERE organization_id = $1", shared).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("Expected the shared organization's members to be kept, got %d (%v)", n, err)
+	}
+
+	// Once Bob owns it, only the organization Alice is alone in goes with her
+	_, err := testDB.Exec(ctx, `UPDATE organizations SET owner_id = $2 WHERE id = $1`, shared, bob)
+	if err != nil {
+		t.Fatalf("Failed to transfer ownership: %v", err)
+	}
+	if err := service.Erase(ctx, alice, alice, ""); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM organizations WHERE id = $1", solo).Scan(&n); err != nil || n != 0 {
+		t.Errorf("Expected Alice's own organization to be erased, got %d (%v)", n, err)
+	}
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM organization_members WHERE organization_id = $1", shared).Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected Bob to be left in the shared organization, got %d (%v)", n, err)
+	}
+}
+
+func TestHandler_CrossTenant_Integration(t *testing.T) {
+	service := setupService(t)
+	admin := createUser(t, "Admin", "admin@example.com")
+	alice := createUser(t, "Alice", "alice@example.com")
+	var globex string
+	err := testDB.QueryRow(context.Background(), `INSERT INTO tenants (name, slug) VALUES ('globex', 'globex')
+		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name RETURNING id::text`).Scan(&globex)
+	if err != nil {
+		t.Fatalf("Failed to create tenant: %v", err)
+	}
+	handler := NewHandler(service, func(*http.Request) string { return "203.0.113.7" })
+
+	// An administrator of another tenant cannot tell Alice exists
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/users/%d/erase", alice), nil)
+	req.SetPathValue("id", fmt.Sprint(alice))
+	ctx := authctx.WithUser(context.Background(), fmt.Sprint(admin), "admin@example.com")
+	req = req.WithContext(authctx.WithTenant(ctx, globex))
+	rec := httptest.NewRecorder()
+	handler.EraseUser(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 erasing a user of another tenant, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var n int
+	if err := testDB.QueryRow(context.Background(), "SELECT COUNT(*) FROM users WHERE id = $1", alice).Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected Alice to be kept, got %d (%v)", n, err)
+	}
+
+	var buf bytes.Buffer
+	if err := service.Export(authctx.WithTenant(context.Background(), globex), alice, &buf); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound exporting a user of another tenant, got %v", err)
+	}
+	if err := service.Erase(context.Background(), alice, admin, ""); !errors.Is(err, database.ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant without a tenant, got %v", err)
+	}
+}
+
+func TestService_UnconfirmedEmail_Integration(t *testing.T) {
+	service := setupService(t)
+	ctx := defaultTenant
+	mallory := createUser(t, "Mallory", "mallory@example.com")
+	createUser(t, "Victim", "victim@example.com")
+
+	// Asking to change to an address does not make it Mallory's
+	_, err := testDB.Exec(context.Background(), `INSERT INTO email_change_tokens (user_id, new_email, token_hash, expires_at, created_at)
+		VALUES ($1, 'victim@example.com', 'hash', NOW() + INTERVAL '1 hour', NOW())`, mallory)
+	if err != nil {
+		t.Fatalf("Failed to request email change: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := service.Export(ctx, mallory, &buf); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	var export Export
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("Expected a JSON archive, got %v: %s", err, buf.String())
+	}
+	for _, login := range export.FailedLogins {
+		if login.Email == "victim@example.com" {
+			t.Errorf("Expected the victim's failed logins not to be exported, got %+v", export.FailedLogins)
+		}
+	}
+
+	if err := service.Erase(ctx, mallory, mallory, ""); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+	var logins, events int
+	err = testDB.QueryRow(context.Background(), `SELECT
+		(SELECT COUNT(*) FROM failed_login_attempts WHERE email = 'victim@example.com'),
+		(SELECT COUNT(*) FROM audit_events WHERE email = 'victim@example.com' AND ip_address IS NOT NULL)`).Scan(&logins, &events)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if logins != 1 || events != 1 {
+		t.Errorf("Expected the victim's failed login and audit event to be kept, got %d and %d", logins, events)
+	}
+}
+
+func TestService_EraseAuditTrail_Integration(t *testing.T) {
+	service := setupService(t)
+	ctx := defaultTenant
+	admin := createUser(t, "Admin", "admin@example.com")
+	alice := createUser(t, "Alice", "alice@example.com")
+	bob := createUser(t, "Bob", "bob@example.com")
+
+	// The administrator unlocked both Alice and Bob
+	for _, id := range []int32{alice, bob} {
+		_, err := testDB.Exec(context.Background(), `INSERT INTO audit_events (event_type, user_id, actor_id, email, ip_address, details, created_at)
+			SELECT 'account_unlocked', id, $2, email, '203.0.113.9', '{}', NOW() FROM users WHERE id = $1`, id, admin)
+		if err != nil {
+			t.Fatalf("Failed to create audit event: %v", err)
+		}
+	}
+
+	// Erasing Alice keeps the administrator's IP on what was done to her
+	if err := service.Erase(ctx, alice, admin, ""); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+	var email, ip *string
+	err := testDB.QueryRow(context.Background(), `SELECT email, ip_address FROM audit_events
+		WHERE event_type = 'account_unlocked' AND user_id IS NULL`).Scan(&email, &ip)
+	if err != nil {
+		t.Fatalf("Failed to read audit event: %v", err)
+	}
+	if email != nil || ip == nil {
+		t.Errorf("Expected Alice's email to be removed and the administrator's IP kept, got %v and %v", email, ip)
+	}
+
+	// Erasing the administrator keeps Bob's email on what they did to him
+	if err := service.Erase(ctx, admin, admin, ""); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+	err = testDB.QueryRow(context.Background(), `SELECT email, ip_address FROM audit_events
+		WHERE event_type = 'account_unlocked' AND user_id = $1`, bob).Scan(&email, &ip)
+	if err != nil {
+		t.Fatalf("Failed to read audit event: %v", err)
+	}
+	if email == nil || *email != "bob@example.com" || ip != nil {
+		t.Errorf("Expected Bob's email to be kept and the administrator's IP removed, got %v and %v", email, ip)
+	}
+}
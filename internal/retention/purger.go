@@ -0,0 +1,61 @@
+// Package retention purges soft-deleted rows once they have been kept for the
+// retention window, after which they can no longer be restored.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Store is a table with soft-deleted rows
+type Store interface {
+	// PurgeDeleted hard-deletes the rows deleted before the cutoff and
+	// returns how many there were
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Purger hard-deletes soft-deleted rows older than the retention window
+type Purger struct {
+	retention time.Duration
+	stores    map[string]Store
+}
+
+// NewPurger creates a Purger for the stores, keyed by the name they are
+// logged with
+func NewPurger(retention time.Duration, stores map[string]Store) *Purger {
+	return &Purger{retention: retention, stores: stores}
+}
+
+// Purge hard-deletes the rows of every store deleted more than the retention
+// window ago
+func (p *Purger) Purge(ctx context.Context) error {
+	before := time.Now().Add(-p.retention)
+	for name, store := range p.stores {
+		purged, err := store.PurgeDeleted(ctx, before)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			slog.Info("Purged deleted rows", "table", name, "rows", purged)
+		}
+	}
+	return nil
+}
+
+// Run purges every interval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Purge(ctx); err != nil {
+				slog.Error("Failed to purge deleted rows", "error", err)
+			}
+		}
+	}
+}
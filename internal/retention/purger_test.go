@@ -0,0 +1,54 @@
+//go:build unit
+
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// storeFunc adapts a function to the Store interface
+type storeFunc func(ctx context.Context, before time.Time) (int64, error)
+
+func (f storeFunc) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return f(ctx, before)
+}
+
+func TestPurger_Purge(t *testing.T) {
+	var cutoffs []time.Time
+	store := storeFunc(func(ctx context.Context, before time.Time) (int64, error) {
+		cutoffs = append(cutoffs, before)
+		return 1, nil
+	})
+
+	purger := NewPurger(24*time.Hour, map[string]Store{"users": store, "addresses": store})
+	earliest := time.Now().Add(-24 * time.Hour)
+	if err := purger.Purge(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	latest := time.Now().Add(-24 * time.Hour)
+
+	if len(cutoffs) != 2 {
+		t.Fatalf("expected both stores to be purged, got %d", len(cutoffs))
+	}
+	for _, cutoff := range cutoffs {
+		if cutoff.Before(earliest) || cutoff.After(latest) {
+			t.Errorf("expected rows deleted over a day ago to be purged, got cutoff %v", cutoff)
+		}
+	}
+}
+
+func TestPurger_Purge_Error(t *testing.T) {
+	errPurge := errors.New("purge failed")
+	purger := NewPurger(time.Hour, map[string]Store{
+		"users": storeFunc(func(ctx context.Context, before time.Time) (int64, error) {
+			return 0, errPurge
+		}),
+	})
+
+	if err := purger.Purge(context.Background()); !errors.Is(err, errPurge) {
+		t.Errorf("expected %v, got %v", errPurge, err)
+	}
+}
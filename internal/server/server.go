@@ -5,15 +5,27 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	_ "go-test-api/docs"
 	"go-test-api/internal/address"
 	addressdb "go-test-api/internal/address/db"
+	"go-test-api/internal/audit"
+	auditdb "go-test-api/internal/audit/db"
 	"go-test-api/internal/auth"
+	authdb "go-test-api/internal/auth/db"
+	"go-test-api/internal/authz"
 	"go-test-api/internal/database"
 	"go-test-api/internal/health"
+	"go-test-api/internal/mail"
 	"go-test-api/internal/middleware"
+	"go-test-api/internal/oidc"
+	"go-test-api/internal/organization"
+	"go-test-api/internal/pagination"
+	"go-test-api/internal/password"
+	"go-test-api/internal/privacy"
+	"go-test-api/internal/retention"
 	"go-test-api/internal/user"
 	userdb "go-test-api/internal/user/db"
 	"go-test-api/internal/validator"
@@ -22,23 +34,95 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// loginThrottlePurgeInterval is how often failed logins and lockouts that no
+// longer count are deleted
+const loginThrottlePurgeInterval = 10 * time.Minute
+
+// sessionFlushInterval is how often session activity is written to the
+// database and expired sessions are deleted
+const sessionFlushInterval = time.Minute
+
+// purgeInterval is how often deleted users and addresses past the retention
+// window are purged
+const purgeInterval = time.Hour
+
+// oidcRequestTimeout bounds each request to an identity provider
+const oidcRequestTimeout = 10 * time.Second
+
 // Server represents the HTTP server with all dependencies
 type Server struct {
 	port           string
 	pool           *pgxpool.Pool
 	userHandler    *user.Handler
+	accountHandler *user.AccountHandler
 	addressHandler *address.Handler
+	orgHandler     *organization.Handler
+	privacyHandler *privacy.Handler
 	authHandler    *auth.Handler
 	authService    *auth.Service
+	revocations    *auth.RevocationList
+	loginThrottle  *auth.LoginThrottle
+	sessions       *auth.SessionTracker
+	purger         *retention.Purger
 	healthHandler  *health.Handler
+
+	revocationSyncInterval time.Duration
+	magicLinkEnabled       bool
 }
 
 // Config holds server configuration
 type Config struct {
-	Port      string
-	Database  database.Config
-	JWTSecret string
-	JWTExpiry time.Duration
+	Port               string
+	BaseURL            string
+	FrontendURL        string
+	Database           database.Config
+	Mail               mail.Config
+	Password           password.Config
+	JWTSecret          string
+	JWTExpiry          time.Duration
+	RefreshTokenExpiry time.Duration
+	// JWTSigningKeyFile, if set, signs access tokens with an asymmetric key
+	// instead of JWTSecret; JWTVerificationKeyFiles are also accepted
+	JWTSigningKeyFile       string
+	JWTVerificationKeyFiles []string
+	// PasswordResetExpiry is how long a password reset link stays valid
+	PasswordResetExpiry time.Duration
+	// EmailVerificationExpiry is how long an email verification or email
+	// change link stays valid
+	EmailVerificationExpiry time.Duration
+	// RequireVerifiedEmail restricts protected routes to verified users
+	RequireVerifiedEmail bool
+	// MagicLinkEnabled registers the passwordless login routes;
+	// MagicLinkExpiry is how long a login link stays valid
+	MagicLinkEnabled bool
+	MagicLinkExpiry  time.Duration
+	// InvitationExpiry is how long an invitation to join an organization
+	// stays valid
+	InvitationExpiry time.Duration
+	// TOTPIssuer is the name authenticator apps show for this service
+	TOTPIssuer string
+	// LoginMaxFailures failed logins for an email within LoginFailureWindow
+	// lock it for LoginLockoutDuration; LoginIPMaxFailures from one client
+	// IP refuse further attempts from it
+	LoginMaxFailures     int
+	LoginIPMaxFailures   int
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+	// ClientIPHeader names a header set by a trusted reverse proxy with the
+	// client IP
+	ClientIPHeader string
+	// RevocationSyncInterval is how often revoked tokens are reloaded from
+	// the database and expired revocations purged
+	RevocationSyncInterval time.Duration
+	// SoftDeleteRetention is how long deleted users and addresses are kept
+	// before they are purged
+	SoftDeleteRetention time.Duration
+	// OIDCProviders are the OpenID Connect providers users can log in with;
+	// OIDCStateSecret signs the login state cookie
+	OIDCProviders   []oidc.ProviderConfig
+	OIDCStateSecret string
+	// CursorSecret signs the pagination cursors of list endpoints
+	CursorSecret string
 }
 
 // New creates a new Server instance with all dependencies injected
@@ -50,33 +134,155 @@ func New(cfg Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to set up mail delivery: %w", err)
+	}
+
 	// Initialize auth service
-	authService := auth.NewService(cfg.JWTSecret, cfg.JWTExpiry)
+	passwords, err := password.New(cfg.Password)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("invalid password hashing config: %w", err)
+	}
+	var keys *auth.KeySet
+	if cfg.JWTSigningKeyFile != "" {
+		keys, err = auth.LoadKeySet(cfg.JWTSigningKeyFile, cfg.JWTVerificationKeyFiles)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to load jwt keys: %w", err)
+		}
+	}
+	authQueries := authdb.New(pool)
+	authRepo := auth.NewRepository(authQueries)
+	revocations := auth.NewRevocationList(authQueries, cfg.JWTExpiry)
+	if err := revocations.Sync(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to load token revocations: %w", err)
+	}
+	sessions := auth.NewSessionTracker(authQueries)
+	auditLog := audit.NewLog(auditdb.New(pool))
+	authService := auth.NewService(auth.ServiceConfig{
+		Keys:                    keys,
+		JWTSecret:               cfg.JWTSecret,
+		JWTExpiry:               cfg.JWTExpiry,
+		RefreshTokenExpiry:      cfg.RefreshTokenExpiry,
+		PasswordResetExpiry:     cfg.PasswordResetExpiry,
+		EmailVerificationExpiry: cfg.EmailVerificationExpiry,
+		MagicLinkExpiry:         cfg.MagicLinkExpiry,
+		RequireVerifiedEmail:    cfg.RequireVerifiedEmail,
+		TOTPIssuer:              cfg.TOTPIssuer,
+		Passwords:               passwords,
+		Sessions:                sessions,
+		Audit:                   auditLog,
+	}, revocations, authRepo)
+	loginThrottle := auth.NewLoginThrottle(auth.ThrottleConfig{
+		MaxFailures:     cfg.LoginMaxFailures,
+		IPMaxFailures:   cfg.LoginIPMaxFailures,
+		Window:          cfg.LoginFailureWindow,
+		LockoutDuration: cfg.LoginLockoutDuration,
+		ClientIPHeader:  cfg.ClientIPHeader,
+	}, pool, auditLog)
 	userQueries := userdb.New(pool)
-	userRepo := user.NewRepository(userQueries)
+	userRepo := user.NewRepository(pool)
+	notifier := auth.NewNotifier(mailer, cfg.BaseURL, cfg.FrontendURL)
+
+	if len(cfg.OIDCProviders) > 0 && cfg.OIDCStateSecret == "" {
+		pool.Close()
+		return nil, fmt.Errorf("an OIDC state secret is required when OIDC providers are configured")
+	}
+	if cfg.CursorSecret == "" {
+		pool.Close()
+		return nil, fmt.Errorf("a cursor secret is required to sign pagination cursors")
+	}
+	oidcClient := &http.Client{Timeout: oidcRequestTimeout}
+	var providers []*oidc.Provider
+	for _, p := range cfg.OIDCProviders {
+		// The callback URL must be registered with the provider
+		redirectURL := cfg.BaseURL + "/auth/oidc/" + p.Name + "/callback"
+		providers = append(providers, oidc.NewProvider(p, redirectURL, oidcClient))
+	}
+	sso := auth.NewSSO(providers, []byte(cfg.OIDCStateSecret), strings.HasPrefix(cfg.BaseURL, "https://"))
+
+	// Organizations are only accessible to their members, and can only be
+	// changed by their admins
+	orgRepo := organization.NewRepository(pool)
+	orgPolicy := authz.NewPolicy(auth.PermOrganizationsAdmin)
+	orgPolicy.Register(organization.EntityType, orgRepo.MemberRule)
+
+	// Addresses belong to an entity, and are only accessible to whoever may
+	// act on it
+	addressEntities := address.NewRegistry()
+	addressEntities.Register(string(addressdb.EntityTypeUser), address.Entity{
+		Exists: userRepo.Exists,
+		Rule:   authz.Self,
+	})
+	addressEntities.Register(string(addressdb.EntityTypeOrganization), address.Entity{
+		Exists: orgRepo.Exists,
+		Rule:   orgRepo.MemberRule,
+	})
+	addressPolicy := addressEntities.Policy(auth.PermAddressesAdmin)
+
+	pages := pagination.New([]byte(cfg.CursorSecret))
+	addressRepo := address.NewRepository(pool, addressEntities)
 
 	return &Server{
 		port:          cfg.Port,
 		pool:          pool,
 		authService:   authService,
+		revocations:   revocations,
+		loginThrottle: loginThrottle,
+		sessions:      sessions,
+		purger: retention.NewPurger(cfg.SoftDeleteRetention, map[string]retention.Store{
+			"users":     userRepo,
+			"addresses": addressRepo,
+		}),
 		healthHandler: health.NewHandler(),
 		userHandler: user.NewHandler(
 			validator.New(),
 			userRepo,
+			pages,
 		),
 		addressHandler: address.NewHandler(
 			validator.New(),
-			address.NewRepository(
-				addressdb.New(pool),
-				userQueries,
-			),
+			addressRepo,
+			addressPolicy,
+			pages,
+		),
+		privacyHandler: privacy.NewHandler(
+			privacy.NewService(pool, revocations, auditLog),
+			loginThrottle.ClientIP,
+		),
+		orgHandler: organization.NewHandler(
+			validator.New(),
+			orgRepo,
+			orgPolicy,
+			pages,
+			notifier,
+			cfg.InvitationExpiry,
+		),
+		accountHandler: user.NewAccountHandler(
+			validator.New(),
+			userRepo,
+			authService,
+			authService,
+			auth.NewAccountThrottle(loginThrottle, notifier),
+			notifier,
+			cfg.EmailVerificationExpiry,
 		),
 		authHandler: auth.NewHandler(
 			validator.New(),
 			authService,
 			userRepo,
 			userQueries,
+			authRepo,
+			notifier,
+			loginThrottle,
+			sso,
 		),
+		revocationSyncInterval: cfg.RevocationSyncInterval,
+		magicLinkEnabled:       cfg.MagicLinkEnabled,
 	}, nil
 }
 
@@ -98,25 +304,98 @@ func (s *Server) setupRoutes() {
 	// Auth routes (public)
 	http.HandleFunc("POST /auth/register", s.authHandler.Register)
 	http.HandleFunc("POST /auth/login", s.authHandler.Login)
+	http.HandleFunc("POST /auth/login/mfa", s.authHandler.LoginMFA)
+	http.HandleFunc("POST /auth/refresh", s.authHandler.Refresh)
+	http.HandleFunc("POST /auth/password/forgot", s.authHandler.ForgotPassword)
+	http.HandleFunc("POST /auth/password/reset", s.authHandler.ResetPassword)
+	http.HandleFunc("GET /auth/verify", s.authHandler.VerifyEmail)
+	http.HandleFunc("GET /auth/unlock", s.authHandler.UnlockAccount)
+	http.HandleFunc("GET /auth/email/confirm", s.accountHandler.ConfirmEmailChange)
+	http.HandleFunc("GET /auth/oidc/{provider}/start", s.authHandler.OIDCStart)
+	http.HandleFunc("GET /auth/oidc/{provider}/callback", s.authHandler.OIDCCallback)
+	http.HandleFunc("GET /.well-known/jwks.json", s.authHandler.JWKS)
+	if s.magicLinkEnabled {
+		http.HandleFunc("POST /auth/magic-link", s.authHandler.RequestMagicLink)
+		http.HandleFunc("GET /auth/magic-link/consume", s.authHandler.ConsumeMagicLink)
+	}
 
-	// Protected routes
+	// Protected routes. Routes with a permission are only reachable by users
+	// whose roles grant it. Routes marked allowUnverified stay reachable for
+	// users who have not verified their email address yet. Routes marked
+	// blockImpersonation are refused to administrators impersonating a user,
+	// since they act on credentials or on behalf of the token holder.
 	authMiddleware := auth.Middleware(s.authService)
+	unverifiedMiddleware := auth.UnverifiedMiddleware(s.authService)
 	protectedRoutes := []struct {
-		method  string
-		path    string
-		handler http.HandlerFunc
+		method             string
+		path               string
+		handler            http.HandlerFunc
+		permission         string
+		allowUnverified    bool
+		blockImpersonation bool
 	}{
-		{"GET", "/users", s.userHandler.List},
-		{"GET", "/addresses", s.addressHandler.List},
-		{"POST", "/addresses", s.addressHandler.Create},
-		{"GET", "/addresses/{id}", s.addressHandler.Get},
-		{"PUT", "/addresses/{id}", s.addressHandler.Update},
-		{"DELETE", "/addresses/{id}", s.addressHandler.Delete},
+		{"POST", "/auth/logout", s.authHandler.Logout, "", true, false},
+		{"POST", "/auth/logout-all", s.authHandler.LogoutAll, "", true, true},
+		{"GET", "/auth/sessions", s.authHandler.ListSessions, "", true, false},
+		{"DELETE", "/auth/sessions/{id}", s.authHandler.DeleteSession, "", true, true},
+		{"POST", "/auth/verify/resend", s.authHandler.ResendVerification, "", true, false},
+		{"POST", "/auth/password/change", s.accountHandler.ChangePassword, "", true, true},
+		{"POST", "/auth/email/change", s.accountHandler.RequestEmailChange, "", true, true},
+		{"POST", "/auth/api-keys", s.authHandler.CreateAPIKey, "", false, true},
+		{"GET", "/auth/api-keys", s.authHandler.ListAPIKeys, "", false, false},
+		{"GET", "/auth/api-keys/{id}", s.authHandler.GetAPIKey, "", false, false},
+		{"PATCH", "/auth/api-keys/{id}", s.authHandler.UpdateAPIKey, "", false, true},
+		{"DELETE", "/auth/api-keys/{id}", s.authHandler.DeleteAPIKey, "", false, true},
+		{"POST", "/auth/mfa/totp", s.authHandler.EnrollTOTP, "", false, true},
+		{"POST", "/auth/mfa/totp/confirm", s.authHandler.ConfirmTOTP, "", false, true},
+		{"DELETE", "/auth/mfa/totp", s.authHandler.DisableTOTP, "", false, true},
+		{"POST", "/auth/mfa/recovery-codes", s.authHandler.RegenerateRecoveryCodes, "", false, true},
+		{"GET", "/users", s.userHandler.List, auth.PermUsersRead, false, false},
+		{"GET", "/users/me", s.userHandler.GetMe, "", false, false},
+		{"GET", "/users/me/export", s.privacyHandler.Export, "", true, true},
+		{"DELETE", "/users/me", s.privacyHandler.EraseMe, "", true, true},
+		{"GET", "/users/{id}", s.userHandler.Get, auth.PermUsersRead, false, false},
+		{"DELETE", "/users/{id}", s.userHandler.Delete, auth.PermUsersDelete, false, true},
+		{"POST", "/users/{id}/unlock", s.authHandler.UnlockUser, auth.PermUsersUnlock, false, true},
+		{"POST", "/admin/users/{id}/impersonate", s.authHandler.ImpersonateUser, auth.PermUsersImpersonate, false, true},
+		{"POST", "/admin/users/{id}/erase", s.privacyHandler.EraseUser, auth.PermUsersErase, false, true},
+		{"PATCH", "/users/me", s.userHandler.UpdateMe, "", false, false},
+		{"GET", "/addresses", s.addressHandler.List, auth.PermAddressesRead, false, false},
+		{"POST", "/addresses", s.addressHandler.Create, auth.PermAddressesWrite, false, false},
+		{"GET", "/addresses/{id}", s.addressHandler.Get, auth.PermAddressesRead, false, false},
+		{"PUT", "/addresses/{id}", s.addressHandler.Update, auth.PermAddressesWrite, false, false},
+		{"DELETE", "/addresses/{id}", s.addressHandler.Delete, auth.PermAddressesWrite, false, false},
+		{"POST", "/addresses/{id}/restore", s.addressHandler.Restore, auth.PermAddressesWrite, false, false},
+		{"GET", "/organizations", s.orgHandler.List, auth.PermOrganizationsRead, false, false},
+		{"POST", "/organizations", s.orgHandler.Create, auth.PermOrganizationsWrite, false, false},
+		{"GET", "/organizations/{id}", s.orgHandler.Get, auth.PermOrganizationsRead, false, false},
+		{"PUT", "/organizations/{id}", s.orgHandler.Update, auth.PermOrganizationsWrite, false, false},
+		{"DELETE", "/organizations/{id}", s.orgHandler.Delete, auth.PermOrganizationsWrite, false, false},
+		{"GET", "/organizations/{id}/members", s.orgHandler.Members, auth.PermOrganizationsRead, false, false},
+		{"DELETE", "/organizations/{id}/members/{user_id}", s.orgHandler.RemoveMember, auth.PermOrganizationsWrite, false, false},
+		{"POST", "/organizations/{id}/transfer", s.orgHandler.TransferOwnership, auth.PermOrganizationsWrite, false, true},
+		{"POST", "/organizations/{id}/invitations", s.orgHandler.Invite, auth.PermOrganizationsWrite, false, false},
+		{"POST", "/invitations/accept", s.orgHandler.AcceptInvitation, auth.PermOrganizationsWrite, false, true},
 	}
 
-	routeList := []string{"GET /health", "GET /swagger/", "POST /auth/register", "POST /auth/login"}
+	routeList := []string{"GET /health", "GET /swagger/", "POST /auth/register", "POST /auth/login", "POST /auth/login/mfa", "POST /auth/refresh", "POST /auth/password/forgot", "POST /auth/password/reset", "GET /auth/verify", "GET /auth/unlock", "GET /auth/email/confirm", "GET /auth/oidc/{provider}/start", "GET /auth/oidc/{provider}/callback", "GET /.well-known/jwks.json"}
+	if s.magicLinkEnabled {
+		routeList = append(routeList, "POST /auth/magic-link", "GET /auth/magic-link/consume")
+	}
 	for _, route := range protectedRoutes {
-		http.Handle(route.method+" "+route.path, authMiddleware(http.HandlerFunc(route.handler)))
+		var handler http.Handler = route.handler
+		if route.blockImpersonation {
+			handler = auth.RejectImpersonation(handler)
+		}
+		if route.permission != "" {
+			handler = auth.RequirePermission(route.permission)(handler)
+		}
+		if route.allowUnverified {
+			handler = unverifiedMiddleware(handler)
+		} else {
+			handler = authMiddleware(handler)
+		}
+		http.Handle(route.method+" "+route.path, handler)
 		routeList = append(routeList, route.method+" "+route.path+" (protected)")
 	}
 
@@ -127,6 +406,14 @@ func (s *Server) setupRoutes() {
 func (s *Server) start() error {
 	s.setupRoutes()
 
+	// Start background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.revocations.Run(ctx, s.revocationSyncInterval)
+	go s.loginThrottle.Run(ctx, loginThrottlePurgeInterval)
+	go s.sessions.Run(ctx, sessionFlushInterval)
+	go s.purger.Run(ctx, purgeInterval)
+
 	// Wrap default mux with logging middleware
 	handler := middleware.Logging(http.DefaultServeMux)
 
@@ -0,0 +1,105 @@
+//go:build unit
+
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "94287082"},
+		{unix: 1111111109, expected: "07081804"},
+		{unix: 1111111111, expected: "14050471"},
+		{unix: 1234567890, expected: "89005924"},
+		{unix: 2000000000, expected: "69279037"},
+		{unix: 20000000000, expected: "65353130"},
+	}
+
+	key, err := decodeSecret(rfcSecret)
+	if err != nil {
+		t.Fatalf("failed to decode secret: %v", err)
+	}
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		if got := code(key, step, 8); got != tt.expected {
+			t.Errorf("at %d: expected %s, got %s", tt.unix, tt.expected, got)
+		}
+		// Six digit codes are the last six digits of the eight digit ones
+		got, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("failed to compute code: %v", err)
+		}
+		if got != tt.expected[2:] {
+			t.Errorf("at %d: expected %s, got %s", tt.unix, tt.expected[2:], got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	current := Step(now)
+
+	tests := []struct {
+		name     string
+		step     int64
+		expected bool
+	}{
+		{name: "current step", step: current, expected: true},
+		{name: "previous step", step: current - 1, expected: true},
+		{name: "next step", step: current + 1, expected: true},
+		{name: "too old", step: current - 2, expected: false},
+		{name: "too new", step: current + 2, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate, err := Code(secret, tt.step)
+			if err != nil {
+				t.Fatalf("failed to compute code: %v", err)
+			}
+			step, ok := Validate(secret, candidate, now, 1)
+			if ok != tt.expected {
+				t.Fatalf("expected valid=%v, got %v", tt.expected, ok)
+			}
+			if ok && step != tt.step {
+				t.Errorf("expected step %d, got %d", tt.step, step)
+			}
+		})
+	}
+
+	if _, ok := Validate(secret, "12345", now, 1); ok {
+		t.Error("expected a short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("go-test-api", "john@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("failed to parse uri: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("expected otpauth://totp, got %s://%s", uri.Scheme, uri.Host)
+	}
+	if uri.Path != "/go-test-api:john@example.com" {
+		t.Errorf("unexpected label %q", uri.Path)
+	}
+	if got := uri.Query().Get("secret"); got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("expected secret JBSWY3DPEHPK3PXP, got %q", got)
+	}
+	if got := uri.Query().Get("issuer"); got != "go-test-api" {
+		t.Errorf("expected issuer go-test-api, got %q", got)
+	}
+}
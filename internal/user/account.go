@@ -0,0 +1,305 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/password"
+	"go-test-api/internal/validator"
+	"go-test-api/pkg/response"
+)
+
+// apiKeyMessage rejects credential changes made with an API key, so that a
+// leaked key cannot be used to take over the account
+const apiKeyMessage = "API keys cannot change account credentials"
+
+// PasswordHasher hashes and verifies passwords
+type PasswordHasher interface {
+	HashPassword(plain string) (string, error)
+	CheckPassword(hashedPassword, plain string) error
+}
+
+// SessionRevoker invalidates every token issued to a user so far
+type SessionRevoker interface {
+	RevokeSessions(ctx context.Context, userID int32) error
+}
+
+// EmailChangeNotifier sends the link confirming a new email address
+type EmailChangeNotifier interface {
+	SendEmailChange(ctx context.Context, to, token string, ttl time.Duration) error
+}
+
+// ErrPasswordThrottled is returned by a PasswordThrottle while password
+// checks for an account are refused
+var ErrPasswordThrottled = errors.New("too many incorrect passwords")
+
+// PasswordThrottle limits how fast the current password of an account can be
+// guessed. Incorrect passwords count as failed logins of the account.
+type PasswordThrottle interface {
+	ClientIP(r *http.Request) string
+	// Begin admits a password check for email from ip, or returns
+	// ErrPasswordThrottled along with how long to wait. The check counts as
+	// failed until the attempt is settled otherwise, so that checks made in
+	// parallel are held to the limits too.
+	Begin(ctx context.Context, email, ip string) (PasswordAttempt, time.Duration, error)
+}
+
+// PasswordAttempt is a password check admitted by a PasswordThrottle. Errors
+// are only logged, since the check's outcome does not depend on them.
+type PasswordAttempt interface {
+	// Fail counts an incorrect password against userID
+	Fail(ctx context.Context, userID int32)
+	// Succeed forgets the failed logins of the account
+	Succeed(ctx context.Context)
+	// Abandon withdraws a check that ended without a verdict. It does
+	// nothing once the check failed or succeeded.
+	Abandon(ctx context.Context)
+}
+
+// AccountHandler handles requests changing the credentials of the current
+// user. Every change invalidates the tokens issued before it.
+type AccountHandler struct {
+	validator         *validator.Validator
+	repo              Repo
+	passwords         PasswordHasher
+	sessions          SessionRevoker
+	throttle          PasswordThrottle
+	notifier          EmailChangeNotifier
+	emailChangeExpiry time.Duration
+}
+
+// NewAccountHandler creates a new AccountHandler. Email change links expire
+// after emailChangeExpiry.
+func NewAccountHandler(v *validator.Validator, repo Repo, passwords PasswordHasher, sessions SessionRevoker, throttle PasswordThrottle, notifier EmailChangeNotifier, emailChangeExpiry time.Duration) *AccountHandler {
+	return &AccountHandler{
+		validator:         v,
+		repo:              repo,
+		passwords:         passwords,
+		sessions:          sessions,
+		throttle:          throttle,
+		notifier:          notifier,
+		emailChangeExpiry: emailChangeExpiry,
+	}
+}
+
+// ChangePassword handles POST /auth/password/change
+// @Summary Change password
+// @Description Replace the current user's password, given the current one. Every existing session, including the current one, is revoked.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/password/change [post]
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !h.checkPassword(w, r, id, req.CurrentPassword) {
+		return
+	}
+
+	hash, err := h.passwords.HashPassword(req.NewPassword)
+	if err != nil {
+		if errors.Is(err, password.ErrTooLong) {
+			response.Error(w, http.StatusBadRequest, "password is too long")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+	if err := h.repo.UpdatePassword(r.Context(), id, hash); err != nil {
+		response.Error(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	// Whoever knew the old password may still hold a session
+	if err := h.sessions.RevokeSessions(r.Context(), id); err != nil {
+		response.Error(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestEmailChange handles POST /auth/email/change
+// @Summary Change email address
+// @Description Start changing the current user's email address, given their password. A confirmation link is sent to the new address, which replaces the current one once the link is opened. Only the latest request can be confirmed.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ChangeEmailRequest true "New email address and current password"
+// @Success 202 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/email/change [post]
+func (h *AccountHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.EqualFold(req.NewEmail, authctx.Email(r.Context())) {
+		response.Error(w, http.StatusBadRequest, "new email is the same as the current one")
+		return
+	}
+
+	if !h.checkPassword(w, r, id, req.Password) {
+		return
+	}
+
+	token, err := opaque.New()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "failed to change email")
+		return
+	}
+	expiresAt := time.Now().Add(h.emailChangeExpiry)
+	if err := h.repo.CreateEmailChange(r.Context(), id, req.NewEmail, opaque.Hash(token), expiresAt); err != nil {
+		response.Error(w, http.StatusInternalServerError, "failed to change email")
+		return
+	}
+	if err := h.notifier.SendEmailChange(r.Context(), req.NewEmail, token, h.emailChangeExpiry); err != nil {
+		slog.Error("Failed to send email change confirmation", "user_id", id, "error", err)
+		response.Error(w, http.StatusInternalServerError, "failed to send confirmation email")
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, MessageResponse{Message: "Confirmation link sent to the new address"})
+}
+
+// ConfirmEmailChange handles GET /auth/email/confirm
+// @Summary Confirm email address change
+// @Description Replace the email address of an account with the one a confirmation link was sent to. The new address counts as verified, and every existing session of the user is revoked.
+// @Tags auth
+// @Produce json
+// @Param token query string true "Email change token"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/email/confirm [get]
+func (h *AccountHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "missing email change token")
+		return
+	}
+
+	id, err := h.repo.ConfirmEmailChange(r.Context(), opaque.Hash(token))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidEmailChangeToken):
+			response.Error(w, http.StatusBadRequest, "invalid or expired email change token")
+		case errors.Is(err, ErrEmailExists):
+			response.Error(w, http.StatusConflict, "email already registered")
+		default:
+			response.Error(w, http.StatusInternalServerError, "failed to change email")
+		}
+		return
+	}
+
+	// Tokens carry the email, so every one issued before the change is stale
+	if err := h.sessions.RevokeSessions(r.Context(), id); err != nil {
+		response.Error(w, http.StatusInternalServerError, "failed to change email")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, MessageResponse{Message: "Email address changed"})
+}
+
+// currentUser returns the ID of the authenticated user, rejecting requests
+// made with an API key. It returns false if the request was answered.
+func (h *AccountHandler) currentUser(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	id, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "invalid user")
+		return 0, false
+	}
+	if authctx.IsAPIKey(r.Context()) {
+		response.Error(w, http.StatusForbidden, apiKeyMessage)
+		return 0, false
+	}
+	return int32(id), true
+}
+
+// checkPassword confirms the user knows their current password. Incorrect
+// passwords are throttled like failed logins of the account. It returns false
+// if the request was answered.
+func (h *AccountHandler) checkPassword(w http.ResponseWriter, r *http.Request, id int32, plain string) bool {
+	email := authctx.Email(r.Context())
+	ip := h.throttle.ClientIP(r)
+	attempt, retryAfter, err := h.throttle.Begin(r.Context(), email, ip)
+	if err != nil {
+		if errors.Is(err, ErrPasswordThrottled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			response.Error(w, http.StatusTooManyRequests, "too many incorrect passwords, try again later")
+			return false
+		}
+		response.Error(w, http.StatusInternalServerError, "failed to check password")
+		return false
+	}
+	// Outlives the request so that a client hanging up does not leave the
+	// check counted as failed
+	defer attempt.Abandon(context.WithoutCancel(r.Context()))
+
+	hash, err := h.repo.PasswordHash(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusUnauthorized, "invalid user")
+			return false
+		}
+		response.Error(w, http.StatusInternalServerError, "failed to check password")
+		return false
+	}
+	if err := h.passwords.CheckPassword(hash, plain); err != nil {
+		attempt.Fail(r.Context(), id)
+		response.Error(w, http.StatusBadRequest, "current password is incorrect")
+		return false
+	}
+	attempt.Succeed(r.Context())
+	return true
+}
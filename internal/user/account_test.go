@@ -0,0 +1,317 @@
+//go:build unit
+
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/opaque"
+	"go-test-api/internal/validator"
+)
+
+// mockPasswordHasher "hashes" by adding a prefix
+type mockPasswordHasher struct{}
+
+func (mockPasswordHasher) HashPassword(plain string) (string, error) {
+	return "hashed:" + plain, nil
+}
+
+func (mockPasswordHasher) CheckPassword(hashedPassword, plain string) error {
+	if hashedPassword != "hashed:"+plain {
+		return errors.New("mismatch")
+	}
+	return nil
+}
+
+// mockSessionRevoker records the users whose sessions were revoked
+type mockSessionRevoker struct {
+	revoked []int32
+	err     error
+}
+
+func (m *mockSessionRevoker) RevokeSessions(ctx context.Context, userID int32) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.revoked = append(m.revoked, userID)
+	return nil
+}
+
+// mockEmailChangeNotifier records the last confirmation sent
+type mockEmailChangeNotifier struct {
+	to    string
+	token string
+}
+
+func (m *mockEmailChangeNotifier) SendEmailChange(ctx context.Context, to, token string, ttl time.Duration) error {
+	m.to, m.token = to, token
+	return nil
+}
+
+// mockPasswordThrottle refuses every check while throttled, and records the
+// failures and successes of the checks it admits
+type mockPasswordThrottle struct {
+	throttled bool
+	failures  []int32
+	successes int
+}
+
+func (m *mockPasswordThrottle) ClientIP(r *http.Request) string {
+	return "192.0.2.1"
+}
+
+func (m *mockPasswordThrottle) Begin(ctx context.Context, email, ip string) (PasswordAttempt, time.Duration, error) {
+	if m.throttled {
+		return nil, 90 * time.Second, ErrPasswordThrottled
+	}
+	return mockPasswordAttempt{m}, 0, nil
+}
+
+// mockPasswordAttempt reports the outcome of a check to its throttle
+type mockPasswordAttempt struct {
+	throttle *mockPasswordThrottle
+}
+
+func (m mockPasswordAttempt) Fail(ctx context.Context, userID int32) {
+	m.throttle.failures = append(m.throttle.failures, userID)
+}
+
+func (m mockPasswordAttempt) Succeed(ctx context.Context) {
+	m.throttle.successes++
+}
+
+func (m mockPasswordAttempt) Abandon(ctx context.Context) {}
+
+func TestAccountHandler_ChangePassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		apiKey         bool
+		body           string
+		storedHash     string
+		revokeErr      error
+		throttled      bool
+		expectedStatus int
+		expectUpdate   bool
+		expectFailure  bool
+	}{
+		{
+			name:           "successful change",
+			body:           `{"current_password": "oldpassword", "new_password": "newpassword"}`,
+			storedHash:     "hashed:oldpassword",
+			expectedStatus: http.StatusNoContent,
+			expectUpdate:   true,
+		},
+		{
+			name:           "wrong current password",
+			body:           `{"current_password": "guessed", "new_password": "newpassword"}`,
+			storedHash:     "hashed:oldpassword",
+			expectedStatus: http.StatusBadRequest,
+			expectFailure:  true,
+		},
+		{
+			name:           "throttled",
+			body:           `{"current_password": "oldpassword", "new_password": "newpassword"}`,
+			storedHash:     "hashed:oldpassword",
+			throttled:      true,
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:           "new password too short",
+			body:           `{"current_password": "oldpassword", "new_password": "short"}`,
+			storedHash:     "hashed:oldpassword",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "api key",
+			apiKey:         true,
+			body:           `{"current_password": "oldpassword", "new_password": "newpassword"}`,
+			storedHash:     "hashed:oldpassword",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "revocation fails",
+			body:           `{"current_password": "oldpassword", "new_password": "newpassword"}`,
+			storedHash:     "hashed:oldpassword",
+			revokeErr:      errors.New("database connection failed"),
+			expectedStatus: http.StatusInternalServerError,
+			expectUpdate:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var updatedHash string
+			mockRepo := &mockUserRepository{
+				passwordHashFunc: func(ctx context.Context, id int32) (string, error) {
+					if id != 7 {
+						return "", ErrNotFound
+					}
+					return tt.storedHash, nil
+				},
+				updatePasswordFunc: func(ctx context.Context, id int32, passwordHash string) error {
+					updatedHash = passwordHash
+					return nil
+				},
+			}
+			sessions := &mockSessionRevoker{err: tt.revokeErr}
+			throttle := &mockPasswordThrottle{throttled: tt.throttled}
+			handler := NewAccountHandler(validator.New(), mockRepo, mockPasswordHasher{}, sessions, throttle, &mockEmailChangeNotifier{}, time.Hour)
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/password/change", strings.NewReader(tt.body))
+			ctx := authctx.WithUser(req.Context(), "7", "john@example.com")
+			if tt.apiKey {
+				ctx = authctx.WithAPIKey(ctx)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ChangePassword(w, req.WithContext(ctx))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectUpdate && updatedHash != "hashed:newpassword" {
+				t.Errorf("expected new password hash to be stored, got %q", updatedHash)
+			}
+			if !tt.expectUpdate && updatedHash != "" {
+				t.Error("expected password to be left unchanged")
+			}
+			if tt.expectedStatus == http.StatusNoContent && (len(sessions.revoked) != 1 || sessions.revoked[0] != 7) {
+				t.Errorf("expected sessions of user 7 to be revoked, got %v", sessions.revoked)
+			}
+			if tt.expectFailure != (len(throttle.failures) == 1) {
+				t.Errorf("expected failure recorded: %v, got %v", tt.expectFailure, throttle.failures)
+			}
+			if tt.expectUpdate && throttle.successes != 1 {
+				t.Errorf("expected the correct password to be recorded, got %d successes", throttle.successes)
+			}
+			if tt.throttled && w.Header().Get("Retry-After") != "90" {
+				t.Errorf("expected Retry-After 90, got %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestAccountHandler_RequestEmailChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "successful request",
+			body:           `{"new_email": "john@new.example.com", "password": "oldpassword"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "same email",
+			body:           `{"new_email": "John@Example.com", "password": "oldpassword"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "wrong password",
+			body:           `{"new_email": "john@new.example.com", "password": "guessed"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid email",
+			body:           `{"new_email": "not-an-email", "password": "oldpassword"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var storedTokenHash, storedEmail string
+			mockRepo := &mockUserRepository{
+				passwordHashFunc: func(ctx context.Context, id int32) (string, error) {
+					return "hashed:oldpassword", nil
+				},
+				createEmailChangeFunc: func(ctx context.Context, id int32, newEmail, tokenHash string, expiresAt time.Time) error {
+					storedEmail, storedTokenHash = newEmail, tokenHash
+					return nil
+				},
+			}
+			notifier := &mockEmailChangeNotifier{}
+			handler := NewAccountHandler(validator.New(), mockRepo, mockPasswordHasher{}, &mockSessionRevoker{}, &mockPasswordThrottle{}, notifier, time.Hour)
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/email/change", strings.NewReader(tt.body))
+			req = req.WithContext(authctx.WithUser(req.Context(), "7", "john@example.com"))
+			w := httptest.NewRecorder()
+
+			handler.RequestEmailChange(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusAccepted {
+				if notifier.token != "" {
+					t.Error("expected no confirmation to be sent")
+				}
+				return
+			}
+
+			// The link goes to the new address; only its hash is stored
+			if storedEmail != "john@new.example.com" || notifier.to != "john@new.example.com" {
+				t.Errorf("expected change to john@new.example.com, stored %q and sent to %q", storedEmail, notifier.to)
+			}
+			if notifier.token == "" || opaque.Hash(notifier.token) != storedTokenHash {
+				t.Error("expected the stored hash to match the token sent")
+			}
+		})
+	}
+}
+
+func TestAccountHandler_ConfirmEmailChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		confirmErr     error
+		expectedStatus int
+	}{
+		{name: "successful confirmation", token: "valid-token", expectedStatus: http.StatusOK},
+		{name: "missing token", expectedStatus: http.StatusBadRequest},
+		{name: "invalid token", token: "used-token", confirmErr: ErrInvalidEmailChangeToken, expectedStatus: http.StatusBadRequest},
+		{name: "email taken meanwhile", token: "valid-token", confirmErr: ErrEmailExists, expectedStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &mockUserRepository{
+				confirmEmailChangeFunc: func(ctx context.Context, tokenHash string) (int32, error) {
+					if tokenHash != opaque.Hash(tt.token) {
+						return 0, errors.New("unexpected token hash")
+					}
+					return 7, tt.confirmErr
+				},
+			}
+			sessions := &mockSessionRevoker{}
+			handler := NewAccountHandler(validator.New(), mockRepo, mockPasswordHasher{}, sessions, &mockPasswordThrottle{}, &mockEmailChangeNotifier{}, time.Hour)
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/email/confirm?token="+tt.token, nil)
+			w := httptest.NewRecorder()
+
+			handler.ConfirmEmailChange(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			revoked := len(sessions.revoked) == 1 && sessions.revoked[0] == 7
+			if revoked != (tt.expectedStatus == http.StatusOK) {
+				t.Errorf("expected sessions revoked only on success, got %v", sessions.revoked)
+			}
+		})
+	}
+}
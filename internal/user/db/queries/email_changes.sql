@@ -0,0 +1,32 @@
+-- name: CreateEmailChangeToken :exec
+WITH superseded AS (
+    DELETE FROM email_change_tokens
+    WHERE user_id = $1 AND used_at IS NULL
+)
+INSERT INTO email_change_tokens (user_id, new_email, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConfirmEmailChange :one
+WITH consumed AS (
+    UPDATE email_change_tokens
+    SET used_at = $2
+    WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+    RETURNING user_id, new_email
+)
+UPDATE users
+SET email = consumed.new_email,
+    email_verified_at = $2,
+    updated_at = $2
+FROM consumed
+WHERE users.id = consumed.user_id AND users.deleted_at IS NULL
+RETURNING users.id;
+
+-- name: DeleteMailedLoginTokens :exec
+-- Drops the password reset and magic link tokens of a user that are still
+-- outstanding, since they were mailed to an address the user no longer has
+WITH resets AS (
+    DELETE FROM password_reset_tokens
+    WHERE password_reset_tokens.user_id = $1 AND used_at IS NULL
+)
+DELETE FROM magic_link_tokens
+WHERE magic_link_tokens.user_id = $1 AND used_at IS NULL;
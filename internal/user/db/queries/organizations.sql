@@ -0,0 +1,29 @@
+-- name: OwnsSharedOrganization :one
+-- Whether the user owns an organization that has other members, which must
+-- be handed over before the user can be deleted
+SELECT EXISTS (
+    SELECT 1
+    FROM organizations o
+    JOIN organization_members m ON m.organization_id = o.id
+    WHERE o.owner_id = $1 AND m.user_id <> o.owner_id
+);
+
+-- name: DeletePurgedUsersOrganizations :execrows
+-- Organizations go with their owner when PurgeDeletedUsers purges them,
+-- unless they have other members. Their addresses are deleted by a trigger.
+DELETE FROM organizations o
+USING users u
+WHERE u.id = o.owner_id
+  AND u.deleted_at <= $1
+  AND NOT EXISTS (
+      SELECT 1
+      FROM organization_members m
+      WHERE m.organization_id = o.id AND m.user_id <> o.owner_id
+  );
+
+-- name: IsOrganizationMember :one
+-- Whether the user belongs to any organization, which keeps them in its
+-- tenant
+SELECT EXISTS (
+    SELECT 1 FROM organization_members WHERE user_id = $1
+);
@@ -0,0 +1,31 @@
+-- name: GetTenantIDBySlug :one
+SELECT id
+FROM tenants
+WHERE slug = $1;
+
+-- name: CreateTenant :one
+INSERT INTO tenants (name, slug)
+VALUES ($1, $2)
+RETURNING id;
+
+-- name: MoveUserToTenant :execrows
+-- Moves a user and their addresses to another tenant. Their sessions and
+-- refresh tokens are revoked: access tokens carry the tenant they were
+-- issued in.
+WITH moved_addresses AS (
+    UPDATE addresses
+    SET tenant_id = @tenant_id
+    WHERE entity_type = 'user' AND entity_id = @id
+), revoked_sessions AS (
+    UPDATE sessions
+    SET revoked_at = @moved_at
+    WHERE user_id = @id AND revoked_at IS NULL
+), revoked_refresh_tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = @moved_at
+    WHERE user_id = @id AND revoked_at IS NULL
+)
+UPDATE users
+SET tenant_id = @tenant_id,
+    updated_at = @moved_at
+WHERE id = @id AND deleted_at IS NULL;
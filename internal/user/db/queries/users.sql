@@ -1,30 +1,124 @@
 -- name: GetUser :one
-SELECT id, name, email, created_at, updated_at 
+SELECT id, name, email, email_verified_at, created_at, updated_at 
 FROM users
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: GetUserByEmail :one
-SELECT id, name, email, password_hash, created_at, updated_at 
+SELECT id, name, email, email_verified_at, password_hash, created_at, updated_at 
+FROM users
+WHERE email = $1 AND deleted_at IS NULL;
+
+-- name: GetUserPasswordHash :one
+SELECT password_hash
+FROM users
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ListUsersByCreatedAt :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE (sqlc.narg('email')::text IS NULL OR email ILIKE sqlc.narg('email'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL OR (created_at, id) > (sqlc.narg('after_created_at')::timestamptz, sqlc.arg('after_id')::int))
+ORDER BY created_at, id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListUsersByCreatedAtDesc :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE (sqlc.narg('email')::text IS NULL OR email ILIKE sqlc.narg('email'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL OR (created_at, id) < (sqlc.narg('after_created_at')::timestamptz, sqlc.arg('after_id')::int))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListUsersByID :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE (sqlc.narg('email')::text IS NULL OR email ILIKE sqlc.narg('email'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_id')::int IS NULL OR id > sqlc.narg('after_id'))
+ORDER BY id
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListUsersByIDDesc :many
+SELECT id, name, email, created_at, updated_at, deleted_at
 FROM users
-WHERE email = $1;
+WHERE (sqlc.narg('email')::text IS NULL OR email ILIKE sqlc.narg('email'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_id')::int IS NULL OR id < sqlc.narg('after_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('row_limit');
 
--- name: ListUsers :many
-SELECT id, name, email, created_at, updated_at 
+-- name: ListUsersByName :many
+SELECT id, name, email, created_at, updated_at, deleted_at
 FROM users
-ORDER BY id;
+WHERE (sqlc.narg('email')::text IS NULL OR email ILIKE sqlc.narg('email'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_name')::text IS NULL OR (name, id) > (sqlc.narg('after_name')::text, sqlc.arg('after_id')::int))
+ORDER BY name, id
+LIMIT sqlc.arg('row_limit');
 
--- name: ListUsersByEmail :many
-SELECT id, name, email, created_at, updated_at 
+-- name: ListUsersByNameDesc :many
+SELECT id, name, email, created_at, updated_at, deleted_at
 FROM users
-WHERE email ILIKE $1
-ORDER BY id;
+WHERE (sqlc.narg('email')::text IS NULL OR email ILIKE sqlc.narg('email'))
+  AND (sqlc.arg('include_deleted')::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg('after_name')::text IS NULL OR (name, id) < (sqlc.narg('after_name')::text, sqlc.arg('after_id')::int))
+ORDER BY name DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: CreateUser :one
+INSERT INTO users (name, email, password_hash, created_at, updated_at, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, email, created_at, updated_at;
 
--- name: UpsertUser :one
-INSERT INTO users (name, email, password_hash, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5)
-ON CONFLICT (email) 
-DO UPDATE SET 
-    name = EXCLUDED.name,
-    password_hash = EXCLUDED.password_hash,
-    updated_at = EXCLUDED.updated_at
+-- name: UpdateUser :one
+UPDATE users
+SET name = $2,
+    updated_at = $3
+WHERE id = $1 AND deleted_at IS NULL
 RETURNING id, name, email, created_at, updated_at;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    updated_at = $3
+WHERE id = $1;
+
+
+-- name: MarkUserEmailVerified :execrows
+UPDATE users
+SET email_verified_at = $2,
+    updated_at = $2
+WHERE id = $1 AND email_verified_at IS NULL;
+
+-- name: RehashUserPassword :execrows
+UPDATE users
+SET password_hash = @new_hash
+WHERE id = @id AND password_hash = @old_hash;
+
+-- name: DeleteUser :execrows
+-- Users are soft-deleted along with their addresses, which only a hard
+-- delete takes with it, and their sessions are revoked. Both are purged by
+-- PurgeDeletedUsers.
+WITH deleted_addresses AS (
+    UPDATE addresses
+    SET deleted_at = $2
+    WHERE entity_type = 'user' AND entity_id = $1 AND deleted_at IS NULL
+), revoked_sessions AS (
+    UPDATE sessions
+    SET revoked_at = $2
+    WHERE user_id = $1 AND revoked_at IS NULL
+)
+UPDATE users
+SET deleted_at = $2
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: PurgeDeletedUsers :execrows
+-- Hard-deletes users deleted before the cutoff. Their addresses are deleted
+-- by a trigger, everything else cascades. Users still owning an organization
+-- are kept until it is handed over, or deleted by
+-- DeletePurgedUsersOrganizations.
+DELETE FROM users
+WHERE deleted_at <= $1
+  AND NOT EXISTS (SELECT 1 FROM organizations o WHERE o.owner_id = users.id);
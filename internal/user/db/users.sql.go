@@ -11,13 +11,22 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const getUser = `-- name: GetUser :one
-SELECT id, name, email, created_at, updated_at 
-FROM users
-WHERE id = $1
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (name, email, password_hash, created_at, updated_at, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, email, created_at, updated_at
 `
 
-type GetUserRow struct {
+type CreateUserParams struct {
+	Name         string             `json:"name"`
+	Email        string             `json:"email"`
+	PasswordHash string             `json:"password_hash"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	TenantID     int32              `json:"tenant_id"`
+}
+
+type CreateUserRow struct {
 	ID        int32              `json:"id"`
 	Name      string             `json:"name"`
 	Email     string             `json:"email"`
@@ -25,6 +34,72 @@ type GetUserRow struct {
 	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
+	row := q.db.QueryRow(ctx, createUser,
+		arg.Name,
+		arg.Email,
+		arg.PasswordHash,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.TenantID,
+	)
+	var i CreateUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+WITH deleted_addresses AS (
+    UPDATE addresses
+    SET deleted_at = $2
+    WHERE entity_type = 'user' AND entity_id = $1 AND deleted_at IS NULL
+), revoked_sessions AS (
+    UPDATE sessions
+    SET revoked_at = $2
+    WHERE user_id = $1 AND revoked_at IS NULL
+)
+UPDATE users
+SET deleted_at = $2
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+type DeleteUserParams struct {
+	ID        int32              `json:"id"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
+}
+
+// Users are soft-deleted along with their addresses, which only a hard
+// delete takes with it, and their sessions are revoked. Both are purged by
+// PurgeDeletedUsers.
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, arg.ID, arg.DeletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, name, email, email_verified_at, created_at, updated_at 
+FROM users
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+type GetUserRow struct {
+	ID              int32              `json:"id"`
+	Name            string             `json:"name"`
+	Email           string             `json:"email"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
 func (q *Queries) GetUser(ctx context.Context, id int32) (GetUserRow, error) {
 	row := q.db.QueryRow(ctx, getUser, id)
 	var i GetUserRow
@@ -32,6 +107,7 @@ func (q *Queries) GetUser(ctx context.Context, id int32) (GetUserRow, error) {
 		&i.ID,
 		&i.Name,
 		&i.Email,
+		&i.EmailVerifiedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
@@ -39,18 +115,19 @@ func (q *Queries) GetUser(ctx context.Context, id int32) (GetUserRow, error) {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, name, email, password_hash, created_at, updated_at 
+SELECT id, name, email, email_verified_at, password_hash, created_at, updated_at 
 FROM users
-WHERE email = $1
+WHERE email = $1 AND deleted_at IS NULL
 `
 
 type GetUserByEmailRow struct {
-	ID           int32              `json:"id"`
-	Name         string             `json:"name"`
-	Email        string             `json:"email"`
-	PasswordHash string             `json:"password_hash"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	ID              int32              `json:"id"`
+	Name            string             `json:"name"`
+	Email           string             `json:"email"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+	PasswordHash    string             `json:"password_hash"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
@@ -60,6 +137,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.ID,
 		&i.Name,
 		&i.Email,
+		&i.EmailVerifiedAt,
 		&i.PasswordHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
@@ -67,35 +145,68 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 	return i, err
 }
 
-const listUsers = `-- name: ListUsers :many
-SELECT id, name, email, created_at, updated_at 
+const getUserPasswordHash = `-- name: GetUserPasswordHash :one
+SELECT password_hash
 FROM users
-ORDER BY id
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserPasswordHash(ctx context.Context, id int32) (string, error) {
+	row := q.db.QueryRow(ctx, getUserPasswordHash, id)
+	var password_hash string
+	err := row.Scan(&password_hash)
+	return password_hash, err
+}
+
+const listUsersByCreatedAt = `-- name: ListUsersByCreatedAt :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND ($3::timestamptz IS NULL OR (created_at, id) > ($3::timestamptz, $4::int))
+ORDER BY created_at, id
+LIMIT $5
 `
 
-type ListUsersRow struct {
+type ListUsersByCreatedAtParams struct {
+	Email          pgtype.Text        `json:"email"`
+	IncludeDeleted bool               `json:"include_deleted"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        int32              `json:"after_id"`
+	RowLimit       int32              `json:"row_limit"`
+}
+
+type ListUsersByCreatedAtRow struct {
 	ID        int32              `json:"id"`
 	Name      string             `json:"name"`
 	Email     string             `json:"email"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
 }
 
-func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+func (q *Queries) ListUsersByCreatedAt(ctx context.Context, arg ListUsersByCreatedAtParams) ([]ListUsersByCreatedAtRow, error) {
+	rows, err := q.db.Query(ctx, listUsersByCreatedAt,
+		arg.Email,
+		arg.IncludeDeleted,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []ListUsersRow{}
+	items := []ListUsersByCreatedAtRow{}
 	for rows.Next() {
-		var i ListUsersRow
+		var i ListUsersByCreatedAtRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
 			&i.Email,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -107,36 +218,113 @@ func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
 	return items, nil
 }
 
-const listUsersByEmail = `-- name: ListUsersByEmail :many
-SELECT id, name, email, created_at, updated_at 
+const listUsersByCreatedAtDesc = `-- name: ListUsersByCreatedAtDesc :many
+SELECT id, name, email, created_at, updated_at, deleted_at
 FROM users
-WHERE email ILIKE $1
+WHERE ($1::text IS NULL OR email ILIKE $1)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND ($3::timestamptz IS NULL OR (created_at, id) < ($3::timestamptz, $4::int))
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListUsersByCreatedAtDescParams struct {
+	Email          pgtype.Text        `json:"email"`
+	IncludeDeleted bool               `json:"include_deleted"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        int32              `json:"after_id"`
+	RowLimit       int32              `json:"row_limit"`
+}
+
+type ListUsersByCreatedAtDescRow struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
+}
+
+func (q *Queries) ListUsersByCreatedAtDesc(ctx context.Context, arg ListUsersByCreatedAtDescParams) ([]ListUsersByCreatedAtDescRow, error) {
+	rows, err := q.db.Query(ctx, listUsersByCreatedAtDesc,
+		arg.Email,
+		arg.IncludeDeleted,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUsersByCreatedAtDescRow{}
+	for rows.Next() {
+		var i ListUsersByCreatedAtDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByID = `-- name: ListUsersByID :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND ($3::int IS NULL OR id > $3)
 ORDER BY id
+LIMIT $4
 `
 
-type ListUsersByEmailRow struct {
+type ListUsersByIDParams struct {
+	Email          pgtype.Text `json:"email"`
+	IncludeDeleted bool        `json:"include_deleted"`
+	AfterID        pgtype.Int4 `json:"after_id"`
+	RowLimit       int32       `json:"row_limit"`
+}
+
+type ListUsersByIDRow struct {
 	ID        int32              `json:"id"`
 	Name      string             `json:"name"`
 	Email     string             `json:"email"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
 }
 
-func (q *Queries) ListUsersByEmail(ctx context.Context, email string) ([]ListUsersByEmailRow, error) {
-	rows, err := q.db.Query(ctx, listUsersByEmail, email)
+func (q *Queries) ListUsersByID(ctx context.Context, arg ListUsersByIDParams) ([]ListUsersByIDRow, error) {
+	rows, err := q.db.Query(ctx, listUsersByID,
+		arg.Email,
+		arg.IncludeDeleted,
+		arg.AfterID,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []ListUsersByEmailRow{}
+	items := []ListUsersByIDRow{}
 	for rows.Next() {
-		var i ListUsersByEmailRow
+		var i ListUsersByIDRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
 			&i.Email,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -148,42 +336,267 @@ func (q *Queries) ListUsersByEmail(ctx context.Context, email string) ([]ListUse
 	return items, nil
 }
 
-const upsertUser = `-- name: UpsertUser :one
-INSERT INTO users (name, email, password_hash, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5)
-ON CONFLICT (email) 
-DO UPDATE SET 
-    name = EXCLUDED.name,
-    password_hash = EXCLUDED.password_hash,
-    updated_at = EXCLUDED.updated_at
-RETURNING id, name, email, created_at, updated_at
+const listUsersByIDDesc = `-- name: ListUsersByIDDesc :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND ($3::int IS NULL OR id < $3)
+ORDER BY id DESC
+LIMIT $4
 `
 
-type UpsertUserParams struct {
-	Name         string             `json:"name"`
-	Email        string             `json:"email"`
-	PasswordHash string             `json:"password_hash"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+type ListUsersByIDDescParams struct {
+	Email          pgtype.Text `json:"email"`
+	IncludeDeleted bool        `json:"include_deleted"`
+	AfterID        pgtype.Int4 `json:"after_id"`
+	RowLimit       int32       `json:"row_limit"`
 }
 
-type UpsertUserRow struct {
+type ListUsersByIDDescRow struct {
 	ID        int32              `json:"id"`
 	Name      string             `json:"name"`
 	Email     string             `json:"email"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
 }
 
-func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (UpsertUserRow, error) {
-	row := q.db.QueryRow(ctx, upsertUser,
-		arg.Name,
+func (q *Queries) ListUsersByIDDesc(ctx context.Context, arg ListUsersByIDDescParams) ([]ListUsersByIDDescRow, error) {
+	rows, err := q.db.Query(ctx, listUsersByIDDesc,
 		arg.Email,
-		arg.PasswordHash,
-		arg.CreatedAt,
-		arg.UpdatedAt,
+		arg.IncludeDeleted,
+		arg.AfterID,
+		arg.RowLimit,
 	)
-	var i UpsertUserRow
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUsersByIDDescRow{}
+	for rows.Next() {
+		var i ListUsersByIDDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByName = `-- name: ListUsersByName :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND ($3::text IS NULL OR (name, id) > ($3::text, $4::int))
+ORDER BY name, id
+LIMIT $5
+`
+
+type ListUsersByNameParams struct {
+	Email          pgtype.Text `json:"email"`
+	IncludeDeleted bool        `json:"include_deleted"`
+	AfterName      pgtype.Text `json:"after_name"`
+	AfterID        int32       `json:"after_id"`
+	RowLimit       int32       `json:"row_limit"`
+}
+
+type ListUsersByNameRow struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
+}
+
+func (q *Queries) ListUsersByName(ctx context.Context, arg ListUsersByNameParams) ([]ListUsersByNameRow, error) {
+	rows, err := q.db.Query(ctx, listUsersByName,
+		arg.Email,
+		arg.IncludeDeleted,
+		arg.AfterName,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUsersByNameRow{}
+	for rows.Next() {
+		var i ListUsersByNameRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByNameDesc = `-- name: ListUsersByNameDesc :many
+SELECT id, name, email, created_at, updated_at, deleted_at
+FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1)
+  AND ($2::boolean OR deleted_at IS NULL)
+  AND ($3::text IS NULL OR (name, id) < ($3::text, $4::int))
+ORDER BY name DESC, id DESC
+LIMIT $5
+`
+
+type ListUsersByNameDescParams struct {
+	Email          pgtype.Text `json:"email"`
+	IncludeDeleted bool        `json:"include_deleted"`
+	AfterName      pgtype.Text `json:"after_name"`
+	AfterID        int32       `json:"after_id"`
+	RowLimit       int32       `json:"row_limit"`
+}
+
+type ListUsersByNameDescRow struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
+}
+
+func (q *Queries) ListUsersByNameDesc(ctx context.Context, arg ListUsersByNameDescParams) ([]ListUsersByNameDescRow, error) {
+	rows, err := q.db.Query(ctx, listUsersByNameDesc,
+		arg.Email,
+		arg.IncludeDeleted,
+		arg.AfterName,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUsersByNameDescRow{}
+	for rows.Next() {
+		var i ListUsersByNameDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :execrows
+UPDATE users
+SET email_verified_at = $2,
+    updated_at = $2
+WHERE id = $1 AND email_verified_at IS NULL
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID              int32              `json:"id"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markUserEmailVerified, arg.ID, arg.EmailVerifiedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at <= $1
+  AND NOT EXISTS (SELECT 1 FROM organizations o WHERE o.owner_id = users.id)
+`
+
+// Hard-deletes users deleted before the cutoff. Their addresses are deleted
+// by a trigger, everything else cascades. Users still owning an organization
+// are kept until it is handed over, or deleted by
+// DeletePurgedUsersOrganizations.
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedUsers, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :execrows
+UPDATE users
+SET password_hash = $1
+WHERE id = $2 AND password_hash = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewHash string `json:"new_hash"`
+	ID      int32  `json:"id"`
+	OldHash string `json:"old_hash"`
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rehashUserPassword, arg.NewHash, arg.ID, arg.OldHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET name = $2,
+    updated_at = $3
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, name, email, created_at, updated_at
+`
+
+type UpdateUserParams struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type UpdateUserRow struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateUserRow, error) {
+	row := q.db.QueryRow(ctx, updateUser, arg.ID, arg.Name, arg.UpdatedAt)
+	var i UpdateUserRow
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
@@ -193,3 +606,21 @@ func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (UpsertU
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    updated_at = $3
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID           int32              `json:"id"`
+	PasswordHash string             `json:"password_hash"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.PasswordHash, arg.UpdatedAt)
+	return err
+}
@@ -1,8 +1,13 @@
 package user
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/pagination"
 	"go-test-api/internal/validator"
 	"go-test-api/pkg/response"
 )
@@ -11,36 +16,193 @@ import (
 type Handler struct {
 	validator *validator.Validator
 	repo      Repo
+	pages     *pagination.Paginator
 }
 
-// NewHandler creates a new user Handler
-func NewHandler(v *validator.Validator, repo Repo) *Handler {
-	return &Handler{validator: v, repo: repo}
+// NewHandler creates a new user Handler. Lists are paginated with pages.
+func NewHandler(v *validator.Validator, repo Repo, pages *pagination.Paginator) *Handler {
+	return &Handler{validator: v, repo: repo, pages: pages}
 }
 
 // List handles GET /users with optional email filter
-// @Summary List all users
-// @Description Get list of all users, optionally filtered by email
+// @Summary List users
+// @Description Get a page of users, optionally filtered by email. Deleted users awaiting purge are left out unless include_deleted is set. Pass the next_cursor of a page as cursor, with the same sort, to get the next one; it is omitted on the last page. Requires the users:read permission.
 // @Tags users
 // @Produce json
 // @Param email query string false "Filter by email"
-// @Success 200 {array} UserResponse
+// @Param include_deleted query bool false "Include deleted users"
+// @Param limit query int false "Page size, 1 to 100" default(20)
+// @Param sort query string false "Sort field: id, name or created_at, prefixed with - for descending order" default(id)
+// @Param cursor query string false "Cursor of the page to get"
+// @Success 200 {object} pagination.Page{data=[]UserResponse}
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /users [get]
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
-	email := r.URL.Query().Get("email")
-	var users []*UserResponse
-	var err error
-	if email != "" {
-		users, err = h.repo.ListByEmail(r.Context(), email)
-	} else {
-		users, err = h.repo.List(r.Context())
+	page, err := h.pages.Parse(r.URL.Query(), userSorts...)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	includeDeleted := false
+	if v := r.URL.Query().Get("include_deleted"); v != "" {
+		includeDeleted, err = strconv.ParseBool(v)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "invalid include_deleted")
+			return
+		}
 	}
+
+	users, next, err := h.repo.List(r.Context(), r.URL.Query().Get("email"), includeDeleted, page)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "failed to list users")
 		return
 	}
-	response.JSON(w, http.StatusOK, users)
+	response.JSON(w, http.StatusOK, pagination.Page{Data: users, NextCursor: h.pages.Encode(next)})
+}
+
+// Get handles GET /users/{id}
+// @Summary Get a user
+// @Description Get a user by ID. Requires the users:read permission.
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id} [get]
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	h.respondWithUser(w, r, int32(id))
+}
+
+// GetMe handles GET /users/me
+// @Summary Get current user
+// @Description Get the authenticated user's profile
+// @Tags users
+// @Produce json
+// @Success 200 {object} UserResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me [get]
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "invalid user")
+		return
+	}
+	h.respondWithUser(w, r, int32(id))
+}
+
+// UpdateMe handles PATCH /users/me
+// @Summary Update current user
+// @Description Update the authenticated user's profile
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body UpdateUserRequest true "Profile fields to update"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me [patch]
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(authctx.UserID(r.Context()), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "invalid user")
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	if err := h.validator.Validate(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.repo.Update(r.Context(), int32(id), &req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "user not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "failed to update user")
+		return
+	}
+	response.JSON(w, http.StatusOK, user)
+}
+
+// Delete handles DELETE /users/{id}
+// @Summary Delete a user
+// @Description Delete a user along with their addresses. They can no longer log in, their API keys and refresh tokens stop working at once, and their sessions are revoked. The account keeps its email address until it is purged with everything the user owns, once the retention window has passed. Users owning an organization with other members cannot be deleted until its ownership is transferred. Requires the users:delete permission.
+// @Tags users
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id} [delete]
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), int32(id)); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "user not found")
+			return
+		}
+		if errors.Is(err, ErrOwnsOrganization) {
+			response.Error(w, http.StatusConflict, "user owns organizations with other members; transfer their ownership first")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondWithUser writes the user with the ID, or 404 if there is none
+func (h *Handler) respondWithUser(w http.ResponseWriter, r *http.Request, id int32) {
+	user, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "user not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	response.JSON(w, http.StatusOK, user)
 }
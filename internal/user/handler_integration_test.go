@@ -6,31 +6,45 @@ package user
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"go-test-api/internal/authctx"
 	"go-test-api/internal/config"
 	"go-test-api/internal/database"
+	"go-test-api/internal/pagination"
 	"go-test-api/internal/user/db"
 	"go-test-api/internal/validator"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var testDB *pgxpool.Pool
 var testQueries *db.Queries
 
+// defaultTenantID is the tenant users belong to unless a test says otherwise
+const defaultTenantID = "1"
+
 func TestMain(m *testing.M) {
 	// Setup
 	var err error
 	ctx := context.Background()
 
 	// Load config (will use development defaults)
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
 
 	testDB, err = database.New(ctx, cfg.Database)
 	if err != nil {
@@ -49,15 +63,23 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
-// cleanupUsers removes all users from the database
+// cleanupUsers removes all users from the database, with their addresses,
+// which TRUNCATE does not cascade to
 func cleanupUsers(t *testing.T) {
 	t.Helper()
-	_, err := testDB.Exec(context.Background(), "TRUNCATE users RESTART IDENTITY CASCADE")
+	_, err := testDB.Exec(context.Background(), "TRUNCATE users, addresses RESTART IDENTITY CASCADE")
 	if err != nil {
 		t.Fatalf("Failed to cleanup users: %v", err)
 	}
 }
 
+// tenantRequest creates a request scoped to the default tenant, as the auth
+// middleware would
+func tenantRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	return req.WithContext(authctx.WithTenant(req.Context(), defaultTenantID))
+}
+
 // setupTestUsers creates users via the repository (simulating auth/register)
 func setupTestUsers(t *testing.T, repo *Repository, users []struct {
 	name  string
@@ -69,7 +91,7 @@ func setupTestUsers(t *testing.T, repo *Repository, users []struct {
 			Name:  u.name,
 			Email: u.email,
 		}
-		_, err := repo.Upsert(context.Background(), userReq, "hashedpassword")
+		_, err := repo.Create(authctx.WithTenant(context.Background(), defaultTenantID), userReq, "hashedpassword")
 		if err != nil {
 			t.Fatalf("Failed to create user %s: %v", u.email, err)
 		}
@@ -80,19 +102,28 @@ func setupTestUsers(t *testing.T, repo *Repository, users []struct {
 func setupHandler(t *testing.T) (*Repository, *Handler) {
 	t.Helper()
 	cleanupUsers(t)
-	repo := NewRepository(testQueries)
-	handler := NewHandler(validator.New(), repo)
+	repo := NewRepository(testDB)
+	handler := NewHandler(validator.New(), repo, pagination.New([]byte("test-secret")))
 	return repo, handler
 }
 
-// executeListRequest makes a GET request to /users and returns the parsed response
+// executeListRequest makes a GET request to /users and returns the users of
+// the page
 func executeListRequest(t *testing.T, handler *Handler, queryParams string) []map[string]interface{} {
+	t.Helper()
+	users, _ := executePageRequest(t, handler, queryParams)
+	return users
+}
+
+// executePageRequest makes a GET request to /users and returns the users of
+// the page and its next cursor
+func executePageRequest(t *testing.T, handler *Handler, queryParams string) ([]map[string]interface{}, string) {
 	t.Helper()
 	url := "/users"
 	if queryParams != "" {
 		url += "?" + queryParams
 	}
-	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req := tenantRequest(http.MethodGet, url, nil)
 	w := httptest.NewRecorder()
 
 	handler.List(w, req)
@@ -101,12 +132,13 @@ func executeListRequest(t *testing.T, handler *Handler, queryParams string) []ma
 		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var response []map[string]interface{}
+	var users []map[string]interface{}
+	response := pagination.Page{Data: &users}
 	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	return response
+	return users, response.NextCursor
 }
 
 func TestUserHandler_List_Integration(t *testing.T) {
@@ -169,3 +201,419 @@ func TestUserHandler_ListByEmail_Integration(t *testing.T) {
 		t.Fatalf("Filtered results missing expected emails: %v", emails)
 	}
 }
+
+func TestUserHandler_List_Pagination_Integration(t *testing.T) {
+	repo, handler := setupHandler(t)
+
+	users := []struct {
+		name  string
+		email string
+	}{
+		{"Eve", "eve@example.com"},
+		{"Alice", "alice@example.com"},
+		{"Dave", "dave@example.com"},
+		{"Bob", "bob@example.com"},
+		{"Carol", "carol@example.com"},
+	}
+	setupTestUsers(t, repo, users)
+
+	tests := []struct {
+		sort          string
+		expectedNames []string
+	}{
+		{"id", []string{"Eve", "Alice", "Dave", "Bob", "Carol"}},
+		{"-id", []string{"Carol", "Bob", "Dave", "Alice", "Eve"}},
+		{"name", []string{"Alice", "Bob", "Carol", "Dave", "Eve"}},
+		{"-name", []string{"Eve", "Dave", "Carol", "Bob", "Alice"}},
+		{"created_at", []string{"Eve", "Alice", "Dave", "Bob", "Carol"}},
+		{"-created_at", []string{"Carol", "Bob", "Dave", "Alice", "Eve"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			var names []string
+			pages := 0
+			query := "limit=2&sort=" + tt.sort
+			for {
+				page, next := executePageRequest(t, handler, query)
+				pages++
+				if len(page) > 2 {
+					t.Fatalf("Expected at most 2 users per page, got %d", len(page))
+				}
+				for _, u := range page {
+					names = append(names, u["name"].(string))
+				}
+				if next == "" {
+					break
+				}
+				if pages > len(users) {
+					t.Fatal("Expected the pages to end")
+				}
+				query = "limit=2&sort=" + tt.sort + "&cursor=" + next
+			}
+
+			if pages != 3 {
+				t.Errorf("Expected 3 pages, got %d", pages)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expectedNames, ",") {
+				t.Errorf("Expected users %v, got %v", tt.expectedNames, names)
+			}
+		})
+	}
+}
+
+func TestRepository_Create_DuplicateEmail_Integration(t *testing.T) {
+	repo, _ := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	original, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "original-hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Re-registering the same email must not touch the existing account
+	_, err = repo.Create(ctx, &CreateUserRequest{Name: "Mallory", Email: "alice@example.com"}, "attacker-hash")
+	if !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("Expected ErrEmailExists, got %v", err)
+	}
+
+	stored, err := testQueries.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Failed to load user: %v", err)
+	}
+	if stored.PasswordHash != "original-hash" {
+		t.Errorf("Expected password hash to be unchanged, got %q", stored.PasswordHash)
+	}
+	if stored.Name != "Alice" {
+		t.Errorf("Expected name 'Alice', got %q", stored.Name)
+	}
+	if fmt.Sprint(stored.ID) != original.ID {
+		t.Errorf("Expected user ID %s, got %d", original.ID, stored.ID)
+	}
+}
+
+func TestUserHandler_UpdateMe_Integration(t *testing.T) {
+	repo, handler := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	created, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "original-hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	req := tenantRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"name": "Alice Smith"}`))
+	req = req.WithContext(authctx.WithUser(req.Context(), created.ID, created.Email))
+	w := httptest.NewRecorder()
+
+	handler.UpdateMe(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	stored, err := testQueries.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Failed to load user: %v", err)
+	}
+	if stored.Name != "Alice Smith" {
+		t.Errorf("Expected name 'Alice Smith', got %q", stored.Name)
+	}
+	if stored.PasswordHash != "original-hash" {
+		t.Errorf("Expected password hash to be unchanged, got %q", stored.PasswordHash)
+	}
+}
+
+func TestUserHandler_Get_Integration(t *testing.T) {
+	repo, handler := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	created, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	req := tenantRequest(http.MethodGet, "/users/"+created.ID, nil)
+	req.SetPathValue("id", created.ID)
+	w := httptest.NewRecorder()
+	handler.Get(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var user UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if user.ID != created.ID || user.Name != "Alice" || user.Email != "alice@example.com" {
+		t.Errorf("Expected user %s named Alice, got %+v", created.ID, user)
+	}
+
+	req = tenantRequest(http.MethodGet, "/users/me", nil)
+	req = req.WithContext(authctx.WithUser(req.Context(), created.ID, created.Email))
+	w = httptest.NewRecorder()
+	handler.GetMe(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d from /users/me, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if _, err := repo.Get(ctx, 9999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
+
+func TestUserHandler_Delete_Integration(t *testing.T) {
+	repo, handler := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	created, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, err = testDB.Exec(ctx, `INSERT INTO addresses (entity_type, entity_id, address_type, street_line1, city, state, postal_code, country, created_at, updated_at)
+		VALUES ('user', $1, 'shipping', '1 Main St', 'Springfield', 'IL', '62701', 'US', NOW(), NOW())`, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to create address: %v", err)
+	}
+
+	req := tenantRequest(http.MethodDelete, "/users/"+created.ID, nil)
+	req.SetPathValue("id", created.ID)
+	w := httptest.NewRecorder()
+	handler.Delete(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if _, err := repo.Get(ctx, mustAtoi32(t, created.ID)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the user to be gone, got %v", err)
+	}
+	if _, err := testQueries.GetUserByEmail(ctx, "alice@example.com"); err == nil {
+		t.Error("Expected a deleted user not to be found by email")
+	}
+	var addresses int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM addresses WHERE entity_type = 'user' AND entity_id = $1 AND deleted_at IS NULL", created.ID).Scan(&addresses); err != nil {
+		t.Fatalf("Failed to count addresses: %v", err)
+	}
+	if addresses != 0 {
+		t.Errorf("Expected the user's addresses to be deleted, got %d", addresses)
+	}
+
+	// Only administrators listing deleted users still see them
+	if users := executeListRequest(t, handler, ""); len(users) != 0 {
+		t.Errorf("Expected no users, got %v", users)
+	}
+	users := executeListRequest(t, handler, "include_deleted=true")
+	if len(users) != 1 || users[0]["deleted_at"] == nil {
+		t.Errorf("Expected the deleted user, got %v", users)
+	}
+
+	// Deleting again finds nothing
+	w = httptest.NewRecorder()
+	handler.Delete(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d deleting again, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRepository_PurgeDeleted_Integration(t *testing.T) {
+	repo, _ := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	deleted, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	kept, err := repo.Create(ctx, &CreateUserRequest{Name: "Bob", Email: "bob@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, err = testDB.Exec(ctx, `INSERT INTO addresses (entity_type, entity_id, address_type, street_line1, city, state, postal_code, country, created_at, updated_at)
+		VALUES ('user', $1, 'shipping', '1 Main St', 'Springfield', 'IL', '62701', 'US', NOW(), NOW())`, deleted.ID)
+	if err != nil {
+		t.Fatalf("Failed to create address: %v", err)
+	}
+	if err := repo.Delete(ctx, mustAtoi32(t, deleted.ID)); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	// Within the retention window nothing is purged
+	purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 0 {
+		t.Errorf("Expected nothing to be purged yet, got %d", purged)
+	}
+
+	purged, err = repo.PurgeDeleted(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 user to be purged, got %d", purged)
+	}
+	var rows int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&rows); err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("Expected only %s to be left, got %d users", kept.Email, rows)
+	}
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM addresses WHERE entity_type = 'user' AND entity_id = $1", deleted.ID).Scan(&rows); err != nil {
+		t.Fatalf("Failed to count addresses: %v", err)
+	}
+	if rows != 0 {
+		t.Errorf("Expected the purged user's addresses to be gone, got %d", rows)
+	}
+}
+
+func TestUserHandler_DeleteOrganizationOwner_Integration(t *testing.T) {
+	repo, handler := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	alice, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := repo.Create(ctx, &CreateUserRequest{Name: "Bob", Email: "bob@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	var shared, solo int32
+	for _, org := range []struct {
+		name string
+		id   *int32
+	}{{"Acme", &shared}, {"Alice's", &solo}} {
+		err := testDB.QueryRow(ctx, `INSERT INTO organizations (name, owner_id, created_at, updated_at, tenant_id)
+			VALUES ($1, $2, NOW(), NOW(), $3) RETURNING id`, org.name, alice.ID, defaultTenantID).Scan(org.id)
+		if err != nil {
+			t.Fatalf("Failed to create organization: %v", err)
+		}
+		_, err = testDB.Exec(ctx, `INSERT INTO organization_members (organization_id, user_id, role, created_at)
+			VALUES ($1, $2, 'owner', NOW())`, *org.id, alice.ID)
+		if err != nil {
+			t.Fatalf("Failed to add owner: %v", err)
+		}
+	}
+	_, err = testDB.Exec(ctx, `INSERT INTO organization_members (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, 'member', NOW())`, shared, bob.ID)
+	if err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	// Alice cannot be deleted while Bob is in her organization
+	req := tenantRequest(http.MethodDelete, "/users/"+alice.ID, nil)
+	req.SetPathValue("id", alice.ID)
+	w := httptest.NewRecorder()
+	handler.Delete(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if _, err := repo.Get(ctx, mustAtoi32(t, alice.ID)); err != nil {
+		t.Fatalf("Expected Alice to be kept, got %v", err)
+	}
+
+	// Once Bob owns it, she can, and only the organization she is alone in
+	// is purged with her
+	if _, err := testDB.Exec(ctx, `UPDATE organizations SET owner_id = $2 WHERE id = $1`, shared, bob.ID); err != nil {
+		t.Fatalf("Failed to transfer ownership: %v", err)
+	}
+	w = httptest.NewRecorder()
+	handler.Delete(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	purged, err := repo.PurgeDeleted(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected Alice to be purged, got %d users", purged)
+	}
+	var rows int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM organizations WHERE id = $1", solo).Scan(&rows); err != nil || rows != 0 {
+		t.Errorf("Expected Alice's own organization to be purged, got %d (%v)", rows, err)
+	}
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM organization_members WHERE organization_id = $1", shared).Scan(&rows); err != nil || rows != 1 {
+		t.Errorf("Expected Bob to be left in the shared organization, got %d (%v)", rows, err)
+	}
+}
+
+func mustAtoi32(t *testing.T, s string) int32 {
+	t.Helper()
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		t.Fatalf("Invalid ID %q: %v", s, err)
+	}
+	return int32(n)
+}
+
+func TestAddressIntegrity_Integration(t *testing.T) {
+	repo, _ := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+	insertAddress := `INSERT INTO addresses (entity_type, entity_id, address_type, street_line1, city, state, postal_code, country, created_at, updated_at)
+		VALUES ('user', $1, 'shipping', '1 Main St', 'Springfield', 'IL', '62701', 'US', NOW(), NOW())`
+
+	// Addresses cannot belong to users that do not exist
+	_, err := testDB.Exec(ctx, insertAddress, 999)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23503" {
+		t.Fatalf("Expected a foreign key violation, got %v", err)
+	}
+
+	// and go with their user when it is hard-deleted
+	u, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := testDB.Exec(ctx, insertAddress, u.ID); err != nil {
+		t.Fatalf("Failed to create address: %v", err)
+	}
+	if _, err := testDB.Exec(ctx, "DELETE FROM users WHERE id = $1", u.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	var rows int
+	if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM addresses").Scan(&rows); err != nil {
+		t.Fatalf("Failed to count addresses: %v", err)
+	}
+	if rows != 0 {
+		t.Errorf("Expected the user's addresses to be deleted with them, got %d", rows)
+	}
+}
+
+func TestRepository_ConfirmEmailChange_DropsMailedTokens_Integration(t *testing.T) {
+	repo, _ := setupHandler(t)
+	ctx := authctx.WithTenant(context.Background(), defaultTenantID)
+
+	u, err := repo.Create(ctx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	id := mustAtoi32(t, u.ID)
+	expiresAt := time.Now().Add(time.Hour)
+	for _, table := range []string{"password_reset_tokens", "magic_link_tokens"} {
+		_, err := testDB.Exec(ctx, "INSERT INTO "+table+" (user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, NOW())",
+			id, table+"-hash", expiresAt)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", table, err)
+		}
+	}
+	if err := repo.CreateEmailChange(ctx, id, "alice@example.org", "change-hash", expiresAt); err != nil {
+		t.Fatalf("Failed to create email change: %v", err)
+	}
+
+	if _, err := repo.ConfirmEmailChange(context.Background(), "change-hash"); err != nil {
+		t.Fatalf("Failed to confirm email change: %v", err)
+	}
+
+	// Links mailed to the old address no longer work
+	for _, table := range []string{"password_reset_tokens", "magic_link_tokens"} {
+		var rows int
+		if err := testDB.QueryRow(ctx, "SELECT COUNT(*) FROM "+table+" WHERE user_id = $1", id).Scan(&rows); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		if rows != 0 {
+			t.Errorf("Expected the outstanding %s to be deleted, got %d", table, rows)
+		}
+	}
+}
@@ -8,56 +8,123 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/pagination"
 	"go-test-api/internal/validator"
 )
 
 // mockUserRepository is a mock implementation of UserRepository for testing
 type mockUserRepository struct {
-	upsertFunc      func(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error)
-	listFunc        func(ctx context.Context) ([]*UserResponse, error)
-	listByEmailFunc func(ctx context.Context, email string) ([]*UserResponse, error)
+	createFunc func(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error)
+	getFunc    func(ctx context.Context, id int32) (*UserResponse, error)
+	updateFunc func(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error)
+	deleteFunc func(ctx context.Context, id int32) error
+	listFunc   func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error)
+
+	passwordHashFunc       func(ctx context.Context, id int32) (string, error)
+	updatePasswordFunc     func(ctx context.Context, id int32, passwordHash string) error
+	createEmailChangeFunc  func(ctx context.Context, id int32, newEmail, tokenHash string, expiresAt time.Time) error
+	confirmEmailChangeFunc func(ctx context.Context, tokenHash string) (int32, error)
+	tenantIDFunc           func(ctx context.Context, slug string) (string, error)
 }
 
-func (m *mockUserRepository) Upsert(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error) {
-	if m.upsertFunc != nil {
-		return m.upsertFunc(ctx, req, passwordHash)
+func (m *mockUserRepository) Create(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error) {
+	if m.createFunc != nil {
+		return m.createFunc(ctx, req, passwordHash)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *mockUserRepository) List(ctx context.Context) ([]*UserResponse, error) {
-	if m.listFunc != nil {
-		return m.listFunc(ctx)
+func (m *mockUserRepository) Get(ctx context.Context, id int32) (*UserResponse, error) {
+	if m.getFunc != nil {
+		return m.getFunc(ctx, id)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *mockUserRepository) ListByEmail(ctx context.Context, email string) ([]*UserResponse, error) {
-	if m.listByEmailFunc != nil {
-		return m.listByEmailFunc(ctx, email)
+func (m *mockUserRepository) Update(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error) {
+	if m.updateFunc != nil {
+		return m.updateFunc(ctx, id, req)
 	}
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockUserRepository) Delete(ctx context.Context, id int32) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, id)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockUserRepository) List(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx, email, includeDeleted, page)
+	}
+	return nil, nil, errors.New("not implemented")
+}
+
+func (m *mockUserRepository) PasswordHash(ctx context.Context, id int32) (string, error) {
+	if m.passwordHashFunc != nil {
+		return m.passwordHashFunc(ctx, id)
+	}
+	return "", errors.New("not implemented")
+}
+
+func (m *mockUserRepository) UpdatePassword(ctx context.Context, id int32, passwordHash string) error {
+	if m.updatePasswordFunc != nil {
+		return m.updatePasswordFunc(ctx, id, passwordHash)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockUserRepository) CreateEmailChange(ctx context.Context, id int32, newEmail, tokenHash string, expiresAt time.Time) error {
+	if m.createEmailChangeFunc != nil {
+		return m.createEmailChangeFunc(ctx, id, newEmail, tokenHash, expiresAt)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockUserRepository) ConfirmEmailChange(ctx context.Context, tokenHash string) (int32, error) {
+	if m.confirmEmailChangeFunc != nil {
+		return m.confirmEmailChangeFunc(ctx, tokenHash)
+	}
+	return 0, errors.New("not implemented")
+}
+
+func (m *mockUserRepository) TenantID(ctx context.Context, slug string) (string, error) {
+	if m.tenantIDFunc != nil {
+		return m.tenantIDFunc(ctx, slug)
+	}
+	return "", errors.New("not implemented")
+}
+
 func TestUserHandler_List(t *testing.T) {
+	pages := pagination.New([]byte("test-secret"))
+	nextCursor := pages.Encode(&pagination.Cursor{Sort: "name", Value: "Jane Smith", ID: 2})
+
 	tests := []struct {
-		name            string
-		query           string
-		mockList        func(ctx context.Context) ([]*UserResponse, error)
-		mockListByEmail func(ctx context.Context, email string) ([]*UserResponse, error)
-		expectedStatus  int
-		expectedCount   int
-		expectError     bool
+		name               string
+		query              string
+		mockList           func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error)
+		expectedStatus     int
+		expectedCount      int
+		expectedNextCursor string
+		expectError        bool
 	}{
 		{
 			name: "successful list with users",
-			mockList: func(ctx context.Context) ([]*UserResponse, error) {
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				if includeDeleted || page.Limit != pagination.DefaultLimit || page.Sort.String() != "id" || page.After != nil {
+					return nil, nil, errors.New("unexpected page")
+				}
 				return []*UserResponse{
 					{ID: "1", Name: "John Doe", Email: "john@example.com"},
 					{ID: "2", Name: "Jane Smith", Email: "jane@example.com"},
-				}, nil
+				}, nil, nil
 			},
 			expectedStatus: http.StatusOK,
 			expectedCount:  2,
@@ -65,8 +132,8 @@ func TestUserHandler_List(t *testing.T) {
 		},
 		{
 			name: "successful list with no users",
-			mockList: func(ctx context.Context) ([]*UserResponse, error) {
-				return []*UserResponse{}, nil
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				return []*UserResponse{}, nil, nil
 			},
 			expectedStatus: http.StatusOK,
 			expectedCount:  0,
@@ -74,25 +141,106 @@ func TestUserHandler_List(t *testing.T) {
 		},
 		{
 			name: "database error",
-			mockList: func(ctx context.Context) ([]*UserResponse, error) {
-				return nil, errors.New("database connection failed")
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				return nil, nil, errors.New("database connection failed")
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectError:    true,
 		},
 		{
 			name:  "filter by email",
-			query: "john",
-			mockListByEmail: func(ctx context.Context, email string) ([]*UserResponse, error) {
+			query: "email=john",
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
 				if email != "john" {
-					return nil, errors.New("unexpected email")
+					return nil, nil, errors.New("unexpected email")
 				}
-				return []*UserResponse{{ID: "1", Name: "John Doe", Email: "john@example.com"}}, nil
+				return []*UserResponse{{ID: "1", Name: "John Doe", Email: "john@example.com"}}, nil, nil
 			},
 			expectedStatus: http.StatusOK,
 			expectedCount:  1,
 			expectError:    false,
 		},
+		{
+			name:  "include deleted users",
+			query: "include_deleted=true",
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				if !includeDeleted {
+					return nil, nil, errors.New("expected deleted users to be included")
+				}
+				deletedAt := time.Now()
+				return []*UserResponse{
+					{ID: "1", Name: "John Doe", Email: "john@example.com"},
+					{ID: "2", Name: "Jane Smith", Email: "jane@example.com", DeletedAt: &deletedAt},
+				}, nil, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+			expectError:    false,
+		},
+		{
+			name:           "invalid include_deleted",
+			query:          "include_deleted=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectError:    true,
+		},
+		{
+			name:  "page with a next cursor",
+			query: "limit=2&sort=name",
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				if page.Limit != 2 || page.Sort.String() != "name" {
+					return nil, nil, errors.New("unexpected page")
+				}
+				return []*UserResponse{
+					{ID: "1", Name: "John Doe", Email: "john@example.com"},
+					{ID: "2", Name: "Jane Smith", Email: "jane@example.com"},
+				}, &pagination.Cursor{Sort: "name", Value: "Jane Smith", ID: 2}, nil
+			},
+			expectedStatus:     http.StatusOK,
+			expectedCount:      2,
+			expectedNextCursor: nextCursor,
+			expectError:        false,
+		},
+		{
+			name:  "page after a cursor",
+			query: "sort=name&cursor=" + nextCursor,
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				if page.After == nil || page.After.ID != 2 || page.After.Value != "Jane Smith" {
+					return nil, nil, errors.New("unexpected cursor")
+				}
+				return []*UserResponse{{ID: "3", Name: "Mary Major", Email: "mary@example.com"}}, nil, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+			expectError:    false,
+		},
+		{
+			name:           "invalid limit",
+			query:          "limit=1000",
+			expectedStatus: http.StatusBadRequest,
+			expectError:    true,
+		},
+		{
+			name:           "invalid sort",
+			query:          "sort=password_hash",
+			expectedStatus: http.StatusBadRequest,
+			expectError:    true,
+		},
+		{
+			name:           "cursor of another sort",
+			query:          "sort=-name&cursor=" + nextCursor,
+			expectedStatus: http.StatusBadRequest,
+			expectError:    true,
+		},
+		{
+			name:  "malformed cursor value",
+			query: "sort=created_at&cursor=" + pages.Encode(&pagination.Cursor{Sort: "created_at", Value: "yesterday", ID: 2}),
+			mockList: func(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+				_, err := page.After.Time()
+				return nil, nil, err
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectError:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,15 +249,14 @@ func TestUserHandler_List(t *testing.T) {
 			t.Parallel()
 			// Setup
 			mockRepo := &mockUserRepository{
-				listFunc:        tt.mockList,
-				listByEmailFunc: tt.mockListByEmail,
+				listFunc: tt.mockList,
 			}
-			handler := NewHandler(validator.New(), mockRepo)
+			handler := NewHandler(validator.New(), mockRepo, pages)
 
 			// Create request (include query if provided)
 			url := "/users"
 			if tt.query != "" {
-				url = url + "?email=" + tt.query
+				url = url + "?" + tt.query
 			}
 			req := httptest.NewRequest(http.MethodGet, url, nil)
 			w := httptest.NewRecorder()
@@ -133,12 +280,263 @@ func TestUserHandler_List(t *testing.T) {
 				}
 			} else {
 				var users []*UserResponse
-				if err := json.NewDecoder(w.Body).Decode(&users); err != nil {
+				page := pagination.Page{Data: &users}
+				if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
 					t.Fatalf("failed to decode response: %v", err)
 				}
 				if len(users) != tt.expectedCount {
 					t.Errorf("expected %d users, got %d", tt.expectedCount, len(users))
 				}
+				if page.NextCursor != tt.expectedNextCursor {
+					t.Errorf("expected next cursor %q, got %q", tt.expectedNextCursor, page.NextCursor)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_UpdateMe(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		body           string
+		mockUpdate     func(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error)
+		expectedStatus int
+		expectedName   string
+	}{
+		{
+			name:   "successful update",
+			userID: "7",
+			body:   `{"name": "New Name"}`,
+			mockUpdate: func(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error) {
+				if id != 7 {
+					return nil, errors.New("unexpected id")
+				}
+				return &UserResponse{ID: "7", Name: req.Name, Email: "john@example.com"}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedName:   "New Name",
+		},
+		{
+			name:           "missing authenticated user",
+			body:           `{"name": "New Name"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "validation error",
+			userID:         "7",
+			body:           `{"name": ""}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "user not found",
+			userID: "7",
+			body:   `{"name": "New Name"}`,
+			mockUpdate: func(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error) {
+				return nil, ErrNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &mockUserRepository{updateFunc: tt.mockUpdate}
+			handler := NewHandler(validator.New(), mockRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(tt.body))
+			if tt.userID != "" {
+				req = req.WithContext(authctx.WithUser(req.Context(), tt.userID, "john@example.com"))
+			}
+			w := httptest.NewRecorder()
+
+			handler.UpdateMe(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedName != "" {
+				var user UserResponse
+				if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if user.Name != tt.expectedName {
+					t.Errorf("expected name %q, got %q", tt.expectedName, user.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_Get(t *testing.T) {
+	getUser := func(ctx context.Context, id int32) (*UserResponse, error) {
+		if id != 7 {
+			return nil, ErrNotFound
+		}
+		return &UserResponse{ID: "7", Name: "John Doe", Email: "john@example.com"}, nil
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		mockGet        func(ctx context.Context, id int32) (*UserResponse, error)
+		expectedStatus int
+	}{
+		{name: "existing user", id: "7", mockGet: getUser, expectedStatus: http.StatusOK},
+		{name: "user not found", id: "8", mockGet: getUser, expectedStatus: http.StatusNotFound},
+		{name: "invalid ID", id: "abc", expectedStatus: http.StatusBadRequest},
+		{
+			name: "database error",
+			id:   "7",
+			mockGet: func(ctx context.Context, id int32) (*UserResponse, error) {
+				return nil, errors.New("database connection failed")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := NewHandler(validator.New(), &mockUserRepository{getFunc: tt.mockGet}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			handler.Get(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var user UserResponse
+				if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if user.ID != "7" || user.Email != "john@example.com" {
+					t.Errorf("expected user 7, got %+v", user)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_GetMe(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		mockGet        func(ctx context.Context, id int32) (*UserResponse, error)
+		expectedStatus int
+	}{
+		{
+			name:   "current user",
+			userID: "7",
+			mockGet: func(ctx context.Context, id int32) (*UserResponse, error) {
+				if id != 7 {
+					return nil, errors.New("unexpected id")
+				}
+				return &UserResponse{ID: "7", Name: "John Doe", Email: "john@example.com"}, nil
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing authenticated user",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "user deleted since the token was issued",
+			userID: "7",
+			mockGet: func(ctx context.Context, id int32) (*UserResponse, error) {
+				return nil, ErrNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := NewHandler(validator.New(), &mockUserRepository{getFunc: tt.mockGet}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			if tt.userID != "" {
+				req = req.WithContext(authctx.WithUser(req.Context(), tt.userID, "john@example.com"))
+			}
+			w := httptest.NewRecorder()
+
+			handler.GetMe(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		mockDelete     func(ctx context.Context, id int32) error
+		expectedStatus int
+	}{
+		{
+			name: "existing user",
+			id:   "7",
+			mockDelete: func(ctx context.Context, id int32) error {
+				if id != 7 {
+					return errors.New("unexpected id")
+				}
+				return nil
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "user not found",
+			id:   "8",
+			mockDelete: func(ctx context.Context, id int32) error {
+				return ErrNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "owner of a shared organization",
+			id:   "7",
+			mockDelete: func(ctx context.Context, id int32) error {
+				return ErrOwnsOrganization
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{name: "invalid ID", id: "abc", expectedStatus: http.StatusBadRequest},
+		{
+			name: "database error",
+			id:   "7",
+			mockDelete: func(ctx context.Context, id int32) error {
+				return errors.New("database connection failed")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := NewHandler(validator.New(), &mockUserRepository{deleteFunc: tt.mockDelete}, nil)
+
+			req := httptest.NewRequest(http.MethodDelete, "/users/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+
+			handler.Delete(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
 			}
 		})
 	}
@@ -1,15 +1,43 @@
 package user
 
+import "time"
+
 // CreateUserRequest represents the request to create a user
 type CreateUserRequest struct {
 	Name     string `json:"name" validate:"required,min=1,max=100"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password,omitempty" validate:"omitempty,min=8,max=72"`
+	Password string `json:"password,omitempty" validate:"omitempty,min=8,max=256"`
+}
+
+// UpdateUserRequest represents the request to update the current user's profile
+type UpdateUserRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
 }
 
-// UserResponse represents a user in responses
+// UserResponse represents a user in responses. DeletedAt is only set on
+// deleted users, which only administrators can list.
 type UserResponse struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ChangePasswordRequest represents a request to change the current user's
+// password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=256"`
+}
+
+// ChangeEmailRequest represents a request to change the current user's email
+// address. The change takes effect once confirmed from the new address.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// MessageResponse represents a response that only carries a message
+type MessageResponse struct {
+	Message string `json:"message"`
 }
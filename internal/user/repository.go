@@ -2,70 +2,492 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/database"
+	"go-test-api/internal/pagination"
 	"go-test-api/internal/user/db"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+// DefaultTenant is the slug of the tenant self-registered users join
+const DefaultTenant = "default"
+
+var (
+	ErrEmailExists             = errors.New("email already registered")
+	ErrNotFound                = errors.New("user not found")
+	ErrInvalidEmailChangeToken = errors.New("invalid email change token")
+	ErrTenantNotFound          = errors.New("tenant not found")
+	ErrOwnsOrganization        = errors.New("user owns an organization with other members")
+	ErrTenantExists            = errors.New("tenant slug already taken")
+	ErrOrganizationMember      = errors.New("user is a member of an organization")
 )
 
 // Repo defines the interface for user data access
 type Repo interface {
-	Upsert(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error)
-	List(ctx context.Context) ([]*UserResponse, error)
-	ListByEmail(ctx context.Context, email string) ([]*UserResponse, error)
+	Create(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error)
+	Get(ctx context.Context, id int32) (*UserResponse, error)
+	Update(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error)
+	Delete(ctx context.Context, id int32) error
+	List(ctx context.Context, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error)
+	PasswordHash(ctx context.Context, id int32) (string, error)
+	UpdatePassword(ctx context.Context, id int32, passwordHash string) error
+	CreateEmailChange(ctx context.Context, id int32, newEmail, tokenHash string, expiresAt time.Time) error
+	ConfirmEmailChange(ctx context.Context, tokenHash string) (int32, error)
+	TenantID(ctx context.Context, slug string) (string, error)
 }
 
-// Repository handles user data access
+// Repository handles user data access. Users are only visible to requests
+// of their own tenant: every method acting on behalf of the caller runs in a
+// transaction scoped to the tenant of the context, and fails with
+// database.ErrNoTenant if it has none.
 type Repository struct {
+	pool    *pgxpool.Pool
 	queries *db.Queries
 }
 
 // NewRepository creates a new Repository
-func NewRepository(queries *db.Queries) *Repository {
-	return &Repository{queries: queries}
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	return &Repository{
+		pool:    pool,
+		queries: db.New(pool),
+	}
 }
 
-// Upsert creates or updates a user by email
-func (r *Repository) Upsert(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error) {
+// inTenant runs fn with queries scoped to the tenant of ctx
+func (r *Repository) inTenant(ctx context.Context, fn func(q *db.Queries) error) error {
+	return database.InTenant(ctx, r.pool, func(tx pgx.Tx) error {
+		return fn(r.queries.WithTx(tx))
+	})
+}
+
+// Create creates a new user in the tenant of ctx. It returns ErrEmailExists
+// if the email is already registered, in any tenant; existing accounts are
+// never modified.
+func (r *Repository) Create(ctx context.Context, req *CreateUserRequest, passwordHash string) (*UserResponse, error) {
 	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
-	user, err := r.queries.UpsertUser(ctx, db.UpsertUserParams{
-		Name:         req.Name,
-		Email:        req.Email,
-		PasswordHash: passwordHash,
-		CreatedAt:    now,
-		UpdatedAt:    now,
+	var user db.CreateUserRow
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		tenantID, err := strconv.ParseInt(authctx.TenantID(ctx), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid tenant id: %w", err)
+		}
+		user, err = q.CreateUser(ctx, db.CreateUserParams{
+			Name:         req.Name,
+			Email:        req.Email,
+			PasswordHash: passwordHash,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			TenantID:     int32(tenantID),
+		})
+		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to upsert user: %w", err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, ErrEmailExists
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 	return &UserResponse{ID: fmt.Sprintf("%d", user.ID), Name: user.Name, Email: user.Email}, nil
 }
 
-// List returns all users
-func (r *Repository) List(ctx context.Context) ([]*UserResponse, error) {
-	users, err := r.queries.ListUsers(ctx)
+// Get returns a user, or ErrNotFound if there is none with the ID
+func (r *Repository) Get(ctx context.Context, id int32) (*UserResponse, error) {
+	var user db.GetUserRow
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		user, err = q.GetUser(ctx, id)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	res := make([]*UserResponse, len(users))
-	for i, u := range users {
-		res[i] = &UserResponse{ID: fmt.Sprintf("%d", u.ID), Name: u.Name, Email: u.Email}
+	return &UserResponse{ID: fmt.Sprintf("%d", user.ID), Name: user.Name, Email: user.Email}, nil
+}
+
+// Exists reports whether there is a user with the ID who is not deleted
+func (r *Repository) Exists(ctx context.Context, id int32) (bool, error) {
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		_, err := q.GetUser(ctx, id)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+	return true, nil
+}
+
+// Update updates a user's profile fields
+func (r *Repository) Update(ctx context.Context, id int32, req *UpdateUserRequest) (*UserResponse, error) {
+	var user db.UpdateUserRow
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		user, err = q.UpdateUser(ctx, db.UpdateUserParams{
+			ID:        id,
+			Name:      req.Name,
+			UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return &UserResponse{ID: fmt.Sprintf("%d", user.ID), Name: user.Name, Email: user.Email}, nil
+}
+
+// Delete soft-deletes a user along with their addresses and revokes their
+// sessions, or returns ErrNotFound if there is no user with the ID. The user
+// is purged with everything they own by PurgeDeleted. It returns
+// ErrOwnsOrganization if they own an organization with other members, which
+// must be handed over first.
+func (r *Repository) Delete(ctx context.Context, id int32) error {
+	var deleted int64
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		shared, err := q.OwnsSharedOrganization(ctx, id)
+		if err != nil {
+			return err
+		}
+		if shared {
+			return ErrOwnsOrganization
+		}
+		deleted, err = q.DeleteUser(ctx, db.DeleteUserParams{
+			ID:        id,
+			DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrOwnsOrganization) {
+			return err
+		}
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// userSorts are the fields users can be listed by; the first is the default
+var userSorts = []string{"id", "name", "created_at"}
+
+// userRow is a row of any of the user list queries, which share their columns
+type userRow interface {
+	db.ListUsersByIDRow | db.ListUsersByIDDescRow |
+		db.ListUsersByNameRow | db.ListUsersByNameDescRow |
+		db.ListUsersByCreatedAtRow | db.ListUsersByCreatedAtDescRow
+}
+
+// List returns a page of users, optionally only those whose email contains
+// email, along with the cursor of the next page, if any. Deleted users are
+// left out unless includeDeleted is set. It returns an error wrapping
+// pagination.ErrInvalidCursor if the page's cursor cannot be read.
+func (r *Repository) List(ctx context.Context, email string, includeDeleted bool, page pagination.Request) (users []*UserResponse, next *pagination.Cursor, err error) {
+	err = r.inTenant(ctx, func(q *db.Queries) error {
+		users, next, err = list(ctx, q, email, includeDeleted, page)
+		return err
+	})
+	return users, next, err
+}
+
+// list reads a page of users with q
+func list(ctx context.Context, q *db.Queries, email string, includeDeleted bool, page pagination.Request) ([]*UserResponse, *pagination.Cursor, error) {
+	filter := pgtype.Text{String: "%" + email + "%", Valid: email != ""}
+	var after pagination.Cursor
+	if page.After != nil {
+		after = *page.After
+	}
+
+	switch page.Sort.Field {
+	case "name":
+		params := db.ListUsersByNameParams{
+			Email:          filter,
+			IncludeDeleted: includeDeleted,
+			AfterName:      pgtype.Text{String: after.Value, Valid: page.After != nil},
+			AfterID:        after.ID,
+			RowLimit:       page.FetchLimit(),
+		}
+		if page.Sort.Desc {
+			rows, err := q.ListUsersByNameDesc(ctx, db.ListUsersByNameDescParams(params))
+			return userPage(page, rows, err)
+		}
+		rows, err := q.ListUsersByName(ctx, params)
+		return userPage(page, rows, err)
+	case "created_at":
+		var afterCreatedAt pgtype.Timestamptz
+		if page.After != nil {
+			t, err := page.After.Time()
+			if err != nil {
+				return nil, nil, err
+			}
+			afterCreatedAt = pgtype.Timestamptz{Time: t, Valid: true}
+		}
+		params := db.ListUsersByCreatedAtParams{
+			Email:          filter,
+			IncludeDeleted: includeDeleted,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        after.ID,
+			RowLimit:       page.FetchLimit(),
+		}
+		if page.Sort.Desc {
+			rows, err := q.ListUsersByCreatedAtDesc(ctx, db.ListUsersByCreatedAtDescParams(params))
+			return userPage(page, rows, err)
+		}
+		rows, err := q.ListUsersByCreatedAt(ctx, params)
+		return userPage(page, rows, err)
+	default:
+		params := db.ListUsersByIDParams{
+			Email:          filter,
+			IncludeDeleted: includeDeleted,
+			AfterID:        pgtype.Int4{Int32: after.ID, Valid: page.After != nil},
+			RowLimit:       page.FetchLimit(),
+		}
+		if page.Sort.Desc {
+			rows, err := q.ListUsersByIDDesc(ctx, db.ListUsersByIDDescParams(params))
+			return userPage(page, rows, err)
+		}
+		rows, err := q.ListUsersByID(ctx, params)
+		return userPage(page, rows, err)
 	}
-	return res, nil
 }
 
-// ListByEmail filters users by email (repository adds SQL wildcard)
-func (r *Repository) ListByEmail(ctx context.Context, email string) ([]*UserResponse, error) {
-	pattern := "%" + email + "%"
-	users, err := r.queries.ListUsersByEmail(ctx, pattern)
+// userPage converts the rows read for a page of users
+func userPage[T userRow](page pagination.Request, rows []T, err error) ([]*UserResponse, *pagination.Cursor, error) {
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users by email: %w", err)
+		return nil, nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	users := make([]db.ListUsersByIDRow, len(rows))
+	for i, row := range rows {
+		users[i] = db.ListUsersByIDRow(row)
 	}
+
+	users, next := pagination.Next(page, users, func(u db.ListUsersByIDRow) (string, int32) {
+		switch page.Sort.Field {
+		case "name":
+			return u.Name, u.ID
+		case "created_at":
+			return pagination.TimeValue(u.CreatedAt.Time), u.ID
+		}
+		return "", u.ID
+	})
 	res := make([]*UserResponse, len(users))
 	for i, u := range users {
 		res[i] = &UserResponse{ID: fmt.Sprintf("%d", u.ID), Name: u.Name, Email: u.Email}
+		if u.DeletedAt.Valid {
+			deletedAt := u.DeletedAt.Time
+			res[i].DeletedAt = &deletedAt
+		}
+	}
+	return res, next, nil
+}
+
+// PurgeDeleted hard-deletes users deleted before the cutoff along with
+// everything they own, returning how many there were. It covers every
+// tenant. Users owning an organization that other members joined since
+// they were deleted are kept until it is handed over.
+func (r *Repository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	cutoff := pgtype.Timestamptz{Time: before, Valid: true}
+	if _, err := q.DeletePurgedUsersOrganizations(ctx, cutoff); err != nil {
+		return 0, fmt.Errorf("failed to purge organizations of deleted users: %w", err)
+	}
+	purged, err := q.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return purged, nil
+}
+
+// PasswordHash returns the stored password hash of a user
+func (r *Repository) PasswordHash(ctx context.Context, id int32) (string, error) {
+	var hash string
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		var err error
+		hash, err = q.GetUserPasswordHash(ctx, id)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+	return hash, nil
+}
+
+// UpdatePassword replaces a user's password hash
+func (r *Repository) UpdatePassword(ctx context.Context, id int32, passwordHash string) error {
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		return q.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+			ID:           id,
+			PasswordHash: passwordHash,
+			UpdatedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+// CreateEmailChange stores a pending change of a user's email to newEmail,
+// identified by the hash of the token sent to that address. Any change still
+// pending for the user is superseded.
+func (r *Repository) CreateEmailChange(ctx context.Context, id int32, newEmail, tokenHash string, expiresAt time.Time) error {
+	err := r.inTenant(ctx, func(q *db.Queries) error {
+		return q.CreateEmailChangeToken(ctx, db.CreateEmailChangeTokenParams{
+			UserID:    id,
+			NewEmail:  newEmail,
+			TokenHash: tokenHash,
+			ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+			CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create email change: %w", err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange applies the pending email change a token was issued for
+// and returns the ID of the user. The new address counts as verified, since
+// the token was delivered to it, and password reset and magic link tokens
+// still outstanding for the old one are dropped. It returns
+// ErrInvalidEmailChangeToken if the token is unknown, used or expired, and
+// ErrEmailExists if the address has been registered since; the token stays
+// usable in that case. The token is presented without logging in, so this is
+// not scoped to a tenant.
+func (r *Repository) ConfirmEmailChange(ctx context.Context, tokenHash string) (int32, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	id, err := q.ConfirmEmailChange(ctx, db.ConfirmEmailChangeParams{
+		TokenHash: tokenHash,
+		UsedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidEmailChangeToken
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return 0, ErrEmailExists
+		}
+		return 0, fmt.Errorf("failed to confirm email change: %w", err)
+	}
+	if err := q.DeleteMailedLoginTokens(ctx, id); err != nil {
+		return 0, fmt.Errorf("failed to delete mailed tokens: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return id, nil
+}
+
+// TenantID returns the ID of the tenant with the slug, or ErrTenantNotFound
+// if there is none
+func (r *Repository) TenantID(ctx context.Context, slug string) (string, error) {
+	id, err := r.queries.GetTenantIDBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrTenantNotFound
+		}
+		return "", fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return strconv.Itoa(int(id)), nil
+}
+
+// CreateTenant creates a tenant and returns its ID, or ErrTenantExists if
+// the slug is taken
+func (r *Repository) CreateTenant(ctx context.Context, name, slug string) (string, error) {
+	id, err := r.queries.CreateTenant(ctx, db.CreateTenantParams{Name: name, Slug: slug})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return "", ErrTenantExists
+		}
+		return "", fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return strconv.Itoa(int(id)), nil
+}
+
+// MoveToTenant moves a user and their addresses to the tenant with the slug,
+// and revokes their sessions so that they log in again to it. It returns
+// ErrNotFound if there is no such user, ErrTenantNotFound if there is no
+// such tenant, and ErrOrganizationMember if the user belongs to an
+// organization, which must stay in its tenant. It covers every tenant.
+func (r *Repository) MoveToTenant(ctx context.Context, id int32, slug string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := r.queries.WithTx(tx)
+
+	tenantID, err := q.GetTenantIDBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTenantNotFound
+		}
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+	member, err := q.IsOrganizationMember(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to check organization membership: %w", err)
+	}
+	if member {
+		return ErrOrganizationMember
+	}
+	moved, err := q.MoveUserToTenant(ctx, db.MoveUserToTenantParams{
+		TenantID: tenantID,
+		ID:       id,
+		MovedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move user: %w", err)
+	}
+	if moved == 0 {
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit move: %w", err)
 	}
-	return res, nil
+	return nil
 }
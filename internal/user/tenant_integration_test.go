@@ -0,0 +1,188 @@
+//go:build integration
+// +build integration
+
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go-test-api/internal/address"
+	"go-test-api/internal/authctx"
+	"go-test-api/internal/authz"
+	"go-test-api/internal/database"
+	"go-test-api/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// createTenant adds a tenant, unless it exists, and returns its ID
+func createTenant(t *testing.T, slug string) string {
+	t.Helper()
+	var id int32
+	err := testDB.QueryRow(context.Background(), `INSERT INTO tenants (name, slug) VALUES ($1, $1)
+		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name RETURNING id`, slug).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to create tenant: %v", err)
+	}
+	return fmt.Sprint(id)
+}
+
+func TestTenantIsolation_Integration(t *testing.T) {
+	repo, _ := setupHandler(t)
+	acme := authctx.WithTenant(context.Background(), defaultTenantID)
+	globex := authctx.WithTenant(context.Background(), createTenant(t, "globex"))
+	page := pagination.Request{Limit: pagination.DefaultLimit, Sort: pagination.Sort{Field: "id"}}
+
+	alice, err := repo.Create(acme, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := repo.Create(globex, &CreateUserRequest{Name: "Bob", Email: "bob@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	aliceID := mustAtoi32(t, alice.ID)
+
+	// Users of another tenant cannot be read, changed or deleted
+	if _, err := repo.Get(globex, aliceID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound reading a user of another tenant, got %v", err)
+	}
+	if exists, err := repo.Exists(globex, aliceID); err != nil || exists {
+		t.Errorf("Expected a user of another tenant not to exist, got %v (%v)", exists, err)
+	}
+	if _, err := repo.Update(globex, aliceID, &UpdateUserRequest{Name: "Mallory"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating a user of another tenant, got %v", err)
+	}
+	if err := repo.Delete(globex, aliceID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a user of another tenant, got %v", err)
+	}
+	users, _, err := repo.List(globex, "", true, page)
+	if err != nil || len(users) != 1 || users[0].ID != bob.ID {
+		t.Errorf("Expected to list only Bob, got %v (%v)", users, err)
+	}
+
+	// Nor can their addresses, and none can be given to them
+	entities := address.NewRegistry()
+	entities.Register("user", address.Entity{Exists: repo.Exists, Rule: authz.Self})
+	addresses := address.NewRepository(testDB, entities)
+	req := &address.CreateAddressRequest{
+		EntityType:  "user",
+		EntityID:    aliceID,
+		AddressType: "shipping",
+		StreetLine1: "1 Main St",
+		City:        "Springfield",
+		State:       "IL",
+		PostalCode:  "62701",
+		Country:     "US",
+	}
+	addr, err := addresses.Create(acme, req)
+	if err != nil {
+		t.Fatalf("Failed to create address: %v", err)
+	}
+	if _, err := addresses.Get(globex, mustAtoi32(t, addr.ID)); !errors.Is(err, address.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound reading an address of another tenant, got %v", err)
+	}
+	listed, _, err := addresses.List(globex, "user", alice.ID, "", true, page)
+	if err != nil || len(listed) != 0 {
+		t.Errorf("Expected no addresses listed from another tenant, got %v (%v)", listed, err)
+	}
+	if _, err := addresses.Create(globex, req); !errors.Is(err, address.ErrEntityNotFound) {
+		t.Errorf("Expected ErrEntityNotFound adding an address to a user of another tenant, got %v", err)
+	}
+
+	// Even a query that forgets to filter by tenant only sees its own rows
+	var userRows, addressRows int
+	err = database.InTenant(globex, testDB, func(tx pgx.Tx) error {
+		return tx.QueryRow(globex, "SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM addresses)").Scan(&userRows, &addressRows)
+	})
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if userRows != 1 || addressRows != 0 {
+		t.Errorf("Expected to see 1 user and no addresses, got %d and %d", userRows, addressRows)
+	}
+
+	// Without a tenant nothing is read at all
+	if _, err := repo.Get(context.Background(), aliceID); !errors.Is(err, database.ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant without a tenant, got %v", err)
+	}
+	err = pgx.BeginFunc(context.Background(), testDB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.Background(), "SET LOCAL ROLE app_tenant"); err != nil {
+			return err
+		}
+		return tx.QueryRow(context.Background(), "SELECT COUNT(*) FROM users").Scan(&userRows)
+	})
+	if err != nil || userRows != 0 {
+		t.Errorf("Expected no users visible without app.tenant_id, got %d (%v)", userRows, err)
+	}
+}
+
+func TestRepository_MoveToTenant_Integration(t *testing.T) {
+	repo, _ := setupHandler(t)
+	ctx := context.Background()
+	if _, err := testDB.Exec(ctx, "DELETE FROM tenants WHERE slug = 'initech'"); err != nil {
+		t.Fatalf("Failed to cleanup tenants: %v", err)
+	}
+	defaultCtx := authctx.WithTenant(ctx, defaultTenantID)
+
+	initechID, err := repo.CreateTenant(ctx, "Initech", "initech")
+	if err != nil {
+		t.Fatalf("Failed to create tenant: %v", err)
+	}
+	if _, err := repo.CreateTenant(ctx, "Initech again", "initech"); !errors.Is(err, ErrTenantExists) {
+		t.Errorf("Expected ErrTenantExists reusing a slug, got %v", err)
+	}
+	initech := authctx.WithTenant(ctx, initechID)
+
+	alice, err := repo.Create(defaultCtx, &CreateUserRequest{Name: "Alice", Email: "alice@example.com"}, "hashedpassword")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	aliceID := mustAtoi32(t, alice.ID)
+	_, err = testDB.Exec(ctx, `INSERT INTO addresses (entity_type, entity_id, address_type, street_line1, city, state, postal_code, country, created_at, updated_at)
+		VALUES ('user', $1, 'shipping', '1 Main St', 'Springfield', 'IL', '62701', 'US', NOW(), NOW())`, aliceID)
+	if err != nil {
+		t.Fatalf("Failed to create address: %v", err)
+	}
+
+	if err := repo.MoveToTenant(ctx, aliceID, "initech"); err != nil {
+		t.Fatalf("Failed to move user: %v", err)
+	}
+	if _, err := repo.Get(initech, aliceID); err != nil {
+		t.Errorf("Expected Alice in her new tenant, got %v", err)
+	}
+	if _, err := repo.Get(defaultCtx, aliceID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected Alice to have left the default tenant, got %v", err)
+	}
+	var addressTenant string
+	err = testDB.QueryRow(ctx, "SELECT tenant_id::text FROM addresses WHERE entity_type = 'user' AND entity_id = $1", aliceID).Scan(&addressTenant)
+	if err != nil || addressTenant != initechID {
+		t.Errorf("Expected Alice's address to move with her, got tenant %s (%v)", addressTenant, err)
+	}
+
+	if err := repo.MoveToTenant(ctx, aliceID, "umbrella"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("Expected ErrTenantNotFound, got %v", err)
+	}
+	if err := repo.MoveToTenant(ctx, 999, "initech"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Organization members stay with their organization
+	var orgID int32
+	err = testDB.QueryRow(ctx, `INSERT INTO organizations (name, owner_id, created_at, updated_at, tenant_id)
+		VALUES ('Initech', $1, NOW(), NOW(), $2) RETURNING id`, aliceID, initechID).Scan(&orgID)
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	_, err = testDB.Exec(ctx, `INSERT INTO organization_members (organization_id, user_id, role, created_at)
+		VALUES ($1, $2, 'owner', NOW())`, orgID, aliceID)
+	if err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	if err := repo.MoveToTenant(ctx, aliceID, DefaultTenant); !errors.Is(err, ErrOrganizationMember) {
+		t.Errorf("Expected ErrOrganizationMember, got %v", err)
+	}
+}
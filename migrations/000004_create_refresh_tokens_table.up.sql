@@ -0,0 +1,16 @@
+CREATE TABLE refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for revoking a whole rotation family on reuse
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+-- Index for revoking all of a user's refresh tokens
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(user_id);
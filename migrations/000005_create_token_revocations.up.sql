@@ -0,0 +1,18 @@
+-- Individually revoked access tokens, keyed by their jti claim
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for purging entries whose token has expired anyway
+CREATE INDEX idx_revoked_tokens_expires ON revoked_tokens(expires_at);
+
+-- Per-user cutoff: every token issued to the user before revoked_before is
+-- invalid. expires_at is when the last such token would have expired.
+CREATE TABLE user_token_revocations (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+CREATE TABLE email_verification_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for throttling resends per user
+CREATE INDEX idx_email_verification_tokens_user ON email_verification_tokens(user_id, created_at);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS users_assign_member_role ON users;
+DROP FUNCTION IF EXISTS assign_member_role();
+DROP INDEX IF EXISTS idx_user_roles_role;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
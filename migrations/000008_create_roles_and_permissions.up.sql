@@ -0,0 +1,68 @@
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+-- Index for looking up the members of a role
+CREATE INDEX idx_user_roles_role ON user_roles(role_id);
+
+-- Seed roles and permissions
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access to every resource'),
+    ('member', 'Regular user');
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'List and view all users'),
+    ('addresses:read', 'View addresses'),
+    ('addresses:write', 'Create, update and delete addresses');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin';
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('addresses:read', 'addresses:write')
+WHERE r.name = 'member';
+
+-- Every user is a member, whichever code path created them
+CREATE FUNCTION assign_member_role() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO user_roles (user_id, role_id)
+    SELECT NEW.id, id FROM roles WHERE name = 'member';
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_assign_member_role
+AFTER INSERT ON users
+FOR EACH ROW EXECUTE FUNCTION assign_member_role();
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id
+FROM users u
+CROSS JOIN roles r
+WHERE r.name = 'member';
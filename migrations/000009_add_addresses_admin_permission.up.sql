@@ -0,0 +1,9 @@
+-- Lets a role manage addresses regardless of who owns them
+INSERT INTO permissions (name, description) VALUES
+    ('addresses:admin', 'Manage addresses owned by anyone');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'addresses:admin'
+WHERE r.name = 'admin';
@@ -0,0 +1,22 @@
+-- TOTP secret of a user. The secret is pending until confirmed_at is set by
+-- a first valid code; only confirmed secrets are required at login.
+CREATE TABLE user_totp (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    -- Time step of the last accepted code, so a code cannot be replayed
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- One-time recovery codes, stored hashed
+CREATE TABLE mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for looking up a user's codes
+CREATE INDEX idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
@@ -0,0 +1,10 @@
+DELETE FROM permissions WHERE name = 'users:unlock';
+
+DROP INDEX IF EXISTS idx_audit_events_type;
+DROP INDEX IF EXISTS idx_audit_events_user;
+DROP TABLE IF EXISTS audit_events;
+DROP INDEX IF EXISTS idx_login_lockouts_email;
+DROP TABLE IF EXISTS login_lockouts;
+DROP INDEX IF EXISTS idx_failed_login_attempts_ip;
+DROP INDEX IF EXISTS idx_failed_login_attempts_email;
+DROP TABLE IF EXISTS failed_login_attempts;
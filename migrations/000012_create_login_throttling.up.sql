@@ -0,0 +1,49 @@
+-- Failed login attempts, counted per email and per client IP to slow down
+-- password guessing. Rows older than the counting window are purged.
+CREATE TABLE failed_login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_failed_login_attempts_email ON failed_login_attempts(email, created_at);
+CREATE INDEX idx_failed_login_attempts_ip ON failed_login_attempts(ip_address, created_at);
+
+-- Temporary lockouts after repeated failures. They are keyed by email rather
+-- than by user so that a lockout does not reveal whether an account exists.
+CREATE TABLE login_lockouts (
+    id SERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    unlock_token_hash TEXT NOT NULL UNIQUE,
+    unlocked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_login_lockouts_email ON login_lockouts(email, created_at);
+
+-- Security relevant events such as lockouts
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    email TEXT,
+    ip_address TEXT,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_audit_events_user ON audit_events(user_id, created_at);
+CREATE INDEX idx_audit_events_type ON audit_events(event_type, created_at);
+
+-- Lets a role unlock accounts before their lockout expires
+INSERT INTO permissions (name, description) VALUES
+    ('users:unlock', 'Unlock accounts locked after failed logins');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'users:unlock'
+WHERE r.name = 'admin';
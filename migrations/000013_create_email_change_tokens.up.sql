@@ -0,0 +1,14 @@
+-- Pending email address changes. The new address only replaces users.email
+-- once the link sent to it is opened.
+CREATE TABLE email_change_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for superseding a user's outstanding changes
+CREATE INDEX idx_email_change_tokens_user ON email_change_tokens(user_id);
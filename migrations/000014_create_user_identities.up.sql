@@ -0,0 +1,16 @@
+-- Accounts at external OpenID Connect providers linked to users. The
+-- subject identifies the account at its provider and never changes, unlike
+-- the email, which is kept as last seen.
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    last_login_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (provider, subject)
+);
+
+-- Index for listing a user's identities
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
@@ -0,0 +1,12 @@
+-- Single-use links that log a user in without a password
+CREATE TABLE magic_link_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for throttling requests and invalidating a user's outstanding links
+CREATE INDEX idx_magic_link_tokens_user ON magic_link_tokens(user_id, created_at);
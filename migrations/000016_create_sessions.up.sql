@@ -0,0 +1,33 @@
+-- Logins, one per refresh token family: sessions.id is the family_id of the
+-- refresh tokens issued for the login, and access tokens carry it in their
+-- sid claim, so that revoking a session rejects them too.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- How the user logged in: password, registration, magic_link or
+    -- oidc:<provider>; mfa is set if a second factor was supplied too
+    method VARCHAR(80) NOT NULL,
+    mfa BOOLEAN NOT NULL DEFAULT FALSE,
+    user_agent TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    -- When the latest refresh token of the session expires
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Index for listing a user's sessions
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+
+-- Index for loading recent revocations into the revocation cache
+CREATE INDEX idx_sessions_revoked ON sessions(revoked_at) WHERE revoked_at IS NOT NULL;
+
+-- Logins from before sessions were tracked, so that they can be listed and
+-- revoked too
+INSERT INTO sessions (id, user_id, method, user_agent, ip_address, created_at, last_seen_at, expires_at)
+SELECT family_id, user_id, 'unknown', '', '', MIN(created_at), MAX(created_at), MAX(expires_at)
+FROM refresh_tokens
+WHERE revoked_at IS NULL
+GROUP BY family_id, user_id
+HAVING MAX(expires_at) > NOW();
@@ -0,0 +1,9 @@
+-- Lets a role obtain tokens that act as another user, for support
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Act as another user to see the API as they do');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'users:impersonate'
+WHERE r.name = 'admin';
@@ -0,0 +1,9 @@
+-- Lets a role delete accounts
+INSERT INTO permissions (name, description) VALUES
+    ('users:delete', 'Delete users and everything they own');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'users:delete'
+WHERE r.name = 'admin';
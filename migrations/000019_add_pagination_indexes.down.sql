@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_addresses_entity_created_at;
+DROP INDEX IF EXISTS idx_addresses_entity;
+CREATE INDEX idx_addresses_entity ON addresses(entity_type, entity_id);
+
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP INDEX IF EXISTS idx_users_name;
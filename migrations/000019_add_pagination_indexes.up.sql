@@ -0,0 +1,8 @@
+-- Keyset pagination reads lists in (sort field, id) order from just after the
+-- last row of the previous page; these indexes serve every supported order
+CREATE INDEX idx_users_name ON users(name, id);
+CREATE INDEX idx_users_created_at ON users(created_at, id);
+
+DROP INDEX IF EXISTS idx_addresses_entity;
+CREATE INDEX idx_addresses_entity ON addresses(entity_type, entity_id, id);
+CREATE INDEX idx_addresses_entity_created_at ON addresses(entity_type, entity_id, created_at, id);
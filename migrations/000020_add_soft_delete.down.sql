@@ -0,0 +1,11 @@
+-- Deleted rows would reappear once the columns are gone
+DELETE FROM addresses
+WHERE deleted_at IS NOT NULL
+   OR (entity_type = 'user' AND entity_id IN (SELECT id FROM users WHERE deleted_at IS NOT NULL));
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_addresses_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE addresses DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
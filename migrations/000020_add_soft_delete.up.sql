@@ -0,0 +1,9 @@
+-- Deleted users and addresses are kept, hidden from every read, until purged
+-- once the retention window has passed. A deleted user keeps their email
+-- address until then.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE addresses ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Indexes for finding rows to purge
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_addresses_deleted_at ON addresses(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Lets a role erase accounts for good, under the right to erasure
+INSERT INTO permissions (name, description) VALUES
+    ('users:erase', 'Erase users and anonymize their audit trail');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'users:erase'
+WHERE r.name = 'admin';
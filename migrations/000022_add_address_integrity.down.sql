@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS users_delete_addresses ON users;
+DROP TRIGGER IF EXISTS addresses_check_entity ON addresses;
+DROP FUNCTION IF EXISTS delete_entity_addresses();
+DROP FUNCTION IF EXISTS check_address_entity();
+DROP FUNCTION IF EXISTS address_entity_exists(entity_type, INTEGER);
@@ -0,0 +1,45 @@
+-- addresses.entity_id cannot have a foreign key, since the table it refers
+-- to depends on entity_type. These triggers enforce the same integrity:
+-- addresses must belong to an existing entity, and are deleted with it.
+
+-- address_entity_exists reports whether the entity exists, locking it like a
+-- foreign key check would, so that it cannot be deleted before the caller's
+-- transaction ends. Every entity type must be handled here.
+CREATE FUNCTION address_entity_exists(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS BOOLEAN AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            PERFORM 1 FROM users WHERE id = p_entity_id FOR KEY SHARE;
+    END CASE;
+    RETURN FOUND;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION check_address_entity() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT address_entity_exists(NEW.entity_type, NEW.entity_id) THEN
+        RAISE EXCEPTION 'address owner % % does not exist', NEW.entity_type, NEW.entity_id
+            USING ERRCODE = 'foreign_key_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER addresses_check_entity
+    BEFORE INSERT OR UPDATE OF entity_type, entity_id ON addresses
+    FOR EACH ROW EXECUTE FUNCTION check_address_entity();
+
+-- delete_entity_addresses deletes the addresses of a deleted entity, whose
+-- entity type is the trigger's argument
+CREATE FUNCTION delete_entity_addresses() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM addresses
+    WHERE entity_type = TG_ARGV[0]::entity_type AND entity_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_delete_addresses
+    AFTER DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION delete_entity_addresses('user');
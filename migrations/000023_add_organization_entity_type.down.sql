@@ -0,0 +1,22 @@
+-- Enum values cannot be dropped, so the type is recreated without it
+DELETE FROM addresses WHERE entity_type = 'organization';
+
+ALTER TYPE entity_type RENAME TO entity_type_old;
+CREATE TYPE entity_type AS ENUM ('user');
+ALTER TABLE addresses
+    ALTER COLUMN entity_type TYPE entity_type USING entity_type::text::entity_type;
+
+-- address_entity_exists takes the type as an argument, so it follows it
+DROP FUNCTION address_entity_exists(entity_type_old, INTEGER);
+CREATE FUNCTION address_entity_exists(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS BOOLEAN AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            PERFORM 1 FROM users WHERE id = p_entity_id FOR KEY SHARE;
+    END CASE;
+    RETURN FOUND;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TYPE entity_type_old;
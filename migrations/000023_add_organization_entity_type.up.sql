@@ -0,0 +1,3 @@
+-- A new enum value cannot be used in the transaction that adds it, so it
+-- gets a migration of its own
+ALTER TYPE entity_type ADD VALUE 'organization';
@@ -0,0 +1,17 @@
+DELETE FROM permissions WHERE name LIKE 'organizations:%';
+
+-- Dropping the table does not fire its delete trigger
+DELETE FROM addresses WHERE entity_type = 'organization';
+
+CREATE OR REPLACE FUNCTION address_entity_exists(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS BOOLEAN AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            PERFORM 1 FROM users WHERE id = p_entity_id FOR KEY SHARE;
+    END CASE;
+    RETURN FOUND;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS organizations;
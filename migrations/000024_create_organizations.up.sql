@@ -0,0 +1,45 @@
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for listing the organizations of a user
+CREATE INDEX idx_organizations_owner ON organizations(owner_id, id);
+
+-- Organizations can own addresses, which go with them
+CREATE OR REPLACE FUNCTION address_entity_exists(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS BOOLEAN AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            PERFORM 1 FROM users WHERE id = p_entity_id FOR KEY SHARE;
+        WHEN 'organization' THEN
+            PERFORM 1 FROM organizations WHERE id = p_entity_id FOR KEY SHARE;
+    END CASE;
+    RETURN FOUND;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER organizations_delete_addresses
+    AFTER DELETE ON organizations
+    FOR EACH ROW EXECUTE FUNCTION delete_entity_addresses('organization');
+
+INSERT INTO permissions (name, description) VALUES
+    ('organizations:read', 'View organizations'),
+    ('organizations:write', 'Create, update and delete organizations'),
+    ('organizations:admin', 'Manage organizations owned by anyone');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name LIKE 'organizations:%'
+WHERE r.name = 'admin';
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('organizations:read', 'organizations:write')
+WHERE r.name = 'member';
@@ -0,0 +1,38 @@
+-- Owners can do anything to their organization, admins manage it and its
+-- members, and members can only read it
+CREATE TYPE organization_role AS ENUM ('owner', 'admin', 'member');
+
+CREATE TABLE organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role organization_role NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+-- An organization has exactly one owner, the one in organizations.owner_id
+CREATE UNIQUE INDEX idx_organization_members_owner ON organization_members(organization_id) WHERE role = 'owner';
+
+-- Index for listing the organizations of a user
+CREATE INDEX idx_organization_members_user ON organization_members(user_id, organization_id);
+
+INSERT INTO organization_members (organization_id, user_id, role, created_at)
+SELECT id, owner_id, 'owner', created_at
+FROM organizations;
+
+-- Emailed invitations to join an organization. They can be accepted by
+-- whoever holds an account with the invited email address.
+CREATE TABLE organization_invitations (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role organization_role NOT NULL CHECK (role <> 'owner'),
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for superseding the outstanding invitations of an email address
+CREATE INDEX idx_organization_invitations_email ON organization_invitations(email, organization_id);
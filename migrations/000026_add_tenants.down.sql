@@ -0,0 +1,27 @@
+DROP POLICY IF EXISTS addresses_tenant_isolation ON addresses;
+ALTER TABLE addresses DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS users_tenant_isolation ON users;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+-- app_tenant is shared by every database of the cluster, so it is only
+-- stripped of its privileges here
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON SEQUENCES FROM app_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON TABLES FROM app_tenant;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM app_tenant;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM app_tenant;
+
+CREATE OR REPLACE FUNCTION check_address_entity() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT address_entity_exists(NEW.entity_type, NEW.entity_id) THEN
+        RAISE EXCEPTION 'address owner % % does not exist', NEW.entity_type, NEW.entity_id
+            USING ERRCODE = 'foreign_key_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS address_entity_tenant(entity_type, INTEGER);
+
+ALTER TABLE addresses DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tenants;
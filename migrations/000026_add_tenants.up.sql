@@ -0,0 +1,81 @@
+-- Tenants are the customers sharing a deployment. Users and addresses belong
+-- to exactly one; emails stay unique across tenants, since logging in by
+-- email must find the tenant.
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Existing users and addresses, and users registering without naming a
+-- tenant, belong to the default tenant
+INSERT INTO tenants (id, name, slug) VALUES (1, 'Default', 'default');
+SELECT setval('tenants_id_seq', 1);
+
+ALTER TABLE users ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+CREATE INDEX idx_users_tenant ON users(tenant_id, id);
+
+-- The tenant of an address is that of its entity, set by the trigger below
+ALTER TABLE addresses ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE addresses ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX idx_addresses_tenant ON addresses(tenant_id);
+
+-- address_entity_tenant returns the tenant of an address's entity, or NULL
+-- if it does not exist. Organizations belong to the tenant of their owner.
+CREATE FUNCTION address_entity_tenant(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS INTEGER AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            RETURN (SELECT tenant_id FROM users WHERE id = p_entity_id);
+        WHEN 'organization' THEN
+            RETURN (SELECT u.tenant_id
+                    FROM organizations o
+                    JOIN users u ON u.id = o.owner_id
+                    WHERE o.id = p_entity_id);
+    END CASE;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Entities of another tenant are hidden by row-level security, so an address
+-- cannot be given to them either
+CREATE OR REPLACE FUNCTION check_address_entity() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.tenant_id := address_entity_tenant(NEW.entity_type, NEW.entity_id);
+    IF NOT address_entity_exists(NEW.entity_type, NEW.entity_id) OR NEW.tenant_id IS NULL THEN
+        RAISE EXCEPTION 'address owner % % does not exist', NEW.entity_type, NEW.entity_id
+            USING ERRCODE = 'foreign_key_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Requests run as app_tenant, with app.tenant_id set to the tenant of the
+-- caller, and only see that tenant's rows. The connecting role owns the
+-- tables and so bypasses the policies, which leaves login, the retention
+-- purge and maintenance tasks working across tenants.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'app_tenant') THEN
+        CREATE ROLE app_tenant NOLOGIN;
+    END IF;
+END
+$$;
+GRANT app_tenant TO CURRENT_USER;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO app_tenant;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO app_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO app_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO app_tenant;
+
+-- Without app.tenant_id set the policies match no rows
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+CREATE POLICY users_tenant_isolation ON users TO app_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::INTEGER)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::INTEGER);
+
+ALTER TABLE addresses ENABLE ROW LEVEL SECURITY;
+CREATE POLICY addresses_tenant_isolation ON addresses TO app_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::INTEGER)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::INTEGER);
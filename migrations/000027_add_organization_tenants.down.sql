@@ -0,0 +1,27 @@
+DROP POLICY IF EXISTS organization_invitations_tenant_isolation ON organization_invitations;
+ALTER TABLE organization_invitations DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS organization_members_tenant_isolation ON organization_members;
+ALTER TABLE organization_members DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS organizations_tenant_isolation ON organizations;
+ALTER TABLE organizations DISABLE ROW LEVEL SECURITY;
+
+CREATE OR REPLACE FUNCTION address_entity_tenant(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS INTEGER AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            RETURN (SELECT tenant_id FROM users WHERE id = p_entity_id);
+        WHEN 'organization' THEN
+            RETURN (SELECT u.tenant_id
+                    FROM organizations o
+                    JOIN users u ON u.id = o.owner_id
+                    WHERE o.id = p_entity_id);
+    END CASE;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+DROP TRIGGER IF EXISTS organization_members_check_tenant ON organization_members;
+DROP FUNCTION IF EXISTS check_organization_member_tenant();
+
+DROP INDEX IF EXISTS idx_organizations_tenant;
+ALTER TABLE organizations DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,69 @@
+-- Organizations belong to a tenant too: that of the owner who created them.
+-- Their members and invitations belong to the tenant of the organization,
+-- and only users of that tenant can be members.
+ALTER TABLE organizations ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE organizations o
+SET tenant_id = u.tenant_id
+FROM users u
+WHERE u.id = o.owner_id;
+ALTER TABLE organizations ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX idx_organizations_tenant ON organizations(tenant_id, id);
+
+-- Members that joined an organization of another tenant before they were
+-- isolated lose their membership
+DELETE FROM organization_members m
+USING organizations o, users u
+WHERE o.id = m.organization_id AND u.id = m.user_id AND u.tenant_id <> o.tenant_id;
+
+CREATE FUNCTION check_organization_member_tenant() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM organizations o
+        JOIN users u ON u.tenant_id = o.tenant_id
+        WHERE o.id = NEW.organization_id AND u.id = NEW.user_id
+    ) THEN
+        RAISE EXCEPTION 'user % is not in the tenant of organization %', NEW.user_id, NEW.organization_id
+            USING ERRCODE = 'foreign_key_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Ownership only moves between members, so this also keeps it in the tenant
+CREATE TRIGGER organization_members_check_tenant
+    BEFORE INSERT OR UPDATE ON organization_members
+    FOR EACH ROW EXECUTE FUNCTION check_organization_member_tenant();
+
+-- Organization addresses follow the organization rather than its owner
+CREATE OR REPLACE FUNCTION address_entity_tenant(p_entity_type entity_type, p_entity_id INTEGER)
+RETURNS INTEGER AS $$
+BEGIN
+    CASE p_entity_type
+        WHEN 'user' THEN
+            RETURN (SELECT tenant_id FROM users WHERE id = p_entity_id);
+        WHEN 'organization' THEN
+            RETURN (SELECT tenant_id FROM organizations WHERE id = p_entity_id);
+    END CASE;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+UPDATE addresses a
+SET tenant_id = o.tenant_id
+FROM organizations o
+WHERE a.entity_type = 'organization' AND a.entity_id = o.id AND a.tenant_id <> o.tenant_id;
+
+ALTER TABLE organizations ENABLE ROW LEVEL SECURITY;
+CREATE POLICY organizations_tenant_isolation ON organizations TO app_tenant
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::INTEGER)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::INTEGER);
+
+-- Members and invitations are visible along with their organization, which
+-- is only visible in its own tenant
+ALTER TABLE organization_members ENABLE ROW LEVEL SECURITY;
+CREATE POLICY organization_members_tenant_isolation ON organization_members TO app_tenant
+    USING (EXISTS (SELECT 1 FROM organizations o WHERE o.id = organization_members.organization_id));
+
+ALTER TABLE organization_invitations ENABLE ROW LEVEL SECURITY;
+CREATE POLICY organization_invitations_tenant_isolation ON organization_invitations TO app_tenant
+    USING (EXISTS (SELECT 1 FROM organizations o WHERE o.id = organization_invitations.organization_id));
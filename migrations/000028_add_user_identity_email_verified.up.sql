@@ -0,0 +1,5 @@
+-- Whether the provider verified the email last seen for an identity. Only
+-- verified addresses are the user's own, and count as such when their data
+-- is exported or erased. Emails seen before this was recorded may have
+-- changed since the identity was linked, so they are not trusted.
+ALTER TABLE user_identities ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+-- Deleting an owner used to delete their organization, and with it every
+-- other member's membership, its invitations and its addresses. Owners must
+-- now hand their organizations over first; organizations they are the only
+-- member of are deleted explicitly along with them.
+ALTER TABLE organizations DROP CONSTRAINT organizations_owner_id_fkey;
+ALTER TABLE organizations ADD CONSTRAINT organizations_owner_id_fkey
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE RESTRICT;
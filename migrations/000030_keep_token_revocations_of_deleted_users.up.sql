@@ -0,0 +1,4 @@
+-- A user's token revocation must outlive them: their access tokens stay
+-- valid until they expire, and erasing a user deletes them at once. The
+-- revocation is purged once those tokens have expired anyway.
+ALTER TABLE user_token_revocations DROP CONSTRAINT user_token_revocations_user_id_fkey;
@@ -6,9 +6,12 @@ import (
 	"net/http"
 )
 
-// ErrorResponse represents an error response
+// ErrorResponse represents an error response. Code and Details are only set
+// for errors that clients are expected to handle programmatically.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error   string                 `json:"error"`
+	Code    string                 `json:"code,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
 // JSON sends a JSON response with the given status code
@@ -25,3 +28,9 @@ func JSON(w http.ResponseWriter, status int, data interface{}) {
 func Error(w http.ResponseWriter, status int, message string) {
 	JSON(w, status, ErrorResponse{Error: message})
 }
+
+// CodedError sends an error response carrying a machine-readable code and
+// optional details alongside the message
+func CodedError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	JSON(w, status, ErrorResponse{Error: message, Code: code, Details: details})
+}
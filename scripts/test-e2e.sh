@@ -13,15 +13,21 @@ echo -e "${GREEN}Starting E2E tests...${NC}"
 NETWORK_NAME="e2e-test-network"
 DB_CONTAINER="e2e-postgres"
 API_CONTAINER="e2e-api"
+# A second instance that only admits users with a verified email
+VERIFIED_ONLY_CONTAINER="e2e-api-verified-only"
 IMAGE_TAG="go-test-api:e2e-test"
 API_PORT="8080"
+VERIFIED_ONLY_PORT="8081"
+# The API writes its mail here, for tests to read the links it sends
+OUTBOX_DIR="$(mktemp -d)"
 
 # Cleanup function
 cleanup() {
     echo -e "${YELLOW}Cleaning up...${NC}"
-    docker stop $API_CONTAINER $DB_CONTAINER 2>/dev/null || true
-    docker rm $API_CONTAINER $DB_CONTAINER 2>/dev/null || true
+    docker stop $VERIFIED_ONLY_CONTAINER $API_CONTAINER $DB_CONTAINER 2>/dev/null || true
+    docker rm $VERIFIED_ONLY_CONTAINER $API_CONTAINER $DB_CONTAINER 2>/dev/null || true
     docker network rm $NETWORK_NAME 2>/dev/null || true
+    rm -rf "$OUTBOX_DIR" 2>/dev/null || true
 }
 
 # Trap to ensure cleanup on exit
@@ -78,11 +84,39 @@ docker run -d \
     -e DB_NAME=gotestdb \
     -e DB_SSLMODE=disable \
     -e JWT_SECRET=test-secret-for-e2e \
+    -e APP_BASE_URL=http://localhost:$API_PORT \
+    -e FRONTEND_URL=http://localhost:3000 \
+    -e MAIL_DRIVER=file \
+    -e MAIL_OUTBOX_DIR=/outbox \
+    -v "$OUTBOX_DIR:/outbox" \
     $IMAGE_TAG
 
 # Give API a moment to start
 sleep 2
 
+# Start the verified-only instance on the same database
+echo -e "${GREEN}Starting verified-only API server...${NC}"
+docker run -d \
+    --name $VERIFIED_ONLY_CONTAINER \
+    --network $NETWORK_NAME \
+    -p $VERIFIED_ONLY_PORT:8080 \
+    -e ENV=production \
+    -e PORT=8080 \
+    -e DB_HOST=$DB_CONTAINER \
+    -e DB_PORT=5432 \
+    -e DB_USER=gouser \
+    -e DB_PASSWORD=gopassword \
+    -e DB_NAME=gotestdb \
+    -e DB_SSLMODE=disable \
+    -e JWT_SECRET=test-secret-for-e2e \
+    -e APP_BASE_URL=http://localhost:$VERIFIED_ONLY_PORT \
+    -e FRONTEND_URL=http://localhost:3000 \
+    -e MAIL_DRIVER=file \
+    -e MAIL_OUTBOX_DIR=/outbox \
+    -e REQUIRE_VERIFIED_EMAIL=true \
+    -v "$OUTBOX_DIR:/outbox" \
+    $IMAGE_TAG
+
 # Show API logs in background
 echo -e "${YELLOW}API logs:${NC}"
 docker logs -f $API_CONTAINER &
@@ -94,6 +128,8 @@ sleep 1
 # Run E2E tests
 echo -e "${GREEN}Running E2E tests...${NC}"
 export API_BASE_URL="http://localhost:$API_PORT"
+export E2E_OUTBOX_DIR="$OUTBOX_DIR"
+export E2E_VERIFIED_ONLY_URL="http://localhost:$VERIFIED_ONLY_PORT"
 go test -tags=e2e -v ./test/e2e/...
 
 # Stop background logs
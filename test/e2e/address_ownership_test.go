@@ -0,0 +1,121 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// authorizedJSON sends a JSON request with a bearer token and decodes the JSON response
+func authorizedJSON(t *testing.T, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to call %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+
+	var result map[string]interface{}
+	if len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v. Body: %s", err, string(respBody))
+		}
+	}
+
+	return resp.StatusCode, result
+}
+
+// registerUser registers a new user and returns its access token and ID
+func registerUser(t *testing.T, prefix string) (string, string) {
+	t.Helper()
+
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E " + prefix,
+		"email":    fmt.Sprintf("e2e-%s-%d@test.com", prefix, time.Now().UnixNano()),
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	token, _ := registered["token"].(string)
+	user, _ := registered["user"].(map[string]interface{})
+	id, _ := user["id"].(string)
+	return token, id
+}
+
+func TestAddressOwnership(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	ownerToken, ownerID := registerUser(t, "owner")
+	otherToken, _ := registerUser(t, "other")
+
+	var entityID int
+	fmt.Sscan(ownerID, &entityID)
+	status, created := authorizedJSON(t, http.MethodPost, "/addresses", ownerToken, map[string]interface{}{
+		"entity_type":  "user",
+		"entity_id":    entityID,
+		"address_type": "billing",
+		"street_line1": "1 Main St",
+		"city":         "Springfield",
+		"state":        "IL",
+		"postal_code":  "62701",
+		"country":      "US",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, created)
+	}
+	addressPath := fmt.Sprintf("/addresses/%v", created["id"])
+
+	// Another user can neither see nor touch the address...
+	if status := authorizedRequest(t, http.MethodGet, addressPath, otherToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's address, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodDelete, addressPath, otherToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting another user's address, got %d", status)
+	}
+	listPath := fmt.Sprintf("/addresses?entity_type=user&entity_id=%s", ownerID)
+	if status := authorizedRequest(t, http.MethodGet, listPath, otherToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 listing another user's addresses, got %d", status)
+	}
+
+	// ...while the owner still can
+	if status := authorizedRequest(t, http.MethodGet, addressPath, ownerToken); status != http.StatusOK {
+		t.Errorf("Expected status 200 for own address, got %d", status)
+	}
+	status, page := authorizedJSON(t, http.MethodGet, listPath+"&limit=1", ownerToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 listing own addresses, got %d: %v", status, page)
+	}
+	if addresses, _ := page["data"].([]interface{}); len(addresses) != 1 {
+		t.Errorf("Expected a page with the address, got %v", page)
+	}
+	if _, ok := page["next_cursor"]; ok {
+		t.Errorf("Expected no next cursor on the last page, got %v", page)
+	}
+}
@@ -0,0 +1,68 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddressRestore(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	ownerToken, ownerID := registerUser(t, "restore-owner")
+	otherToken, _ := registerUser(t, "restore-other")
+
+	var entityID int
+	fmt.Sscan(ownerID, &entityID)
+	status, created := authorizedJSON(t, http.MethodPost, "/addresses", ownerToken, map[string]interface{}{
+		"entity_type":  "user",
+		"entity_id":    entityID,
+		"address_type": "shipping",
+		"street_line1": "1 Main St",
+		"city":         "Springfield",
+		"state":        "IL",
+		"postal_code":  "62701",
+		"country":      "US",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, created)
+	}
+	addressPath := fmt.Sprintf("/addresses/%v", created["id"])
+	listPath := fmt.Sprintf("/addresses?entity_type=user&entity_id=%s", ownerID)
+
+	if status := authorizedRequest(t, http.MethodDelete, addressPath, ownerToken); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 deleting the address, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, addressPath, ownerToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted address, got %d", status)
+	}
+	status, page := authorizedJSON(t, http.MethodGet, listPath, ownerToken, nil)
+	if addresses, _ := page["data"].([]interface{}); status != http.StatusOK || len(addresses) != 0 {
+		t.Errorf("Expected no addresses listed after deleting, got %d: %v", status, page)
+	}
+	if status := authorizedRequest(t, http.MethodGet, listPath+"&include_deleted=true", ownerToken); status != http.StatusForbidden {
+		t.Errorf("Expected status 403 listing deleted addresses as a member, got %d", status)
+	}
+
+	// Only the owner can restore it, and only once
+	if status := authorizedRequest(t, http.MethodPost, addressPath+"/restore", otherToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring another user's address, got %d", status)
+	}
+	status, restored := authorizedJSON(t, http.MethodPost, addressPath+"/restore", ownerToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 restoring the address, got %d: %v", status, restored)
+	}
+	if _, ok := restored["deleted_at"]; ok {
+		t.Errorf("Expected a restored address not to be deleted, got %v", restored)
+	}
+	if status := authorizedRequest(t, http.MethodGet, addressPath, ownerToken); status != http.StatusOK {
+		t.Errorf("Expected status 200 for a restored address, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodPost, addressPath+"/restore", ownerToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring an address that is not deleted, got %d", status)
+	}
+}
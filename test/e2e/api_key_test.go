@@ -0,0 +1,67 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// apiKeyRequest sends a request authenticated with an API key and returns the status code
+func apiKeyRequest(t *testing.T, method, path, key string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, baseURL+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "ApiKey "+key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to call %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	token, userID := registerUser(t, "apikey")
+
+	status, created := authorizedJSON(t, http.MethodPost, "/auth/api-keys", token, map[string]interface{}{
+		"name":   "ci",
+		"scopes": []string{"addresses:read"},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, created)
+	}
+	key, _ := created["key"].(string)
+	if key == "" {
+		t.Fatal("Response missing 'key' field")
+	}
+
+	addressesPath := fmt.Sprintf("/addresses?entity_type=user&entity_id=%s", userID)
+	if status := apiKeyRequest(t, http.MethodGet, addressesPath, key); status != http.StatusOK {
+		t.Errorf("Expected status 200 with API key, got %d", status)
+	}
+
+	// The key is limited to its scopes...
+	if status := apiKeyRequest(t, http.MethodDelete, "/addresses/1", key); status != http.StatusForbidden {
+		t.Errorf("Expected status 403 outside the key's scopes, got %d", status)
+	}
+
+	// ...and stops working once deleted
+	keyPath := fmt.Sprintf("/auth/api-keys/%v", created["id"])
+	if status := authorizedRequest(t, http.MethodDelete, keyPath, token); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 deleting the key, got %d", status)
+	}
+	if status := apiKeyRequest(t, http.MethodGet, addressesPath, key); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a deleted key, got %d", status)
+	}
+}
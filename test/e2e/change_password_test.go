@@ -0,0 +1,61 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChangePassword(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-change-password-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Change Password",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+	token, _ := registered["token"].(string)
+
+	status, _ = authorizedJSON(t, http.MethodPost, "/auth/password/change", token, map[string]string{
+		"current_password": "wrongpassword",
+		"new_password":     "newsecurepassword456",
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 with a wrong current password, got %d", status)
+	}
+
+	// A wrong password counts as a failed login, so the next check waits
+	status, _ = authorizedJSON(t, http.MethodPost, "/auth/password/change", token, map[string]string{
+		"current_password": "securepassword123",
+		"new_password":     "newsecurepassword456",
+	})
+	if status != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 right after a wrong current password, got %d", status)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	status, changed := authorizedJSON(t, http.MethodPost, "/auth/password/change", token, map[string]string{
+		"current_password": "securepassword123",
+		"new_password":     "newsecurepassword456",
+	})
+	if status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 changing password, got %d: %v", status, changed)
+	}
+
+	// The change ends every session, including the one that made it
+	if status := authorizedRequest(t, http.MethodGet, "/users", token); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a token issued before the change, got %d", status)
+	}
+
+	if status, _ := login(t, email, "newsecurepassword456"); status != http.StatusOK {
+		t.Errorf("Expected status 200 logging in with the new password, got %d", status)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// verifiedOnlyStatus calls /auth/sessions with token on the instance of the
+// API that only admits verified users, named by E2E_VERIFIED_ONLY_URL, and
+// returns the response status. It returns 0 if there is no such instance.
+func verifiedOnlyStatus(t *testing.T, token string) int {
+	t.Helper()
+
+	base := os.Getenv("E2E_VERIFIED_ONLY_URL")
+	if base == "" {
+		return 0
+	}
+	waitForInstance(t, base, 30*time.Second)
+
+	req, err := http.NewRequest(http.MethodGet, base+"/auth/sessions", nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to call /auth/sessions: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// verifyEmail opens an email verification link and returns the response status
+func verifyEmail(t *testing.T, token string) int {
+	t.Helper()
+
+	resp, err := http.Get(baseURL + "/auth/verify?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed to call /auth/verify: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestEmailVerification(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-verify-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Verify User",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	user, _ := registered["user"].(map[string]interface{})
+	if verified, _ := user["email_verified"].(bool); verified {
+		t.Error("Expected a new user to be unverified")
+	}
+
+	// Registering just sent a verification email, so a resend is throttled
+	token, _ := registered["token"].(string)
+	if status := authorizedRequest(t, http.MethodPost, "/auth/verify/resend", token); status != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 from resend, got %d", status)
+	}
+
+	if status := verifyEmail(t, "not-a-real-token"); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown token, got %d", status)
+	}
+}
+
+func TestEmailVerificationLink(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-verify-link-%d@test.com", time.Now().UnixNano())
+	credentials := map[string]string{"email": email, "password": "securepassword123"}
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Verify Link User",
+		"email":    email,
+		"password": credentials["password"],
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	// An instance requiring verified emails turns the new user away
+	unverified, _ := registered["token"].(string)
+	if status := verifiedOnlyStatus(t, unverified); status != 0 && status != http.StatusForbidden {
+		t.Errorf("Expected status 403 for an unverified user, got %d", status)
+	}
+
+	token := mailedToken(t, email, "/auth/verify")
+	if status := verifyEmail(t, token); status != http.StatusOK {
+		t.Fatalf("Expected status 200 verifying the email, got %d", status)
+	}
+
+	// Tokens issued from now on say the email is verified...
+	status, loggedIn := postJSON(t, "/auth/login", credentials)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 logging in, got %d: %v", status, loggedIn)
+	}
+	verified, _ := loggedIn["token"].(string)
+	if claims := tokenClaims(t, verified); claims["email_verified"] != true {
+		t.Errorf("Expected the token to mark the email verified, got %v", claims["email_verified"])
+	}
+	// ...which lets the user through
+	if status := verifiedOnlyStatus(t, verified); status != 0 && status != http.StatusOK {
+		t.Errorf("Expected status 200 for a verified user, got %d", status)
+	}
+
+	// The link only works once
+	if status := verifyEmail(t, token); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 reusing the verification link, got %d", status)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemberCannotImpersonate(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	token, _ := registerUser(t, "impersonator")
+	_, targetID := registerUser(t, "impersonated")
+
+	if status := authorizedRequest(t, http.MethodPost, "/admin/users/"+targetID+"/impersonate", token); status != http.StatusForbidden {
+		t.Errorf("Expected status 403 impersonating without the permission, got %d", status)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJWKSEndpoint(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	resp, err := http.Get(baseURL + "/.well-known/jwks.json")
+	if err != nil {
+		t.Fatalf("Failed to call JWKS endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+	// Shared secrets are never published
+	for _, key := range jwks.Keys {
+		if key["kty"] == "oct" {
+			t.Errorf("Expected no symmetric keys in JWKS, got %v", key)
+		}
+	}
+}
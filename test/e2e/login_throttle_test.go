@@ -0,0 +1,64 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// login attempts a login and returns the response status and Retry-After header
+func login(t *testing.T, email, password string) (int, string) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"email": email, "password": password})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	resp, err := http.Post(baseURL+"/auth/login", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to call /auth/login: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, resp.Header.Get("Retry-After")
+}
+
+func TestLoginThrottle(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-throttle-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Throttle",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	if status, _ := login(t, email, "wrongpassword"); status != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 for a wrong password, got %d", status)
+	}
+
+	// Right after a failure even the right password has to wait
+	status, retryAfter := login(t, email, "securepassword123")
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 right after a failure, got %d", status)
+	}
+	seconds, err := strconv.Atoi(retryAfter)
+	if err != nil || seconds < 1 {
+		t.Fatalf("Expected a Retry-After in seconds, got %q", retryAfter)
+	}
+
+	time.Sleep(time.Duration(seconds) * time.Second)
+	if status, _ := login(t, email, "securepassword123"); status != http.StatusOK {
+		t.Errorf("Expected status 200 after waiting, got %d", status)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// authorizedRequest sends a request with a bearer token and returns the status code
+func authorizedRequest(t *testing.T, method, path, token string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, baseURL+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to call %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-logout-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Logout User",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	token, _ := registered["token"].(string)
+	user, _ := registered["user"].(map[string]interface{})
+	addressesPath := fmt.Sprintf("/addresses?entity_type=user&entity_id=%v", user["id"])
+
+	if status := authorizedRequest(t, http.MethodGet, addressesPath, token); status != http.StatusOK {
+		t.Fatalf("Expected status 200 before logout, got %d", status)
+	}
+
+	if status := authorizedRequest(t, http.MethodPost, "/auth/logout", token); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 from logout, got %d", status)
+	}
+
+	if status := authorizedRequest(t, http.MethodGet, addressesPath, token); status != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 after logout, got %d", status)
+	}
+}
@@ -0,0 +1,151 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// consumeMagicLink opens a magic link and returns the response status and body
+func consumeMagicLink(t *testing.T, token string) (int, map[string]interface{}) {
+	t.Helper()
+
+	resp, err := http.Get(baseURL + "/auth/magic-link/consume?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed to call /auth/magic-link/consume: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	return resp.StatusCode, result
+}
+
+// registerForMagicLink registers a user and returns their email
+func registerForMagicLink(t *testing.T, prefix string) string {
+	t.Helper()
+
+	email := fmt.Sprintf("%s-%d@test.com", prefix, time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Magic Link",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+	return email
+}
+
+// requestMagicLink asks for a magic link to email
+func requestMagicLink(t *testing.T, email string) {
+	t.Helper()
+
+	if status, result := postJSON(t, "/auth/magic-link", map[string]string{"email": email}); status != http.StatusAccepted {
+		t.Fatalf("Expected status 202 requesting a magic link, got %d: %v", status, result)
+	}
+}
+
+func TestMagicLink(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := registerForMagicLink(t, "e2e-magic-link")
+
+	// The response does not tell registered and unknown emails apart
+	status, known := postJSON(t, "/auth/magic-link", map[string]string{"email": email})
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected status 202 for a registered email, got %d: %v", status, known)
+	}
+	status, unknown := postJSON(t, "/auth/magic-link", map[string]string{"email": "unknown-" + email})
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected status 202 for an unknown email, got %d: %v", status, unknown)
+	}
+	if known["message"] != unknown["message"] {
+		t.Errorf("Expected the same message for registered and unknown emails, got %v and %v", known, unknown)
+	}
+
+	// A second request right away is throttled without saying so
+	status, _ = postJSON(t, "/auth/magic-link", map[string]string{"email": email})
+	if status != http.StatusAccepted {
+		t.Errorf("Expected status 202 for a throttled request, got %d", status)
+	}
+
+	status, invalid := postJSON(t, "/auth/magic-link", map[string]string{"email": "not-an-email"})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid email, got %d: %v", status, invalid)
+	}
+
+	for _, path := range []string{"/auth/magic-link/consume", "/auth/magic-link/consume?token=not-a-real-token"} {
+		resp, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatalf("Failed to call %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 from %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := registerForMagicLink(t, "e2e-magic-login")
+	requestMagicLink(t, email)
+	token := mailedToken(t, email, "/auth/magic-link/consume")
+
+	status, result := consumeMagicLink(t, token)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 opening the link, got %d: %v", status, result)
+	}
+	accessToken, ok := result["token"].(string)
+	if !ok || accessToken == "" {
+		t.Fatalf("Response missing 'token' field: %v", result)
+	}
+	if claims := tokenClaims(t, accessToken); claims["email"] != email {
+		t.Errorf("Expected a token for %s, got %v", email, claims["email"])
+	}
+	if sessions := listSessions(t, accessToken); len(sessions) == 0 {
+		t.Error("Expected the token to work")
+	}
+
+	// The link only works once
+	if status, result := consumeMagicLink(t, token); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 reusing the link, got %d: %v", status, result)
+	}
+}
+
+func TestMagicLinkSupersedes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out the interval between magic links")
+	}
+	waitForAPI(t, 30*time.Second)
+
+	email := registerForMagicLink(t, "e2e-magic-supersede")
+	requestMagicLink(t, email)
+	older := mailedToken(t, email, "/auth/magic-link/consume")
+
+	// Links are sent at most once a minute
+	time.Sleep(61 * time.Second)
+	requestMagicLink(t, email)
+	newer := mailedToken(t, email, "/auth/magic-link/consume")
+	if newer == older {
+		t.Fatal("Expected a second magic link")
+	}
+
+	// Using the newer link retires the older one
+	if status, result := consumeMagicLink(t, newer); status != http.StatusOK {
+		t.Fatalf("Expected status 200 opening the newer link, got %d: %v", status, result)
+	}
+	if status, result := consumeMagicLink(t, older); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 opening the older link, got %d: %v", status, result)
+	}
+}
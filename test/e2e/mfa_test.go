@@ -0,0 +1,132 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-test-api/internal/totp"
+)
+
+// enrollTOTP enrolls the user of token in two-factor authentication and
+// returns the code that confirmed it and the recovery codes
+func enrollTOTP(t *testing.T, token string) (string, []interface{}) {
+	t.Helper()
+
+	status, enrollment := authorizedJSON(t, http.MethodPost, "/auth/mfa/totp", token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 starting enrollment, got %d: %v", status, enrollment)
+	}
+	secret, _ := enrollment["secret"].(string)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("Failed to compute code: %v", err)
+	}
+	status, confirmed := authorizedJSON(t, http.MethodPost, "/auth/mfa/totp/confirm", token, map[string]string{"code": code})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 confirming enrollment, got %d: %v", status, confirmed)
+	}
+	recoveryCodes, _ := confirmed["recovery_codes"].([]interface{})
+	if len(recoveryCodes) == 0 {
+		t.Fatal("Response missing recovery codes")
+	}
+	return code, recoveryCodes
+}
+
+func TestTOTPLogin(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-mfa-%d@test.com", time.Now().UnixNano())
+	credentials := map[string]string{"email": email, "password": "securepassword123"}
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E MFA",
+		"email":    email,
+		"password": credentials["password"],
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+	token, _ := registered["token"].(string)
+
+	code, recoveryCodes := enrollTOTP(t, token)
+
+	// The password alone now only earns a challenge...
+	status, challenge := postJSON(t, "/auth/login", credentials)
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected status 202 from login, got %d: %v", status, challenge)
+	}
+	if _, ok := challenge["token"]; ok {
+		t.Fatal("Expected no access token before the second factor")
+	}
+	mfaToken, _ := challenge["mfa_token"].(string)
+
+	// ...which is not an access token
+	if status := authorizedRequest(t, http.MethodGet, "/users", mfaToken); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 using the challenge as an access token, got %d", status)
+	}
+
+	// The code used for confirmation cannot be replayed
+	status, _ = postJSON(t, "/auth/login/mfa", map[string]string{"mfa_token": mfaToken, "code": code})
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 replaying a code, got %d", status)
+	}
+	// Wait out the delay a failed attempt imposes
+	time.Sleep(1100 * time.Millisecond)
+
+	// A recovery code completes the login, once
+	recoveryCode, _ := recoveryCodes[0].(string)
+	status, tokens := postJSON(t, "/auth/login/mfa", map[string]string{"mfa_token": mfaToken, "code": recoveryCode})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 completing login, got %d: %v", status, tokens)
+	}
+	if _, ok := tokens["token"].(string); !ok {
+		t.Error("Response missing 'token' field")
+	}
+
+	status, challenge = postJSON(t, "/auth/login", credentials)
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected status 202 from login, got %d: %v", status, challenge)
+	}
+	mfaToken, _ = challenge["mfa_token"].(string)
+	status, _ = postJSON(t, "/auth/login/mfa", map[string]string{"mfa_token": mfaToken, "code": recoveryCode})
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 reusing a recovery code, got %d", status)
+	}
+}
+
+func TestTOTPManagementThrottle(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	token, _ := registerUser(t, "e2e-mfa-throttle")
+	_, recoveryCodes := enrollTOTP(t, token)
+	recoveryCode, _ := recoveryCodes[0].(string)
+
+	for i, endpoint := range []struct{ method, path string }{
+		{http.MethodPost, "/auth/mfa/recovery-codes"},
+		{http.MethodDelete, "/auth/mfa/totp"},
+	} {
+		// A wrong code counts as a failed login...
+		status, body := authorizedJSON(t, endpoint.method, endpoint.path, token, map[string]string{"code": "000000"})
+		if status != http.StatusBadRequest {
+			t.Fatalf("Expected status 400 for a wrong code at %s, got %d: %v", endpoint.path, status, body)
+		}
+
+		// ...so even a right one has to wait
+		status, body = authorizedJSON(t, endpoint.method, endpoint.path, token, map[string]string{"code": recoveryCode})
+		if status != http.StatusTooManyRequests {
+			t.Fatalf("Expected status 429 right after a wrong code at %s, got %d: %v", endpoint.path, status, body)
+		}
+		// The delay doubles with each failure
+		time.Sleep(time.Duration(i+1) * 1100 * time.Millisecond)
+	}
+
+	// Once the delay has passed the right code is accepted
+	status, body := authorizedJSON(t, http.MethodDelete, "/auth/mfa/totp", token, map[string]string{"code": recoveryCode})
+	if status != http.StatusNoContent {
+		t.Errorf("Expected status 204 disabling two-factor authentication, got %d: %v", status, body)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOrganizationAddresses(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	ownerToken, _ := registerUser(t, "orgowner")
+	otherToken, _ := registerUser(t, "orgother")
+
+	status, org := authorizedJSON(t, http.MethodPost, "/organizations", ownerToken, map[string]interface{}{
+		"name": "Acme",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating the organization, got %d: %v", status, org)
+	}
+	orgID, _ := org["id"].(string)
+
+	var entityID int
+	fmt.Sscan(orgID, &entityID)
+	status, created := authorizedJSON(t, http.MethodPost, "/addresses", ownerToken, map[string]interface{}{
+		"entity_type":  "organization",
+		"entity_id":    entityID,
+		"address_type": "billing",
+		"street_line1": "1 Main St",
+		"city":         "Springfield",
+		"state":        "IL",
+		"postal_code":  "62701",
+		"country":      "US",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201 adding an organization address, got %d: %v", status, created)
+	}
+	addressPath := fmt.Sprintf("/addresses/%v", created["id"])
+
+	// Someone outside the organization sees neither it nor its addresses
+	if status := authorizedRequest(t, http.MethodGet, "/organizations/"+orgID, otherToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 getting another user's organization, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, addressPath, otherToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 getting another organization's address, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, addressPath, ownerToken); status != http.StatusOK {
+		t.Errorf("Expected status 200 getting the organization's address, got %d", status)
+	}
+
+	// Deleting the organization deletes its addresses
+	if status := authorizedRequest(t, http.MethodDelete, "/organizations/"+orgID, ownerToken); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 deleting the organization, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, addressPath, ownerToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 getting the address of a deleted organization, got %d", status)
+	}
+}
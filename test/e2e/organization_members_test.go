@@ -0,0 +1,70 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOrganizationMembers(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	ownerToken, ownerID := registerUser(t, "orgmembers")
+	otherToken, _ := registerUser(t, "orginvitee")
+
+	status, org := authorizedJSON(t, http.MethodPost, "/organizations", ownerToken, map[string]interface{}{
+		"name": "Acme",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating the organization, got %d: %v", status, org)
+	}
+	orgPath := fmt.Sprintf("/organizations/%v", org["id"])
+
+	// The creator is its only member, as owner
+	status, members := authorizedJSON(t, http.MethodGet, orgPath+"/members", ownerToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 listing members, got %d: %v", status, members)
+	}
+	data, _ := members["data"].([]interface{})
+	if len(data) != 1 {
+		t.Fatalf("Expected 1 member, got %v", members)
+	}
+	if member, _ := data[0].(map[string]interface{}); member["user_id"] != ownerID || member["role"] != "owner" {
+		t.Errorf("Expected user %s as owner, got %v", ownerID, member)
+	}
+
+	status, invitation := authorizedJSON(t, http.MethodPost, orgPath+"/invitations", ownerToken, map[string]interface{}{
+		"email": fmt.Sprintf("e2e-invited-%d@test.com", time.Now().UnixNano()),
+		"role":  "member",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201 inviting, got %d: %v", status, invitation)
+	}
+	if _, ok := invitation["token"]; ok {
+		t.Errorf("Expected the invitation token to only be emailed, got %v", invitation)
+	}
+
+	// Outsiders cannot invite, and forged invitations are refused
+	status, _ = authorizedJSON(t, http.MethodPost, orgPath+"/invitations", otherToken, map[string]interface{}{
+		"email": "someone@test.com",
+		"role":  "member",
+	})
+	if status != http.StatusNotFound {
+		t.Errorf("Expected status 404 inviting to another user's organization, got %d", status)
+	}
+	status, _ = authorizedJSON(t, http.MethodPost, "/invitations/accept", otherToken, map[string]interface{}{
+		"token": "not-a-real-token",
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 accepting a forged invitation, got %d", status)
+	}
+
+	// The owner can only leave by handing the organization over first
+	if status := authorizedRequest(t, http.MethodDelete, orgPath+"/members/"+ownerID, ownerToken); status != http.StatusConflict {
+		t.Errorf("Expected status 409 removing the owner, got %d", status)
+	}
+}
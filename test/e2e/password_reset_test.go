@@ -0,0 +1,103 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"go-test-api/internal/mail"
+)
+
+// linkPattern matches the links in emails
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// mailedToken waits for an email to the address with a link to path, and
+// returns the token the most recent one carries. The API must write its mail
+// to the outbox directory named by E2E_OUTBOX_DIR; the test is skipped
+// otherwise.
+func mailedToken(t *testing.T, to, path string) string {
+	t.Helper()
+
+	dir := os.Getenv("E2E_OUTBOX_DIR")
+	if dir == "" {
+		t.Skip("E2E_OUTBOX_DIR is not set")
+	}
+	outbox, err := mail.NewFileMailer(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+
+	// Some emails are sent after the response
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		messages, err := outbox.Messages()
+		if err != nil {
+			t.Fatalf("Failed to read outbox: %v", err)
+		}
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].To != to {
+				continue
+			}
+			for _, link := range linkPattern.FindAllString(messages[i].Body, -1) {
+				u, err := url.Parse(link)
+				if err == nil && u.Path == path && u.Query().Get("token") != "" {
+					return u.Query().Get("token")
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No email to %s with a link to %s", to, path)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-reset-%d@test.com", time.Now().UnixNano())
+	status, result := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Reset User",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, result)
+	}
+
+	status, result = postJSON(t, "/auth/password/forgot", map[string]string{"email": email})
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %v", status, result)
+	}
+	token := mailedToken(t, email, "/reset-password")
+
+	reset := map[string]string{"token": token, "password": "newsecurepassword456"}
+	if status, result := postJSON(t, "/auth/password/reset", reset); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 resetting the password, got %d: %v", status, result)
+	}
+
+	if status, _ := login(t, email, "newsecurepassword456"); status != http.StatusOK {
+		t.Errorf("Expected status 200 logging in with the new password, got %d", status)
+	}
+
+	// The token only works once
+	reset["password"] = "anotherpassword789"
+	if status, result := postJSON(t, "/auth/password/reset", reset); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 reusing the reset token, got %d: %v", status, result)
+	}
+	if status, _ := login(t, email, "newsecurepassword456"); status != http.StatusOK {
+		t.Errorf("Expected the password set with the token to stay, got %d", status)
+	}
+
+	// Last, since a failed login delays the next one
+	if status, _ := login(t, email, "securepassword123"); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 logging in with the old password, got %d", status)
+	}
+}
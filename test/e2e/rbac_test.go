@@ -0,0 +1,36 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemberCannotListUsers(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-rbac-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E RBAC User",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	user, _ := registered["user"].(map[string]interface{})
+	roles, _ := user["roles"].([]interface{})
+	if len(roles) != 1 || roles[0] != "member" {
+		t.Errorf("Expected roles [member], got %v", user["roles"])
+	}
+
+	token, _ := registered["token"].(string)
+	if status := authorizedRequest(t, http.MethodGet, "/users", token); status != http.StatusForbidden {
+		t.Errorf("Expected status 403 from GET /users, got %d", status)
+	}
+}
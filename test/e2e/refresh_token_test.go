@@ -0,0 +1,83 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// postJSON sends a JSON POST request and decodes the JSON response
+func postJSON(t *testing.T, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	resp, err := http.Post(baseURL+path, "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatalf("Failed to call %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v. Body: %s", err, string(respBody))
+	}
+
+	return resp.StatusCode, result
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-refresh-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Refresh User",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+
+	original, ok := registered["refresh_token"].(string)
+	if !ok || original == "" {
+		t.Fatal("Response missing 'refresh_token' field")
+	}
+
+	// First use rotates the token
+	status, refreshed := postJSON(t, "/auth/refresh", map[string]string{"refresh_token": original})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", status, refreshed)
+	}
+	rotated, _ := refreshed["refresh_token"].(string)
+	if rotated == "" || rotated == original {
+		t.Fatalf("Expected a new refresh token, got %q", rotated)
+	}
+
+	// Replaying the original token is rejected...
+	status, _ = postJSON(t, "/auth/refresh", map[string]string{"refresh_token": original})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 on reuse, got %d", status)
+	}
+
+	// ...and revokes the rotated token from the same family
+	status, _ = postJSON(t, "/auth/refresh", map[string]string{"refresh_token": rotated})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 after family revocation, got %d", status)
+	}
+}
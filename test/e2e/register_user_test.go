@@ -5,11 +5,13 @@ package e2e
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,11 +27,17 @@ func init() {
 
 // waitForAPI waits for the API to be ready
 func waitForAPI(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	waitForInstance(t, baseURL, timeout)
+}
+
+// waitForInstance waits for the instance of the API at base to be ready
+func waitForInstance(t *testing.T, base string, timeout time.Duration) {
 	t.Helper()
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
-		resp, err := http.Get(baseURL + "/health")
+		resp, err := http.Get(base + "/health")
 		if err == nil && resp.StatusCode == http.StatusOK {
 			resp.Body.Close()
 			t.Log("API is ready")
@@ -148,3 +156,91 @@ func TestRegisterUser(t *testing.T) {
 
 	t.Logf("Successfully registered user: %s", email)
 }
+
+func TestRegisterUserDuplicate(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-duplicate-%d@test.com", time.Now().UnixNano())
+	status, _ := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Original User",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+
+	// Registering the same email again must not take over the account
+	status, result := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Attacker",
+		"email":    email,
+		"password": "attackerpassword123",
+	})
+	if status != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %v", status, result)
+	}
+
+	// The original password still works
+	status, result = postJSON(t, "/auth/login", map[string]string{
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected original password to still work, got %d: %v", status, result)
+	}
+	user, _ := result["user"].(map[string]interface{})
+	if user["name"] != "E2E Original User" {
+		t.Errorf("Expected name 'E2E Original User', got '%v'", user["name"])
+	}
+}
+
+func TestRegisterUserIgnoresTenant(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	// Callers cannot pick the tenant they join; accounts always land in the
+	// default tenant
+	email := fmt.Sprintf("e2e-tenant-%d@test.com", time.Now().UnixNano())
+	status, result := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Tenant User",
+		"email":    email,
+		"password": "securepassword123",
+		"tenant":   "no-such-tenant",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, result)
+	}
+
+	// The account is readable with the token of its tenant, which a fresh
+	// login finds in the database
+	token, _ := result["token"].(string)
+	if status, me := authorizedJSON(t, http.MethodGet, "/users/me", token, nil); status != http.StatusOK || me["email"] != email {
+		t.Fatalf("Expected to fetch the created account, got %d: %v", status, me)
+	}
+	status, result = postJSON(t, "/auth/login", map[string]string{"email": email, "password": "securepassword123"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 logging in, got %d: %v", status, result)
+	}
+	token, _ = result["token"].(string)
+	if claims := tokenClaims(t, token); claims["tid"] != "1" {
+		t.Errorf("Expected the account in the default tenant, got tenant %v", claims["tid"])
+	}
+}
+
+// tokenClaims decodes the claims of a JWT without verifying it
+func tokenClaims(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a JWT, got %q", token)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("Failed to decode token payload: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("Failed to parse token claims: %v", err)
+	}
+	return claims
+}
@@ -0,0 +1,106 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// listSessions lists the sessions of the token's user
+func listSessions(t *testing.T, token string) []map[string]interface{} {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/auth/sessions", nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to call /auth/sessions: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 listing sessions, got %d", resp.StatusCode)
+	}
+	var sessions []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
+		t.Fatalf("Failed to parse sessions: %v", err)
+	}
+	return sessions
+}
+
+func TestSessions(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	email := fmt.Sprintf("e2e-sessions-%d@test.com", time.Now().UnixNano())
+	status, registered := postJSON(t, "/auth/register", map[string]string{
+		"name":     "E2E Sessions",
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, registered)
+	}
+	firstToken, _ := registered["token"].(string)
+
+	status, loggedIn := postJSON(t, "/auth/login", map[string]string{
+		"email":    email,
+		"password": "securepassword123",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 from login, got %d: %v", status, loggedIn)
+	}
+	secondToken, _ := loggedIn["token"].(string)
+
+	sessions := listSessions(t, firstToken)
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d: %v", len(sessions), sessions)
+	}
+	var secondID string
+	methods := map[interface{}]bool{}
+	for _, session := range sessions {
+		methods[session["method"]] = true
+		if session["current"] != true {
+			secondID, _ = session["id"].(string)
+		}
+	}
+	if !methods["registration"] || !methods["password"] {
+		t.Errorf("Expected a registration and a password session, got %v", sessions)
+	}
+	if secondID == "" {
+		t.Fatalf("Expected exactly one current session, got %v", sessions)
+	}
+
+	// Revoking the other session logs out its tokens only
+	if status := authorizedRequest(t, http.MethodDelete, "/auth/sessions/"+secondID, firstToken); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 revoking a session, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, "/auth/sessions", secondToken); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a token of a revoked session, got %d", status)
+	}
+	if sessions := listSessions(t, firstToken); len(sessions) != 1 {
+		t.Errorf("Expected 1 session after revoking the other, got %d: %v", len(sessions), sessions)
+	}
+
+	if status := authorizedRequest(t, http.MethodDelete, "/auth/sessions/"+secondID, firstToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 revoking a revoked session, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodDelete, "/auth/sessions/not-a-session", firstToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an invalid session ID, got %d", status)
+	}
+
+	// Another user cannot revoke sessions that are not theirs
+	otherToken, _ := registerUser(t, "sessions-other")
+	otherID, _ := listSessions(t, otherToken)[0]["id"].(string)
+	if status := authorizedRequest(t, http.MethodDelete, "/auth/sessions/"+otherID, firstToken); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 revoking another user's session, got %d", status)
+	}
+}
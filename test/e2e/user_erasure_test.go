@@ -0,0 +1,68 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUserExportAndErasure(t *testing.T) {
+	waitForAPI(t, 30*time.Second)
+
+	token, id := registerUser(t, "erasure")
+
+	var entityID int
+	fmt.Sscan(id, &entityID)
+	status, created := authorizedJSON(t, http.MethodPost, "/addresses", token, map[string]interface{}{
+		"entity_type":  "user",
+		"entity_id":    entityID,
+		"address_type": "billing",
+		"street_line1": "1 Main St",
+		"city":         "Springfield",
+		"state":        "IL",
+		"postal_code":  "62701",
+		"country":      "US",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", status, created)
+	}
+
+	status, export := authorizedJSON(t, http.MethodGet, "/users/me/export", token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 exporting, got %d: %v", status, export)
+	}
+	profile, _ := export["profile"].(map[string]interface{})
+	if profile["id"] != id {
+		t.Errorf("Expected the export of user %s, got %v", id, export["profile"])
+	}
+	if addresses, _ := export["addresses"].([]interface{}); len(addresses) != 1 {
+		t.Errorf("Expected 1 exported address, got %v", export["addresses"])
+	}
+	if sessions, _ := export["sessions"].([]interface{}); len(sessions) != 1 {
+		t.Errorf("Expected 1 exported session, got %v", export["sessions"])
+	}
+	email, _ := profile["email"].(string)
+
+	if status := authorizedRequest(t, http.MethodPost, "/admin/users/"+id+"/erase", token); status != http.StatusForbidden {
+		t.Errorf("Expected status 403 erasing as a member, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodDelete, "/users/me", token); status != http.StatusNoContent {
+		t.Fatalf("Expected status 204 erasing the account, got %d", status)
+	}
+
+	// Nothing is left to log in to, and the token issued before the erasure
+	// no longer authenticates
+	if status, _ := login(t, email, "securepassword123"); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 logging in after erasure, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, "/users/me/export", token); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 exporting after erasure, got %d", status)
+	}
+	if status := authorizedRequest(t, http.MethodGet, "/users/me", token); status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 reading the account after erasure, got %d", status)
+	}
+}